@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"apiconsume/utils"
+)
+
+const (
+	defaultAdaptivePercentile = 0.99
+	defaultAdaptiveFactor     = 2
+	defaultAdaptiveMin        = time.Second
+	defaultAdaptiveMax        = 10 * time.Minute
+	defaultAdaptiveWindow     = 7 * 24 * time.Hour
+	defaultAdaptiveMinSamples = 20
+)
+
+var adaptiveTimeout = flag.Bool("adaptive-timeout", false, "calcula a espera pelos headers de cada tentativa pelo histórico de latência do job (p99 × 2, entre 1s e 10m); acima de -attempt-timeout, a tentativa ganha esse tempo a mais para o body")
+
+// AdaptiveTimeoutConfig tira do histograma de latência do job (ver stats),
+// que mede o tempo até os headers, o prazo para os headers de cada
+// tentativa: o Percentile das respostas da Window vezes Factor, entre Min e
+// Max. A tentativa inteira, com a leitura do body, continua sob o
+// attempt_timeout, que, quando o prazo dos headers passa dele, vira o prazo
+// mais o próprio attempt_timeout para o body. Com menos de MinSamples
+// respostas, só ele vale.
+type AdaptiveTimeoutConfig struct {
+	Percentile float64   `json:"percentile,omitempty"`
+	Factor     float64   `json:"factor,omitempty"`
+	Min        *Duration `json:"min,omitempty"`
+	Max        *Duration `json:"max,omitempty"`
+	Window     *Duration `json:"window,omitempty"`
+	MinSamples int       `json:"min_samples,omitempty"`
+}
+
+func (c *AdaptiveTimeoutConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Percentile < 0 || c.Percentile > 1 {
+		return fmt.Errorf("adaptive_timeout: percentile deve estar entre 0 e 1 (ex: 0.99)")
+	}
+	if c.Factor < 0 || c.Factor > 0 && c.Factor < 1 {
+		return fmt.Errorf("adaptive_timeout: factor deve ser pelo menos 1")
+	}
+	if c.Min != nil && c.Min.Duration <= 0 || c.Max != nil && c.Max.Duration <= 0 {
+		return fmt.Errorf("adaptive_timeout: min e max devem ser positivos")
+	}
+	if c.min() > c.max() {
+		return fmt.Errorf("adaptive_timeout: min (%v) maior que max (%v)", c.min(), c.max())
+	}
+	if c.Window != nil && c.Window.Duration < time.Hour {
+		return fmt.Errorf("adaptive_timeout: window deve ser de pelo menos 1h")
+	}
+	if c.MinSamples < 0 {
+		return fmt.Errorf("adaptive_timeout: min_samples não pode ser negativo")
+	}
+	return nil
+}
+
+func (c *AdaptiveTimeoutConfig) percentile() float64 {
+	if c.Percentile > 0 {
+		return c.Percentile
+	}
+	return defaultAdaptivePercentile
+}
+
+func (c *AdaptiveTimeoutConfig) factor() float64 {
+	if c.Factor > 0 {
+		return c.Factor
+	}
+	return defaultAdaptiveFactor
+}
+
+func (c *AdaptiveTimeoutConfig) min() time.Duration {
+	if c.Min != nil {
+		return c.Min.Duration
+	}
+	return defaultAdaptiveMin
+}
+
+func (c *AdaptiveTimeoutConfig) max() time.Duration {
+	if c.Max != nil {
+		return c.Max.Duration
+	}
+	return defaultAdaptiveMax
+}
+
+func (c *AdaptiveTimeoutConfig) window() time.Duration {
+	if c.Window != nil {
+		return c.Window.Duration
+	}
+	return defaultAdaptiveWindow
+}
+
+func (c *AdaptiveTimeoutConfig) minSamples() int64 {
+	if c.MinSamples > 0 {
+		return int64(c.MinSamples)
+	}
+	return defaultAdaptiveMinSamples
+}
+
+// adaptiveTimeoutFlag é o adaptive_timeout de -adaptive-timeout: os padrões
+// em todos os jobs que não configuram o próprio.
+func adaptiveTimeoutFlag() *AdaptiveTimeoutConfig {
+	if !*adaptiveTimeout {
+		return nil
+	}
+	return &AdaptiveTimeoutConfig{}
+}
+
+// applyAdaptiveTimeout define o HeaderTimeout de rl pelo histórico do job,
+// estendendo o AttemptTimeout quando ele terminaria antes; sem histórico
+// suficiente, fica só o AttemptTimeout.
+func (job JobConfig) applyAdaptiveTimeout(rl *utils.RateLimitClient, c *AdaptiveTimeoutConfig, now time.Time) {
+	if c == nil || stateStore == nil {
+		return
+	}
+	var st latencyState
+	if _, err := stateStore.Load("latency-"+job.Name, &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+		return
+	}
+	from := now.Add(-c.window())
+	var h utils.LatencyHistogram
+	for _, hr := range st.Hours {
+		if hr.Hour.Add(time.Hour).After(from) {
+			h.Merge(&hr.Histogram)
+		}
+	}
+	if h.Total < c.minSamples() {
+		log.Printf("[%s] Timeout adaptativo: %d respostas em %v, abaixo de %d; só o attempt_timeout de %v", job.Name, h.Total, c.window(), c.minSamples(), rl.AttemptTimeout)
+		return
+	}
+
+	q := h.Quantile(c.percentile())
+	timeout := time.Duration(float64(q) * c.factor())
+	timeout = max(c.min(), min(timeout, c.max())).Round(time.Millisecond)
+	log.Printf("[%s] Timeout adaptativo: headers em até %v (p%g %v × %g, %d respostas em %v)", job.Name, timeout, c.percentile()*100, roundLatency(q), c.factor(), h.Total, c.window())
+	rl.HeaderTimeout = timeout
+	if rl.AttemptTimeout > 0 && timeout >= rl.AttemptTimeout {
+		// o attempt_timeout cortaria antes dos headers: ele passa a ser o
+		// tempo que o body ainda tem depois deles
+		rl.AttemptTimeout += timeout
+		log.Printf("[%s] Timeout adaptativo: tentativa estendida para %v", job.Name, rl.AttemptTimeout)
+	}
+}
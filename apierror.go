@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+var htmlPages = flag.String("html-pages", utils.HTMLPagesRetry, "página HTML (login, WAF) recebida com 2xx no lugar do JSON: retry, fail ou allow")
+
+func validateHTMLPages(mode string) error {
+	switch mode {
+	case "", utils.HTMLPagesRetry, utils.HTMLPagesFail, utils.HTMLPagesAllow:
+		return nil
+	}
+	return fmt.Errorf("html_pages deve ser retry, fail ou allow")
+}
+
+// ErrorEnvelope aponta onde o provedor coloca o código e a mensagem nos
+// bodies de erro. Sem ele, os formatos mais comuns são tentados em ordem.
+type ErrorEnvelope struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+var commonEnvelopes = []ErrorEnvelope{
+	{Code: "$.error.code", Message: "$.error.message"},
+	{Code: "$.error", Message: "$.error_description"},
+	{Code: "$.code", Message: "$.message"},
+	{Code: "$.errors[0].code", Message: "$.errors[0].message"},
+	{Code: "$.type", Message: "$.detail"},
+}
+
+func (e *ErrorEnvelope) validate() error {
+	if e.Code == "" && e.Message == "" {
+		return fmt.Errorf("informe code e/ou message")
+	}
+	for _, p := range []string{e.Code, e.Message} {
+		if p == "" {
+			continue
+		}
+		if _, err := utils.ParseJSONPath(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apiError é uma resposta de erro cujo body trouxe código e/ou mensagem.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	switch {
+	case e.Code != "" && e.Message != "":
+		return fmt.Sprintf("Status %d - %s: %s", e.Status, e.Code, e.Message)
+	case e.Message != "":
+		return fmt.Sprintf("Status %d - %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("Status %d - %s", e.Status, e.Code)
+}
+
+// requestError monta o erro de uma requisição que falhou, lendo o envelope
+// de erro do body quando há um.
+func requestError(envelope *ErrorEnvelope, status int, body []byte, err error) error {
+	if err != nil {
+		return fmt.Errorf("Status %d - %w", status, err)
+	}
+	if apiErr := parseErrorEnvelope(envelope, status, body); apiErr != nil {
+		log.Printf("Erro da API: %v", apiErr)
+		return apiErr
+	}
+	return fmt.Errorf("Status %d", status)
+}
+
+func parseErrorEnvelope(envelope *ErrorEnvelope, status int, body []byte) *apiError {
+	var doc any
+	if len(body) == 0 || json.Unmarshal(body, &doc) != nil {
+		return nil
+	}
+	envelopes := commonEnvelopes
+	if envelope != nil {
+		envelopes = []ErrorEnvelope{*envelope}
+	}
+	for _, e := range envelopes {
+		code, message := envelopeField(doc, e.Code), envelopeField(doc, e.Message)
+		if code != "" || message != "" {
+			return &apiError{Status: status, Code: code, Message: message}
+		}
+	}
+	return nil
+}
+
+// envelopeField só aceita valores escalares: em {"error": {...}}, $.error
+// não é a mensagem.
+func envelopeField(doc any, path string) string {
+	if path == "" {
+		return ""
+	}
+	p, err := utils.ParseJSONPath(path)
+	if err != nil {
+		return ""
+	}
+	v, ok := p.First(doc)
+	if !ok {
+		return ""
+	}
+	switch v.(type) {
+	case string, float64, bool:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+	return ""
+}
+
+// withEnvelope copia código e mensagem do erro da API para o registro de
+// errors.json; uma página HTML no lugar do JSON vira o código html_<tipo>,
+// com o título da página como mensagem, e um desafio de WAF, waf_challenge.
+func (r ErrorResponse) withEnvelope(err error) ErrorResponse {
+	var apiErr *apiError
+	var page *utils.HTMLPageError
+	var waf *utils.WAFChallengeError
+	var empty *emptyResultError
+	switch {
+	case errors.As(err, &apiErr):
+		r.Status, r.Code, r.Message = apiErr.Status, apiErr.Code, apiErr.Message
+	case errors.As(err, &page):
+		r.Status, r.Code, r.Message = page.Status, "html_"+page.Kind, page.Title
+	case errors.As(err, &waf):
+		r.Status, r.Code, r.Message = waf.Status, "waf_challenge", waf.Vendor
+	case errors.As(err, &empty):
+		r.Code = "empty_result"
+	}
+	return r
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// APIVersionConfig fixa a versão da API do provedor, enviada no header ou
+// no parâmetro de query indicado, para que trocar de versão seja só mudar a
+// configuração. ResponseHeader é onde o provedor informa a versão que de
+// fato atendeu (padrão: o próprio Header); uma diferença vira aviso.
+type APIVersionConfig struct {
+	Version        string `json:"version"`
+	Header         string `json:"header,omitempty"`
+	Param          string `json:"param,omitempty"`
+	ResponseHeader string `json:"response_header,omitempty"`
+}
+
+func (c *APIVersionConfig) validate() error {
+	switch {
+	case c.Version == "":
+		return fmt.Errorf("version vazio")
+	case c.Header == "" && c.Param == "":
+		return fmt.Errorf("informe header ou param")
+	case c.Header != "" && c.Param != "":
+		return fmt.Errorf("header e param são exclusivos")
+	}
+	return nil
+}
+
+// versioned aplica a versão ao job e à URL; ao contrário do locale, o
+// header prevalece sobre o mesmo header já definido (o do preset de
+// provider, por exemplo).
+func (job JobConfig) versioned(rawURL string) (JobConfig, string) {
+	c := job.APIVersion
+	if c == nil {
+		return job, rawURL
+	}
+
+	if c.Header != "" {
+		headers := make(map[string]string, len(job.Headers)+1)
+		for k, v := range job.Headers {
+			if !strings.EqualFold(k, c.Header) {
+				headers[k] = v
+			}
+		}
+		headers[c.Header] = c.Version
+		job.Headers = headers
+		return job, rawURL
+	}
+
+	// sem reescrever a URL, que ainda pode ter templates
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return job, rawURL + sep + url.QueryEscape(c.Param) + "=" + url.QueryEscape(c.Version)
+}
+
+func (c *APIVersionConfig) check() *utils.VersionCheck {
+	if c == nil {
+		return nil
+	}
+	header := c.ResponseHeader
+	if header == "" {
+		header = c.Header
+	}
+	return &utils.VersionCheck{Want: c.Version, Header: header}
+}
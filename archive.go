@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	archiveDir   = flag.String("archive-dir", "", "guarda uma cópia comprimida e datada de cada resposta neste diretório")
+	archiveCodec = flag.String("archive-codec", "gzip", "compressão do arquivo: gzip, zstd ou xz (zstd/xz usam os binários do sistema)")
+	archiveLevel = flag.String("archive-level", "", "nível de compressão (vazio = padrão do codec)")
+
+	archiveSample  = flag.Int("archive-sample", 0, "arquiva só esta quantidade de registros, espalhados pelo array (0 = todos)")
+	archiveRecords = flag.String("archive-records", "", "jsonpath do array amostrado por -archive-sample (padrão: raiz)")
+	archiveMax     ByteSize
+)
+
+func init() {
+	flag.Var(&archiveMax, "archive-max", "arquiva só os primeiros bytes de respostas maiores que isso, ex: 256KB (vazio = inteiras)")
+}
+
+var (
+	archiver      utils.Codec
+	archiverLevel int
+)
+
+func setupArchive() error {
+	if *archiveDir == "" {
+		return nil
+	}
+
+	var err error
+	if archiver, err = utils.LookupCodec(*archiveCodec); err != nil {
+		return err
+	}
+
+	archiverLevel = archiver.DefaultLevel
+	if *archiveLevel != "" {
+		if archiverLevel, err = strconv.Atoi(*archiveLevel); err != nil {
+			return fmt.Errorf("nível %q inválido", *archiveLevel)
+		}
+	}
+	if _, err := archiver.Compress(nil, archiverLevel); err != nil {
+		return err
+	}
+	if *archiveSample < 0 {
+		return fmt.Errorf("-archive-sample negativo")
+	}
+	if *archiveRecords != "" {
+		if _, err := utils.ParseJSONPath(*archiveRecords); err != nil {
+			return fmt.Errorf("-archive-records: %w", err)
+		}
+	}
+	return os.MkdirAll(*archiveDir, 0o755)
+}
+
+// writeOutput grava uma resposta e, com -archive-dir, também sua cópia
+// comprimida.
+func writeOutput(path string, data []byte) {
+	writeFileWith(path, data, publishFile)
+	archiveOutput(path, data)
+}
+
+func archiveOutput(path string, data []byte) {
+	if *archiveDir == "" {
+		return
+	}
+
+	original := len(data)
+	data, kind := debugExcerpt(data)
+
+	compressed, err := archiver.Compress(data, archiverLevel)
+	if err != nil {
+		log.Printf("Erro ao comprimir %s: %v", path, err)
+		return
+	}
+
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext) + "-" + time.Now().Format("20060102T150405") + kind + ext + archiver.Ext
+
+	writeFile(filepath.Join(*archiveDir, name), compressed)
+	if kind != "" {
+		log.Printf("Arquivado %s (%d de %d bytes mantidos -> %d bytes, %s)", name, len(data), original, len(compressed), archiver.Name)
+		return
+	}
+	log.Printf("Arquivado %s (%d -> %d bytes, %s)", name, len(data), len(compressed), archiver.Name)
+}
+
+// debugExcerpt reduz a cópia arquivada de respostas grandes, que serve
+// para depuração: primeiro a amostra de -archive-sample registros (o JSON
+// continua válido) e, se ainda passar de -archive-max, só os primeiros
+// bytes. O sufixo (".sample", ".truncated") vai para o nome do arquivo.
+func debugExcerpt(data []byte) ([]byte, string) {
+	var kind string
+	if *archiveSample > 0 {
+		if out, err := transformOutput(data, *archiveRecords, "archive", sampleRecords); err == nil && len(out) < len(data) {
+			data, kind = out, ".sample"
+		}
+	}
+	if archiveMax > 0 && int64(len(data)) > int64(archiveMax) {
+		data, kind = data[:archiveMax], ".truncated"
+	}
+	return data, kind
+}
+
+// sampleRecords escolhe -archive-sample registros em intervalos regulares,
+// a partir do primeiro, para a amostra cobrir o array inteiro e se repetir
+// entre execuções iguais.
+func sampleRecords(records []any) ([]any, error) {
+	n := *archiveSample
+	if len(records) <= n {
+		return records, nil
+	}
+	out := make([]any, n)
+	for i := range out {
+		out[i] = records[i*len(records)/n]
+	}
+	return out, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import "apiconsume/utils"
+
+// attemptDetail é uma tentativa da requisição que falhou, em errors.json:
+// um timeout de servidor lento aparece com elapsed alto (e às vezes bytes
+// parciais), uma conexão recusada com elapsed quase zero.
+type attemptDetail struct {
+	Status  int      `json:"status,omitempty"`
+	Elapsed Duration `json:"elapsed"`
+	Bytes   int64    `json:"bytes"`
+	Wait    Duration `json:"wait,omitzero"`
+	Error   string   `json:"error,omitempty"`
+	Conn    string   `json:"conn,omitempty"`
+	Idle    Duration `json:"idle,omitzero"`
+}
+
+func attemptDetails(trace *utils.AttemptTrace) []attemptDetail {
+	var out []attemptDetail
+	for _, a := range trace.Attempts() {
+		out = append(out, attemptDetail{
+			Status:  a.Status,
+			Elapsed: Duration{a.Elapsed},
+			Bytes:   a.Bytes,
+			Wait:    Duration{a.Wait},
+			Error:   a.Err,
+			Conn:    a.Conn,
+			Idle:    Duration{a.Idle},
+		})
+	}
+	return out
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"flag"
+	"log"
+)
+
+var audit = flag.Bool("audit", false, "ensaio contra produção: GETs são feitos normalmente, mas requisições que alteram dados (POST, PUT, PATCH, DELETE), sinks e webhooks viram no-ops registrados no log")
+
+// auditSkip registra, em modo -audit, o passo que alteraria algo fora da
+// máquina e diz se ele deve ser pulado.
+func auditSkip(format string, args ...any) bool {
+	if !*audit {
+		return false
+	}
+	log.Printf("[audit] não executado: "+format, args...)
+	return true
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// AuthConfig descreve como o job obtém (e renova, em 401/403) seu token.
+// Os campos de texto aceitam templates, ex: "{{env \"CLIENT_SECRET\"}}".
+type AuthConfig struct {
+	Type string `json:"type"`
+
+	// bearer e basic (aceitam templates, ex: {{env "API_TOKEN"}})
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// static: headers (também usado pelo login) e parâmetros de query
+	Query map[string]string `json:"query,omitempty"`
+
+	// sigv4 (credenciais em AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)
+	Region  string `json:"region,omitempty"`
+	Service string `json:"service,omitempty"`
+
+	// tipos registrados com utils.RegisterAuth
+	Options map[string]string `json:"options,omitempty"`
+
+	// apikey: a chave (em token) vai no header (padrão X-API-Key) ou, com
+	// param, na query
+	Param string `json:"param,omitempty"`
+
+	// oauth2 (grant refresh_token; sem refresh_token, client_credentials)
+	TokenURL     string `json:"token_url,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+
+	// login
+	Method    string            `json:"method,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	TokenPath string            `json:"token_path,omitempty"`
+
+	// gcp (ID token) e azure (identidade gerenciada)
+	Audience string `json:"audience,omitempty"`
+
+	// negotiate (Kerberos/SPNEGO)
+	Command []string `json:"command,omitempty"`
+
+	Header string  `json:"header,omitempty"`
+	Prefix *string `json:"prefix,omitempty"`
+
+	// Persist guarda o token em -state-dir até expirar, para que execuções
+	// seguidas não autentiquem de novo.
+	Persist bool `json:"persist,omitempty"`
+}
+
+// authTypes são os tipos de auth embutidos; os registrados por
+// utils.RegisterAuth vêm depois, em supportedAuths.
+var authTypes = []string{"static", "bearer", "apikey", "basic", "sigv4", "oauth2", "login", "gcp", "azure", "negotiate"}
+
+func supportedAuths() []string {
+	return append(slices.Clone(authTypes), utils.RegisteredAuths()...)
+}
+
+func (c *AuthConfig) provider() (utils.AuthProvider, error) {
+	var (
+		source utils.TokenSource
+		err    error
+	)
+
+	switch c.Type {
+	case "static":
+		return c.staticAuth()
+	case "bearer":
+		token, err := renderTemplate(c.Token, templateVars{})
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return nil, fmt.Errorf("bearer exige token")
+		}
+		return utils.NewBearerAuth(token), nil
+	case "apikey":
+		return c.apiKeyAuth()
+	case "basic":
+		fields, err := renderAll(c.Username, c.Password)
+		if err != nil {
+			return nil, err
+		}
+		if fields[0] == "" {
+			return nil, fmt.Errorf("basic exige username")
+		}
+		return &utils.BasicAuth{Username: fields[0], Password: fields[1]}, nil
+	case "sigv4":
+		region := c.Region
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" || c.Service == "" {
+			return nil, fmt.Errorf("sigv4 exige region (ou AWS_REGION) e service (ex: execute-api)")
+		}
+		return utils.NewSigV4Auth(region, c.Service)
+	case "negotiate":
+		if len(c.Command) == 0 {
+			return nil, fmt.Errorf("negotiate exige command (gerador do token SPNEGO)")
+		}
+		return &utils.NegotiateAuth{Command: c.Command}, nil
+	case "oauth2":
+		source, err = c.oauth2Source()
+	case "login":
+		source, err = c.loginSource()
+	case "gcp":
+		source, err = c.gcpSource()
+	case "azure":
+		source, err = c.azureSource()
+	default:
+		if factory, ok := utils.LookupAuth(c.Type); ok {
+			return c.customAuth(factory)
+		}
+		return nil, fmt.Errorf("tipo %q não suportado (%s)", c.Type, strings.Join(supportedAuths(), ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	auth := utils.NewTokenAuth(source)
+	if c.Header != "" {
+		auth.Header = c.Header
+	}
+	if c.Prefix != nil {
+		auth.Prefix = *c.Prefix
+	}
+	if c.Persist {
+		auth.Cache = c.tokenCache()
+	}
+	return auth, nil
+}
+
+func (c *AuthConfig) oauth2Source() (utils.TokenSource, error) {
+	fields, err := renderAll(c.TokenURL, c.ClientID, c.ClientSecret, c.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if fields[0] == "" {
+		return nil, fmt.Errorf("oauth2 exige token_url")
+	}
+	if fields[3] == "" {
+		if fields[1] == "" {
+			return nil, fmt.Errorf("oauth2 exige refresh_token ou, para client_credentials, client_id")
+		}
+		return &utils.OAuth2ClientCredentials{
+			TokenURL:     fields[0],
+			ClientID:     fields[1],
+			ClientSecret: fields[2],
+			Scope:        c.Scope,
+		}, nil
+	}
+
+	src := &utils.OAuth2Refresh{
+		TokenURL:     fields[0],
+		ClientID:     fields[1],
+		ClientSecret: fields[2],
+		RefreshToken: fields[3],
+		Scope:        c.Scope,
+	}
+	if !c.Persist {
+		src.Rotated = func(string) {
+			log.Printf("AVISO: o servidor rotacionou o refresh token de %s; sem persist, a próxima execução volta ao da configuração", fields[0])
+		}
+		return src, nil
+	}
+	// o refresh token rotacionado numa execução anterior vale mais que o da
+	// configuração, que o servidor já pode ter invalidado
+	cache := c.tokenCache()
+	if rotated := cache.refreshToken(); rotated != "" {
+		src.RefreshToken = rotated
+	}
+	src.Rotated = cache.saveRefreshToken
+	return src, nil
+}
+
+func (c *AuthConfig) loginSource() (utils.TokenSource, error) {
+	fields, err := renderAll(c.URL, c.Body)
+	if err != nil {
+		return nil, err
+	}
+	if fields[0] == "" {
+		return nil, fmt.Errorf("login exige url")
+	}
+
+	tokenPath := c.TokenPath
+	if tokenPath == "" {
+		tokenPath = "$.access_token"
+	}
+	path, err := utils.ParseJSONPath(tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("token_path: %w", err)
+	}
+
+	headers, err := renderMap(c.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	login := &utils.LoginToken{
+		Method:    c.Method,
+		URL:       fields[0],
+		Headers:   headers,
+		TokenPath: path,
+	}
+	if fields[1] != "" {
+		login.Body = []byte(fields[1])
+	}
+	return login, nil
+}
+
+func (c *AuthConfig) gcpSource() (utils.TokenSource, error) {
+	if c.Audience == "" {
+		return nil, fmt.Errorf("gcp exige audience (ex: client ID do IAP ou URL do serviço)")
+	}
+	return &utils.GCPIdentityToken{Audience: c.Audience}, nil
+}
+
+func (c *AuthConfig) azureSource() (utils.TokenSource, error) {
+	if c.Audience == "" {
+		return nil, fmt.Errorf("azure exige audience (App ID URI do recurso)")
+	}
+	clientID, err := renderTemplate(c.ClientID, templateVars{})
+	if err != nil {
+		return nil, err
+	}
+	return &utils.AzureManagedIdentity{Resource: c.Audience, ClientID: clientID}, nil
+}
+
+func (c *AuthConfig) staticAuth() (utils.AuthProvider, error) {
+	if len(c.Headers) == 0 && len(c.Query) == 0 {
+		return nil, fmt.Errorf("static exige headers e/ou query")
+	}
+	headers, err := renderMap(c.Headers)
+	if err != nil {
+		return nil, err
+	}
+	query, err := renderMap(c.Query)
+	if err != nil {
+		return nil, err
+	}
+	return &utils.StaticAuth{Headers: headers, Query: query}, nil
+}
+
+func (c *AuthConfig) customAuth(factory utils.AuthFactory) (utils.AuthProvider, error) {
+	options, err := renderMap(c.Options)
+	if err != nil {
+		return nil, err
+	}
+	return factory(options)
+}
+
+func renderMap(values map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		var err error
+		if out[k], err = renderTemplate(v, templateVars{}); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func renderAll(texts ...string) ([]string, error) {
+	out := make([]string, len(texts))
+	for i, text := range texts {
+		var err error
+		if out[i], err = renderTemplate(text, templateVars{}); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (c *AuthConfig) apiKeyAuth() (utils.AuthProvider, error) {
+	key, err := renderTemplate(c.Token, templateVars{})
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, fmt.Errorf("apikey exige token")
+	}
+	if c.Param != "" {
+		return &utils.StaticAuth{Query: map[string]string{c.Param: key}}, nil
+	}
+	header := c.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return &utils.StaticAuth{Headers: map[string]string{header: key}}, nil
+}
+
+// tokenState é o token persistido de um auth com persist, com o refresh
+// token que o servidor tenha rotacionado.
+type tokenState struct {
+	Token        string    `json:"token"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+}
+
+// stateTokenCache guarda o token em -state-dir, num arquivo por
+// credencial: jobs com o mesmo token_url, client_id e scope compartilham o
+// token.
+type stateTokenCache struct {
+	name string
+}
+
+func (c *AuthConfig) tokenCache() stateTokenCache {
+	sum := sha256.Sum256([]byte(strings.Join([]string{c.Type, c.TokenURL, c.ClientID, c.Scope, c.URL, c.Audience}, "\x00")))
+	return stateTokenCache{name: "token-" + hex.EncodeToString(sum[:8])}
+}
+
+func (c stateTokenCache) Load() (string, time.Time, bool) {
+	var st tokenState
+	ok, err := stateStore.Load(c.name, &st)
+	if err != nil {
+		log.Printf("%v", err)
+		return "", time.Time{}, false
+	}
+	if !ok || st.Token == "" || (!st.Expiry.IsZero() && time.Now().After(st.Expiry)) {
+		return "", time.Time{}, false
+	}
+	return st.Token, st.Expiry, true
+}
+
+func (c stateTokenCache) Save(token string, expiry time.Time) {
+	c.update(func(st *tokenState) { st.Token, st.Expiry = token, expiry })
+}
+
+// refreshToken é o refresh token rotacionado guardado, mesmo com o access
+// token já vencido.
+func (c stateTokenCache) refreshToken() string {
+	var st tokenState
+	if _, err := stateStore.Load(c.name, &st); err != nil {
+		log.Printf("%v", err)
+	}
+	return st.RefreshToken
+}
+
+func (c stateTokenCache) saveRefreshToken(token string) {
+	c.update(func(st *tokenState) { st.RefreshToken = token })
+}
+
+// update regrava o estado do token sem perder os outros campos, que outro
+// job com a mesma credencial pode ter gravado.
+func (c stateTokenCache) update(fn func(*tokenState)) {
+	defer lockState(c.name)()
+	var st tokenState
+	if _, err := stateStore.Load(c.name, &st); err != nil {
+		log.Printf("%v", err)
+	}
+	fn(&st)
+	if err := stateStore.Save(c.name, st); err != nil {
+		log.Printf("Erro ao salvar token: %v", err)
+	}
+}
+
+// loadEnvAuth monta a autenticação do modo .env a partir de AUTH_TYPE:
+// oauth2 (TOKEN_URL, CLIENT_ID, CLIENT_SECRET, SCOPE e, opcional,
+// REFRESH_TOKEN), bearer (TOKEN) ou apikey (API_KEY, API_KEY_HEADER ou
+// API_KEY_PARAM). TOKEN_PERSIST=true guarda o token entre execuções.
+func loadEnvAuth(path string) (utils.AuthProvider, error) {
+	values, err := loadEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := envAuthConfig(values)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return c.provider()
+}
+
+func envAuthConfig(values map[string]string) (*AuthConfig, error) {
+	c := &AuthConfig{Type: values["AUTH_TYPE"], Persist: values["TOKEN_PERSIST"] == "true"}
+	switch c.Type {
+	case "":
+		return nil, nil
+	case "oauth2":
+		c.TokenURL = values["TOKEN_URL"]
+		c.ClientID = values["CLIENT_ID"]
+		c.ClientSecret = values["CLIENT_SECRET"]
+		c.RefreshToken = values["REFRESH_TOKEN"]
+		c.Scope = values["SCOPE"]
+	case "bearer":
+		c.Token = values["TOKEN"]
+	case "apikey":
+		c.Token = values["API_KEY"]
+		c.Header = values["API_KEY_HEADER"]
+		c.Param = values["API_KEY_PARAM"]
+	default:
+		return nil, fmt.Errorf("AUTH_TYPE %q não suportado (oauth2, bearer, apikey)", c.Type)
+	}
+	return c, nil
+}
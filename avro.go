@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	sinkTypes["avro"] = newAvroSink
+}
+
+// avroSink grava os registros como Avro Object Container File. Com
+// registry, o schema é registrado no subject (ou, com fetch, lido de lá)
+// e seu id vai nos metadados do arquivo.
+type avroSink struct {
+	Records  string          `json:"records"`
+	Schema   string          `json:"schema"`
+	Path     string          `json:"path"`
+	Codec    string          `json:"codec"`
+	Registry *registryConfig `json:"registry"`
+
+	columns  []utils.Column
+	registry *utils.SchemaRegistry
+}
+
+type registryConfig struct {
+	URL      string `json:"url"`
+	Subject  string `json:"subject"`
+	Fetch    bool   `json:"fetch"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+func newAvroSink(raw json.RawMessage, baseDir string) (sink, error) {
+	s := &avroSink{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+
+	switch s.Codec {
+	case "", "deflate", "null":
+	default:
+		return nil, fmt.Errorf("codec %q não suportado (deflate, null)", s.Codec)
+	}
+
+	var err error
+	if s.columns, err = loadSchemaColumns(s.Schema, baseDir); err != nil {
+		return nil, err
+	}
+
+	if r := s.Registry; r != nil {
+		fields, err := renderAll(r.URL, r.User, r.Password)
+		if err != nil {
+			return nil, err
+		}
+		if fields[0] == "" {
+			return nil, fmt.Errorf("registry exige url")
+		}
+		if r.Fetch && s.Schema != "" {
+			return nil, fmt.Errorf("use schema ou registry.fetch, não ambos")
+		}
+		s.registry = &utils.SchemaRegistry{URL: fields[0], User: fields[1], Password: fields[2]}
+	}
+	return s, nil
+}
+
+func (s *avroSink) Deliver(ctx context.Context, out sinkOutput) error {
+	subject := out.Job.Name + "-value"
+	if s.Registry != nil && s.Registry.Subject != "" {
+		subject = s.Registry.Subject
+	}
+
+	var (
+		schema   []byte
+		schemaID int
+		columns  = s.columns
+		err      error
+	)
+
+	if s.registry != nil && s.Registry.Fetch {
+		if schemaID, schema, err = s.registry.Latest(ctx, subject); err != nil {
+			return err
+		}
+		if columns, err = utils.ColumnsFromAvroSchema(schema); err != nil {
+			return err
+		}
+	}
+
+	records, columns, err := tabularRecords(out.Data, s.Records, columns)
+	if err != nil {
+		return err
+	}
+
+	if schema == nil {
+		if schema, err = utils.AvroSchema(out.Job.Name, columns); err != nil {
+			return err
+		}
+		if s.registry != nil {
+			if schemaID, err = s.registry.Register(ctx, subject, schema); err != nil {
+				return err
+			}
+		}
+	}
+
+	meta := map[string]string{}
+	if s.registry != nil {
+		meta["schema.registry.subject"] = subject
+		meta["schema.registry.id"] = strconv.Itoa(schemaID)
+	}
+
+	var buf bytes.Buffer
+	if err := utils.WriteAvro(&buf, schema, columns, records, s.Codec, meta); err != nil {
+		return err
+	}
+
+	writeFile(sinkPath(s.Path, out.Path, ".avro"), buf.Bytes())
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	backupOutputs = flag.String("backup", "", "antes de sobrescrever uma saída, guarda a anterior: prev (<nome>.prev.json) ou dated (<nome>.<data>.json, até -backup-keep) (vazio = não guarda)")
+	backupKeep    = flag.Int("backup-keep", 7, "com -backup dated, cópias datadas mantidas por arquivo")
+)
+
+const backupStamp = "20060102T150405"
+
+func validateBackup() error {
+	switch *backupOutputs {
+	case "", "prev", "dated":
+	default:
+		return fmt.Errorf("-backup deve ser prev ou dated")
+	}
+	if *backupKeep < 1 {
+		return fmt.Errorf("-backup-keep deve ser >= 1")
+	}
+	return nil
+}
+
+// backupOutput guarda a versão atual de path antes que a nova a substitua,
+// para voltar atrás na hora quando um payload ruim passa pela validação.
+// A cópia é um hard link (o rename da nova versão não a altera), ou uma
+// cópia quando o filesystem não tem links.
+func backupOutput(path string, now time.Time) {
+	if *backupOutputs == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	target := base + ".prev" + ext
+	if *backupOutputs == "dated" {
+		target = base + "." + now.Format(backupStamp) + ext
+	}
+
+	if err := linkOrCopy(path, target); err != nil {
+		log.Printf("Erro ao guardar cópia de %s: %v", path, err)
+		return
+	}
+	if *backupOutputs == "dated" {
+		pruneBackups(base, ext)
+	}
+}
+
+func linkOrCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, outputPerm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// pruneBackups apaga as cópias datadas de base além das -backup-keep mais
+// novas.
+func pruneBackups(base, ext string) {
+	matches, err := filepath.Glob(base + ".*" + ext)
+	if err != nil {
+		return
+	}
+	var dated []string
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, base+"."), ext)
+		if _, err := time.Parse(backupStamp, stamp); err == nil {
+			dated = append(dated, m)
+		}
+	}
+	sort.Strings(dated)
+	for len(dated) > *backupKeep {
+		if err := os.Remove(dated[0]); err != nil {
+			log.Printf("Erro ao apagar cópia antiga %s: %v", dated[0], err)
+		}
+		dated = dated[1:]
+	}
+}
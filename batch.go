@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"apiconsume/utils"
+)
+
+const defaultBatchTemplate = `{"ids": {{json .IDs}}}`
+
+var (
+	batchSize      = flag.Int("batch-size", 0, "agrupa os IDs do bulk em lotes de N por requisição (0 = desativado)")
+	batchMethod    = flag.String("batch-method", "POST", "método HTTP das requisições em lote")
+	batchTemplate  = flag.String("batch-template", "", "arquivo com o template Go do payload do lote (padrão: {\"ids\": [...]})")
+	batchItemsPath = flag.String("batch-items-path", "$", "jsonpath da lista de itens na resposta do lote")
+	batchIDField   = flag.String("batch-id-field", "$.id", "jsonpath do ID dentro de cada item da resposta")
+)
+
+type batchPayloadData struct {
+	IDs   []string
+	Index int
+}
+
+func loadBatchTemplate(path string) (*template.Template, error) {
+	text := defaultBatchTemplate
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler template do lote: %w", err)
+		}
+		text = string(data)
+	}
+
+	return template.New("batch").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+}
+
+func chunkIDs(ids []string, size int) [][]string {
+	var chunks [][]string
+	for size < len(ids) {
+		ids, chunks = ids[size:], append(chunks, ids[:size])
+	}
+	return append(chunks, ids)
+}
+
+func runBatch(ctx context.Context, rl *utils.RateLimitClient, url string, ids []string, outputDir, errorLogPath string) {
+	tmpl, err := loadBatchTemplate(*batchTemplate)
+	if err != nil {
+		log.Fatalf("Erro no template do lote: %v", err)
+	}
+
+	itemsPath, err := utils.ParseJSONPath(*batchItemsPath)
+	if err != nil {
+		log.Fatalf("Erro em -batch-items-path: %v", err)
+	}
+	idPath, err := utils.ParseJSONPath(*batchIDField)
+	if err != nil {
+		log.Fatalf("Erro em -batch-id-field: %v", err)
+	}
+
+	var errors []ErrorResponse
+	chunks := chunkIDs(ids, *batchSize)
+
+	log.Printf("Modo lote iniciado: %d itens em %d lotes", len(ids), len(chunks))
+
+	for i, chunk := range chunks {
+		if waitExecutionWindow(ctx) != nil {
+			break
+		}
+
+		var payload bytes.Buffer
+		if err := tmpl.Execute(&payload, batchPayloadData{IDs: chunk, Index: i}); err != nil {
+			log.Fatalf("Erro ao montar payload do lote: %v", err)
+		}
+
+		reqCtx, trace := utils.WithAttemptTrace(ctx)
+		body, status, err := doRequest(reqCtx, rl, requestSpec{Method: *batchMethod, URL: url, Body: payload.Bytes()})
+		if err != nil || status != 200 {
+			failure := requestError(nil, status, body, err)
+			attempts := attemptDetails(trace)
+			for _, id := range chunk {
+				errors = append(errors, ErrorResponse{At: time.Now(), Attempt: i + 1, Item: id, Error: failure.Error(), Attempts: attempts}.withEnvelope(failure))
+			}
+			continue
+		}
+
+		records, err := demuxBatch(body, itemsPath, idPath)
+		if err != nil {
+			for _, id := range chunk {
+				errors = append(errors, ErrorResponse{At: time.Now(), Attempt: i + 1, Item: id, Error: err.Error()})
+			}
+			continue
+		}
+
+		for _, id := range chunk {
+			record, ok := records[id]
+			if !ok {
+				errors = append(errors, ErrorResponse{At: time.Now(), Attempt: i + 1, Item: id, Error: "item ausente na resposta do lote"})
+				continue
+			}
+			writeOutput(filepath.Join(outputDir, bulkFileName(id)), record)
+		}
+	}
+
+	if len(errors) > 0 {
+		saveErrors(errorLogPath, errors)
+	}
+
+	log.Printf("Modo lote finalizado: %d itens, %d falhas", len(ids), len(errors))
+}
+
+func demuxBatch(body []byte, itemsPath, idPath *utils.JSONPath) (map[string][]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("resposta do lote não é JSON válido: %w", err)
+	}
+
+	found := itemsPath.Find(doc)
+	if len(found) == 1 {
+		if list, ok := found[0].([]any); ok {
+			found = list
+		}
+	}
+
+	records := make(map[string][]byte, len(found))
+	for _, item := range found {
+		id, ok := idPath.First(item)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		records[fmt.Sprint(id)] = data
+	}
+
+	return records, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+)
+
+var bindAddr = flag.String("bind", "", "IP local ou interface (ex: eth1) de saída das requisições, para provedores que liberam só um dos IPs (vazio = padrão do sistema)")
+
+// resolveBind traduz o bind (um IP local ou o nome de uma interface) no
+// IP de origem; numa interface, vale o primeiro IPv4, ou o primeiro IP.
+func resolveBind(bind string) (net.IP, error) {
+	if ip := net.ParseIP(bind); ip != nil {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return ip, nil
+			}
+		}
+		return nil, fmt.Errorf("bind: IP %s não pertence a nenhuma interface local", bind)
+	}
+
+	iface, err := net.InterfaceByName(bind)
+	if err != nil {
+		return nil, fmt.Errorf("bind: %q não é IP nem interface local", bind)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var first net.IP
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok || n.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if n.IP.To4() != nil {
+			return n.IP, nil
+		}
+		if first == nil {
+			first = n.IP
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("bind: interface %s sem endereço", bind)
+	}
+	return first, nil
+}
+
+func validateBind(bind string) error {
+	if bind == "" {
+		return nil
+	}
+	if *proxyAuth == "ntlm" {
+		return fmt.Errorf("bind não se aplica com -proxy-auth ntlm")
+	}
+	_, err := resolveBind(bind)
+	return err
+}
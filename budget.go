@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var minJobBudget = flag.Duration("min-job-budget", time.Minute, "com -deadline, tempo mínimo para iniciar um job de priority low; com menos, ele é ignorado")
+
+var jobPriorities = map[string]utils.Priority{
+	"low":    utils.PriorityLow,
+	"normal": utils.PriorityNormal,
+	"high":   utils.PriorityHigh,
+}
+
+// priority devolve a prioridade do job; sem priority, jobs critical são high.
+func (job JobConfig) priority() (utils.Priority, error) {
+	if job.Priority == "" {
+		if job.isCritical() {
+			return utils.PriorityHigh, nil
+		}
+		return utils.PriorityNormal, nil
+	}
+	p, ok := jobPriorities[job.Priority]
+	if !ok {
+		return 0, fmt.Errorf("priority deve ser low, normal ou high")
+	}
+	return p, nil
+}
+
+// runBudget reparte o que resta de -deadline entre os jobs que ainda não
+// começaram. Jobs sem grupo rodam todos juntos e ficam com o tempo restante
+// inteiro; num grupo, o tempo é dividido pelas rodadas que ainda faltam.
+type runBudget struct {
+	deadline time.Time
+
+	mu      sync.Mutex
+	pending map[string]int
+	slots   map[string]int
+}
+
+func newRunBudget(ctx context.Context, cfg *MultiJobConfig) *runBudget {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	b := &runBudget{deadline: deadline, pending: map[string]int{}, slots: map[string]int{}}
+	for _, job := range cfg.Jobs {
+		if job.Group == "" {
+			continue
+		}
+		b.pending[job.Group]++
+		b.slots[job.Group] = max(cfg.Groups[job.Group].Concurrency, 1)
+	}
+	return b
+}
+
+// allocate é chamado quando o job vai começar e devolve quanto tempo ele
+// tem (0 = sem -deadline); ok falso quer dizer que o job deve ser ignorado
+// por falta de tempo.
+func (b *runBudget) allocate(job JobConfig) (budget time.Duration, ok bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	remaining := time.Until(b.deadline)
+	budget = remaining
+	if job.Group != "" {
+		rounds := (b.pending[job.Group] + b.slots[job.Group] - 1) / b.slots[job.Group]
+		b.pending[job.Group]--
+		if rounds > 1 {
+			budget = remaining / time.Duration(rounds)
+		}
+	}
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	// só jobs low são ignorados; os demais rodam com pelo menos
+	// -min-job-budget, se ainda houver esse tempo
+	if budget < *minJobBudget {
+		if p, _ := job.priority(); p <= utils.PriorityLow {
+			return 0, false
+		}
+		budget = min(*minJobBudget, remaining)
+	}
+	return budget, true
+}
+
+// withBudget limita o job ao seu orçamento, com um motivo legível no erro.
+func withBudget(ctx context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return ctx, func() {}
+	}
+	cause := &crawlLimitError{msg: fmt.Sprintf("job passou do seu orçamento de %v dentro de -deadline", budget.Round(time.Second))}
+	return context.WithTimeoutCause(ctx, budget, cause)
+}
+
+func skipOutOfTime(job JobConfig, res *jobResult) {
+	log.Printf("[%s] Job ignorado: sem tempo antes do fim de -deadline", job.Name)
+	res.Skipped = true
+	res.Error = "ignorado: sem tempo"
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	bulkInput         = flag.String("bulk-input", "", "arquivo com um ID por linha; ativa o modo bulk substituindo {id} na URL")
+	bulkMinWorkers    = flag.Int("bulk-min-workers", 1, "concorrência mínima no modo bulk")
+	bulkMaxWorkers    = flag.Int("bulk-max-workers", 16, "concorrência máxima no modo bulk")
+	bulkTargetLatency = flag.Duration("bulk-target-latency", 2*time.Second, "latência acima da qual a concorrência é reduzida")
+)
+
+func loadBulkInput(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir entrada do bulk: %w", err)
+	}
+	defer file.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+
+	return ids, scanner.Err()
+}
+
+type bulkOptions struct {
+	MinWorkers    int
+	MaxWorkers    int
+	TargetLatency time.Duration
+	Limits        crawlLimits
+	Status        *StatusConfig
+	ErrorEnvelope *ErrorEnvelope
+
+	// Provider é o perfil em que a concorrência que rendeu mais sem erros
+	// fica guardada, para a próxima execução começar nela (vazio = não
+	// guarda).
+	Provider string
+}
+
+func bulkOptionsFromFlags() bulkOptions {
+	return bulkOptions{
+		MinWorkers:    *bulkMinWorkers,
+		MaxWorkers:    *bulkMaxWorkers,
+		TargetLatency: *bulkTargetLatency,
+		Limits:        flagSettings().limits(),
+	}
+}
+
+func runBulk(ctx context.Context, rl *utils.RateLimitClient, base requestSpec, ids []string, outputDir string, opts bulkOptions) []ErrorResponse {
+	ctrl := utils.NewAIMDController(opts.MinWorkers, opts.MaxWorkers, opts.TargetLatency)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errors []ErrorResponse
+	)
+
+	usage := &crawlUsage{limits: opts.Limits}
+	var stopped atomic.Bool
+
+	if opts.Provider != "" {
+		if n := bulkConcurrency(opts.Provider); n > 0 {
+			ctrl.Seed(n)
+			log.Printf("Concorrência inicial %d, aprendida para %s", ctrl.Limit(), opts.Provider)
+		}
+	}
+
+	log.Printf("Modo bulk iniciado: %d itens, concorrência %d-%d", len(ids), ctrl.Min, ctrl.Max)
+
+	for _, id := range ids {
+		if stopped.Load() {
+			break
+		}
+		if err := waitExecutionWindow(ctx); err != nil {
+			break
+		}
+		if err := ctrl.Acquire(ctx); err != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			spec := base
+			spec.URL = strings.ReplaceAll(base.URL, "{id}", url.PathEscape(id))
+
+			reqCtx, trace := utils.WithAttemptTrace(ctx)
+			body, _, status, err := opts.Status.fetch(reqCtx, rl, spec)
+
+			overloaded := err != nil || status == http.StatusTooManyRequests || status >= 500
+			ctrl.Release(serverLatency(trace), overloaded)
+
+			if err == nil && opts.Status.accepts(status) {
+				if limitErr := usage.add(1, 0, int64(len(body))); limitErr != nil {
+					// só o primeiro estouro é registrado como erro
+					if !stopped.Swap(true) {
+						mu.Lock()
+						errors = append(errors, ErrorResponse{At: time.Now(), Attempt: 1, Item: id, Error: limitErr.Error()})
+						mu.Unlock()
+					}
+					return
+				}
+				writeOutput(filepath.Join(outputDir, bulkFileName(id)), body)
+				return
+			}
+
+			failure := requestError(opts.ErrorEnvelope, status, body, explain(ctx, err))
+			mu.Lock()
+			errors = append(errors, ErrorResponse{
+				At:       time.Now(),
+				Attempt:  1,
+				Item:     id,
+				Error:    failure.Error(),
+				Attempts: attemptDetails(trace),
+			}.withEnvelope(failure))
+			mu.Unlock()
+		}(id)
+	}
+
+	wg.Wait()
+
+	log.Printf("Modo bulk finalizado: %d itens, %d falhas, concorrência final %d", len(ids), len(errors), ctrl.Limit())
+	if opts.Provider != "" {
+		if best := ctrl.Best(); best > 0 {
+			log.Printf("Melhor concorrência sem erros para %s: %d", opts.Provider, best)
+			recordConcurrency(opts.Provider, best, time.Now())
+		}
+	}
+	return errors
+}
+
+// serverLatency é o tempo da última tentativa da requisição, sem as esperas
+// do próprio cliente (a vez no rate limiter, o backoff e o Retry-After), que
+// o AIMD leria como sobrecarga do servidor.
+func serverLatency(trace *utils.AttemptTrace) time.Duration {
+	attempts := trace.Attempts()
+	if len(attempts) == 0 {
+		return 0
+	}
+	return attempts[len(attempts)-1].Elapsed
+}
+
+func bulkFileName(id string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, id)
+	return "response-" + safe + ".json"
+}
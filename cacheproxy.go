@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	proxyUpstream = flag.String("upstream", "", "cache-proxy: URL base da API (padrão: a do job de -proxy-job)")
+	proxyJob      = flag.String("proxy-job", "", "cache-proxy: job de -config cujo limite, auth e dialeto valem para o proxy")
+	proxyListen   = flag.String("proxy-listen", "127.0.0.1:8081", "cache-proxy: endereço do proxy")
+	proxyToken    = flag.String("proxy-token-env", "CACHE_PROXY_TOKEN", "cache-proxy: variável com o token exigido dos clientes em Proxy-Authorization: Bearer")
+	proxyWrites   = flag.Bool("proxy-allow-writes", false, "cache-proxy: repassa também POST, PUT, PATCH e DELETE (padrão: só GET e HEAD)")
+	proxyTTL      = flag.Duration("proxy-ttl", time.Minute, "cache-proxy: validade das respostas em cache (0 = sem cache)")
+	proxyCacheMax = ByteSize(64 << 20)
+)
+
+func init() {
+	commands["cache-proxy"] = cacheProxyCommand
+	flag.Var(&proxyCacheMax, "proxy-cache-max", "cache-proxy: memória para as respostas em cache, ex: 64MB; as menos usadas saem primeiro")
+}
+
+// proxyPrefix é onde ficam os endpoints do próprio proxy, fora do caminho
+// repassado para a API.
+const proxyPrefix = "/_proxy/"
+
+// hopHeaders valem só para uma conexão e não são repassados (RFC 9110,
+// 7.6.1). Accept-Encoding fica com o transporte: a resposta chega aberta e
+// serve do cache para qualquer cliente.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Accept-Encoding",
+}
+
+func stripHopHeaders(h http.Header) {
+	for _, f := range h.Values("Connection") {
+		for _, name := range strings.Split(f, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// cacheProxyCommand roda um proxy reverso local na frente da API com rate
+// limit: os serviços internos chamam o proxy à vontade e ele segura o
+// limite do provedor (rate, backoff e Retry-After do RateLimitClient),
+// devolve do cache as respostas GET repetidas dentro de -proxy-ttl e junta
+// numa só requisição as iguais que chegam ao mesmo tempo. GET
+// /_proxy/metrics tem as métricas do cache no formato do Prometheus.
+func cacheProxyCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("uso: api-requester cache-proxy -upstream https://api.com [-proxy-listen 127.0.0.1:8081] [-proxy-ttl 1m]")
+	}
+	// com -proxy-job, o proxy assina com as credenciais do job o que for
+	// pedido: quem chama precisa do token, mesmo no loopback
+	token, err := listenToken(*proxyToken, *proxyListen, true)
+	if err != nil {
+		return err
+	}
+	p, err := newCacheProxy()
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+proxyPrefix+"metrics", p.handleMetrics)
+	mux.Handle("/", p)
+
+	srv := &http.Server{Addr: *proxyListen, Handler: requireBearer(token, "Proxy-Authorization", mux), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Proxy de cache ouvindo em %s para %s (ttl %v, até %s em memória)", *proxyListen, utils.RedactURL(*p.upstream), *proxyTTL, proxyCacheMax.String())
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+type cacheProxy struct {
+	upstream *url.URL
+	rl       *utils.RateLimitClient
+	cache    *proxyCache
+
+	mu       sync.Mutex
+	inflight map[string]*proxyCall
+
+	hits, misses, coalesced, bypassed atomic.Int64
+	upstreamRequests, upstreamErrors  atomic.Int64
+}
+
+// proxyCall é a requisição à API em andamento para uma chave, que as
+// iguais esperam em vez de repetir.
+type proxyCall struct {
+	done  chan struct{}
+	entry *proxyEntry
+	err   error
+}
+
+// sharedRateClient é o rate limiter que o proxy e o limiter dividem entre
+// clientes de fora: o do job name de -config, com taxa, auth, preset e
+// dialeto dele, ou, sem job, o das flags.
+func sharedRateClient(name string) (*utils.RateLimitClient, *JobConfig, error) {
+	if name == "" {
+		return newRateClient(flagSettings()), nil, nil
+	}
+	jobs, err := loadTriggerJobs()
+	if err != nil {
+		return nil, nil, err
+	}
+	job, ok := jobs[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("job %q não existe em -config", name)
+	}
+	return job.rateClient(job.JobSettings), &job, nil
+}
+
+func newCacheProxy() (*cacheProxy, error) {
+	rl, job, err := sharedRateClient(*proxyJob)
+	if err != nil {
+		return nil, err
+	}
+	base := *proxyUpstream
+	if base == "" && job != nil {
+		u, err := url.Parse(job.URL)
+		if err != nil {
+			return nil, fmt.Errorf("url do job %q: %w", job.Name, err)
+		}
+		base = u.Scheme + "://" + u.Host
+	}
+	if base == "" {
+		return nil, fmt.Errorf("informe -upstream ou -proxy-job")
+	}
+	upstream, err := url.Parse(base)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		return nil, fmt.Errorf("-upstream inválido: %q", base)
+	}
+
+	return &cacheProxy{
+		upstream: upstream,
+		rl:       rl,
+		cache:    newProxyCache(int64(proxyCacheMax)),
+		inflight: map[string]*proxyCall{},
+	}, nil
+}
+
+// target é a URL da API para o caminho e a query pedidos ao proxy.
+func (p *cacheProxy) target(r *http.Request) string {
+	u := *p.upstream
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+	return u.String()
+}
+
+// cacheKey separa as respostas por credencial: um serviço nunca recebe do
+// cache o que a API devolveu para o token de outro.
+func cacheKey(r *http.Request, target string) string {
+	h := sha256.New()
+	for _, name := range []string{"Authorization", "Cookie", "Accept", "Accept-Language"} {
+		fmt.Fprintf(h, "%s: %s\n", name, strings.Join(r.Header.Values(name), ", "))
+	}
+	for name, values := range r.Header {
+		// Proxy-Authorization é o token do próprio proxy, igual para todos
+		if utils.SensitiveName(name) && name != "Authorization" && name != "Cookie" && name != "Proxy-Authorization" {
+			fmt.Fprintf(h, "%s: %s\n", name, strings.Join(values, ", "))
+		}
+	}
+	return utils.CacheKey(r.Method, target, h.Sum(nil))
+}
+
+func (p *cacheProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, proxyPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if !readOnly && !*proxyWrites {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": r.Method + " não é repassado sem -proxy-allow-writes"})
+		return
+	}
+	target := p.target(r)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, "body inválido: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	cacheable := readOnly && *proxyTTL > 0
+	if !cacheable {
+		p.bypassed.Add(1)
+		entry, err := p.forward(r, target, body)
+		p.reply(w, r, entry, err, "BYPASS")
+		return
+	}
+
+	key := cacheKey(r, target)
+	noCache := strings.Contains(r.Header.Get("Cache-Control"), "no-cache")
+	if !noCache {
+		if entry, ok := p.cache.get(key); ok {
+			p.hits.Add(1)
+			p.reply(w, r, entry, nil, "HIT")
+			return
+		}
+	}
+
+	p.mu.Lock()
+	if call, ok := p.inflight[key]; ok {
+		p.mu.Unlock()
+		p.coalesced.Add(1)
+		<-call.done
+		p.reply(w, r, call.entry, call.err, "HIT")
+		return
+	}
+	call := &proxyCall{done: make(chan struct{})}
+	p.inflight[key] = call
+	p.mu.Unlock()
+
+	p.misses.Add(1)
+	call.entry, call.err = p.forward(r, target, body)
+	if call.err == nil && call.entry.storable() {
+		p.cache.put(key, call.entry)
+	}
+
+	p.mu.Lock()
+	delete(p.inflight, key)
+	p.mu.Unlock()
+	close(call.done)
+
+	p.reply(w, r, call.entry, call.err, "MISS")
+}
+
+// forward faz a requisição à API pelo RateLimitClient, que espera a vez,
+// respeita o Retry-After e tenta de novo antes de devolver.
+func (p *cacheProxy) forward(r *http.Request, target string, body []byte) (*proxyEntry, error) {
+	// sem o contexto do cliente: a resposta serve também às requisições
+	// juntadas a esta, mesmo que quem a pediu desista
+	req, err := http.NewRequestWithContext(context.WithoutCancel(r.Context()), r.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	stripHopHeaders(req.Header)
+	req.Header.Del("Cache-Control")
+
+	p.upstreamRequests.Add(1)
+	resp, err := p.rl.Do(req)
+	if err != nil {
+		p.upstreamErrors.Add(1)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		p.upstreamErrors.Add(1)
+		return nil, err
+	}
+	header := resp.Header.Clone()
+	stripHopHeaders(header)
+	header.Del("Content-Length")
+	return &proxyEntry{status: resp.StatusCode, header: header, body: data, stored: time.Now()}, nil
+}
+
+func (p *cacheProxy) reply(w http.ResponseWriter, r *http.Request, entry *proxyEntry, err error, result string) {
+	if err != nil {
+		log.Printf("Proxy: %s %s: %v", r.Method, r.URL.Path, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("X-Cache", result)
+	if result == "HIT" {
+		w.Header().Set("Age", fmt.Sprint(int(time.Since(entry.stored).Seconds())))
+	}
+	w.WriteHeader(entry.status)
+	if r.Method != http.MethodHead {
+		w.Write(entry.body)
+	}
+}
+
+func (p *cacheProxy) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	metric := func(name, help, kind string) {
+		fmt.Fprintf(&b, "# HELP apirequester_proxy_%s %s\n# TYPE apirequester_proxy_%s %s\n", name, help, name, kind)
+	}
+
+	metric("requests_total", "Requisições recebidas pelo proxy, por resultado do cache.", "counter")
+	fmt.Fprintf(&b, "apirequester_proxy_requests_total{result=\"hit\"} %d\n", p.hits.Load())
+	fmt.Fprintf(&b, "apirequester_proxy_requests_total{result=\"miss\"} %d\n", p.misses.Load())
+	fmt.Fprintf(&b, "apirequester_proxy_requests_total{result=\"coalesced\"} %d\n", p.coalesced.Load())
+	fmt.Fprintf(&b, "apirequester_proxy_requests_total{result=\"bypass\"} %d\n", p.bypassed.Load())
+	metric("upstream_requests_total", "Requisições repassadas à API (sem contar as novas tentativas).", "counter")
+	fmt.Fprintf(&b, "apirequester_proxy_upstream_requests_total %d\n", p.upstreamRequests.Load())
+	metric("upstream_errors_total", "Requisições à API que falharam depois das novas tentativas.", "counter")
+	fmt.Fprintf(&b, "apirequester_proxy_upstream_errors_total %d\n", p.upstreamErrors.Load())
+
+	entries, size, evictions := p.cache.stats()
+	metric("cache_entries", "Respostas em cache.", "gauge")
+	fmt.Fprintf(&b, "apirequester_proxy_cache_entries %d\n", entries)
+	metric("cache_bytes", "Bytes das respostas em cache.", "gauge")
+	fmt.Fprintf(&b, "apirequester_proxy_cache_bytes %d\n", size)
+	metric("cache_evictions_total", "Respostas tiradas do cache para caber em -proxy-cache-max.", "counter")
+	fmt.Fprintf(&b, "apirequester_proxy_cache_evictions_total %d\n", evictions)
+	metric("rate", "Taxa atual do rate limiter (req/s).", "gauge")
+	fmt.Fprintf(&b, "apirequester_proxy_rate %d\n", p.rl.CurrentRate())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	io.WriteString(w, b.String())
+}
+
+type proxyEntry struct {
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+// storable diz se a resposta pode ir para o cache: só 200, e não quando a
+// API pede no-store (Cache-Control private vale, já que a chave tem a
+// credencial).
+func (e *proxyEntry) storable() bool {
+	return e.status == http.StatusOK && !strings.Contains(e.header.Get("Cache-Control"), "no-store")
+}
+
+func (e *proxyEntry) size() int64 {
+	n := int64(len(e.body))
+	for name, values := range e.header {
+		n += int64(len(name))
+		for _, v := range values {
+			n += int64(len(v))
+		}
+	}
+	return n
+}
+
+// proxyCache guarda as respostas em memória até -proxy-ttl, tirando as
+// usadas há mais tempo quando passa de max bytes.
+type proxyCache struct {
+	max int64
+
+	mu        sync.Mutex
+	order     *list.List
+	items     map[string]*list.Element
+	size      int64
+	evictions int64
+}
+
+type proxyItem struct {
+	key   string
+	entry *proxyEntry
+}
+
+func newProxyCache(max int64) *proxyCache {
+	return &proxyCache{max: max, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *proxyCache) get(key string) (*proxyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*proxyItem).entry
+	if time.Since(entry.stored) > *proxyTTL {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+func (c *proxyCache) put(key string, entry *proxyEntry) {
+	size := entry.size()
+	if c.max > 0 && size > c.max {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&proxyItem{key: key, entry: entry})
+	c.size += size
+	for c.max > 0 && c.size > c.max {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+func (c *proxyCache) remove(el *list.Element) {
+	item := el.Value.(*proxyItem)
+	c.order.Remove(el)
+	delete(c.items, item.key)
+	c.size -= item.entry.size()
+}
+
+func (c *proxyCache) stats() (entries int, size, evictions int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items), c.size, c.evictions
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+var capabilitiesJSON = flag.Bool("json", false, "capabilities: lista em JSON, para ferramentas")
+
+func init() {
+	commands["capabilities"] = capabilitiesCommand
+}
+
+const capabilitiesUsage = "uso: api-requester capabilities [-json]"
+
+// capabilities é o que este binário suporta, para que a plataforma valide
+// um -config contra a versão instalada antes de publicá-lo.
+type capabilities struct {
+	Version       string              `json:"version"`
+	Platform      string              `json:"platform"`
+	SchemaVersion int                 `json:"schema_version"`
+	Commands      []string            `json:"commands"`
+	Auth          []string            `json:"auth"`
+	Sinks         []string            `json:"sinks"`
+	Pagination    map[string][]string `json:"pagination"`
+	Config        map[string][]string `json:"config"`
+}
+
+func currentCapabilities() capabilities {
+	return capabilities{
+		Version:       version,
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		SchemaVersion: configSchemaVersion,
+		Commands:      sortedKeys(commands),
+		Auth:          supportedAuths(),
+		Sinks:         sortedKeys(sinkTypes),
+		Pagination:    map[string][]string{"modes": paginateModes, "streams": paginateStreams},
+		Config: map[string][]string{
+			"top_level": jsonFields(reflect.TypeFor[MultiJobConfig]()),
+			"job":       jsonFields(reflect.TypeFor[JobConfig]()),
+			"defaults":  jsonFields(reflect.TypeFor[JobSettings]()),
+		},
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// jsonFields lista os campos JSON de t, incluindo os das structs embutidas.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Anonymous {
+			names = append(names, jsonFields(f.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// capabilitiesCommand lista os tipos de auth, sinks, modos de paginação e
+// campos de configuração suportados, em texto ou, com --json, para
+// ferramentas.
+func capabilitiesCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf(capabilitiesUsage)
+	}
+
+	c := currentCapabilities()
+	if *capabilitiesJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	fmt.Printf("api-requester %s (%s), schema_version %d\n", c.Version, c.Platform, c.SchemaVersion)
+	fmt.Printf("comandos:   %s\n", strings.Join(c.Commands, ", "))
+	fmt.Printf("auth:       %s\n", strings.Join(c.Auth, ", "))
+	fmt.Printf("sinks:      %s\n", strings.Join(c.Sinks, ", "))
+	fmt.Printf("paginação:  %s (stream: %s)\n", strings.Join(c.Pagination["modes"], ", "), strings.Join(c.Pagination["streams"], ", "))
+	fmt.Printf("campos de job: %s\n", strings.Join(c.Config["job"], ", "))
+	return nil
+}
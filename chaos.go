@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"apiconsume/utils"
+)
+
+var (
+	chaosSpec = flag.String("chaos", "", "modo de teste: injeta falhas nas requisições, ex: \"delay=0.2:3s,drop=0.05,429=0.1,500=0.05\"")
+	chaosSeed = flag.Uint64("chaos-seed", 0, "semente do -chaos, para repetir a mesma sequência de falhas (0 = aleatória)")
+)
+
+var chaos *utils.ChaosTransport
+
+// configureChaos monta o transporte de falhas usado pelos clients das
+// requisições dos jobs; tokens, filas e sinks não passam por ele.
+func configureChaos() error {
+	if *chaosSpec == "" {
+		return nil
+	}
+	var err error
+	if chaos, err = utils.ParseChaos(*chaosSpec, *chaosSeed); err != nil {
+		return err
+	}
+	log.Printf("AVISO: modo chaos ativo (%s); não use em produção", chaos)
+	return nil
+}
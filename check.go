@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	commands["check"] = checkCommand
+}
+
+const checkTimeout = 10 * time.Second
+
+// endpointCheck é o resultado das verificações de um job: cada etapa é
+// "ok", "FALHA" ou "-" (não se aplica, ou não chegou a rodar).
+type endpointCheck struct {
+	Job, Host      string
+	DNS, TLS, Auth string
+	Detail         []string
+}
+
+func (c *endpointCheck) failed() bool {
+	return c.DNS == "FALHA" || c.TLS == "FALHA" || c.Auth == "FALHA"
+}
+
+// checkCommand verifica, depois de um deploy, se cada job de -config
+// alcança o provedor: resolve o host, abre a conexão TLS validando a
+// cadeia do certificado e faz uma requisição barata (HEAD, sem retries) já
+// autenticada. Sai com erro se algum job falhou.
+func checkCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("uso: api-requester check -config jobs.json [-tags ...]")
+	}
+	if *jobConfigPath == "" {
+		return fmt.Errorf("informe -config")
+	}
+	cfg, err := loadJobConfig(*jobConfigPath)
+	if err != nil {
+		return err
+	}
+	if cfg, err = selectTagged(cfg); err != nil {
+		return err
+	}
+
+	defaults := flagSettings().merge(cfg.Defaults)
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tHOST\tDNS\tTLS\tAUTH\tDETALHE")
+	for _, job := range cfg.Jobs {
+		c := checkEndpoint(ctx, job, defaults.merge(job.JobSettings))
+		if c.failed() {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Job, c.Host, c.DNS, c.TLS, c.Auth, strings.Join(c.Detail, "; "))
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d de %d jobs falharam na verificação", failed, len(cfg.Jobs))
+	}
+	return nil
+}
+
+func checkEndpoint(ctx context.Context, job JobConfig, settings JobSettings) endpointCheck {
+	c := endpointCheck{Job: job.Name, DNS: "-", TLS: "-", Auth: "-"}
+	fail := func(step *string, format string, a ...any) endpointCheck {
+		*step = "FALHA"
+		c.Detail = append(c.Detail, fmt.Sprintf(format, a...))
+		return c
+	}
+
+	job, rawURL := job.localize(settings, job.requestURL())
+	job, rawURL = job.versioned(rawURL)
+	spec, err := job.render(rawURL, templateVars{})
+	if err != nil {
+		return fail(&c.DNS, "template da requisição: %v", err)
+	}
+	u, err := url.Parse(spec.URL)
+	if err != nil || u.Hostname() == "" {
+		return fail(&c.DNS, "URL inválida: %s", spec.URL)
+	}
+	c.Host = u.Host
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return fail(&c.DNS, "DNS: %v", err)
+	}
+	c.DNS = "ok"
+	c.Detail = append(c.Detail, fmt.Sprintf("%d endereços", len(addrs)))
+
+	if u.Scheme == "https" {
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		d := tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+		if err != nil {
+			return fail(&c.TLS, "TLS: %v", err)
+		}
+		state := conn.(*tls.Conn).ConnectionState()
+		conn.Close()
+		c.TLS = "ok"
+		if len(state.PeerCertificates) > 0 {
+			days := int(time.Until(state.PeerCertificates[0].NotAfter).Hours() / 24)
+			c.Detail = append(c.Detail, fmt.Sprintf("certificado expira em %d dias", days))
+		}
+	}
+
+	status, elapsed, err := probeEndpoint(ctx, job, settings, spec)
+	switch {
+	case err != nil:
+		return fail(&c.Auth, "requisição: %v", err)
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return fail(&c.Auth, "status %d: credenciais recusadas", status)
+	case status >= 500:
+		return fail(&c.Auth, "status %d do provedor", status)
+	}
+	c.Auth = "ok"
+	c.Detail = append(c.Detail, fmt.Sprintf("status %d em %v", status, elapsed.Round(time.Millisecond)))
+	return c
+}
+
+// probeEndpoint faz um HEAD autenticado, sem retries, na URL do job; se o
+// provedor não aceita HEAD, um GET.
+func probeEndpoint(ctx context.Context, job JobConfig, settings JobSettings, spec requestSpec) (int, time.Duration, error) {
+	rl := job.rateClient(settings)
+	rl.MaxRetries = 0
+
+	started := time.Now()
+	status := 0
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, spec.URL, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		for k, v := range spec.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := rl.Do(req)
+		if err != nil && resp == nil {
+			return 0, 0, err
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+	return status, time.Since(started), nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+)
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{}
+
+func runCommand(name string, cmd command) {
+	ctx, cancel := setupRuntime()
+	defer cancel()
+
+	if err := cmd(ctx, flag.Args()); err != nil {
+		cancel()
+		log.Printf("Erro em %s: %v", name, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// maxDiffLines é quantas diferenças o log mostra por escrita.
+const maxDiffLines = 20
+
+// CompareConfig lê o recurso remoto (ReadURL, ou a própria URL) antes da
+// escrita do job e só a envia se o payload muda algo nele: cada campo do
+// body (recursivamente, nos objetos) é comparado com o mesmo campo do
+// recurso, e campos que só o servidor tem (id, created_at) não contam.
+// Ignore lista os campos do payload fora da comparação (ex: $.updated_at).
+// As diferenças vão para o log; sem nenhuma, a escrita não é feita e a
+// saída do job é o recurso lido.
+type CompareConfig struct {
+	ReadURL string   `json:"read_url,omitempty"`
+	Ignore  []string `json:"ignore,omitempty"`
+}
+
+func (c *CompareConfig) validate(job JobConfig) error {
+	switch strings.ToUpper(job.Method) {
+	case http.MethodPut, http.MethodPatch:
+	case http.MethodPost:
+		if c.ReadURL == "" {
+			return fmt.Errorf("com method POST, read_url é obrigatório")
+		}
+	default:
+		return fmt.Errorf("exige method PUT, PATCH ou POST")
+	}
+	if job.Body == "" {
+		return fmt.Errorf("exige body")
+	}
+	if job.BulkInput != "" || job.Paginate != nil || job.Download != nil || job.Tenants != nil {
+		return fmt.Errorf("não se aplica a bulk_input, paginate, download nem tenants")
+	}
+	for _, p := range c.Ignore {
+		if _, err := utils.ParseJSONPath(p); err != nil {
+			return fmt.Errorf("ignore: %w", err)
+		}
+	}
+	return nil
+}
+
+// diff lista o que a escrita de payload mudaria em current, um campo por
+// linha.
+func (c *CompareConfig) diff(current any, payload []byte) ([]string, error) {
+	var intended any
+	if err := json.Unmarshal(payload, &intended); err != nil {
+		return nil, fmt.Errorf("compare exige body JSON: %w", err)
+	}
+	ignore := make(map[string]bool, len(c.Ignore))
+	for _, p := range c.Ignore {
+		if !strings.HasPrefix(p, "$") {
+			p = "$." + p
+		}
+		ignore[p] = true
+	}
+	var out []string
+	payloadDiff("$", current, intended, ignore, &out)
+	return out, nil
+}
+
+func payloadDiff(path string, current, intended any, ignore map[string]bool, out *[]string) {
+	if ignore[path] {
+		return
+	}
+	if want, ok := intended.(map[string]any); ok {
+		if have, ok := current.(map[string]any); ok {
+			keys := make([]string, 0, len(want))
+			for k := range want {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if _, exists := have[k]; !exists && !ignore[path+"."+k] {
+					*out = append(*out, fmt.Sprintf("%s.%s: ausente → %s", path, k, compactJSON(want[k])))
+					continue
+				}
+				payloadDiff(path+"."+k, have[k], want[k], ignore, out)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(current, intended) {
+		*out = append(*out, fmt.Sprintf("%s: %s → %s", path, compactJSON(current), compactJSON(intended)))
+	}
+}
+
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(data) > 80 {
+		return string(data[:77]) + "..."
+	}
+	return string(data)
+}
+
+// unchanged diz se a escrita de spec pode ser pulada, logando as
+// diferenças quando não pode.
+func (c *CompareConfig) unchanged(job JobConfig, current any, spec requestSpec) (bool, error) {
+	changes, err := c.diff(current, spec.Body)
+	if err != nil {
+		return false, err
+	}
+	if len(changes) == 0 {
+		log.Printf("[%s] Compare: o recurso já está como o payload; escrita não enviada", job.Name)
+		return true, nil
+	}
+	log.Printf("[%s] Compare: %d diferenças, enviando a escrita", job.Name, len(changes))
+	for i, line := range changes {
+		if i == maxDiffLines {
+			log.Printf("[%s]   ... e mais %d", job.Name, len(changes)-i)
+			break
+		}
+		log.Printf("[%s]   %s", job.Name, line)
+	}
+	return false, nil
+}
+
+// compareRemote lê o recurso e diz se a escrita do job pode ser pulada;
+// nesse caso, devolve o recurso lido como saída. O recurso fica em
+// {{.Current}} para o body da escrita, como no conditional.
+func compareRemote(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, rawURL string, vars *templateVars, usage *crawlUsage) ([]byte, http.Header, bool, error) {
+	readURL := rawURL
+	if job.Compare.ReadURL != "" {
+		readURL = job.Compare.ReadURL
+	}
+	body, header, current, err := readResource(ctx, rl, job, readURL, *vars, usage)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("leitura do recurso: %w", err)
+	}
+	vars.Current = current
+	spec, err := job.render(rawURL, *vars)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("erro no template da requisição: %w", err)
+	}
+	same, err := job.Compare.unchanged(job, current, spec)
+	if err != nil || !same {
+		return nil, nil, false, err
+	}
+	return body, header, true, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+const defaultConditionalAttempts = 5
+
+// ConditionalConfig faz da escrita do job (PUT, PATCH ou DELETE) um
+// read-modify-write: um GET no recurso (ReadURL, ou a própria URL) traz o
+// estado atual e o ETag, o body é renderizado com o recurso lido em
+// {{.Current}} e a escrita vai com If-Match. Um 412 indica que outro
+// cliente escreveu no meio: o recurso é relido e a escrita refeita, até
+// MaxAttempts vezes.
+type ConditionalConfig struct {
+	ReadURL     string `json:"read_url,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+}
+
+func (c *ConditionalConfig) validate(job JobConfig) error {
+	switch strings.ToUpper(job.Method) {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return fmt.Errorf("exige method PUT, PATCH ou DELETE")
+	}
+	switch {
+	case job.BulkInput != "":
+		return fmt.Errorf("não se aplica a bulk_input")
+	case job.Paginate != nil:
+		return fmt.Errorf("não se aplica a paginate")
+	case job.Download != nil:
+		return fmt.Errorf("não se aplica a download")
+	case c.MaxAttempts < 0:
+		return fmt.Errorf("max_attempts deve ser positivo")
+	}
+	return nil
+}
+
+func (c *ConditionalConfig) attempts() int {
+	if c.MaxAttempts > 0 {
+		return c.MaxAttempts
+	}
+	return defaultConditionalAttempts
+}
+
+// conditionalWrite lê o recurso, escreve com a pré-condição e repete a
+// leitura a cada 412. Nem a leitura nem a escrita passam pelo cache.
+func conditionalWrite(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, rawURL string, vars templateVars, usage *crawlUsage) ([]byte, http.Header, error) {
+	c := job.Conditional
+	readURL := rawURL
+	if c.ReadURL != "" {
+		readURL = c.ReadURL
+	}
+
+	for attempt := 1; ; attempt++ {
+		current, precondition, err := readCurrent(ctx, rl, job, readURL, vars, usage)
+		if err != nil {
+			return nil, nil, fmt.Errorf("leitura do recurso: %w", err)
+		}
+		vars.Current = current
+
+		spec, err := job.render(rawURL, vars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("erro no template da requisição: %w", err)
+		}
+		headers := make(map[string]string, len(spec.Headers)+1)
+		for k, v := range spec.Headers {
+			headers[k] = v
+		}
+		for k, v := range precondition {
+			headers[k] = v
+		}
+		spec.Headers = headers
+
+		if job.Compare != nil {
+			same, err := job.Compare.unchanged(job, current, spec)
+			if err != nil {
+				return nil, nil, err
+			}
+			if same {
+				out, _ := json.Marshal(current)
+				return out, nil, nil
+			}
+		}
+
+		body, header, status, err := sendUncached(ctx, rl, job, spec, usage)
+		if err == nil && status == http.StatusPreconditionFailed {
+			if attempt < c.attempts() {
+				log.Printf("[%s] 412: o recurso mudou desde a leitura; relendo (tentativa %d/%d)", job.Name, attempt+1, c.attempts())
+				continue
+			}
+			return nil, nil, fmt.Errorf("o recurso mudou a cada leitura: escrita desistida após %d tentativas (412)", attempt)
+		}
+		if err != nil || !job.Status.accepts(status) {
+			return nil, nil, requestError(job.ErrorEnvelope, status, body, err)
+		}
+		if job.Status.empty(status) {
+			body = []byte("[]")
+		}
+		return body, header, nil
+	}
+}
+
+// readCurrent devolve o recurso (o JSON decodificado, ou o texto) e o header
+// de pré-condição da escrita: If-Match com o ETag ou, sem ele,
+// If-Unmodified-Since com o Last-Modified.
+func readCurrent(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, readURL string, vars templateVars, usage *crawlUsage) (any, map[string]string, error) {
+	_, header, current, err := readResource(ctx, rl, job, readURL, vars, usage)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var precondition map[string]string
+	if etag := header.Get("ETag"); etag != "" {
+		precondition = map[string]string{"If-Match": etag}
+	} else if modified := header.Get("Last-Modified"); modified != "" {
+		precondition = map[string]string{"If-Unmodified-Since": modified}
+	} else {
+		return nil, nil, fmt.Errorf("a resposta não traz ETag nem Last-Modified; sem eles a escrita não é condicional")
+	}
+	return current, precondition, nil
+}
+
+// readResource faz o GET do recurso e devolve a resposta e o recurso
+// decodificado (o JSON, ou o texto).
+func readResource(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, readURL string, vars templateVars, usage *crawlUsage) ([]byte, http.Header, any, error) {
+	// o body é da escrita e depende do que vai ser lido
+	read := job
+	read.Body = ""
+	spec, err := read.render(readURL, vars)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("erro no template da requisição: %w", err)
+	}
+	spec = requestSpec{Method: http.MethodGet, URL: spec.URL, Headers: spec.Headers}
+
+	body, header, status, err := sendUncached(ctx, rl, job, spec, usage)
+	if err != nil || status != http.StatusOK {
+		return nil, nil, nil, requestError(job.ErrorEnvelope, status, body, err)
+	}
+
+	var current any
+	if err := json.Unmarshal(body, &current); err != nil {
+		current = string(body)
+	}
+	return body, header, current, nil
+}
+
+func sendUncached(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, spec requestSpec, usage *crawlUsage) ([]byte, http.Header, int, error) {
+	resp, err := job.Status.send(ctx, rl, spec)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.Header, resp.StatusCode, err
+	}
+	if err := usage.add(1, 0, int64(len(body))); err != nil {
+		return nil, resp.Header, resp.StatusCode, err
+	}
+	return body, resp.Header, resp.StatusCode, nil
+}
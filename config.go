@@ -0,0 +1,680 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var jobConfigPath = flag.String("config", "", "arquivo JSON com múltiplos jobs (substitui a URL do .env)")
+
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duração deve ser string (ex: \"30s\"): %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// JobSettings usa ponteiros para distinguir "não informado" de zero e
+// permitir herança: flags -> defaults -> job.
+type JobSettings struct {
+	MaxRetries      *int                   `json:"max_retries,omitempty"`
+	BaseBackoff     *Duration              `json:"base_backoff,omitempty"`
+	Rate            *int                   `json:"rate,omitempty"`
+	AttemptTimeout  *Duration              `json:"attempt_timeout,omitempty"`
+	MaxRetryAfter   *Duration              `json:"max_retry_after,omitempty"`
+	Concurrency     *int                   `json:"concurrency,omitempty"`
+	MaxPages        *int                   `json:"max_pages,omitempty"`
+	MaxRecords      *int                   `json:"max_records,omitempty"`
+	MaxBytes        *ByteSize              `json:"max_bytes,omitempty"`
+	MaxDuration     *Duration              `json:"max_duration,omitempty"`
+	CaptureHeaders  []string               `json:"capture_headers,omitempty"`
+	Locale          *LocaleConfig          `json:"locale,omitempty"`
+	BodyRules       []utils.BodyRule       `json:"body_rules,omitempty"`
+	Client          string                 `json:"client,omitempty"`
+	HTMLPages       string                 `json:"html_pages,omitempty"`
+	Bind            string                 `json:"bind,omitempty"`
+	IPFamily        string                 `json:"ip_family,omitempty"`
+	DialFallback    *Duration              `json:"dial_fallback,omitempty"`
+	FreshConnRetry  *bool                  `json:"fresh_conn_retry,omitempty"`
+	DoneWindow      *Duration              `json:"done_window,omitempty"`
+	SlowStart       *Duration              `json:"slow_start,omitempty"`
+	RetryStatus     []int                  `json:"retry_status,omitempty"`
+	Stagger         *Duration              `json:"throttle_stagger,omitempty"`
+	AdaptiveTimeout *AdaptiveTimeoutConfig `json:"adaptive_timeout,omitempty"`
+}
+
+type JobConfig struct {
+	Name            string                 `json:"name"`
+	Provider        string                 `json:"provider,omitempty"`
+	Method          string                 `json:"method,omitempty"`
+	URL             string                 `json:"url"`
+	Headers         map[string]string      `json:"headers,omitempty"`
+	Query           map[string]QueryParam  `json:"query,omitempty"`
+	APIVersion      *APIVersionConfig      `json:"api_version,omitempty"`
+	QueryStyle      string                 `json:"query_style,omitempty"`
+	DateParam       *string                `json:"date_param,omitempty"`
+	Body            string                 `json:"body,omitempty"`
+	BulkInput       string                 `json:"bulk_input,omitempty"`
+	OpenAPI         *OpenAPIRef            `json:"openapi,omitempty"`
+	Quality         *utils.QualityConfig   `json:"quality,omitempty"`
+	Dedup           *DedupConfig           `json:"dedup,omitempty"`
+	Watermark       *WatermarkConfig       `json:"watermark,omitempty"`
+	Publish         *PublishConfig         `json:"publish,omitempty"`
+	SignURL         *SignURLConfig         `json:"sign_url,omitempty"`
+	Auth            *AuthConfig            `json:"auth,omitempty"`
+	Sinks           []SinkConfig           `json:"sinks,omitempty"`
+	Paginate        *PaginateConfig        `json:"paginate,omitempty"`
+	Enrich          *EnrichConfig          `json:"enrich,omitempty"`
+	Project         *ProjectConfig         `json:"project,omitempty"`
+	Sort            *SortConfig            `json:"sort,omitempty"`
+	Download        *DownloadConfig        `json:"download,omitempty"`
+	Conditional     *ConditionalConfig     `json:"conditional,omitempty"`
+	Critical        bool                   `json:"critical,omitempty"`
+	Optional        bool                   `json:"optional,omitempty"`
+	Production      bool                   `json:"production,omitempty"`
+	AuditSafe       bool                   `json:"audit_safe,omitempty"`
+	Tags            []string               `json:"tags,omitempty"`
+	Group           string                 `json:"group,omitempty"`
+	Priority        string                 `json:"priority,omitempty"`
+	Status          *StatusConfig          `json:"status,omitempty"`
+	ErrorEnvelope   *ErrorEnvelope         `json:"error_envelope,omitempty"`
+	ResponseHeaders *ResponseHeadersConfig `json:"response_headers,omitempty"`
+	SLO             *SLOConfig             `json:"slo,omitempty"`
+	Decrypt         *DecryptConfig         `json:"decrypt,omitempty"`
+	Verify          *VerifyConfig          `json:"verify,omitempty"`
+	Quarantine      *QuarantineConfig      `json:"quarantine,omitempty"`
+	Delta           *DeltaConfig           `json:"delta,omitempty"`
+	Stale           *StaleConfig           `json:"stale,omitempty"`
+	Filter          *FilterConfig          `json:"filter,omitempty"`
+	Tenants         *TenantsConfig         `json:"tenants,omitempty"`
+	Details         *DetailsConfig         `json:"details,omitempty"`
+	Compare         *CompareConfig         `json:"compare,omitempty"`
+	Provenance      *ProvenanceConfig      `json:"provenance,omitempty"`
+	NonEmpty        *NonEmptyConfig        `json:"non_empty,omitempty"`
+	LintIgnore      []string               `json:"lint_ignore,omitempty"`
+	JobSettings
+
+	schema  *utils.JSONSchema
+	signer  *utils.URLSigner
+	auth    utils.AuthProvider
+	decrypt utils.Decryptor
+	verify  *utils.SignatureVerifier
+	sinks   []sink
+	// janelas de manutenção do provedor do job
+	maintenance []*MaintenanceConfig
+	dialect     *utils.RateDialect
+	pacer       *utils.RateLimitClient
+	// registros em quarentena na execução atual
+	rejects *rejectLog
+	// execução de -config que roda o job (vazio nos gatilhos)
+	runID string
+}
+
+func (job JobConfig) spec(url string) requestSpec {
+	spec := requestSpec{Method: job.Method, URL: url, Headers: job.Headers}
+	if job.Body != "" {
+		spec.Body = []byte(job.Body)
+	}
+	return spec
+}
+
+// requestURL é a URL do job com o parâmetro da data do dia: dataBase, ou o
+// nome em date_param ("" desliga).
+func (job JobConfig) requestURL() string {
+	switch {
+	case job.DateParam == nil:
+		return buildURL(job.URL)
+	case *job.DateParam == "":
+		return job.URL
+	}
+	return buildDateURL(job.URL, *job.DateParam)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// configSchemaVersion é a versão do formato de -config que este binário
+// entende; um arquivo com schema_version maior é recusado, em vez de ter
+// os campos novos ignorados em silêncio.
+const configSchemaVersion = 1
+
+type MultiJobConfig struct {
+	SchemaVersion int          `json:"schema_version,omitempty"`
+	Defaults      JobSettings  `json:"defaults"`
+	Jobs          []JobConfig  `json:"jobs"`
+	Joins         []JoinConfig `json:"joins,omitempty"`
+
+	Groups      map[string]GroupConfig `json:"groups,omitempty"`
+	Maintenance []MaintenanceConfig    `json:"maintenance,omitempty"`
+	Schedules   []ScheduleConfig       `json:"schedules,omitempty"`
+}
+
+func loadJobConfig(path string) (*MultiJobConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler configuração: %w", err)
+	}
+
+	var cfg MultiJobConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("configuração inválida: %w", err)
+	}
+
+	if cfg.SchemaVersion > configSchemaVersion {
+		return nil, fmt.Errorf("configuração com schema_version %d; este binário (%s) entende até a %d", cfg.SchemaVersion, version, configSchemaVersion)
+	}
+	if len(cfg.Jobs) == 0 {
+		return nil, fmt.Errorf("nenhum job definido")
+	}
+	if _, err := utils.CompileBodyRules(cfg.Defaults.BodyRules); err != nil {
+		return nil, fmt.Errorf("defaults: body_rules: %w", err)
+	}
+	if err := validateRetryStatus(cfg.Defaults.RetryStatus); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	if err := validateClientMode(cfg.Defaults.Client); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	if err := validateHTMLPages(cfg.Defaults.HTMLPages); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	if err := cfg.Defaults.AdaptiveTimeout.validate(); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	if err := cfg.Defaults.dialOptions().validate(); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for i, job := range cfg.Jobs {
+		if job.Name == "" {
+			return nil, fmt.Errorf("job #%d sem nome", i+1)
+		}
+		if bulkFileName(job.Name) != "response-"+job.Name+".json" {
+			return nil, fmt.Errorf("nome de job %q inválido: use letras, números, '-', '_' ou '.'", job.Name)
+		}
+		if seen[job.Name] {
+			return nil, fmt.Errorf("job %q duplicado", job.Name)
+		}
+		seen[job.Name] = true
+		if job.OpenAPI != nil {
+			if err := resolveOpenAPIJob(&cfg.Jobs[i], filepath.Dir(path)); err != nil {
+				return nil, fmt.Errorf("job %q: %w", job.Name, err)
+			}
+			job = cfg.Jobs[i]
+		}
+		if job.Provider != "" {
+			if err := applyProvider(&cfg.Jobs[i]); err != nil {
+				return nil, fmt.Errorf("job %q: %w", job.Name, err)
+			}
+			job = cfg.Jobs[i]
+		}
+		if job.URL == "" {
+			return nil, fmt.Errorf("job %q sem url", job.Name)
+		}
+		if err := job.validateQuery(); err != nil {
+			return nil, fmt.Errorf("job %q: query: %w", job.Name, err)
+		}
+		if job.APIVersion != nil {
+			if err := job.APIVersion.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: api_version: %w", job.Name, err)
+			}
+		}
+		if job.Quality != nil {
+			if err := job.Quality.Validate(); err != nil {
+				return nil, fmt.Errorf("job %q: quality: %w", job.Name, err)
+			}
+		}
+		if job.Dedup != nil {
+			if err := job.Dedup.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: dedup: %w", job.Name, err)
+			}
+		}
+		if job.Paginate != nil {
+			if err := job.Paginate.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: %w", job.Name, err)
+			}
+			if job.BulkInput != "" {
+				return nil, fmt.Errorf("job %q: paginate não se aplica a bulk_input", job.Name)
+			}
+			if other := job.Paginate.streamConflict(job); job.Paginate.Stream != "" && other != "" {
+				return nil, fmt.Errorf("job %q: paginate.stream não se aplica com %s", job.Name, other)
+			}
+		}
+		if job.Enrich != nil {
+			if err := job.Enrich.validate(filepath.Dir(path)); err != nil {
+				return nil, fmt.Errorf("job %q: enrich: %w", job.Name, err)
+			}
+			if job.Dedup != nil && job.Enrich.Records != "" {
+				return nil, fmt.Errorf("job %q: enrich: com dedup a saída já é o array de registros; omita enrich.records", job.Name)
+			}
+		}
+		if job.Quarantine != nil {
+			if err := job.Quarantine.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: quarantine: %w", job.Name, err)
+			}
+		}
+		if job.Delta != nil {
+			if err := job.Delta.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: delta: %w", job.Name, err)
+			}
+			if job.Dedup != nil && job.Delta.Records != "" {
+				return nil, fmt.Errorf("job %q: delta: com dedup a saída já é o array de registros; omita delta.records", job.Name)
+			}
+		}
+		if job.Project != nil {
+			if err := job.Project.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: project: %w", job.Name, err)
+			}
+			if job.Dedup != nil && job.Project.Records != "" {
+				return nil, fmt.Errorf("job %q: project: com dedup a saída já é o array de registros; omita project.records", job.Name)
+			}
+		}
+		if job.Sort != nil {
+			if err := job.Sort.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: sort: %w", job.Name, err)
+			}
+			if job.Dedup != nil && job.Sort.Records != "" {
+				return nil, fmt.Errorf("job %q: sort: com dedup a saída já é o array de registros; omita sort.records", job.Name)
+			}
+		}
+		if _, err := job.priority(); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if job.Filter != nil {
+			if err := job.Filter.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: filter: %w", job.Name, err)
+			}
+		}
+		if job.NonEmpty != nil {
+			if err := job.NonEmpty.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: non_empty: %w", job.Name, err)
+			}
+		}
+		if job.Stale != nil {
+			if err := job.Stale.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: stale: %w", job.Name, err)
+			}
+		}
+		if job.Details != nil {
+			if err := job.Details.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: details: %w", job.Name, err)
+			}
+			if job.Dedup != nil && job.Details.Records != "" {
+				return nil, fmt.Errorf("job %q: details: com dedup a saída já é o array de registros; omita details.records", job.Name)
+			}
+		}
+		if job.Tenants != nil {
+			if err := job.Tenants.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: tenants: %w", job.Name, err)
+			}
+		}
+		if job.Optional && job.isCritical() {
+			return nil, fmt.Errorf("job %q: optional não combina com critical", job.Name)
+		}
+		if _, err := utils.CompileBodyRules(job.BodyRules); err != nil {
+			return nil, fmt.Errorf("job %q: body_rules: %w", job.Name, err)
+		}
+		if err := validateClientMode(job.Client); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if err := validateRetryStatus(job.RetryStatus); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if err := validateHTMLPages(job.HTMLPages); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if err := job.AdaptiveTimeout.validate(); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if err := cfg.Defaults.merge(job.JobSettings).dialOptions().validate(); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if job.ResponseHeaders != nil {
+			if err := job.ResponseHeaders.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: response_headers: %w", job.Name, err)
+			}
+		}
+		if job.SLO != nil {
+			if err := job.SLO.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: slo: %w", job.Name, err)
+			}
+		}
+		if job.ErrorEnvelope != nil {
+			if err := job.ErrorEnvelope.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: error_envelope: %w", job.Name, err)
+			}
+		}
+		if job.Status != nil {
+			if err := job.Status.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: status: %w", job.Name, err)
+			}
+		}
+		if job.Download != nil {
+			if err := job.Download.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: %w", job.Name, err)
+			}
+		}
+		if job.Conditional != nil {
+			if err := job.Conditional.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: conditional: %w", job.Name, err)
+			}
+		}
+		if job.Compare != nil {
+			if err := job.Compare.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: compare: %w", job.Name, err)
+			}
+		}
+		if job.Provenance != nil {
+			if err := job.Provenance.validate(job); err != nil {
+				return nil, fmt.Errorf("job %q: provenance: %w", job.Name, err)
+			}
+		}
+		if err := validateLintIgnore(job.LintIgnore); err != nil {
+			return nil, fmt.Errorf("job %q: lint_ignore: %w", job.Name, err)
+		}
+		if job.Watermark != nil {
+			if err := job.Watermark.validate(); err != nil {
+				return nil, fmt.Errorf("job %q: watermark: %w", job.Name, err)
+			}
+		}
+		if job.SignURL != nil {
+			signer, err := job.SignURL.signer()
+			if err != nil {
+				return nil, fmt.Errorf("job %q: sign_url: %w", job.Name, err)
+			}
+			cfg.Jobs[i].signer = signer
+		}
+		if job.Auth != nil {
+			auth, err := job.Auth.provider()
+			if err != nil {
+				return nil, fmt.Errorf("job %q: auth: %w", job.Name, err)
+			}
+			cfg.Jobs[i].auth = auth
+		}
+		if job.Decrypt != nil {
+			d, err := job.Decrypt.decryptor(filepath.Dir(path))
+			if err != nil {
+				return nil, fmt.Errorf("job %q: decrypt: %w", job.Name, err)
+			}
+			cfg.Jobs[i].decrypt = d
+		}
+		if job.Verify != nil {
+			v, err := job.Verify.verifier(filepath.Dir(path))
+			if err != nil {
+				return nil, fmt.Errorf("job %q: verify: %w", job.Name, err)
+			}
+			cfg.Jobs[i].verify = v
+		}
+		if len(job.Sinks) > 0 {
+			sinks, err := buildSinks(job.Sinks, filepath.Dir(path))
+			if err != nil {
+				return nil, fmt.Errorf("job %q: %w", job.Name, err)
+			}
+			cfg.Jobs[i].sinks = sinks
+			if job.Download != nil {
+				for j, s := range sinks {
+					if _, ok := s.(streamSink); !ok {
+						return nil, fmt.Errorf("job %q: sink #%d (%s) não aceita download; use upload", job.Name, j+1, job.Sinks[j].Type)
+					}
+				}
+			}
+		}
+	}
+
+	if err := validateGroups(&cfg); err != nil {
+		return nil, err
+	}
+	if err := assignMaintenance(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateSchedules(&cfg); err != nil {
+		return nil, err
+	}
+
+	jobs := make(map[string]JobConfig, len(cfg.Jobs))
+	for _, job := range cfg.Jobs {
+		jobs[job.Name] = job
+	}
+	for i := range cfg.Joins {
+		if err := cfg.Joins[i].validate(jobs); err != nil {
+			return nil, fmt.Errorf("join #%d: %w", i+1, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (s JobSettings) merge(over JobSettings) JobSettings {
+	if over.MaxRetries != nil {
+		s.MaxRetries = over.MaxRetries
+	}
+	if over.BaseBackoff != nil {
+		s.BaseBackoff = over.BaseBackoff
+	}
+	if over.Rate != nil {
+		s.Rate = over.Rate
+	}
+	if over.AttemptTimeout != nil {
+		s.AttemptTimeout = over.AttemptTimeout
+	}
+	if over.MaxRetryAfter != nil {
+		s.MaxRetryAfter = over.MaxRetryAfter
+	}
+	if over.Concurrency != nil {
+		s.Concurrency = over.Concurrency
+	}
+	if over.MaxPages != nil {
+		s.MaxPages = over.MaxPages
+	}
+	if over.MaxRecords != nil {
+		s.MaxRecords = over.MaxRecords
+	}
+	if over.MaxBytes != nil {
+		s.MaxBytes = over.MaxBytes
+	}
+	if over.MaxDuration != nil {
+		s.MaxDuration = over.MaxDuration
+	}
+	if over.CaptureHeaders != nil {
+		s.CaptureHeaders = over.CaptureHeaders
+	}
+	if over.BodyRules != nil {
+		s.BodyRules = over.BodyRules
+	}
+	if over.Locale != nil {
+		s.Locale = over.Locale
+	}
+	if over.Client != "" {
+		s.Client = over.Client
+	}
+	if over.HTMLPages != "" {
+		s.HTMLPages = over.HTMLPages
+	}
+	if over.Bind != "" {
+		s.Bind = over.Bind
+	}
+	if over.IPFamily != "" {
+		s.IPFamily = over.IPFamily
+	}
+	if over.DialFallback != nil {
+		s.DialFallback = over.DialFallback
+	}
+	if over.FreshConnRetry != nil {
+		s.FreshConnRetry = over.FreshConnRetry
+	}
+	if over.DoneWindow != nil {
+		s.DoneWindow = over.DoneWindow
+	}
+	if over.SlowStart != nil {
+		s.SlowStart = over.SlowStart
+	}
+	if over.RetryStatus != nil {
+		s.RetryStatus = over.RetryStatus
+	}
+	if over.Stagger != nil {
+		s.Stagger = over.Stagger
+	}
+	if over.AdaptiveTimeout != nil {
+		s.AdaptiveTimeout = over.AdaptiveTimeout
+	}
+	return s
+}
+
+func flagSettings() JobSettings {
+	return JobSettings{
+		AttemptTimeout:  &Duration{*attemptTimeout},
+		MaxRetryAfter:   &Duration{*maxRetryAfter},
+		Concurrency:     bulkMaxWorkers,
+		MaxPages:        maxPages,
+		MaxRecords:      maxRecords,
+		MaxBytes:        &maxBytes,
+		MaxDuration:     &Duration{*maxDuration},
+		CaptureHeaders:  captureHeaderNames(),
+		HTMLPages:       *htmlPages,
+		Bind:            *bindAddr,
+		IPFamily:        *ipFamily,
+		DialFallback:    &Duration{*dialFallback},
+		FreshConnRetry:  freshConnRetry,
+		DoneWindow:      &Duration{*doneWindow},
+		SlowStart:       &Duration{*slowStart},
+		RetryStatus:     retryStatus,
+		Stagger:         &Duration{*staggerStep},
+		AdaptiveTimeout: adaptiveTimeoutFlag(),
+	}
+}
+
+func newRateClient(s JobSettings) *utils.RateLimitClient {
+	rl := utils.NewRateLimitClient()
+	rl.RateCalendar = rateCal
+	rl.Bandwidth = bandwidth
+	if chaos != nil {
+		rl.Client.Transport = chaos
+	}
+	if o := s.dialOptions(); o.custom() {
+		rl.Client.Transport = dialTransport(o)
+		if chaos != nil {
+			rl.Client.Transport = chaos.Over(rl.Client.Transport)
+		}
+	}
+
+	if s.MaxRetries != nil {
+		rl.MaxRetries = *s.MaxRetries
+	}
+	if s.BaseBackoff != nil {
+		rl.BaseBackoff = s.BaseBackoff.Duration
+	}
+	if s.Rate != nil && *s.Rate > 0 {
+		rl.SafeRate = *s.Rate
+		rl.DynamicRate = *s.Rate
+	}
+	if s.AttemptTimeout != nil {
+		rl.AttemptTimeout = s.AttemptTimeout.Duration
+	}
+	if s.MaxRetryAfter != nil {
+		rl.MaxRetryAfter = s.MaxRetryAfter.Duration
+	}
+	if s.SlowStart != nil {
+		rl.SlowStart, rl.SlowStartIdle = s.SlowStart.Duration, *slowStartIdle
+	}
+	if s.RetryStatus != nil {
+		rl.RetryStatus = s.RetryStatus
+	}
+	if s.Stagger != nil {
+		rl.ThrottleStagger = s.Stagger.Duration
+	}
+	if len(s.CaptureHeaders) > 0 {
+		rl.Capture = utils.NewHeaderCapture(s.CaptureHeaders)
+	}
+	// as regras já foram validadas em loadJobConfig
+	rl.BodyRules, _ = utils.CompileBodyRules(s.BodyRules)
+	rl.HTMLPages = s.HTMLPages
+	rl.WAFCooldown = *wafCooldown
+	rl.ConnDebug = *connDebug
+	if s.FreshConnRetry != nil {
+		rl.FreshRetry = *s.FreshConnRetry
+	}
+	rl.Audit = *audit
+	rl.Usage = &utils.UsageMeter{}
+	rl.Warnings = &utils.WarningLog{}
+	rl.SlowResponse = *warnSlow
+	rl.NearQuota = *warnQuota
+	return rl
+}
+
+func (job JobConfig) rateClient(s JobSettings) *utils.RateLimitClient {
+	rl := newRateClient(s)
+	if s.Client == clientIsolated {
+		rl.Client = job.isolatedHTTPClient(s.dialOptions())
+	}
+	// POST de busca, GraphQL query...: só lê, então roda também no -audit
+	rl.Audit = rl.Audit && !job.AuditSafe
+	rl.Latency = &utils.LatencyRecorder{}
+	rl.URLSigner = job.signer
+	rl.Auth = job.auth
+	rl.Decryptor = job.decrypt
+	rl.Verifier = job.verify
+	rl.Dialect = job.dialect
+	rl.Pacer = job.pacer
+	rl.Version = job.APIVersion.check()
+	job.watchDeprecations(rl)
+	job.applyAdaptiveTimeout(rl, s.AdaptiveTimeout, time.Now())
+	return rl
+}
+
+func marshalJobConfig(cfg *MultiJobConfig) ([]byte, error) {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func appendJobToConfig(path string, job JobConfig) error {
+	var cfg MultiJobConfig
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("configuração existente inválida: %w", err)
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	for _, existing := range cfg.Jobs {
+		if existing.Name == job.Name {
+			return fmt.Errorf("job %q já existe em %s", job.Name, path)
+		}
+	}
+	cfg.Jobs = append(cfg.Jobs, job)
+
+	out, err := marshalJobConfig(&cfg)
+	if err != nil {
+		return err
+	}
+	writeFile(path, out)
+	return nil
+}
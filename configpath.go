@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	configEnv      = "API_REQUESTER_CONFIG"
+	configFileName = "jobs.json"
+)
+
+// version é gravada no build de release (make release), via
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+func init() {
+	commands["version"] = versionCommand
+}
+
+func versionCommand(ctx context.Context, args []string) error {
+	fmt.Printf("api-requester %s (%s/%s)\n", version, runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// discoverConfig resolve o arquivo de jobs quando -config não foi passado:
+// a variável API_REQUESTER_CONFIG, depois api-requester/jobs.json no
+// diretório de configuração do usuário ($XDG_CONFIG_HOME, ~/.config ou
+// %AppData%) e por fim jobs.json no diretório atual. Os dois últimos só
+// valem sem .env no diretório atual, para não trocar o modo de quem já
+// roda pelo .env.
+func discoverConfig() {
+	if *jobConfigPath != "" {
+		return
+	}
+	if path := os.Getenv(configEnv); path != "" {
+		*jobConfigPath = path
+		log.Printf("Configuração de jobs: %s (%s)", path, configEnv)
+		return
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(cwd, ".env")); err == nil {
+		return
+	}
+
+	var candidates []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "api-requester", configFileName))
+	}
+	candidates = append(candidates, filepath.Join(cwd, configFileName))
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			*jobConfigPath = path
+			log.Printf("Configuração de jobs: %s", path)
+			return
+		}
+	}
+}
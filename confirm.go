@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+var assumeYes = flag.Bool("yes", false, "confirma sem perguntar os jobs de produção com DELETE, PUT ou PATCH")
+
+const previewBodyMax = 2048
+
+// isProduction marca os jobs cujas escritas pedem confirmação: production
+// no job ou a tag "production".
+func (job JobConfig) isProduction() bool {
+	return job.Production || job.hasTag("production")
+}
+
+func destructiveMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodDelete, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// confirmDestructive mostra as requisições resolvidas dos jobs de produção
+// com método destrutivo e pede confirmação no terminal; sem terminal, só
+// segue com -yes. Em -audit essas requisições já não saem, a menos que o
+// job seja audit_safe.
+func confirmDestructive(jobs []JobConfig) error {
+	var gated []JobConfig
+	for _, job := range jobs {
+		if job.isProduction() && destructiveMethod(job.Method) && (!*audit || job.AuditSafe) {
+			gated = append(gated, job)
+		}
+	}
+	if len(gated) == 0 || *assumeYes {
+		return nil
+	}
+
+	for _, job := range gated {
+		previewRequest(job)
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("%d job(s) de produção com método destrutivo: confirme com -yes", len(gated))
+	}
+
+	fmt.Fprint(os.Stderr, "Executar estas requisições? [s/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("sem resposta: confirme com -yes")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "s", "sim", "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("execução cancelada")
+}
+
+// previewRequest imprime a requisição como ela sai, com os templates
+// resolvidos quando possível e os valores de headers de credencial
+// ocultos.
+func previewRequest(job JobConfig) {
+	spec, err := job.render(job.requestURL(), templateVars{})
+	if err != nil {
+		spec = job.spec(job.requestURL())
+	}
+
+	w := os.Stderr
+	fmt.Fprintf(w, "\n[%s] %s %s\n", job.Name, strings.ToUpper(spec.Method), spec.URL)
+	names := make([]string, 0, len(spec.Headers))
+	for name := range spec.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := spec.Headers[name]
+		if credentialHeader(name) {
+			value = "***"
+		}
+		fmt.Fprintf(w, "%s: %s\n", name, value)
+	}
+	if job.Auth != nil {
+		fmt.Fprintf(w, "(auth %s)\n", job.Auth.Type)
+	}
+	if len(spec.Body) > 0 {
+		body := string(spec.Body)
+		if len(body) > previewBodyMax {
+			body = body[:previewBodyMax] + "..."
+		}
+		fmt.Fprintf(w, "\n%s\n", body)
+	}
+}
+
+func credentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, marker := range []string{"authorization", "token", "key", "secret", "cookie", "signature"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
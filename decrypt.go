@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// DecryptConfig abre respostas cifradas pelo provedor antes de qualquer
+// validação, para que o response.json gravado seja texto aberto. A chave
+// vem de key (aceita templates, ex: {{env "JWE_KEY"}}), de key_file ou do
+// stdout de key_command (KMS, Vault...), lido uma vez ao carregar a
+// configuração.
+type DecryptConfig struct {
+	Type string `json:"type"`
+
+	// jwe
+	Key        string   `json:"key,omitempty"`
+	KeyFile    string   `json:"key_file,omitempty"`
+	KeyCommand []string `json:"key_command,omitempty"`
+
+	// command: recebe o payload no stdin e devolve o texto aberto no stdout
+	// (ex: gpg --batch --decrypt)
+	Command []string `json:"command,omitempty"`
+
+	// tipos registrados com utils.RegisterDecryptor
+	Options map[string]string `json:"options,omitempty"`
+}
+
+func (c *DecryptConfig) decryptor(baseDir string) (utils.Decryptor, error) {
+	switch c.Type {
+	case "jwe":
+		key, err := c.loadKey(baseDir)
+		if err != nil {
+			return nil, err
+		}
+		return utils.NewJWEDecryptor(key)
+	case "command":
+		if len(c.Command) == 0 {
+			return nil, fmt.Errorf("command exige command (ex: [\"gpg\", \"--batch\", \"--decrypt\"])")
+		}
+		return &utils.CommandDecryptor{Command: c.Command}, nil
+	}
+	if factory, ok := utils.LookupDecryptor(c.Type); ok {
+		options, err := renderMap(c.Options)
+		if err != nil {
+			return nil, err
+		}
+		return factory(options)
+	}
+	types := append([]string{"jwe", "command"}, utils.RegisteredDecryptors()...)
+	return nil, fmt.Errorf("tipo %q não suportado (%s)", c.Type, strings.Join(types, ", "))
+}
+
+func (c *DecryptConfig) loadKey(baseDir string) ([]byte, error) {
+	switch {
+	case c.Key != "":
+		key, err := renderTemplate(c.Key, templateVars{})
+		if err != nil {
+			return nil, err
+		}
+		return []byte(key), nil
+	case c.KeyFile != "":
+		path := c.KeyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		return os.ReadFile(path)
+	case len(c.KeyCommand) > 0:
+		cmd := exec.Command(c.KeyCommand[0], c.KeyCommand[1:]...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("key_command: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return bytes.TrimSpace(out), nil
+	}
+	return nil, fmt.Errorf("informe key, key_file ou key_command")
+}
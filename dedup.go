@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"apiconsume/utils"
+)
+
+type DedupConfig struct {
+	Key       string    `json:"key"`
+	Records   string    `json:"records,omitempty"`
+	Retention *Duration `json:"retention,omitempty"`
+}
+
+const defaultDedupRetention = 30 * 24 * time.Hour
+
+func (c *DedupConfig) validate() error {
+	if c.Key == "" {
+		return fmt.Errorf("dedup.key é obrigatório")
+	}
+	if _, err := utils.ParseJSONPath(c.Key); err != nil {
+		return err
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDedup devolve apenas os registros novos e uma função que persiste as
+// chaves vistas; ela só deve ser chamada depois que a saída foi gravada.
+func applyDedup(job JobConfig, body []byte) ([]byte, func(), error) {
+	cfg := job.Dedup
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, nil, fmt.Errorf("resposta não é JSON válido: %w", err)
+	}
+
+	key, _ := utils.ParseJSONPath(cfg.Key)
+	stateName := "dedup-" + job.Name
+
+	var seen utils.SeenKeys
+	if _, err := stateStore.Load(stateName, &seen); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	records := utils.SelectRecords(doc, cfg.Records)
+	fresh := cfg.filter(&seen, records, key, now)
+
+	out, err := json.Marshal(fresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("[%s] Dedup: %d registros recebidos, %d novos", job.Name, len(records), len(fresh))
+
+	commit := func() {
+		if err := stateStore.Save(stateName, &seen); err != nil {
+			log.Printf("[%s] Erro ao salvar estado de dedup: %v", job.Name, err)
+		}
+	}
+	return out, commit, nil
+}
+
+func (c *DedupConfig) filter(seen *utils.SeenKeys, records []any, key *utils.JSONPath, now time.Time) []any {
+	retention := defaultDedupRetention
+	if c.Retention != nil {
+		retention = c.Retention.Duration
+	}
+	seen.Prune(retention, now)
+	return seen.Filter(records, key, now)
+}
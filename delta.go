@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// DeltaConfig grava, ao lado da saída completa, só o que mudou desde a
+// execução anterior, para consumidores no estilo CDC: registros novos,
+// removidos e alterados, comparados pela chave Key.
+type DeltaConfig struct {
+	Key     string `json:"key"`
+	Records string `json:"records,omitempty"`
+}
+
+func (c *DeltaConfig) validate() error {
+	if c.Key == "" {
+		return fmt.Errorf("key é obrigatório")
+	}
+	if _, err := utils.ParseJSONPath(c.Key); err != nil {
+		return err
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deltaState guarda, por chave, o valor original da chave e o sha256 do
+// registro na última saída publicada.
+type deltaState struct {
+	Records map[string]deltaEntry `json:"records"`
+}
+
+type deltaEntry struct {
+	Key  any    `json:"key"`
+	Hash string `json:"hash"`
+}
+
+// recordDelta é a diferença de uma saída para a anterior, já em NDJSON.
+type recordDelta struct {
+	added, removed, changed bytes.Buffer
+	counts                  [3]int
+	next                    deltaState
+}
+
+// computeDelta compara os registros de out com os da última saída
+// publicada do job. Registros sem a chave ficam fora da comparação.
+func computeDelta(job JobConfig, out []byte) (*recordDelta, error) {
+	c := job.Delta
+	var doc any
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("saída não é JSON válido: %w", err)
+	}
+	var prev deltaState
+	if _, err := stateStore.Load("delta-"+job.Name, &prev); err != nil {
+		return nil, err
+	}
+
+	key, _ := utils.ParseJSONPath(c.Key)
+	d := &recordDelta{next: deltaState{Records: map[string]deltaEntry{}}}
+	added, changed, removed := json.NewEncoder(&d.added), json.NewEncoder(&d.changed), json.NewEncoder(&d.removed)
+	missing := 0
+	for _, rec := range utils.SelectRecords(doc, c.Records) {
+		value, ok := key.First(rec)
+		if !ok || value == nil {
+			missing++
+			continue
+		}
+		data, err := json.Marshal(withoutProvenance(job, rec))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		k, entry := fmt.Sprint(value), deltaEntry{Key: value, Hash: hex.EncodeToString(sum[:])}
+		d.next.Records[k] = entry
+
+		old, seen := prev.Records[k]
+		switch {
+		case !seen:
+			added.Encode(rec)
+			d.counts[0]++
+		case old.Hash != entry.Hash:
+			changed.Encode(rec)
+			d.counts[2]++
+		}
+	}
+	for _, k := range sortedKeys(prev.Records) {
+		if _, still := d.next.Records[k]; !still {
+			removed.Encode(map[string]any{"key": prev.Records[k].Key})
+			d.counts[1]++
+		}
+	}
+	if missing > 0 {
+		log.Printf("[%s] Delta: %d registros sem %s ficaram fora da comparação", job.Name, missing, c.Key)
+	}
+	return d, nil
+}
+
+// publish grava added, removed e changed ao lado da saída (vazios quando
+// nada mudou, para não sobrar o delta de outra execução) e devolve o
+// commit do estado.
+func (d *recordDelta) publish(job JobConfig, outputPath string) func() {
+	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	writeFileWith(base+".added.ndjson", d.added.Bytes(), publishFile)
+	writeFileWith(base+".removed.ndjson", d.removed.Bytes(), publishFile)
+	writeFileWith(base+".changed.ndjson", d.changed.Bytes(), publishFile)
+	log.Printf("[%s] Delta: %d novos, %d removidos, %d alterados", job.Name, d.counts[0], d.counts[1], d.counts[2])
+
+	return func() {
+		if err := stateStore.Save("delta-"+job.Name, d.next); err != nil {
+			log.Printf("[%s] Erro ao salvar estado do delta: %v", job.Name, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var deprecationWebhook = flag.String("deprecation-webhook", "", "URL que recebe um POST JSON quando uma API anuncia Deprecation ou Sunset")
+
+const deprecationAlertTimeout = 10 * time.Second
+
+// deprecationNotifier é implementado pelos sinks que também avisam quando a
+// API do job anuncia descontinuação.
+type deprecationNotifier interface {
+	NotifyDeprecation(ctx context.Context, job JobConfig, notice utils.DeprecationNotice) error
+}
+
+type deprecationAlert struct {
+	RunID string `json:"run_id,omitempty"`
+	Job   string `json:"job"`
+	utils.DeprecationNotice
+}
+
+// deprecationState lembra os avisos já notificados, para o alerta sair uma
+// vez e não a cada execução; o log continua avisando sempre.
+type deprecationState struct {
+	Notified map[string]time.Time `json:"notified"`
+}
+
+var deprecationMu sync.Mutex
+
+func (job JobConfig) watchDeprecations(rl *utils.RateLimitClient) {
+	if rl.Deprecations == nil {
+		return
+	}
+	rl.Deprecations.OnNotice = func(notice utils.DeprecationNotice) {
+		alertDeprecation(job, notice)
+	}
+}
+
+func alertDeprecation(job JobConfig, notice utils.DeprecationNotice) {
+	deprecationMu.Lock()
+	defer deprecationMu.Unlock()
+
+	stateName := "deprecations-" + job.Name
+	var st deprecationState
+	if _, err := stateStore.Load(stateName, &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+	if _, ok := st.Notified[notice.Key()]; ok {
+		return
+	}
+
+	ctx := context.Background()
+	if *deprecationWebhook != "" {
+		alert := deprecationAlert{RunID: job.runID, Job: job.Name, DeprecationNotice: notice}
+		if err := postNotice(ctx, *deprecationWebhook, alert, deprecationAlertTimeout); err != nil {
+			log.Printf("[%s] Erro ao notificar descontinuação via webhook: %v", job.Name, err)
+		}
+	}
+	for i, s := range job.sinks {
+		n, ok := s.(deprecationNotifier)
+		if !ok {
+			continue
+		}
+		if err := n.NotifyDeprecation(ctx, job, notice); err != nil {
+			log.Printf("[%s] Erro ao notificar descontinuação via %s: %v", job.Name, job.Sinks[i].Type, err)
+		}
+	}
+
+	if st.Notified == nil {
+		st.Notified = map[string]time.Time{}
+	}
+	st.Notified[notice.Key()] = time.Now()
+	if err := stateStore.Save(stateName, st); err != nil {
+		log.Printf("[%s] Erro ao salvar avisos de descontinuação: %v", job.Name, err)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+
+	"apiconsume/utils"
+)
+
+// DetailsConfig busca, para cada registro da listagem, o endpoint de
+// detalhe (URL com {id}, ex: /items/{id}) pelo rate limiter do job, com os
+// mesmos headers e autenticação, e junta a resposta ao registro: em Into,
+// se informado, ou campo a campo, com o detalhe prevalecendo. ID é o
+// jsonpath do id no registro; uma URL que começa com / é relativa ao host
+// do job. OnError diz o que fazer com o registro cujo detalhe falhou: fail
+// (padrão, ou quarentena com quarantine), keep ou drop.
+type DetailsConfig struct {
+	URL         string `json:"url"`
+	ID          string `json:"id"`
+	Into        string `json:"into,omitempty"`
+	Records     string `json:"records,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+	OnError     string `json:"on_error,omitempty"`
+
+	id *utils.JSONPath
+}
+
+func (c *DetailsConfig) validate(job JobConfig) error {
+	if c.URL == "" || c.ID == "" {
+		return fmt.Errorf("url e id são obrigatórios")
+	}
+	if !strings.Contains(c.URL, "{id}") {
+		return fmt.Errorf("url deve ter {id}")
+	}
+	var err error
+	if c.id, err = utils.ParseJSONPath(c.ID); err != nil {
+		return err
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	switch c.OnError {
+	case "", "fail", "keep", "drop":
+	default:
+		return fmt.Errorf("on_error deve ser fail, keep ou drop")
+	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency não pode ser negativo")
+	}
+	if job.BulkInput != "" || job.Download != nil || job.Tenants != nil {
+		return fmt.Errorf("não se aplica a jobs com bulk_input, download ou tenants")
+	}
+	return nil
+}
+
+// detailURL é a URL do detalhe de id, com / resolvido contra o host do job.
+func (c *DetailsConfig) detailURL(jobURL, id string) string {
+	u := strings.ReplaceAll(c.URL, "{id}", url.PathEscape(id))
+	if strings.HasPrefix(u, "/") {
+		if base, err := url.Parse(jobURL); err == nil {
+			u = base.Scheme + "://" + base.Host + u
+		}
+	}
+	return u
+}
+
+// fetchDetails completa a listagem com os detalhes, até Concurrency (4)
+// requisições por vez; ids repetidos são buscados uma vez só.
+func fetchDetails(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, jobURL string, vars templateVars, body []byte) ([]byte, error) {
+	c := job.Details
+	// o detalhe é um GET com os headers do job, sem o body nem a query da
+	// listagem
+	base := job
+	base.Method, base.Body, base.Query = "", "", nil
+	spec, err := base.render(c.URL, vars)
+	if err != nil {
+		return nil, fmt.Errorf("erro no template de details.url: %w", err)
+	}
+
+	return transformOutput(body, c.Records, "details", func(records []any) ([]any, error) {
+		ids := make([]string, len(records))
+		unique := map[string]bool{}
+		for i, rec := range records {
+			if value, ok := c.id.First(rec); ok && value != nil {
+				ids[i] = fmt.Sprint(value)
+				unique[ids[i]] = true
+			}
+		}
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			details = make(map[string]any, len(unique))
+			failed  = map[string]error{}
+		)
+		concurrency := c.Concurrency
+		if concurrency == 0 {
+			concurrency = 4
+		}
+		slots := make(chan struct{}, concurrency)
+		for id := range unique {
+			if ctx.Err() != nil {
+				break
+			}
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				req := spec
+				req.URL = c.detailURL(jobURL, id)
+				data, _, status, err := job.Status.fetch(ctx, rl, req)
+				var detail any
+				if err == nil && job.Status.accepts(status) {
+					if err = json.Unmarshal(data, &detail); err != nil {
+						err = fmt.Errorf("detalhe não é JSON válido: %w", err)
+					}
+				} else {
+					err = requestError(job.ErrorEnvelope, status, data, explain(ctx, err))
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed[id] = err
+					return
+				}
+				details[id] = detail
+			}(id)
+		}
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		out := make([]any, 0, len(records))
+		missing := 0
+		for i, rec := range records {
+			obj, ok := rec.(map[string]any)
+			if !ok || ids[i] == "" {
+				missing++
+				out = append(out, rec)
+				continue
+			}
+			if err := failed[ids[i]]; err != nil {
+				switch c.OnError {
+				case "keep":
+					out = append(out, rec)
+					continue
+				case "drop":
+					continue
+				}
+				err = fmt.Errorf("detalhe de %s: %w", ids[i], err)
+				if job.Quarantine == nil {
+					return nil, err
+				}
+				if err := job.reject("details", err.Error(), rec); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			out = append(out, c.merge(obj, details[ids[i]]))
+		}
+		log.Printf("[%s] Details: %d detalhes buscados, %d falhas, %d registros sem id", job.Name, len(details), len(failed), missing)
+		return out, nil
+	})
+}
+
+// merge junta o detalhe ao registro: em Into ou, sendo um objeto, campo a
+// campo.
+func (c *DetailsConfig) merge(rec map[string]any, detail any) map[string]any {
+	if c.Into != "" {
+		rec[c.Into] = detail
+		return rec
+	}
+	if fields, ok := detail.(map[string]any); ok {
+		for k, v := range fields {
+			rec[k] = v
+		}
+	}
+	return rec
+}
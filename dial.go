@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	familyDual       = "dual"
+	familyIPv4       = "ipv4"
+	familyIPv6       = "ipv6"
+	familyPreferIPv4 = "prefer_ipv4"
+	familyPreferIPv6 = "prefer_ipv6"
+)
+
+var (
+	ipFamily     = flag.String("ip-family", "", "família de endereços das conexões: dual (padrão, Happy Eyeballs), ipv4, ipv6, prefer_ipv4 ou prefer_ipv6")
+	dialFallback = flag.Duration("dial-fallback", 0, "em dual, espera antes de tentar a outra família (0 = padrão de 300ms, negativo = uma família por vez)")
+
+	connDebug      = flag.Bool("conn-debug", false, "loga se cada tentativa usou conexão nova ou reaproveitada (e há quanto tempo ociosa)")
+	freshConnRetry = flag.Bool("fresh-conn-retry", false, "descarta as conexões ociosas antes de cada nova tentativa e repete uma vez, em conexão nova, a requisição idempotente que falha numa reaproveitada")
+)
+
+// dialOptions é como o job abre conexões: IP de saída (bind), família de
+// endereços e, em dual, o atraso do Happy Eyeballs (RFC 6555) antes de
+// tentar a outra família.
+type dialOptions struct {
+	Bind     string
+	Family   string
+	Fallback time.Duration
+}
+
+func (s JobSettings) dialOptions() dialOptions {
+	o := dialOptions{Bind: s.Bind, Family: s.IPFamily}
+	if s.DialFallback != nil {
+		o.Fallback = s.DialFallback.Duration
+	}
+	return o
+}
+
+func (o dialOptions) custom() bool {
+	return o != dialOptions{} && o != dialOptions{Family: familyDual}
+}
+
+func validateIPFamily(family string) error {
+	switch family {
+	case "", familyDual, familyIPv4, familyIPv6, familyPreferIPv4, familyPreferIPv6:
+		return nil
+	}
+	return fmt.Errorf("ip_family deve ser dual, ipv4, ipv6, prefer_ipv4 ou prefer_ipv6")
+}
+
+// validate confere a combinação; a família do bind manda no destino.
+func (o dialOptions) validate() error {
+	if err := validateIPFamily(o.Family); err != nil {
+		return err
+	}
+	if err := validateBind(o.Bind); err != nil {
+		return err
+	}
+	if o.Bind == "" || o.Family == "" || o.Family == familyDual {
+		return nil
+	}
+	ip, err := resolveBind(o.Bind)
+	if err != nil {
+		return err
+	}
+	if v4 := ip.To4() != nil; (v4 && o.Family == familyIPv6) || (!v4 && o.Family == familyIPv4) {
+		return fmt.Errorf("ip_family %s não combina com bind %s (%s)", o.Family, o.Bind, ip)
+	}
+	return nil
+}
+
+var (
+	dialMu         sync.Mutex
+	dialTransports = map[dialOptions]http.RoundTripper{}
+)
+
+// dialTransport é o pool de conexões com essas opções de discagem,
+// compartilhado pelos jobs que as usam. Se o bind não resolve mais
+// (interface removida), as conexões falham em vez de sair por outro IP,
+// que o provedor recusaria.
+func dialTransport(o dialOptions) http.RoundTripper {
+	dialMu.Lock()
+	defer dialMu.Unlock()
+
+	if t, ok := dialTransports[o]; ok {
+		return t
+	}
+	base, ok := http.DefaultTransport.(*http.Transport)
+	// sob -test o transporte padrão é o cassette, que não abre conexões
+	if !ok {
+		dialTransports[o] = http.DefaultTransport
+		return http.DefaultTransport
+	}
+
+	t := base.Clone()
+	t.DialContext = o.dialer()
+	dialTransports[o] = t
+	return t
+}
+
+func (o dialOptions) dialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, FallbackDelay: o.Fallback}
+	family := o.Family
+	if o.Bind != "" {
+		ip, err := resolveBind(o.Bind)
+		if err != nil {
+			return func(context.Context, string, string) (net.Conn, error) {
+				return nil, err
+			}
+		}
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+		// com origem IPv4 só dá para discar destinos IPv4, e vice-versa
+		family = familyIPv6
+		if ip.To4() != nil {
+			family = familyIPv4
+		}
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network != "tcp" {
+			return dialResolved(ctx, d, network, addr)
+		}
+		switch family {
+		case familyIPv4:
+			return dialResolved(ctx, d, "tcp4", addr)
+		case familyIPv6:
+			return dialResolved(ctx, d, "tcp6", addr)
+		case familyPreferIPv4, familyPreferIPv6:
+			// uma família inteira primeiro (sem endereço, recusada ou fora
+			// do ar), só então a outra
+			first, second := "tcp4", "tcp6"
+			if family == familyPreferIPv6 {
+				first, second = second, first
+			}
+			conn, err := dialResolved(ctx, d, first, addr)
+			if err != nil && ctx.Err() == nil {
+				if conn, err2 := dialResolved(ctx, d, second, addr); err2 == nil {
+					return conn, nil
+				}
+			}
+			return conn, err
+		}
+		return dialResolved(ctx, d, network, addr)
+	}
+}
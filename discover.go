@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"apiconsume/utils"
+)
+
+var schemaOut = flag.String("schema-out", "schema.json", "discover: arquivo onde o schema inferido é salvo")
+
+func init() {
+	commands["discover"] = discoverCommand
+}
+
+// discoverCommand busca uma amostra (da URL do .env, ou do job informado
+// quando -config é usado), infere o schema e imprime o relatório de campos.
+func discoverCommand(ctx context.Context, args []string) error {
+	spec, err := sampleRequestSpec(args)
+	if err != nil {
+		return err
+	}
+
+	rl := newRateClient(flagSettings())
+	body, status, err := doRequest(ctx, rl, spec)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("amostra retornou status %d", status)
+	}
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("amostra não é JSON válido: %w", err)
+	}
+
+	inferrer := utils.NewSchemaInferrer()
+	inferrer.Observe(doc)
+
+	data, err := json.MarshalIndent(inferrer.Schema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	writeFile(*schemaOut, append(data, '\n'))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CAMPO\tTIPOS\tNULO\tPRESENÇA\tEXEMPLO")
+	for _, f := range inferrer.Report() {
+		nullable := ""
+		if f.Nullable {
+			nullable = "sim"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.0f%%\t%s\n", f.Path, strings.Join(f.Types, "|"), nullable, f.Presence*100, f.Example)
+	}
+	tw.Flush()
+
+	log.Printf("Schema salvo em %s (%d bytes de amostra)", *schemaOut, len(body))
+	return nil
+}
+
+func sampleRequestSpec(args []string) (requestSpec, error) {
+	if *jobConfigPath != "" {
+		if len(args) != 1 {
+			return requestSpec{}, fmt.Errorf("com -config informe o nome do job")
+		}
+		cfg, err := loadJobConfig(*jobConfigPath)
+		if err != nil {
+			return requestSpec{}, err
+		}
+		for _, job := range cfg.Jobs {
+			if job.Name == args[0] {
+				return job.render(job.requestURL(), templateVars{})
+			}
+		}
+		return requestSpec{}, fmt.Errorf("job %q não encontrado", args[0])
+	}
+
+	urlBase, err := loadEnvValues(".env")
+	if err != nil {
+		return requestSpec{}, err
+	}
+	return requestSpec{URL: buildURL(urlBase)}, nil
+}
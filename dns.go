@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	dnsCacheTTL = flag.Duration("dns-cache", 30*time.Second, "guarda as respostas de DNS por este tempo, para que uma falha do resolver não derrube as conexões seguintes (0 = desativado)")
+	dnsRetries  = flag.Int("dns-retries", 3, "novas consultas DNS após timeout ou NXDOMAIN, com espera própria de 100ms dobrando (0 = nenhuma)")
+)
+
+// dnsCache guarda os endereços resolvidos de cada host por -dns-cache.
+type dnsCache struct {
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+var resolver = &dnsCache{entries: map[string]dnsEntry{}}
+
+func dnsEnabled() bool {
+	return *dnsCacheTTL > 0 || *dnsRetries > 0
+}
+
+// configureDNS faz o http.DefaultTransport discar pelo cache de DNS; os
+// transportes de bind e ip_family (dialTransport) já usam o mesmo dialer.
+func configureDNS() {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok || !dnsEnabled() {
+		return
+	}
+	transport = transport.Clone()
+	transport.DialContext = dialOptions{}.dialer()
+	http.DefaultTransport = transport
+}
+
+// lookup resolve host, do cache quando a resposta ainda vale. Um timeout,
+// uma falha temporária ou um NXDOMAIN (que o resolver instável também
+// devolve) é consultado de novo até -dns-retries vezes, sem gastar as
+// tentativas da requisição.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.addrs, nil
+	}
+
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err == nil {
+			if *dnsCacheTTL > 0 {
+				c.mu.Lock()
+				c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(*dnsCacheTTL)}
+				c.mu.Unlock()
+			}
+			return addrs, nil
+		}
+		if attempt >= *dnsRetries || !transientDNS(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("DNS de %s falhou (%v); nova consulta em %v", host, err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func transientDNS(err error) bool {
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return false
+	}
+	return dnsErr.IsTimeout || dnsErr.IsTemporary || dnsErr.IsNotFound
+}
+
+// dialResolved disca addr resolvendo o host pelo cache. Em "tcp", com as
+// duas famílias, os endereços da segunda começam depois de
+// d.FallbackDelay, como o Happy Eyeballs do net.Dialer.
+func dialResolved(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil || !dnsEnabled() {
+		return d.DialContext(ctx, network, addr)
+	}
+	ips, err := resolver.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// a primeira família é a do primeiro endereço da resposta
+	var primary, fallback []string
+	var primaryV4 bool
+	for _, ip := range ips {
+		v4 := net.ParseIP(ip).To4() != nil
+		switch {
+		case (network == "tcp4" && !v4) || (network == "tcp6" && v4):
+		case len(primary) == 0 || primaryV4 == v4:
+			primary, primaryV4 = append(primary, net.JoinHostPort(ip, port)), v4
+		default:
+			fallback = append(fallback, net.JoinHostPort(ip, port))
+		}
+	}
+	if len(primary) == 0 {
+		return nil, &net.DNSError{Err: "nenhum endereço da família pedida", Name: host, IsNotFound: true}
+	}
+	if len(fallback) == 0 || d.FallbackDelay < 0 {
+		return dialSerial(ctx, d, network, append(primary, fallback...))
+	}
+	return dialRace(ctx, d, network, primary, fallback)
+}
+
+func dialSerial(ctx context.Context, d *net.Dialer, network string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// dialRace disca primary e, após o atraso (ou a falha de primary),
+// fallback; fica a primeira conexão, e a outra é fechada.
+func dialRace(ctx context.Context, d *net.Dialer, network string, primary, fallback []string) (net.Conn, error) {
+	type dialed struct {
+		conn net.Conn
+		err  error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialed, 2)
+	start := func(addrs []string) {
+		go func() {
+			conn, err := dialSerial(ctx, d, network, addrs)
+			results <- dialed{conn, err}
+		}()
+	}
+	delay := d.FallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	start(primary)
+	pending, started := 1, false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !started {
+				start(fallback)
+				pending, started = pending+1, true
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if !started {
+				start(fallback)
+				pending, started = pending+1, true
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
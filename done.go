@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+)
+
+var (
+	doneWindow = flag.Duration("done-window", 0, "não repete o job que já concluiu com sucesso a data de hoje há menos deste tempo (0 = desativado)")
+	forceRun   = flag.Bool("force", false, "roda os jobs mesmo que já tenham concluído dentro de -done-window")
+)
+
+// doneState é a última execução com sucesso do job, para que o retry do
+// orquestrador de uma tarefa que já terminou não baixe tudo de novo.
+type doneState struct {
+	Date       string    `json:"date"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+func runDate(now time.Time) string {
+	return now.Format("2006-01-02")
+}
+
+// alreadyDone devolve quando o job concluiu a data de hoje, se foi dentro
+// da janela done_window e -force não foi informado.
+func alreadyDone(job JobConfig, settings JobSettings, now time.Time) (time.Time, bool) {
+	if *forceRun || settings.DoneWindow == nil || settings.DoneWindow.Duration <= 0 {
+		return time.Time{}, false
+	}
+	var st doneState
+	if _, err := stateStore.Load("done-"+shard.lockName(job), &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+		return time.Time{}, false
+	}
+	if st.Date != runDate(now) || now.Sub(st.FinishedAt) >= settings.DoneWindow.Duration {
+		return time.Time{}, false
+	}
+	return st.FinishedAt, true
+}
+
+func recordDone(job JobConfig, settings JobSettings, now time.Time) {
+	if settings.DoneWindow == nil || settings.DoneWindow.Duration <= 0 {
+		return
+	}
+	if err := stateStore.Save("done-"+shard.lockName(job), doneState{Date: runDate(now), FinishedAt: now}); err != nil {
+		log.Printf("[%s] Erro ao salvar a última execução concluída: %v", job.Name, err)
+	}
+}
+
+func skipAlreadyDone(job JobConfig, res *jobResult, at time.Time) {
+	log.Printf("[%s] Job ignorado: já concluído hoje às %s (done_window); use -force para rodar de novo", job.Name, at.Format("15:04:05"))
+	res.Skipped = true
+	res.Error = "ignorado: já concluído em " + at.Format(time.RFC3339)
+}
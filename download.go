@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// DownloadConfig grava a resposta como arquivo (PDF, ZIP, exportações), sem
+// ler tudo em memória nem validar JSON. A extensão vem do
+// Content-Disposition ou do Content-Type (com um Content-Type genérico, do
+// formato reconhecido nos primeiros bytes); com KeepName, o nome inteiro do
+// Content-Disposition é usado.
+type DownloadConfig struct {
+	KeepName bool           `json:"keep_name,omitempty"`
+	Extract  *ExtractConfig `json:"extract,omitempty"`
+}
+
+// downloadInfo vai para response-<job>.meta.json.
+type downloadInfo struct {
+	File        string `json:"file"`
+	ContentType string `json:"content_type,omitempty"`
+	Sniffed     string `json:"sniffed,omitempty"`
+	Bytes       int64  `json:"bytes"`
+	SHA256      string `json:"sha256"`
+
+	Extracted []string `json:"extracted,omitempty"`
+}
+
+var downloadExtensions = map[string]string{
+	"application/zip":              ".zip",
+	"application/x-zip-compressed": ".zip",
+	"application/gzip":             ".gz",
+	"application/x-gzip":           ".gz",
+	"application/pdf":              ".pdf",
+	"text/csv":                     ".csv",
+	"application/json":             ".json",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": ".xlsx",
+}
+
+func (c *DownloadConfig) validate(job JobConfig) error {
+	switch {
+	case job.BulkInput != "":
+		return fmt.Errorf("download não se aplica a bulk_input")
+	case job.Paginate != nil:
+		return fmt.Errorf("download não se aplica a paginate")
+	case job.Quality != nil, job.Dedup != nil, job.Watermark != nil, job.Enrich != nil, job.Project != nil, job.Sort != nil:
+		return fmt.Errorf("download grava o arquivo como veio; quality, dedup, watermark, enrich, project e sort não se aplicam")
+	case job.Publish != nil:
+		return fmt.Errorf("publish ainda não suporta download")
+	}
+	if c.Extract != nil {
+		return c.Extract.validate()
+	}
+	return nil
+}
+
+// downloadName escolhe o nome final: o do Content-Disposition (só a base,
+// sem diretórios) ou response-<job> com a extensão do tipo. sniffed é o
+// formato reconhecido no body quando o Content-Type é genérico: ele troca
+// uma extensão que não diz nada (.bin, ou nenhuma) e, se comprime outro
+// formato, soma a sua à do conteúdo (.json.gz).
+func (c *DownloadConfig) downloadName(job JobConfig, contentType, disposition string, sniffed *utils.ContentSniffer) string {
+	var suggested string
+	if _, params, err := mime.ParseMediaType(disposition); err == nil {
+		suggested = filepath.Base(filepath.Clean("/" + strings.ReplaceAll(params["filename"], `\`, "/")))
+		if suggested == "/" || suggested == "." {
+			suggested = ""
+		}
+	}
+	if c.KeepName && suggested != "" {
+		return suggested
+	}
+
+	ext := filepath.Ext(suggested)
+	if ext == "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if known, ok := downloadExtensions[mediaType]; ok {
+			ext = known
+		} else if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 && sniffed == nil {
+			ext = exts[0]
+		} else {
+			ext = ".bin"
+		}
+	}
+	if sniffed != nil && ext != sniffed.Ext {
+		switch {
+		case ext == ".bin":
+			ext = sniffed.Ext
+		case sniffed.Decode != nil && ext != ".tgz":
+			ext += sniffed.Ext
+		case filepath.Ext(suggested) == "":
+			// o que um Content-Type genérico sugeria (.json, .txt)
+			ext = sniffed.Ext
+		}
+	}
+	return "response-" + job.Name + ext
+}
+
+func runDownload(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, spec requestSpec, outputDir string, limits crawlLimits) (*downloadInfo, http.Header, error) {
+	resp, err := job.Status.send(ctx, rl, spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if !job.Status.accepts(resp.StatusCode) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, nil, requestError(job.ErrorEnvelope, resp.StatusCode, body, nil)
+	}
+	if limits.Bytes > 0 && resp.ContentLength > limits.Bytes {
+		return nil, nil, &crawlLimitError{msg: fmt.Sprintf("Content-Length de %d bytes passa de max_bytes (%d)", resp.ContentLength, limits.Bytes)}
+	}
+	dir := outputDir
+	if *tempDir != "" {
+		dir = *tempDir
+	}
+	if resp.ContentLength > 0 {
+		if err := utils.EnsureSpace(dir, resp.ContentLength); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// o body é conferido antes do nome: provedores mandam ZIP e gzip como
+	// application/octet-stream, ou até como application/json
+	body := bufio.NewReaderSize(resp.Body, utils.SniffLen)
+	head, _ := body.Peek(utils.SniffLen)
+	contentType := resp.Header.Get("Content-Type")
+	streamType := contentType
+	var sniffed *utils.ContentSniffer
+	if s, ok := utils.SniffContent(head); ok && utils.GenericContentType(contentType) {
+		sniffed, streamType = &s, s.MediaType
+		log.Printf("[%s] Conteúdo %s com Content-Type %q", job.Name, s.Name, contentType)
+	}
+	name := job.Download.downloadName(job, contentType, resp.Header.Get("Content-Disposition"), sniffed)
+	path := filepath.Join(outputDir, name)
+
+	tmp, err := os.CreateTemp(dir, "tmp-*.tmp")
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao criar arquivo temporário: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		tmp.Close()
+		os.Remove(tmpName)
+	}()
+
+	// arquivo, sha256 e sinks recebem o body na mesma passada
+	counter := &downloadCounter{hash: sha256.New(), limit: limits.Bytes}
+	streams := openSinkStreams(ctx, job, streamType, resp.ContentLength)
+	saved := false
+	defer func() {
+		if !saved {
+			abortSinkStreams(streams, fmt.Errorf("download de %s interrompido", name))
+		}
+	}()
+	writers := append([]io.Writer{tmp, counter}, teeWriters(streams)...)
+	if _, err := io.Copy(io.MultiWriter(writers...), body); err != nil {
+		return nil, nil, err
+	}
+	if err := applyOutputOwnership(tmp); err != nil {
+		return nil, nil, err
+	}
+	if err := utils.SyncFile(tmp); err != nil {
+		return nil, nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := publishFile(tmpName, path); err != nil {
+		return nil, nil, err
+	}
+	saved = true
+
+	info := &downloadInfo{File: name, ContentType: contentType, Bytes: counter.n, SHA256: hex.EncodeToString(counter.hash.Sum(nil))}
+	if sniffed != nil {
+		info.Sniffed = sniffed.Name
+	}
+	log.Printf("[%s] Arquivo %s salvo (%d bytes, sha256 %s)", job.Name, name, info.Bytes, info.SHA256)
+	if err := finishSinkStreams(ctx, job, path, streams); err != nil {
+		return info, resp.Header, err
+	}
+
+	if job.Download.Extract != nil {
+		if info.Extracted, err = extractArchive(job, path, outputDir, limits.Bytes); err != nil {
+			return info, resp.Header, fmt.Errorf("extração de %s: %w", name, err)
+		}
+	}
+	return info, resp.Header, nil
+}
+
+// downloadCounter soma o sha256 e os bytes, e interrompe a cópia ao passar
+// de max_bytes.
+type downloadCounter struct {
+	hash  hash.Hash
+	n     int64
+	limit int64
+}
+
+func (c *downloadCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	if c.limit > 0 && c.n > c.limit {
+		return 0, &crawlLimitError{msg: fmt.Sprintf("mais de %d bytes baixados (max_bytes)", c.limit)}
+	}
+	return c.hash.Write(p)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	sinkTypes["email"] = newEmailSink
+}
+
+// emailSink envia a saída (anexo, ou resumo com link quando grande demais)
+// para To em caso de sucesso e avisa FailureTo quando o job falha.
+type emailSink struct {
+	SMTP        string   `json:"smtp"`
+	User        string   `json:"user"`
+	Password    string   `json:"password"`
+	From        string   `json:"from"`
+	To          []string `json:"to"`
+	FailureTo   []string `json:"failure_to"`
+	Subject     string   `json:"subject"`
+	Attach      *bool    `json:"attach"`
+	MaxAttachMB int      `json:"max_attachment_mb"`
+	Link        string   `json:"link"`
+
+	server *utils.SMTPServer
+}
+
+func newEmailSink(raw json.RawMessage, baseDir string) (sink, error) {
+	s := &emailSink{MaxAttachMB: 10}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+
+	if s.SMTP == "" || s.From == "" {
+		return nil, fmt.Errorf("email exige smtp (host:porta) e from")
+	}
+	if len(s.To) == 0 && len(s.FailureTo) == 0 {
+		return nil, fmt.Errorf("email exige to e/ou failure_to")
+	}
+
+	fields, err := renderAll(s.User, s.Password)
+	if err != nil {
+		return nil, err
+	}
+	s.server = &utils.SMTPServer{Addr: s.SMTP, User: fields[0], Password: fields[1]}
+	return s, nil
+}
+
+func (s *emailSink) Deliver(ctx context.Context, out sinkOutput) error {
+	if len(s.To) == 0 {
+		return nil
+	}
+
+	name := filepath.Base(out.Path)
+	subject := s.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("[api-requester] %s concluído", out.Job.Name)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Job %s concluído em %s.\n\n", out.Job.Name, time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&body, "Arquivo: %s (%d bytes)\n", name, len(out.Data))
+	if s.Link != "" {
+		fmt.Fprintf(&body, "Link: %s\n", strings.ReplaceAll(s.Link, "{file}", name))
+	}
+	writeRunID(&body, out.Job)
+
+	mail := utils.Mail{From: s.From, To: s.To, Subject: subject, Headers: runIDHeaders(out.Job)}
+
+	attach := s.Attach == nil || *s.Attach
+	if attach && len(out.Data) <= s.MaxAttachMB<<20 {
+		mail.Attachments = []utils.MailAttachment{{Name: name, Data: out.Data}}
+	} else if attach {
+		fmt.Fprintf(&body, "\nO arquivo excede %d MB e não foi anexado.\n", s.MaxAttachMB)
+	}
+	mail.Body = body.String()
+
+	return s.server.Send(mail)
+}
+
+func (s *emailSink) NotifyFailure(ctx context.Context, job JobConfig, errs []ErrorResponse) error {
+	if len(s.FailureTo) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Job %s falhou em %s.\n\n", job.Name, time.Now().Format("2006-01-02 15:04:05"))
+	for _, e := range errs {
+		if e.Item != "" && e.Item != job.Name {
+			fmt.Fprintf(&body, "- %s: %s\n", e.Item, e.Error)
+		} else {
+			fmt.Fprintf(&body, "- %s\n", e.Error)
+		}
+	}
+	writeRunID(&body, job)
+
+	return s.server.Send(utils.Mail{
+		From:    s.From,
+		To:      s.FailureTo,
+		Subject: fmt.Sprintf("[api-requester] FALHA em %s", job.Name),
+		Headers: runIDHeaders(job),
+		Body:    body.String(),
+	})
+}
+
+func (s *emailSink) NotifyDeprecation(ctx context.Context, job JobConfig, notice utils.DeprecationNotice) error {
+	if len(s.FailureTo) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "O job %s recebeu aviso de descontinuação:\n\n%s\n", job.Name, notice)
+	writeRunID(&body, job)
+
+	return s.server.Send(utils.Mail{
+		From:    s.From,
+		To:      s.FailureTo,
+		Subject: fmt.Sprintf("[api-requester] API descontinuada em %s", job.Name),
+		Headers: runIDHeaders(job),
+		Body:    body.String(),
+	})
+}
+
+// writeRunID põe a execução no fim da mensagem, para achar o run-summary e
+// o rerun correspondentes.
+func writeRunID(body *strings.Builder, job JobConfig) {
+	if job.runID != "" {
+		fmt.Fprintf(body, "\nExecução: %s\n", job.runID)
+	}
+}
+
+func runIDHeaders(job JobConfig) map[string]string {
+	if job.runID == "" {
+		return nil
+	}
+	return map[string]string{runIDHeader: job.runID}
+}
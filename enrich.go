@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// EnrichConfig junta a cada registro colunas de um arquivo local (CSV com
+// cabeçalho ou array JSON), casando Key (jsonpath no registro) com
+// LookupKey (coluna ou jsonpath na linha do arquivo). Fields escolhe o que
+// copiar (destino -> coluna); sem ele, todas as colunas entram, sem
+// sobrescrever campos do registro. Unmatched: keep (padrão), drop ou fail.
+type EnrichConfig struct {
+	File      string            `json:"file"`
+	Key       string            `json:"key"`
+	LookupKey string            `json:"lookup_key"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Unmatched string            `json:"unmatched,omitempty"`
+	Records   string            `json:"records,omitempty"`
+
+	path string
+}
+
+func (c *EnrichConfig) validate(baseDir string) error {
+	if c.File == "" || c.Key == "" || c.LookupKey == "" {
+		return fmt.Errorf("file, key e lookup_key são obrigatórios")
+	}
+	for _, p := range []string{c.Key, c.LookupKey} {
+		if _, err := utils.ParseJSONPath(p); err != nil {
+			return err
+		}
+	}
+	for target, source := range c.Fields {
+		if _, err := utils.ParseJSONPath(source); err != nil {
+			return fmt.Errorf("campo %q: %w", target, err)
+		}
+	}
+	switch c.Unmatched {
+	case "", "keep", "drop", "fail":
+	default:
+		return fmt.Errorf("unmatched deve ser keep, drop ou fail")
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+
+	c.path = c.File
+	if !filepath.IsAbs(c.path) {
+		c.path = filepath.Join(baseDir, c.path)
+	}
+	return nil
+}
+
+// loadLookup lê o arquivo a cada execução, para refletir atualizações entre
+// rodadas agendadas. Chaves repetidas ficam com a primeira linha.
+func (c *EnrichConfig) loadLookup() (map[string]map[string]any, error) {
+	rows, err := readLookupRows(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler lookup %s: %w", c.File, err)
+	}
+
+	key, _ := utils.ParseJSONPath(c.LookupKey)
+	index := make(map[string]map[string]any, len(rows))
+	duplicates := 0
+	for _, row := range rows {
+		value, ok := key.First(row)
+		if !ok || value == nil {
+			continue
+		}
+		k := fmt.Sprint(value)
+		if _, dup := index[k]; dup {
+			duplicates++
+			continue
+		}
+		index[k] = row
+	}
+	if duplicates > 0 {
+		log.Printf("Lookup %s: %d chaves repetidas ignoradas", c.File, duplicates)
+	}
+	return index, nil
+}
+
+func readLookupRows(path string) ([]map[string]any, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var rows []map[string]any
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("esperado array de objetos: %w", err)
+		}
+		return rows, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	lines, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("CSV sem cabeçalho")
+	}
+
+	header := lines[0]
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	rows := make([]map[string]any, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			if i < len(line) {
+				row[name] = line[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func enrichOutput(job JobConfig, body []byte) ([]byte, error) {
+	c := job.Enrich
+	index, err := c.loadLookup()
+	if err != nil {
+		return nil, err
+	}
+
+	return transformOutput(body, c.Records, "enrich", func(records []any) ([]any, error) {
+		var reject func(rec any, reason string) error
+		if job.Quarantine != nil {
+			reject = func(rec any, reason string) error { return job.reject("enrich", reason, rec) }
+		}
+		out, matched, err := c.enrichRecords(records, index, reject)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[%s] Enrich: %d de %d registros encontrados em %s", job.Name, matched, len(records), c.File)
+		return out, nil
+	})
+}
+
+// enrichRecords junta a cada registro a linha da tabela com a mesma chave.
+// Com reject, um registro sem correspondência em unmatched fail vai para a
+// quarentena em vez de falhar o job.
+func (c *EnrichConfig) enrichRecords(records []any, index map[string]map[string]any, reject func(rec any, reason string) error) ([]any, int, error) {
+	key, _ := utils.ParseJSONPath(c.Key)
+	fields := make(map[string]*utils.JSONPath, len(c.Fields))
+	for target, source := range c.Fields {
+		fields[target], _ = utils.ParseJSONPath(source)
+	}
+
+	out := make([]any, 0, len(records))
+	matched := 0
+	for _, rec := range records {
+		obj, ok := rec.(map[string]any)
+		if !ok {
+			out = append(out, rec)
+			continue
+		}
+
+		var row map[string]any
+		if value, ok := key.First(obj); ok && value != nil {
+			row = index[fmt.Sprint(value)]
+		}
+
+		if row == nil {
+			switch c.Unmatched {
+			case "drop":
+				continue
+			case "fail":
+				value, _ := key.First(obj)
+				err := fmt.Errorf("chave %v não encontrada em %s", value, c.File)
+				if reject == nil {
+					return nil, 0, err
+				}
+				if err := reject(rec, err.Error()); err != nil {
+					return nil, 0, err
+				}
+				continue
+			}
+		} else {
+			matched++
+		}
+
+		if len(fields) > 0 {
+			// sem correspondência, os destinos ficam null para manter as colunas
+			for target, p := range fields {
+				var value any
+				if row != nil {
+					value, _ = p.First(row)
+				}
+				obj[target] = value
+			}
+		} else {
+			for name, value := range row {
+				if _, exists := obj[name]; !exists {
+					obj[name] = value
+				}
+			}
+		}
+		out = append(out, obj)
+	}
+	return out, matched, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errorSummarize = flag.Int("error-summarize", 100, "erros idênticos a partir desta quantidade viram uma só entrada com contagem em errors.json (0 = nunca)")
+	errorLogKeep   = flag.Int("error-log-keep", 3, "partes rotacionadas de errors.json mantidas além do arquivo atual")
+	errorLogMax    = ByteSize(50 << 20)
+)
+
+func init() {
+	flag.Var(&errorLogMax, "error-log-max", "rotaciona errors.json ao passar deste tamanho, ex: 50MB (0 = sem limite)")
+}
+
+// errorSampleItems é quantos itens uma entrada resumida ainda lista.
+const errorSampleItems = 10
+
+// summarizeErrors junta os erros idênticos (mesma mensagem, status e código
+// da API, em qualquer item ou tentativa) que se repetem -error-summarize
+// vezes ou mais numa só entrada, na posição do primeiro, com a contagem,
+// o primeiro e o último horário e uma amostra dos itens.
+func summarizeErrors(errs []ErrorResponse) []ErrorResponse {
+	if *errorSummarize <= 0 || len(errs) < *errorSummarize {
+		return errs
+	}
+	key := func(e ErrorResponse) string {
+		return e.Error + "\x00" + strconv.Itoa(e.Status) + "\x00" + e.Code + "\x00" + e.Message
+	}
+	counts := map[string]int{}
+	for _, e := range errs {
+		counts[key(e)]++
+	}
+
+	out := make([]ErrorResponse, 0, len(errs))
+	groups := map[string]int{}
+	collapsed := 0
+	for _, e := range errs {
+		k := key(e)
+		if counts[k] < *errorSummarize {
+			out = append(out, e)
+			continue
+		}
+		i, ok := groups[k]
+		if !ok {
+			s := e
+			s.FirstAt, s.LastAt = e.At, e.At
+			i, groups[k] = len(out), len(out)
+			out = append(out, s)
+		}
+		s := &out[i]
+		s.Count++
+		collapsed++
+		if !e.At.IsZero() && (s.FirstAt.IsZero() || e.At.Before(s.FirstAt)) {
+			s.FirstAt = e.At
+		}
+		if e.At.After(s.LastAt) {
+			s.LastAt = e.At
+		}
+		if e.Item != "" && len(s.Items) < errorSampleItems {
+			s.Items = append(s.Items, e.Item)
+		}
+	}
+	for i := range out {
+		if out[i].Count > 0 {
+			out[i].At, out[i].Item = time.Time{}, ""
+		}
+	}
+	log.Printf("errors.json: %d erros idênticos resumidos em %d entradas", collapsed, len(groups))
+	return out
+}
+
+// rotatedErrorLog é a parte n de um errors.json rotacionado:
+// errors.json, errors.1.json, errors.2.json...
+func rotatedErrorLog(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	return strings.TrimSuffix(path, ".json") + "." + strconv.Itoa(n) + ".json"
+}
+
+// splitErrorLog divide os erros em partes de até -error-log-max bytes, cada
+// uma um array JSON, da mais recente (o fim da lista) para a mais antiga.
+func splitErrorLog(errs []ErrorResponse) [][]ErrorResponse {
+	if errorLogMax <= 0 {
+		return [][]ErrorResponse{errs}
+	}
+	var parts [][]ErrorResponse
+	end, size := len(errs), 0
+	for i := len(errs) - 1; i >= 0; i-- {
+		data, err := json.MarshalIndent(errs[i], "  ", "  ")
+		if err != nil {
+			continue
+		}
+		n := len(data) + 4
+		if size > 0 && int64(size+n) > int64(errorLogMax) {
+			parts = append(parts, errs[i+1:end])
+			end, size = i+1, 0
+		}
+		size += n
+	}
+	return append(parts, errs[:end])
+}
+
+// writeErrorLog grava errors.json resumido e rotacionado por tamanho: o
+// arquivo principal fica com os erros mais recentes, as partes anteriores
+// vão para errors.1.json, errors.2.json... até -error-log-keep, e as mais
+// antigas que isso viram uma entrada com a quantidade descartada.
+func writeErrorLog(path string, errs []ErrorResponse) {
+	parts := splitErrorLog(summarizeErrors(errs))
+	if keep := max(*errorLogKeep, 0) + 1; len(parts) > keep {
+		dropped := 0
+		for _, p := range parts[keep:] {
+			for _, e := range p {
+				dropped += max(e.Count, 1)
+			}
+		}
+		parts = parts[:keep]
+		oldest := &parts[keep-1]
+		*oldest = append([]ErrorResponse{{Error: fmt.Sprintf("%d erros mais antigos descartados pela rotação (-error-log-keep)", dropped), Count: dropped}}, *oldest...)
+		log.Printf("errors.json: %d erros mais antigos descartados pela rotação", dropped)
+	}
+
+	for n, part := range parts {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(part)
+		if err := os.WriteFile(rotatedErrorLog(path, n), buf.Bytes(), 0o644); err != nil {
+			log.Printf("Erro ao criar arquivo de erros: %v", err)
+			return
+		}
+	}
+	if len(parts) > 1 {
+		log.Printf("errors.json rotacionado em %d partes de até %d bytes", len(parts), errorLogMax)
+	}
+	// partes de uma execução anterior maior não se misturam com esta
+	for n := len(parts); ; n++ {
+		if err := os.Remove(rotatedErrorLog(path, n)); err != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+)
+
+var (
+	expectContinueMin     = ByteSize(1 << 20)
+	expectContinueTimeout = flag.Duration("expect-continue-timeout", time.Second, "espera pelo 100 Continue antes de enviar o body mesmo assim (0 = não usa Expect)")
+)
+
+func init() {
+	flag.Var(&expectContinueMin, "expect-continue", "bodies a partir deste tamanho vão com Expect: 100-continue, ex: 1MB")
+}
+
+// configureExpectContinue aplica -expect-continue-timeout ao
+// http.DefaultTransport (já com o proxy, se houver). Com Expect:
+// 100-continue, o servidor pode recusar a requisição (401, 413...) só pelos
+// headers, antes de o body ser transmitido.
+func configureExpectContinue() {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok || *expectContinueTimeout <= 0 {
+		return
+	}
+	transport = transport.Clone()
+	transport.ExpectContinueTimeout = *expectContinueTimeout
+	http.DefaultTransport = transport
+}
+
+// expectsContinue diz se o body é grande o bastante para esperar o 100
+// Continue; o timeout zero faria o transporte enviar o body de imediato.
+func expectsContinue(size int) bool {
+	return int64(size) >= int64(expectContinueMin) && *expectContinueTimeout > 0
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// ExtractConfig extrai do arquivo baixado (ZIP, tar ou tar.gz) os membros
+// que casam com Members (globs, ex: "*.csv" ou "dados/*.csv") para Dir,
+// relativo ao diretório de saída.
+type ExtractConfig struct {
+	Members     []string `json:"members,omitempty"`
+	Dir         string   `json:"dir,omitempty"`
+	KeepArchive *bool    `json:"keep_archive,omitempty"`
+}
+
+func (c *ExtractConfig) validate() error {
+	for _, pattern := range c.Members {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("extract.members: glob %q inválido", pattern)
+		}
+	}
+	if filepath.IsAbs(c.Dir) || !filepath.IsLocal(filepath.Clean("./"+c.Dir)) {
+		return fmt.Errorf("extract.dir deve ser relativo ao diretório de saída")
+	}
+	return nil
+}
+
+// match compara o glob com o caminho do membro; um glob sem "/" também vale
+// para o nome do arquivo em qualquer pasta.
+func (c *ExtractConfig) match(name string) bool {
+	if len(c.Members) == 0 {
+		return true
+	}
+	for _, pattern := range c.Members {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// memberPath resolve o destino do membro recusando caminhos absolutos,
+// ".." e nomes com letra de unidade, que escapariam do diretório.
+func memberPath(dir, name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if path.IsAbs(clean) || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("membro %q sai do diretório de extração", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+func extractArchive(job JobConfig, archivePath, outputDir string, limit int64) ([]string, error) {
+	c := job.Download.Extract
+	dir := filepath.Join(outputDir, c.Dir)
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(512)
+
+	x := &extractor{cfg: c, base: outputDir, dir: dir, limit: limit}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if err := x.zip(f, info.Size()); err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		if err := x.tar(gz); err != nil {
+			return nil, err
+		}
+	case len(head) > 262 && string(head[257:262]) == "ustar":
+		if err := x.tar(br); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s não é ZIP, tar nem tar.gz", filepath.Base(archivePath))
+	}
+
+	if len(x.files) == 0 {
+		return nil, fmt.Errorf("nenhum membro de %s casa com %v", filepath.Base(archivePath), c.Members)
+	}
+	log.Printf("[%s] %d arquivos extraídos de %s para %s", job.Name, len(x.files), filepath.Base(archivePath), dir)
+
+	if c.KeepArchive != nil && !*c.KeepArchive {
+		f.Close()
+		if err := os.Remove(archivePath); err != nil {
+			log.Printf("[%s] Erro ao remover %s: %v", job.Name, archivePath, err)
+		}
+	}
+	return x.files, nil
+}
+
+type extractor struct {
+	cfg   *ExtractConfig
+	base  string
+	dir   string
+	limit int64
+	total int64
+	files []string
+}
+
+func (x *extractor) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, m := range zr.File {
+		if !m.Mode().IsRegular() || !x.cfg.match(m.Name) {
+			continue
+		}
+		rc, err := m.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.Name, err)
+		}
+		err = x.write(m.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// links e dispositivos são ignorados: um symlink poderia apontar
+		// para fora do diretório
+		if h.Typeflag != tar.TypeReg || !x.cfg.match(h.Name) {
+			continue
+		}
+		if err := x.write(h.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// write grava o membro via temporário e rename, como as demais saídas, e
+// aplica max_bytes ao total descompactado.
+func (x *extractor) write(name string, r io.Reader) error {
+	dest, err := memberPath(x.dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "tmp-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		tmp.Close()
+		os.Remove(tmpName)
+	}()
+
+	src := r
+	if x.limit > 0 {
+		src = io.LimitReader(r, x.limit-x.total+1)
+	}
+	n, err := io.Copy(tmp, src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	x.total += n
+	if x.limit > 0 && x.total > x.limit {
+		return &crawlLimitError{msg: fmt.Sprintf("extração passou de %d bytes (max_bytes)", x.limit)}
+	}
+
+	if err := applyOutputOwnership(tmp); err != nil {
+		return err
+	}
+	if err := utils.SyncFile(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := publishFile(tmpName, dest); err != nil {
+		return err
+	}
+
+	rel, _ := filepath.Rel(x.base, dest)
+	x.files = append(x.files, filepath.ToSlash(rel))
+	return nil
+}
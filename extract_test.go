@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMemberPath(t *testing.T) {
+	dir := filepath.Join("out", "extraidos")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"dados.csv", filepath.Join(dir, "dados.csv")},
+		{"sub/dados.csv", filepath.Join(dir, "sub", "dados.csv")},
+		{`sub\dados.csv`, filepath.Join(dir, "sub", "dados.csv")},
+		{"./sub/../dados.csv", filepath.Join(dir, "dados.csv")},
+		{"sub//dados.csv", filepath.Join(dir, "sub", "dados.csv")},
+		{"../dados.csv", ""},
+		{"sub/../../dados.csv", ""},
+		{`..\..\etc\passwd`, ""},
+		{"/etc/passwd", ""},
+		{`\etc\passwd`, ""},
+		{"..", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := memberPath(dir, tt.name)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("memberPath(%q) = %q; quer erro", tt.name, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("memberPath(%q): %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("memberPath(%q) = %q; quer %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"apiconsume/utils"
+)
+
+// FilterConfig descarta, já na coleta, os registros que não passam em
+// Where (ver utils.RecordFilter), para não gravar nem pós-processar o que
+// não vai ser carregado. Em jobs paginados, vale para os registros de cada
+// página, antes de ir para a memória ou para o stream; sem paginate,
+// Records é o caminho só de chaves até os registros (vazio = a resposta é o
+// array).
+type FilterConfig struct {
+	Where   string `json:"where"`
+	Records string `json:"records,omitempty"`
+
+	filter *utils.RecordFilter
+	keys   []string
+}
+
+func (c *FilterConfig) validate(job JobConfig) error {
+	if c.Where == "" {
+		return fmt.Errorf("where é obrigatório")
+	}
+	var err error
+	if c.filter, err = utils.CompileFilter(c.Where); err != nil {
+		return err
+	}
+	if job.BulkInput != "" || job.Download != nil {
+		return fmt.Errorf("não se aplica a jobs com bulk_input ou download")
+	}
+	if c.Records != "" {
+		if job.Paginate != nil {
+			return fmt.Errorf("com paginate, os registros são os de paginate.records; omita filter.records")
+		}
+		p, err := utils.ParseJSONPath(c.Records)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if c.keys, ok = p.Keys(); !ok {
+			return fmt.Errorf("filter.records %s: use só chaves ($.data.items), sem índices nem curingas", c.Records)
+		}
+	}
+	return nil
+}
+
+// apply filtra os registros da resposta inteira. O array é lido um registro
+// por vez e só os mantidos vão para a saída, como vieram; o resto do
+// documento é copiado sem ser decodificado.
+func (c *FilterConfig) apply(job JobConfig, body []byte) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(body); c.Records == "" && (len(trimmed) == 0 || trimmed[0] != '[') {
+		// um objeto só é o único registro
+		return transformOutput(body, "", "filter", func(records []any) ([]any, error) {
+			return c.filter.Keep(records), nil
+		})
+	}
+
+	s := &recordStream{dec: json.NewDecoder(bytes.NewReader(body)), filter: c.filter}
+	err := s.value(c.keys)
+	if err == nil {
+		if _, err = s.dec.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = fmt.Errorf("conteúdo depois do documento")
+		}
+	}
+	switch {
+	case errors.Is(err, errNoRecords):
+		return nil, fmt.Errorf("filter.records %s não aponta para um array", c.Records)
+	case err != nil:
+		return nil, fmt.Errorf("resposta não é JSON válido: %w", err)
+	}
+	log.Printf("[%s] Filtro: %d de %d registros mantidos", job.Name, s.kept, s.total)
+	return s.out.Bytes(), nil
+}
+
+var errNoRecords = errors.New("sem o array de registros")
+
+// recordStream reescreve o documento lido por dec em out, filtrando os
+// registros do array no fim do caminho.
+type recordStream struct {
+	dec    *json.Decoder
+	out    bytes.Buffer
+	filter *utils.RecordFilter
+
+	total, kept int
+}
+
+// value copia o próximo valor, descendo por keys até o array.
+func (s *recordStream) value(keys []string) error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		if tok != json.Delim('[') {
+			return errNoRecords
+		}
+		return s.records()
+	}
+	if tok != json.Delim('{') {
+		return errNoRecords
+	}
+
+	s.out.WriteByte('{')
+	found := false
+	for i := 0; s.dec.More(); i++ {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if i > 0 {
+			s.out.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		s.out.Write(name)
+		s.out.WriteByte(':')
+
+		if key == keys[0] && !found {
+			found = true
+			if err := s.value(keys[1:]); err != nil {
+				return err
+			}
+			continue
+		}
+		var raw json.RawMessage
+		if err := s.dec.Decode(&raw); err != nil {
+			return err
+		}
+		s.out.Write(raw)
+	}
+	if _, err := s.dec.Token(); err != nil {
+		return err
+	}
+	s.out.WriteByte('}')
+	if !found {
+		return errNoRecords
+	}
+	return nil
+}
+
+func (s *recordStream) records() error {
+	s.out.WriteByte('[')
+	for s.dec.More() {
+		var raw json.RawMessage
+		if err := s.dec.Decode(&raw); err != nil {
+			return err
+		}
+		var rec any
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		s.total++
+		if !s.filter.Match(rec) {
+			continue
+		}
+		if s.kept > 0 {
+			s.out.WriteByte(',')
+		}
+		s.kept++
+		s.out.Write(raw)
+	}
+	if _, err := s.dec.Token(); err != nil {
+		return err
+	}
+	s.out.WriteByte(']')
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFilterApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		records string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "array na raiz", body: `[{"s":"A","n":1},{"s":"B","n":2},{"s":"A","n":3}]`, want: `[{"s":"A","n":1},{"s":"A","n":3}]`},
+		{name: "nenhum mantido", body: `[{"s":"B"}]`, want: `[]`},
+		{name: "array vazio", body: ` [] `, want: `[]`},
+		{name: "registros como vieram", body: `[{"s": "A", "id": 12345678901234567890}]`, want: `[{"s": "A", "id": 12345678901234567890}]`},
+		{name: "objeto único mantido", body: `{"s":"A"}`, want: `{"s":"A"}`},
+		{name: "objeto único descartado", body: `{"s":"B"}`, want: `null`},
+		{
+			name:    "array aninhado",
+			records: "$.data.items",
+			body:    `{"meta":{"page":1},"data":{"total":3,"items":[{"s":"A"},{"s":"B"}],"next":null},"ok":true}`,
+			want:    `{"meta":{"page":1},"data":{"total":3,"items":[{"s":"A"}],"next":null},"ok":true}`,
+		},
+		{name: "caminho ausente", records: "$.data", body: `{"outro":[]}`, wantErr: true},
+		{name: "caminho não é array", records: "$.data", body: `{"data":{"s":"A"}}`, wantErr: true},
+		{name: "JSON truncado", body: `[{"s":"A"},`, wantErr: true},
+		{name: "conteúdo depois do array", body: `[] []`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &FilterConfig{Where: `s == "A"`, Records: tt.records}
+			if err := c.validate(JobConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.apply(JobConfig{Name: "t"}, []byte(tt.body))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("apply(%s) = %s; quer erro", tt.body, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply(%s): %v", tt.body, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("apply(%s) = %s; quer %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRecordsPathKeysOnly(t *testing.T) {
+	c := &FilterConfig{Where: "x", Records: "$.data[0].items"}
+	if err := c.validate(JobConfig{}); err == nil {
+		t.Error("validate: quer erro para caminho com índice")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "test: regrava os arquivos golden com as saídas atuais")
+
+func init() {
+	commands["test"] = testCommand
+}
+
+// arquivos de saída que mudam a cada execução (horários, durações) e não
+// entram no golden
+func volatileOutput(name string) bool {
+	return name == "run-summary.json" || name == "errors.json" || strings.HasSuffix(name, ".meta.json")
+}
+
+// testCommand executa cada caso (diretório com jobs.json, cassette.json e
+// golden/) sem tocar a rede e compara as saídas com o golden.
+func testCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: api-requester test [-update-golden] caso...")
+	}
+
+	failed := 0
+	for _, dir := range args {
+		if err := runGoldenCase(ctx, dir); err != nil {
+			failed++
+			log.Printf("FALHOU %s: %v", dir, err)
+			continue
+		}
+		log.Printf("ok %s", dir)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d de %d casos falharam", failed, len(args))
+	}
+	return nil
+}
+
+func runGoldenCase(ctx context.Context, dir string) error {
+	cassette, err := utils.LoadCassette(filepath.Join(dir, "cassette.json"))
+	if err != nil {
+		return err
+	}
+	cfg, err := loadJobConfig(filepath.Join(dir, "jobs.json"))
+	if err != nil {
+		return err
+	}
+
+	root, err := os.MkdirTemp("", "api-requester-test-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+	outDir := filepath.Join(root, "out")
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	// todo o tráfego (requisições, tokens, sinks) passa pelo cassette, e o
+	// estado e o cache da máquina não entram no caso
+	prevTransport, prevState, prevCache := http.DefaultTransport, stateStore, responseCache
+	defer func() { http.DefaultTransport, stateStore, responseCache = prevTransport, prevState, prevCache }()
+	http.DefaultTransport, responseCache = cassette, nil
+	if stateStore, err = utils.NewStateStore(filepath.Join(root, "state")); err != nil {
+		return err
+	}
+
+	code := runJobs(ctx, cfg, outDir, filepath.Join(outDir, "errors.json"))
+	for _, it := range cassette.Unused() {
+		log.Printf("AVISO: %s: interação não usada: %s", dir, it)
+	}
+	if code != 0 {
+		return fmt.Errorf("jobs falharam (código %d; ver a tabela acima)", code)
+	}
+
+	golden := filepath.Join(dir, "golden")
+	if *updateGolden {
+		return writeGolden(outDir, golden)
+	}
+	return compareGolden(outDir, golden)
+}
+
+func writeGolden(outDir, golden string) error {
+	if err := os.RemoveAll(golden); err != nil {
+		return err
+	}
+	return walkOutputs(outDir, func(rel string, data []byte) error {
+		target := filepath.Join(golden, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+}
+
+func compareGolden(outDir, golden string) error {
+	var diffs []string
+	seen := map[string]bool{}
+
+	err := walkOutputs(outDir, func(rel string, got []byte) error {
+		seen[rel] = true
+		want, err := os.ReadFile(filepath.Join(golden, rel))
+		if os.IsNotExist(err) {
+			diffs = append(diffs, rel+": saída sem golden (rode com -update-golden)")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d := goldenDiff(want, got); d != "" {
+			diffs = append(diffs, rel+": "+d)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(golden, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(golden, path)
+		if !seen[rel] {
+			diffs = append(diffs, rel+": esperado no golden, mas não foi gerado")
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d diferença(s):\n  %s", len(diffs), strings.Join(diffs, "\n  "))
+	}
+	return nil
+}
+
+func walkOutputs(outDir string, fn func(rel string, data []byte) error) error {
+	return filepath.WalkDir(outDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || volatileOutput(d.Name()) {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(outDir, path)
+		return fn(rel, data)
+	})
+}
+
+// goldenDiff aponta a primeira linha diferente; JSON é comparado depois de
+// normalizado (chaves em ordem, indentado), então formatação não conta.
+func goldenDiff(want, got []byte) string {
+	want, got = normalizeJSON(want), normalizeJSON(got)
+	if bytes.Equal(want, got) {
+		return ""
+	}
+	wl, gl := strings.Split(string(want), "\n"), strings.Split(string(got), "\n")
+	for i := 0; i < max(len(wl), len(gl)); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("linha %d: esperado %q, obtido %q", i+1, strings.TrimSpace(w), strings.TrimSpace(g))
+		}
+	}
+	return "conteúdo diferente"
+}
+
+func normalizeJSON(data []byte) []byte {
+	var doc any
+	if json.Unmarshal(data, &doc) != nil {
+		return data
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return data
+	}
+	return out
+}
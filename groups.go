@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+
+	"apiconsume/utils"
+)
+
+var perJobLimiter = flag.Bool("per-job-limiter", false, "cada job sem group usa um rate limiter próprio, em vez de dividir um com os jobs do mesmo provider")
+
+// GroupConfig isola jobs que batem no mesmo host ou provedor: eles dividem
+// um rate limiter e rodam com no máximo Concurrency ao mesmo tempo (padrão
+// 1, em série); grupos diferentes rodam em paralelo entre si.
+type GroupConfig struct {
+	Concurrency int  `json:"concurrency,omitempty"`
+	Rate        *int `json:"rate,omitempty"`
+}
+
+func validateGroups(cfg *MultiJobConfig) error {
+	for name, g := range cfg.Groups {
+		if g.Concurrency < 0 {
+			return fmt.Errorf("group %q: concurrency deve ser >= 0", name)
+		}
+	}
+	return nil
+}
+
+// jobGroups prepara, para cada grupo usado, o semáforo e o rate limiter
+// compartilhado. O limiter parte das configurações do primeiro job do grupo
+// (em ordem de nome), com o rate do grupo por cima.
+type jobGroups struct {
+	slots  map[string]chan struct{}
+	pacers map[string]*utils.RateLimitClient
+	// com -interleave-providers, os jobs sem group de um mesmo provider
+	lanes map[string]*providerLane
+	// sem ele, o rate limiter que esses jobs dividem
+	hosts map[string]*utils.RateLimitClient
+}
+
+func newJobGroups(cfg *MultiJobConfig, defaults JobSettings) *jobGroups {
+	g := &jobGroups{slots: map[string]chan struct{}{}, pacers: map[string]*utils.RateLimitClient{}}
+
+	jobs := append([]JobConfig(nil), cfg.Jobs...)
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+
+	for _, job := range jobs {
+		if job.Group == "" || g.slots[job.Group] != nil {
+			continue
+		}
+		gc := cfg.Groups[job.Group]
+		n := gc.Concurrency
+		if n <= 0 {
+			n = 1
+		}
+		g.slots[job.Group] = make(chan struct{}, n)
+
+		settings := defaults.merge(job.JobSettings)
+		if gc.Rate != nil {
+			settings.Rate = gc.Rate
+		}
+		pacer := newRateClient(settings)
+		pacer.Dialect = job.dialect
+		g.pacers[job.Group] = pacer
+
+		log.Printf("Grupo %s: até %d jobs em paralelo, rate limiter compartilhado", job.Group, n)
+	}
+	switch {
+	case *interleaveProviders:
+		g.lanes = newProviderLanes(jobs, defaults)
+	case !*perJobLimiter:
+		g.hosts = newHostPacers(jobs, defaults)
+	}
+	return g
+}
+
+// newHostPacers cria um rate limiter para cada provider (ver usageKey) com
+// mais de um job sem group, para que a taxa aprendida e a cota valham para
+// todos eles, e não cada job por conta própria. Como nos grupos, o limiter
+// parte das configurações do primeiro job em ordem de nome.
+func newHostPacers(jobs []JobConfig, defaults JobSettings) map[string]*utils.RateLimitClient {
+	count := map[string]int{}
+	for _, job := range jobs {
+		if job.Group == "" {
+			count[job.usageKey()]++
+		}
+	}
+
+	pacers := map[string]*utils.RateLimitClient{}
+	for _, job := range jobs {
+		key := job.usageKey()
+		if job.Group != "" || count[key] < 2 || pacers[key] != nil {
+			continue
+		}
+		pacer := newRateClient(defaults.merge(job.JobSettings))
+		pacer.Dialect = job.dialect
+		pacers[key] = pacer
+		log.Printf("Provider %s: %d jobs num rate limiter compartilhado", key, count[key])
+	}
+	return pacers
+}
+
+// acquire espera a vez do job no seu grupo e devolve a função que libera.
+func (g *jobGroups) acquire(ctx context.Context, job *JobConfig) (func(), error) {
+	slots := g.slots[job.Group]
+	if slots == nil {
+		if lane := g.lanes[job.usageKey()]; lane != nil && job.Group == "" {
+			job.pacer = lane.pacer
+			return lane.acquire(ctx, *job)
+		}
+		if job.Group == "" {
+			job.pacer = g.hosts[job.usageKey()]
+		}
+		return func() {}, nil
+	}
+	job.pacer = g.pacers[job.Group]
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	sinkTypes["gsheets"] = newSheetsSink
+}
+
+// sheetsSink grava os registros numa planilha do Google Sheets, acrescentando
+// linhas (append) ou substituindo o conteúdo da aba (replace).
+type sheetsSink struct {
+	Spreadsheet string         `json:"spreadsheet_id"`
+	Worksheet   string         `json:"worksheet"`
+	Credentials string         `json:"credentials"`
+	Mode        string         `json:"mode"`
+	Records     string         `json:"records"`
+	Schema      string         `json:"schema"`
+	Columns     []sheetsColumn `json:"columns"`
+	Endpoint    string         `json:"endpoint"`
+
+	columns []utils.Column
+	client  *utils.SheetsClient
+}
+
+// sheetsColumn mapeia um campo do registro (jsonpath relativo ao registro)
+// para uma coluna da planilha.
+type sheetsColumn struct {
+	Header string `json:"header"`
+	Path   string `json:"path"`
+
+	path *utils.JSONPath
+}
+
+func newSheetsSink(raw json.RawMessage, baseDir string) (sink, error) {
+	s := &sheetsSink{Mode: "append"}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+
+	if s.Spreadsheet == "" {
+		return nil, fmt.Errorf("gsheets exige spreadsheet_id")
+	}
+	if s.Mode != "append" && s.Mode != "replace" {
+		return nil, fmt.Errorf("mode %q inválido (append ou replace)", s.Mode)
+	}
+
+	for i := range s.Columns {
+		col := &s.Columns[i]
+		if col.Path == "" {
+			return nil, fmt.Errorf("coluna #%d sem path", i+1)
+		}
+		if col.Header == "" {
+			col.Header = col.Path
+		}
+		var err error
+		if col.path, err = utils.ParseJSONPath(col.Path); err != nil {
+			return nil, fmt.Errorf("coluna %q: %w", col.Header, err)
+		}
+	}
+
+	var err error
+	if s.columns, err = loadSchemaColumns(s.Schema, baseDir); err != nil {
+		return nil, err
+	}
+
+	creds := s.Credentials
+	if creds == "" {
+		creds = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if creds == "" {
+		return nil, fmt.Errorf("gsheets exige credentials (ou GOOGLE_APPLICATION_CREDENTIALS)")
+	}
+	if !filepath.IsAbs(creds) {
+		creds = filepath.Join(baseDir, creds)
+	}
+	account, err := utils.LoadGoogleServiceAccount(creds, utils.SheetsScope)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client = &utils.SheetsClient{Endpoint: s.Endpoint, Auth: utils.NewTokenAuth(account)}
+	return s, nil
+}
+
+func (s *sheetsSink) Deliver(ctx context.Context, out sinkOutput) error {
+	headers, rows, err := s.rows(out.Data)
+	if err != nil {
+		return err
+	}
+
+	sheet := s.Worksheet
+	if sheet == "" {
+		sheet = out.Job.Name
+	}
+
+	if s.Mode == "replace" {
+		if err := s.client.Clear(ctx, s.Spreadsheet, utils.SheetRange(sheet, "")); err != nil {
+			return err
+		}
+		return s.client.Update(ctx, s.Spreadsheet, utils.SheetRange(sheet, "A1"), append([][]any{headers}, rows...))
+	}
+
+	// Em append, o cabeçalho só é escrito quando a aba ainda está vazia.
+	first, err := s.client.Get(ctx, s.Spreadsheet, utils.SheetRange(sheet, "1:1"))
+	if err != nil {
+		return err
+	}
+	if len(first) == 0 {
+		rows = append([][]any{headers}, rows...)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return s.client.Append(ctx, s.Spreadsheet, utils.SheetRange(sheet, "A1"), rows)
+}
+
+// rows converte os registros em linhas na ordem das colunas mapeadas ou,
+// sem mapeamento, das colunas do schema (informado ou inferido).
+func (s *sheetsSink) rows(data []byte) ([]any, [][]any, error) {
+	var (
+		records []any
+		headers []any
+		rows    [][]any
+	)
+
+	if len(s.Columns) > 0 {
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, nil, fmt.Errorf("resposta não é JSON: %w", err)
+		}
+		records = utils.SelectRecords(doc, s.Records)
+
+		for _, col := range s.Columns {
+			headers = append(headers, col.Header)
+		}
+		for _, record := range records {
+			row := make([]any, len(s.Columns))
+			for i, col := range s.Columns {
+				value, _ := col.path.First(record)
+				row[i] = sheetCell(value)
+			}
+			rows = append(rows, row)
+		}
+		return headers, rows, nil
+	}
+
+	records, columns, err := tabularRecords(data, s.Records, s.columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, col := range columns {
+		headers = append(headers, col.Name)
+	}
+	for _, record := range records {
+		obj, _ := record.(map[string]any)
+		row := make([]any, len(columns))
+		for i, col := range columns {
+			row[i] = sheetCell(obj[col.Name])
+		}
+		rows = append(rows, row)
+	}
+	return headers, rows, nil
+}
+
+func sheetCell(value any) any {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string, float64, bool, json.Number:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	harFilter = flag.String("har-filter", "", "import-har/replay-har: regex aplicada à URL para escolher as entradas")
+	harOutDir = flag.String("har-out", "har-replay", "replay-har: diretório onde as respostas são salvas")
+)
+
+func init() {
+	commands["import-har"] = importHARCommand
+	commands["replay-har"] = replayHARCommand
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// headers que o transporte do Go controla sozinho ou que não fazem sentido
+// fora do navegador
+var harSkippedHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Connection": true, "Accept-Encoding": true,
+	"Keep-Alive": true, "Transfer-Encoding": true, "Upgrade": true, "Te": true,
+}
+
+func loadHARJobs(path string) ([]JobConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler HAR: %w", err)
+	}
+
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("HAR inválido: %w", err)
+	}
+
+	var filter *regexp.Regexp
+	if *harFilter != "" {
+		filter, err = regexp.Compile(*harFilter)
+		if err != nil {
+			return nil, fmt.Errorf("-har-filter inválido: %w", err)
+		}
+	}
+
+	names := map[string]int{}
+	var jobs []JobConfig
+	for _, entry := range har.Log.Entries {
+		req := entry.Request
+		if filter != nil && !filter.MatchString(req.URL) {
+			continue
+		}
+
+		headers := map[string]string{}
+		for _, h := range req.Headers {
+			key := http.CanonicalHeaderKey(h.Name)
+			if strings.HasPrefix(h.Name, ":") || harSkippedHeaders[key] {
+				continue
+			}
+			headers[key] = h.Value
+		}
+
+		job := JobConfig{Method: strings.ToUpper(req.Method), URL: req.URL, DateParam: ptr("")}
+		if len(headers) > 0 {
+			job.Headers = headers
+		}
+		if req.PostData != nil {
+			job.Body = req.PostData.Text
+		}
+
+		base := jobNameFromURL(req.URL)
+		names[base]++
+		job.Name = fmt.Sprintf("%s-%d", base, names[base])
+		jobs = append(jobs, job)
+	}
+
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("nenhuma entrada do HAR selecionada")
+	}
+	return jobs, nil
+}
+
+func importHARCommand(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("uso: api-requester import-har [-har-filter regex] [-o jobs.json] arquivo.har")
+	}
+
+	jobs, err := loadHARJobs(args[0])
+	if err != nil {
+		return err
+	}
+	return emitImportedJobs(jobs)
+}
+
+// replayHARCommand reenvia as entradas em ordem, passando pelo rate limiter e
+// pelos retries, e salva cada resposta para comparação com o que o navegador viu.
+func replayHARCommand(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("uso: api-requester replay-har [-har-filter regex] [-har-out dir] arquivo.har")
+	}
+
+	jobs, err := loadHARJobs(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*harOutDir, 0o755); err != nil {
+		return err
+	}
+
+	rl := newRateClient(flagSettings())
+	var errors []ErrorResponse
+
+	for i, job := range jobs {
+		if err := waitExecutionWindow(ctx); err != nil {
+			return err
+		}
+
+		reqCtx, trace := utils.WithAttemptTrace(ctx)
+		body, status, err := doRequest(reqCtx, rl, job.spec(job.URL))
+		log.Printf("[%d/%d] %s %s -> %d", i+1, len(jobs), job.Method, job.URL, status)
+
+		if err != nil {
+			errors = append(errors, ErrorResponse{At: time.Now(), Attempt: i + 1, Item: job.Name, Error: err.Error(), Attempts: attemptDetails(trace)})
+			continue
+		}
+		if status >= 400 {
+			failure := requestError(nil, status, body, nil)
+			errors = append(errors, ErrorResponse{At: time.Now(), Attempt: i + 1, Item: job.Name, Error: failure.Error(), Attempts: attemptDetails(trace)}.withEnvelope(failure))
+		}
+
+		writeFile(filepath.Join(*harOutDir, fmt.Sprintf("%03d-%s.body", i+1, job.Name)), body)
+	}
+
+	if len(errors) > 0 {
+		saveErrors(filepath.Join(*harOutDir, "errors.json"), errors)
+		return fmt.Errorf("%d de %d requisições falharam", len(errors), len(jobs))
+	}
+	return nil
+}
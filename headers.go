@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ResponseHeadersConfig guarda headers da primeira resposta aceita da
+// coleta (ex: X-Total-Count, para reconciliar com o total informado pelo
+// servidor) em response-<job>.meta.json e, com Inject, também na saída:
+// como campo do objeto raiz ou de cada registro do array.
+type ResponseHeadersConfig struct {
+	Names  []string `json:"names"`
+	Inject string   `json:"inject,omitempty"`
+}
+
+func (c *ResponseHeadersConfig) validate(job JobConfig) error {
+	switch {
+	case len(c.Names) == 0:
+		return fmt.Errorf("names vazio")
+	case job.BulkInput != "":
+		return fmt.Errorf("não se aplica a bulk_input")
+	case job.Download != nil && c.Inject != "":
+		return fmt.Errorf("inject não se aplica a download")
+	}
+	return nil
+}
+
+// pick devolve os headers pedidos que vieram na resposta, com o nome
+// canônico; respostas do cache não têm headers.
+func (c *ResponseHeadersConfig) pick(h http.Header) map[string]string {
+	values := map[string]string{}
+	for _, name := range c.Names {
+		if v := h.Get(name); v != "" {
+			values[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+	return values
+}
+
+func injectHeaders(field string, values map[string]string, data []byte) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("response_headers.inject: saída não é JSON: %w", err)
+	}
+	switch v := doc.(type) {
+	case map[string]any:
+		v[field] = values
+	case []any:
+		for _, rec := range v {
+			if obj, ok := rec.(map[string]any); ok {
+				obj[field] = values
+			}
+		}
+	default:
+		return nil, fmt.Errorf("response_headers.inject: saída não é objeto nem array")
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var historyMax = ByteSize(10 << 20)
+
+func init() {
+	commands["history"] = historyCommand
+	flag.Var(&historyMax, "history-max", "rotaciona run-history.ndjson para run-history.1.ndjson ao passar deste tamanho, ex: 10MB (0 = sem limite)")
+}
+
+const (
+	historyFile  = "run-history.ndjson"
+	historyUsage = "uso: api-requester history tail [-f] [-job nome] [-n 20] [-no-color]"
+)
+
+// historyRecord é uma linha de run-history.ndjson: o resultado de um job
+// numa execução de -config, como em run-summary.json, com a execução e o
+// horário.
+type historyRecord struct {
+	RunID string    `json:"run_id"`
+	At    time.Time `json:"at"`
+	jobResult
+}
+
+// appendHistory acrescenta os jobs da execução ao histórico ao lado de
+// run-summary.json, que só guarda a última.
+func appendHistory(outputDir string, summary runSummary) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range summary.Jobs {
+		if err := enc.Encode(historyRecord{RunID: summary.RunID, At: summary.FinishedAt, jobResult: r}); err != nil {
+			log.Printf("Erro ao gerar histórico: %v", err)
+			return
+		}
+	}
+
+	path := filepath.Join(outputDir, historyFile)
+	if info, err := os.Stat(path); err == nil && historyMax > 0 && info.Size()+int64(buf.Len()) > int64(historyMax) {
+		if err := os.Rename(path, rotatedHistory(path)); err != nil {
+			log.Printf("Erro ao rotacionar %s: %v", historyFile, err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		log.Printf("Erro ao abrir %s: %v", historyFile, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		log.Printf("Erro ao gravar %s: %v", historyFile, err)
+	}
+}
+
+func rotatedHistory(path string) string {
+	return strings.TrimSuffix(path, ".ndjson") + ".1.ndjson"
+}
+
+// historyCommand mostra o histórico de execuções legível, para o operador
+// não ter que ler o NDJSON na mão durante um incidente: tail lista os
+// últimos registros e, com -f, segue os novos.
+func historyCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "tail" {
+		return fmt.Errorf(historyUsage)
+	}
+	fs := flag.NewFlagSet("history tail", flag.ContinueOnError)
+	follow := fs.Bool("f", false, "segue os registros novos")
+	job := fs.String("job", "", "só os registros deste job")
+	n := fs.Int("n", 20, "registros anteriores mostrados")
+	noColor := fs.Bool("no-color", false, "sem cores (também com NO_COLOR ou fora de um terminal)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf(historyUsage)
+	}
+
+	p := historyPrinter{job: *job, color: !*noColor && colorOutput()}
+	path := historyFile
+
+	var recent []historyRecord
+	for _, file := range []string{rotatedHistory(path), path} {
+		data, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		recent = append(recent, p.parse(data)...)
+	}
+	if len(recent) > *n {
+		recent = recent[len(recent)-*n:]
+	}
+	for _, rec := range recent {
+		p.print(rec)
+	}
+	if !*follow {
+		if len(recent) == 0 {
+			fmt.Fprintf(os.Stderr, "Nenhum registro em %s\n", path)
+		}
+		return nil
+	}
+	return p.follow(ctx, path)
+}
+
+// colorOutput diz se o stdout é um terminal que aceita cores.
+func colorOutput() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type historyPrinter struct {
+	job   string
+	color bool
+}
+
+// parse lê as linhas completas de data, pulando as que não são registros
+// e as de outros jobs.
+func (p historyPrinter) parse(data []byte) []historyRecord {
+	var out []historyRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var rec historyRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.Job == "" {
+			continue
+		}
+		if p.job == "" || rec.Job == p.job {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiDim    = "\033[2m"
+)
+
+func (p historyPrinter) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+func (p historyPrinter) print(rec historyRecord) {
+	status, color := "ok", ansiGreen
+	switch {
+	case rec.Maintenance:
+		status, color = "MANUTENÇÃO", ansiYellow
+	case rec.Skipped:
+		status, color = "IGNORADO", ansiYellow
+	case !rec.Success:
+		status, color = "FALHA", ansiRed
+		if rec.Critical {
+			status += " (critical)"
+		}
+	}
+
+	line := fmt.Sprintf("%s  %s  %s  %d registros  %v  %d tentativas",
+		p.paint(ansiDim, rec.At.Local().Format("2006-01-02 15:04:05")),
+		rec.Job, p.paint(color, status), rec.Records, rec.Duration.Round(time.Millisecond), rec.Attempts)
+	if rec.Retries != nil && rec.Retries.Retries > 0 {
+		line += fmt.Sprintf(" (%d novas)", rec.Retries.Retries)
+	}
+	if rec.Rejected > 0 {
+		line += fmt.Sprintf("  %d em quarentena", rec.Rejected)
+	}
+	if !rec.StaleFrom.IsZero() {
+		line += p.paint(ansiYellow, "  stale de "+rec.StaleFrom.Local().Format("2006-01-02 15:04"))
+	}
+	line += p.paint(ansiDim, "  run "+rec.RunID)
+	fmt.Println(line)
+	if rec.Error != "" {
+		fmt.Println("    " + p.paint(color, rec.Error))
+	}
+}
+
+// historyPoll é o intervalo com que -f procura registros novos.
+const historyPoll = time.Second
+
+// follow mostra os registros acrescentados a path depois do fim atual, até
+// ctx acabar; se o arquivo foi rotacionado (é outro, ou ficou menor),
+// recomeça do início do novo.
+func (p historyPrinter) follow(ctx context.Context, path string) error {
+	var offset int64
+	last, err := os.Stat(path)
+	if err == nil {
+		offset = last.Size()
+	}
+	var partial []byte
+	for {
+		if err := utils.SleepContext(ctx, historyPoll); err != nil {
+			return nil
+		}
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			offset, partial = 0, nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err == nil && (last == nil || !os.SameFile(last, info) || info.Size() < offset) {
+			offset, partial = 0, nil
+		}
+		if err == nil {
+			last = info
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+
+		data = append(partial, data...)
+		end := bytes.LastIndexByte(data, '\n') + 1
+		partial = append([]byte(nil), data[end:]...)
+		for _, rec := range p.parse(data[:end]) {
+			p.print(rec)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// listenToken lê de env o token que os clientes de um servidor HTTP (serve,
+// cache-proxy) precisam mandar. Sem token, o servidor só sobe num endereço
+// de loopback (ver generateToken), e nem assim quando required.
+func listenToken(env, addr string, required bool) (string, error) {
+	token := os.Getenv(env)
+	if token != "" {
+		return token, nil
+	}
+	if required {
+		return "", fmt.Errorf("defina o token dos clientes na variável %s", env)
+	}
+	if !loopbackAddr(addr) {
+		return "", fmt.Errorf("%s ouve fora do loopback: defina o token dos clientes na variável %s ou use 127.0.0.1", addr, env)
+	}
+	return "", nil
+}
+
+// generateToken cria um token aleatório para quando a variável não foi
+// definida e o grava em path, só para o usuário, de onde os scripts locais
+// o leem.
+func generateToken(path string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && loopbackHost(host)
+}
+
+func loopbackHost(host string) bool {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireLoopbackHost recusa, num servidor que ouve no loopback, as
+// requisições com Host de fora dele: é o que chega de uma página que
+// reapontou o próprio domínio para 127.0.0.1 (DNS rebinding).
+func requireLoopbackHost(addr string, next http.Handler) http.Handler {
+	if !loopbackAddr(addr) {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !loopbackHost(host) {
+			writeJSON(w, http.StatusMisdirectedRequest, map[string]string{"error": fmt.Sprintf("host %q não é de loopback", r.Host)})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireJSON responde 415 quando o body não é declarado como JSON: um
+// formulário ou text/plain de outra página no navegador chega sem preflight
+// de CORS.
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "envie o body com Content-Type: application/json"})
+		return false
+	}
+	return true
+}
+
+// requireBearer só deixa passar as requisições com "Bearer <token>" em
+// header; sem token, deixa passar todas.
+func requireBearer(token, header string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get(header), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api-requester"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "token ausente ou inválido em " + header})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+
+	"apiconsume/utils"
+)
+
+const (
+	clientShared   = "shared"
+	clientIsolated = "isolated"
+)
+
+func validateClientMode(mode string) error {
+	switch mode {
+	case "", clientShared, clientIsolated:
+		return nil
+	}
+	return fmt.Errorf("client deve ser shared ou isolated")
+}
+
+// isolatedClient é o pool de conexões e o cookie jar de um job com client
+// "isolated", guardados entre execuções (serve, watch) para não abrir um
+// pool novo a cada uma.
+type isolatedClient struct {
+	transport http.RoundTripper
+	jar       http.CookieJar
+}
+
+var (
+	isolatedMu      sync.Mutex
+	isolatedClients = map[string]*isolatedClient{}
+)
+
+// isolatedHTTPClient é o client de um job com client "isolated": transporte
+// próprio (conexões e sessões TLS) e cookie jar próprio, para que nada de um
+// tenant sirva a outro. Com "shared" (padrão), todos os jobs usam o pool
+// comum do http.DefaultTransport, que reaproveita conexões com o mesmo
+// host, sem cookies. Com bind ou ip_family, o transporte parte do pool com
+// essas opções de discagem.
+func (job JobConfig) isolatedHTTPClient(dial dialOptions) *http.Client {
+	isolatedMu.Lock()
+	c, ok := isolatedClients[job.Name]
+	if !ok {
+		base := http.DefaultTransport
+		if dial.custom() {
+			base = dialTransport(dial)
+		}
+		c = &isolatedClient{transport: base}
+		// sob -test o transporte padrão é o cassette, que não tem pool
+		if t, ok := base.(*http.Transport); ok {
+			c.transport = t.Clone()
+		}
+		c.jar, _ = cookiejar.New(nil)
+		isolatedClients[job.Name] = c
+	}
+	isolatedMu.Unlock()
+
+	transport := c.transport
+	if chaos != nil {
+		transport = chaos.Over(transport)
+	}
+	return &http.Client{Transport: transport, Jar: c.jar, CheckRedirect: utils.CheckRedirect}
+}
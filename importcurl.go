@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var (
+	importJobName = flag.String("name", "", "import: nome do job gerado (padrão: último segmento da URL)")
+	importOutput  = flag.String("o", "", "import: arquivo de configuração onde o job é adicionado (padrão: stdout)")
+)
+
+func init() {
+	commands["import-curl"] = importCurlCommand
+}
+
+func importCurlCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: api-requester import-curl [-name nome] [-o jobs.json] 'curl ...'")
+	}
+
+	tokens, err := splitShellWords(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	job, err := parseCurl(tokens)
+	if err != nil {
+		return err
+	}
+
+	if *importJobName != "" {
+		job.Name = *importJobName
+	}
+
+	return emitImportedJobs([]JobConfig{job})
+}
+
+func emitImportedJobs(jobs []JobConfig) error {
+	if *importOutput == "" {
+		data, err := marshalJobConfig(&MultiJobConfig{Jobs: jobs})
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, job := range jobs {
+		if err := appendJobToConfig(*importOutput, job); err != nil {
+			return err
+		}
+		log.Printf("Job %q adicionado em %s", job.Name, *importOutput)
+	}
+	return nil
+}
+
+func parseCurl(tokens []string) (JobConfig, error) {
+	var job JobConfig
+
+	if len(tokens) > 0 && tokens[0] == "curl" {
+		tokens = tokens[1:]
+	}
+
+	var (
+		data     []string
+		rawURL   string
+		forceGet bool
+		// -d e afins sem Content-Type saem do curl como formulário
+		form bool
+	)
+	headers := map[string]string{}
+
+	next := func(i *int, flagName string) (string, error) {
+		*i++
+		if *i >= len(tokens) {
+			return "", fmt.Errorf("flag %s sem valor", flagName)
+		}
+		return tokens[*i], nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		// aceita --flag=valor além de --flag valor
+		name, inline, hasInline := strings.Cut(tok, "=")
+		if !strings.HasPrefix(tok, "--") || !hasInline {
+			name, inline, hasInline = tok, "", false
+		}
+		value := func() (string, error) {
+			if hasInline {
+				return inline, nil
+			}
+			return next(&i, name)
+		}
+
+		switch name {
+		case "-X", "--request":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			job.Method = strings.ToUpper(v)
+		case "-H", "--header":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			k, hv, ok := strings.Cut(v, ":")
+			if !ok {
+				return job, fmt.Errorf("header inválido %q", v)
+			}
+			headers[http.CanonicalHeaderKey(strings.TrimSpace(k))] = strings.TrimSpace(hv)
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii", "--data-urlencode":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			if strings.HasPrefix(v, "@") && name != "--data-raw" {
+				return job, fmt.Errorf("body a partir de arquivo (%s) não é suportado", v)
+			}
+			if name == "--data-urlencode" {
+				if v, err = curlURLEncode(v); err != nil {
+					return job, err
+				}
+			}
+			data = append(data, v)
+			form = true
+		case "--json":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			data = append(data, v)
+			headers["Content-Type"] = "application/json"
+			headers["Accept"] = "application/json"
+		case "-u", "--user":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(v))
+		case "-A", "--user-agent":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			headers["User-Agent"] = v
+		case "-b", "--cookie":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			headers["Cookie"] = v
+		case "-e", "--referer":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			headers["Referer"] = v
+		case "--url":
+			v, err := value()
+			if err != nil {
+				return job, err
+			}
+			rawURL = v
+		case "-G", "--get":
+			forceGet = true
+		case "-o", "--output", "-m", "--max-time", "--connect-timeout", "-w", "--write-out", "--retry":
+			if _, err := value(); err != nil {
+				return job, err
+			}
+		default:
+			if strings.HasPrefix(tok, "-") {
+				// flags sem efeito na requisição (-s, -k, -L, -i, -v, --compressed...)
+				continue
+			}
+			rawURL = tok
+		}
+	}
+
+	if rawURL == "" {
+		return job, fmt.Errorf("nenhuma URL encontrada no comando curl")
+	}
+
+	body := strings.Join(data, "&")
+	if forceGet && body != "" {
+		sep := "?"
+		if strings.Contains(rawURL, "?") {
+			sep = "&"
+		}
+		rawURL += sep + body
+		body = ""
+	}
+
+	if job.Method == "" {
+		job.Method = http.MethodGet
+		if body != "" {
+			job.Method = http.MethodPost
+		}
+	}
+	if _, ok := headers["Content-Type"]; form && body != "" && !ok {
+		headers["Content-Type"] = "application/x-www-form-urlencoded"
+	}
+
+	job.URL = rawURL
+	// o curl não mandava a data do dia
+	job.DateParam = ptr("")
+	job.Body = body
+	if len(headers) > 0 {
+		job.Headers = headers
+	}
+	job.Name = jobNameFromURL(rawURL)
+	return job, nil
+}
+
+// curlURLEncode codifica o valor de --data-urlencode como o curl: "conteúdo"
+// e "=conteúdo" inteiros, "nome=conteúdo" só depois do "=".
+func curlURLEncode(v string) (string, error) {
+	name, content, ok := strings.Cut(v, "=")
+	if !ok {
+		// "nome@arquivo", como no curl
+		if strings.Contains(v, "@") {
+			return "", fmt.Errorf("body a partir de arquivo (%s) não é suportado", v)
+		}
+		name, content = "", v
+	}
+	escaped := strings.ReplaceAll(url.QueryEscape(content), "+", "%20")
+	if name == "" {
+		return escaped, nil
+	}
+	return name + "=" + escaped, nil
+}
+
+func jobNameFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "job"
+	}
+
+	name := path.Base(strings.TrimRight(u.Path, "/"))
+	if name == "." || name == "/" || name == "" {
+		name = u.Hostname()
+	}
+
+	name = strings.TrimSuffix(strings.TrimPrefix(bulkFileName(name), "response-"), ".json")
+	if name == "" {
+		return "job"
+	}
+	return name
+}
+
+// splitShellWords separa o comando como um shell POSIX faria, tratando aspas
+// simples, duplas, escapes e quebras de linha com "\".
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+	)
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]):
+				i++
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+				}
+			default:
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] == '\n' || runes[i] == '\r' {
+					continue
+				}
+				current.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("aspas sem fechamento no comando")
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want []string
+	}{
+		{"palavras", "curl -X GET https://api.com", []string{"curl", "-X", "GET", "https://api.com"}},
+		{"espaços repetidos", "  curl \t -v  ", []string{"curl", "-v"}},
+		{"aspas simples", `curl -H 'Authorization: Bearer x'`, []string{"curl", "-H", "Authorization: Bearer x"}},
+		{"aspas duplas", `curl -d "{\"a\": 1}"`, []string{"curl", "-d", `{"a": 1}`}},
+		{"escape literal em aspas simples", `'a\"b'`, []string{`a\"b`}},
+		{"barra que não escapa em aspas duplas", `"a\nb"`, []string{`a\nb`}},
+		{"escape fora de aspas", `a\ b c`, []string{"a b", "c"}},
+		{"continuação de linha", "curl \\\n  -v \\\r\n  https://api.com", []string{"curl", "-v", "https://api.com"}},
+		{"continuação dentro de aspas duplas", "\"a\\\nb\"", []string{"ab"}},
+		{"aspas vazias", `curl -d ''`, []string{"curl", "-d", ""}},
+		{"aspas coladas", `x'y'"z"`, []string{"xyz"}},
+		{"vazio", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitShellWords(tt.cmd)
+			if err != nil {
+				t.Fatalf("splitShellWords(%q): %v", tt.cmd, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitShellWords(%q) = %q; quer %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitShellWordsUnclosedQuote(t *testing.T) {
+	for _, cmd := range []string{`curl 'abc`, `curl "abc`, `curl "a\"`} {
+		if _, err := splitShellWords(cmd); err == nil {
+			t.Errorf("splitShellWords(%q): quer erro", cmd)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var postmanEnv = flag.String("postman-env", "", "import-postman: arquivo de environment do Postman para resolver variáveis")
+
+func init() {
+	commands["import-postman"] = importPostmanCommand
+}
+
+type postmanKV struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Disabled bool   `json:"disabled"`
+	Enabled  *bool  `json:"enabled"`
+	Type     string `json:"type"`
+}
+
+func (kv postmanKV) active() bool {
+	return !kv.Disabled && (kv.Enabled == nil || *kv.Enabled)
+}
+
+func (kv postmanKV) str() string {
+	if s, ok := kv.Value.(string); ok {
+		return s
+	}
+	if kv.Value == nil {
+		return ""
+	}
+	return fmt.Sprint(kv.Value)
+}
+
+type postmanAuth struct {
+	Type   string      `json:"type"`
+	Bearer []postmanKV `json:"bearer"`
+	Basic  []postmanKV `json:"basic"`
+	APIKey []postmanKV `json:"apikey"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Auth    *postmanAuth    `json:"auth"`
+	Request *postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	Header []postmanKV     `json:"header"`
+	URL    json.RawMessage `json:"url"`
+	Auth   *postmanAuth    `json:"auth"`
+	Body   *struct {
+		Mode string `json:"mode"`
+		Raw  string `json:"raw"`
+	} `json:"body"`
+}
+
+type postmanCollection struct {
+	Item     []postmanItem `json:"item"`
+	Auth     *postmanAuth  `json:"auth"`
+	Variable []postmanKV   `json:"variable"`
+}
+
+var postmanVarPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+func importPostmanCommand(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("uso: api-requester import-postman [-postman-env env.json] [-o jobs.json] collection.json")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("erro ao ler collection: %w", err)
+	}
+
+	var col postmanCollection
+	if err := json.Unmarshal(data, &col); err != nil {
+		return fmt.Errorf("collection inválida: %w", err)
+	}
+
+	vars := map[string]string{}
+	for _, v := range col.Variable {
+		if v.active() {
+			vars[v.Key] = v.str()
+		}
+	}
+
+	if *postmanEnv != "" {
+		envData, err := os.ReadFile(*postmanEnv)
+		if err != nil {
+			return fmt.Errorf("erro ao ler environment: %w", err)
+		}
+		var env struct {
+			Values []postmanKV `json:"values"`
+		}
+		if err := json.Unmarshal(envData, &env); err != nil {
+			return fmt.Errorf("environment inválido: %w", err)
+		}
+		for _, v := range env.Values {
+			if v.active() {
+				vars[v.Key] = v.str()
+			}
+		}
+	}
+
+	conv := &postmanConverter{vars: vars, names: map[string]int{}, secrets: map[string]bool{}}
+	conv.walk(col.Item, col.Auth, "")
+
+	if len(conv.jobs) == 0 {
+		return fmt.Errorf("nenhuma requisição encontrada na collection")
+	}
+	if missing := conv.unresolved(); len(missing) > 0 {
+		return fmt.Errorf("variáveis não resolvidas: {{%s}}; defina-as na collection ou em -postman-env", strings.Join(missing, "}}, {{"))
+	}
+	if len(conv.secrets) > 0 {
+		var names []string
+		for name := range conv.secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		log.Printf("AVISO: os segredos da autenticação viraram {{env}}; exporte %s no ambiente da execução", strings.Join(names, ", "))
+	}
+
+	return emitImportedJobs(conv.jobs)
+}
+
+type postmanConverter struct {
+	vars       map[string]string
+	names      map[string]int
+	jobs       []JobConfig
+	missingSet map[string]bool
+	secrets    map[string]bool
+}
+
+func (c *postmanConverter) walk(items []postmanItem, inherited *postmanAuth, prefix string) {
+	for _, item := range items {
+		auth := inherited
+		if item.Auth != nil {
+			auth = item.Auth
+		}
+
+		if item.Request == nil {
+			c.walk(item.Item, auth, prefix+item.Name+"-")
+			continue
+		}
+
+		if item.Request.Auth != nil {
+			auth = item.Request.Auth
+		}
+		c.jobs = append(c.jobs, c.convert(prefix+item.Name, item.Request, auth))
+	}
+}
+
+func (c *postmanConverter) convert(name string, req *postmanRequest, auth *postmanAuth) JobConfig {
+	job := JobConfig{
+		Name:   c.uniqueName(name),
+		Method: strings.ToUpper(req.Method),
+	}
+	if job.Method == "" {
+		job.Method = "GET"
+	}
+
+	headers := map[string]string{}
+	for _, h := range req.Header {
+		if h.active() {
+			headers[h.Key] = c.expand(h.str())
+		}
+	}
+
+	rawURL := c.expand(postmanRawURL(req.URL))
+
+	if auth != nil {
+		switch auth.Type {
+		case "bearer":
+			job.Auth = &AuthConfig{Type: "bearer", Token: c.secret(postmanAuthValue(auth.Bearer, "token"), "TOKEN")}
+		case "basic":
+			job.Auth = &AuthConfig{
+				Type:     "basic",
+				Username: c.expand(postmanAuthValue(auth.Basic, "username")),
+				Password: c.secret(postmanAuthValue(auth.Basic, "password"), "PASSWORD"),
+			}
+		case "apikey":
+			job.Auth = &AuthConfig{Type: "apikey", Token: c.secret(postmanAuthValue(auth.APIKey, "value"), "API_KEY")}
+			key := c.expand(postmanAuthValue(auth.APIKey, "key"))
+			if postmanAuthValue(auth.APIKey, "in") == "query" {
+				job.Auth.Param = key
+			} else {
+				job.Auth.Header = key
+			}
+		case "", "noauth", "inherit":
+		default:
+			log.Printf("Aviso: autenticação %q do request %q não suportada", auth.Type, name)
+		}
+	}
+
+	if req.Body != nil && req.Body.Mode == "raw" && req.Body.Raw != "" {
+		job.Body = c.expand(req.Body.Raw)
+	}
+
+	job.URL = rawURL
+	job.DateParam = ptr("")
+	if len(headers) > 0 {
+		job.Headers = headers
+	}
+	return job
+}
+
+func (c *postmanConverter) expand(s string) string {
+	return postmanVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		key := postmanVarPattern.FindStringSubmatch(m)[1]
+		if v, ok := c.vars[key]; ok {
+			return v
+		}
+		if c.missingSet == nil {
+			c.missingSet = map[string]bool{}
+		}
+		c.missingSet[key] = true
+		return m
+	})
+}
+
+func (c *postmanConverter) unresolved() []string {
+	var out []string
+	for k := range c.missingSet {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// secret troca o segredo da autenticação por uma referência ao ambiente,
+// para que não fique no arquivo de jobs: {{token}} vira {{env "TOKEN"}};
+// um valor literal usa fallback como nome da variável.
+func (c *postmanConverter) secret(value, fallback string) string {
+	if value == "" {
+		return ""
+	}
+	name := fallback
+	if m := postmanVarPattern.FindStringSubmatch(value); m != nil && m[0] == strings.TrimSpace(value) {
+		name = postmanEnvName(m[1])
+	}
+	c.secrets[name] = true
+	return fmt.Sprintf("{{env %q}}", name)
+}
+
+// postmanEnvName é o nome de variável de ambiente de uma variável do
+// Postman: maiúsculas, com _ no lugar do que não for letra ou dígito.
+func postmanEnvName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+func (c *postmanConverter) uniqueName(name string) string {
+	base := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(bulkFileName(strings.ReplaceAll(name, " ", "-")), "response-"), ".json"))
+	c.names[base]++
+	if n := c.names[base]; n > 1 {
+		return fmt.Sprintf("%s-%d", base, n)
+	}
+	return base
+}
+
+func postmanRawURL(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var u struct {
+		Raw string `json:"raw"`
+	}
+	json.Unmarshal(raw, &u)
+	return u.Raw
+}
+
+func postmanAuthValue(kvs []postmanKV, key string) string {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.str()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// runJobs executa os jobs em paralelo e devolve o código de saída conforme
+// -fail-on.
+func runJobs(ctx context.Context, cfg *MultiJobConfig, outputDir, errorLogPath string) int {
+	defaults := flagSettings().merge(cfg.Defaults)
+	started := time.Now()
+	runID := newRunID()
+	log.Printf("Execução %s", runID)
+	groups := newJobGroups(cfg, defaults)
+	budget := newRunBudget(ctx, cfg)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		errors    []ErrorResponse
+		results   []jobResult
+		succeeded = map[string]bool{}
+	)
+
+	for _, job := range cfg.Jobs {
+		wg.Add(1)
+		go func(job JobConfig) {
+			defer wg.Done()
+
+			job.runID = runID
+			res := jobResult{Job: job.Name, Critical: job.isCritical(), Optional: job.Optional}
+			prio, _ := job.priority()
+			settings := defaults.merge(job.JobSettings)
+
+			var jobErrors []ErrorResponse
+			unlock, lockErr := stateStore.LockJob(shard.lockName(job))
+			if lockErr != nil {
+				jobErrors = skipStateLocked(job, &res, lockErr)
+			} else if at, done := alreadyDone(job, settings, time.Now()); done {
+				skipAlreadyDone(job, &res, at)
+			} else if m, end := job.maintenanceAt(time.Now()); m != nil && !m.tolerate() {
+				skipMaintenance(job, &res, m, end)
+			} else if blocked := wafCooldownFor(job, time.Now()); blocked != nil {
+				skipWAFCooldown(job, &res, blocked)
+			} else if release, err := groups.acquire(ctx, &job); err != nil {
+				jobErrors = jobFailure(job, err)
+				res.Error = err.Error()
+			} else if d, ok := budget.allocate(job); !ok {
+				release()
+				skipOutOfTime(job, &res)
+			} else {
+				jobCtx, cancel := withBudget(utils.WithPriority(ctx, prio), d)
+				jobErrors = runJob(jobCtx, job, settings, outputDir, &res)
+				cancel()
+				release()
+				if len(jobErrors) == 0 {
+					recordDone(job, settings, time.Now())
+				} else {
+					serveStale(ctx, job, outputDir, &res, time.Now())
+				}
+			}
+			unlock()
+			// em mode tolerate, a falha durante a manutenção é esperada: fica
+			// em errors.json, mas sem alerta
+			if m, end := job.maintenanceAt(time.Now()); m != nil && len(jobErrors) > 0 {
+				skipMaintenance(job, &res, m, end)
+			} else if len(jobErrors) > 0 {
+				notifyFailure(ctx, job, jobErrors)
+			}
+
+			mu.Lock()
+			errors = append(errors, jobErrors...)
+			results = append(results, res)
+			succeeded[job.Name] = len(jobErrors) == 0 && !res.Skipped
+			mu.Unlock()
+		}(job)
+	}
+
+	wg.Wait()
+
+	errors = append(errors, runJoins(cfg.Joins, succeeded, outputDir)...)
+
+	if len(errors) > 0 {
+		saveErrors(errorLogPath, errors)
+	}
+	var rejects []rejectedRecord
+	for _, res := range results {
+		rejects = append(rejects, res.rejects...)
+	}
+	saveRejects(filepath.Join(filepath.Dir(errorLogPath), "rejects.ndjson"), rejects)
+
+	log.Printf("%d jobs executados, %d falhas registradas", len(cfg.Jobs), len(errors))
+	notifyWarnings(ctx, runID, runWarnings(results))
+	saveRunRequests(runID, started, results)
+	return summarizeRun(runID, results, started, outputDir)
+}
+
+func runJob(ctx context.Context, job JobConfig, settings JobSettings, outputDir string, res *jobResult) (errs []ErrorResponse) {
+	rl := job.rateClient(settings)
+	seedProfile(job, rl)
+	rl.Requests = newRequestLog()
+	job.rejects = &rejectLog{}
+	started := time.Now()
+	var meta runMetadata
+	// com tenants, os clientes de cada API key (ver runTenants)
+	var keyClients []*utils.RateLimitClient
+	defer func() {
+		writeRunMetadata(job, rl, outputDir, started, errs, meta)
+		recordUsage(job, rl.Usage.Snapshot(), time.Now())
+		recordProfile(job, rl, time.Now())
+		recordWAFCooldown(job, rl)
+
+		res.Success = len(errs) == 0
+		res.Duration = Duration{time.Since(started)}
+		res.Attempts = rl.Attempts()
+		stats := rl.RetryStats()
+		for _, kc := range keyClients {
+			res.Attempts += kc.Attempts()
+			stats.Merge(kc.RetryStats())
+		}
+		if stats.Requests > 0 {
+			res.Retries = &stats
+		}
+		samples := rl.Latency.Samples()
+		recordLatency(job, samples, time.Now())
+		if job.SLO != nil {
+			res.SLO = trackSLO(job, samples, time.Now(), rl.Warnings)
+		}
+		checkSafeRate(job, rl, time.Now())
+		res.warnings = jobWarnings(rl)
+		res.rejects = job.rejects.list()
+		res.requests.Requests, res.requests.Dropped = rl.Requests.Records()
+		res.Rejected = len(res.rejects)
+		if len(errs) == 1 {
+			res.Error = errs[0].Error
+		} else if len(errs) > 1 {
+			res.Error = fmt.Sprintf("%d falhas (ver errors.json)", len(errs))
+		}
+	}()
+
+	job, urlRequest := job.localize(settings, job.requestURL())
+	job, urlRequest = job.versioned(urlRequest)
+	limits := settings.limits()
+
+	ctx, cancel := limits.withDeadline(ctx)
+	defer cancel()
+	ctx, trace := utils.WithAttemptTrace(ctx)
+
+	log.Printf("[%s] Iniciando job", job.Name)
+
+	if job.BulkInput != "" {
+		ids, err := loadBulkInput(job.BulkInput)
+		if err != nil {
+			return jobFailure(job, err)
+		}
+		ids, shardDone, err := claimShard(job.Name, ids)
+		if err != nil {
+			return jobFailure(job, err)
+		}
+		defer shardDone()
+
+		jobDir := filepath.Join(outputDir, job.Name)
+		if err := os.MkdirAll(jobDir, 0o755); err != nil {
+			return jobFailure(job, err)
+		}
+
+		opts := bulkOptionsFromFlags()
+		if settings.Concurrency != nil {
+			opts.MaxWorkers = *settings.Concurrency
+		}
+		opts.Limits = limits
+		opts.Status = job.Status
+		opts.ErrorEnvelope = job.ErrorEnvelope
+		opts.Provider = job.usageKey()
+		spec, err := job.render(urlRequest, templateVars{})
+		if err != nil {
+			return jobFailure(job, fmt.Errorf("erro no template da requisição: %w", err))
+		}
+		errs := runBulk(ctx, rl, spec, ids, jobDir, opts)
+		res.Records = len(ids) - len(errs)
+		return errs
+	}
+
+	if job.Tenants != nil {
+		jobDir := filepath.Join(outputDir, job.Name)
+		if err := os.MkdirAll(jobDir, 0o755); err != nil {
+			return jobFailure(job, err)
+		}
+		opts := bulkOptions{Status: job.Status, ErrorEnvelope: job.ErrorEnvelope}
+		spec, err := job.render(urlRequest, templateVars{})
+		if err != nil {
+			return jobFailure(job, fmt.Errorf("erro no template da requisição: %w", err))
+		}
+		errs, keyClients = runTenants(ctx, job, settings, rl, spec, jobDir, opts)
+		res.Records = job.Tenants.count() - len(errs)
+		return errs
+	}
+
+	if err := waitExecutionWindow(ctx); err != nil {
+		return jobFailure(job, err)
+	}
+	if len(job.sinks) > 0 && *spoolDir != "" {
+		drainSpool(ctx, job)
+	}
+
+	if job.Download != nil {
+		spec, err := job.render(urlRequest, templateVars{})
+		if err != nil {
+			return jobFailure(job, fmt.Errorf("erro no template da requisição: %w", err))
+		}
+		download, header, err := runDownload(ctx, rl, job, spec, outputDir, limits)
+		meta.Download = download
+		if err != nil {
+			return requestFailure(job, explain(ctx, err), trace)
+		}
+		if job.ResponseHeaders != nil {
+			meta.ResponseHeaders = job.ResponseHeaders.pick(header)
+		}
+		res.Records = len(download.Extracted)
+		return nil
+	}
+
+	if job.Paginate != nil && job.Paginate.Stream != "" {
+		records, header, err := streamPages(ctx, rl, job, urlRequest, limits, outputDir)
+		if err != nil {
+			return requestFailure(job, explain(ctx, err), trace)
+		}
+		if job.ResponseHeaders != nil {
+			meta.ResponseHeaders = job.ResponseHeaders.pick(header)
+		}
+		res.Records = records
+		return nil
+	}
+
+	var vars templateVars
+	if job.Watermark != nil {
+		wm, err := loadWatermark(job)
+		if err != nil {
+			return jobFailure(job, err)
+		}
+		vars.Watermark = wm
+	}
+
+	body, header, err := fetchNonEmpty(ctx, rl, job, urlRequest, vars, limits)
+	if err != nil {
+		return requestFailure(job, explain(ctx, err), trace)
+	}
+	if job.ResponseHeaders != nil {
+		meta.ResponseHeaders = job.ResponseHeaders.pick(header)
+	}
+
+	// estados só são persistidos depois que a saída foi gravada
+	var commits []func()
+
+	if job.Quality != nil {
+		commit, err := checkQuality(job, body, outputDir)
+		if err != nil {
+			return jobFailure(job, err)
+		}
+		commits = append(commits, commit)
+	}
+
+	if job.Watermark != nil {
+		commit, err := advanceWatermark(job, body, vars.Watermark)
+		if err != nil {
+			return jobFailure(job, err)
+		}
+		commits = append(commits, commit)
+	}
+
+	out := body
+	if job.Dedup != nil {
+		var commit func()
+		out, commit, err = applyDedup(job, body)
+		if err != nil {
+			return jobFailure(job, err)
+		}
+		commits = append(commits, commit)
+	}
+
+	if job.Details != nil {
+		if out, err = fetchDetails(ctx, rl, job, urlRequest, vars, out); err != nil {
+			return requestFailure(job, explain(ctx, err), trace)
+		}
+	}
+	if job.Enrich != nil {
+		if out, err = enrichOutput(job, out); err != nil {
+			return jobFailure(job, err)
+		}
+	}
+	if job.Project != nil {
+		if out, err = projectOutput(job.Project, out); err != nil {
+			return jobFailure(job, err)
+		}
+	}
+	if job.Sort != nil {
+		if out, err = sortOutput(job.Sort, out); err != nil {
+			return jobFailure(job, err)
+		}
+	}
+
+	if job.ResponseHeaders != nil && job.ResponseHeaders.Inject != "" {
+		if out, err = injectHeaders(job.ResponseHeaders.Inject, meta.ResponseHeaders, out); err != nil {
+			return jobFailure(job, err)
+		}
+	}
+
+	commits = append(commits, checkGrowth(job, out, rl.Warnings))
+
+	var delta *recordDelta
+	if job.Delta != nil {
+		if delta, err = computeDelta(job, out); err != nil {
+			return jobFailure(job, err)
+		}
+	}
+
+	outputPath := filepath.Join(outputDir, "response-"+job.Name+".json")
+	if err := publishOutput(ctx, job, outputPath, out); err != nil {
+		return jobFailure(job, err)
+	}
+	if delta != nil {
+		commits = append(commits, delta.publish(job, outputPath))
+	}
+	if job.Stale != nil {
+		commits = append(commits, recordFetched(job, started))
+	}
+	if err := deliverSinks(ctx, job, outputPath, out); err != nil {
+		return jobFailure(job, err)
+	}
+	for _, commit := range commits {
+		commit()
+	}
+
+	res.Records = countRecords(out)
+	log.Printf("[%s] Resposta %d bytes salva", job.Name, len(body))
+	return nil
+}
+
+// fetchJob renderiza a requisição do job, executa e valida o schema, sem
+// gravar nada; usado tanto pelos jobs agendados quanto pelos gatilhos.
+// Devolve também os headers da (primeira) resposta.
+func fetchJob(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, url string, vars templateVars, limits crawlLimits) ([]byte, http.Header, error) {
+	usage := &crawlUsage{limits: limits}
+	if job.Conditional != nil {
+		return conditionalWrite(ctx, rl, job, url, vars, usage)
+	}
+	if job.Compare != nil {
+		body, header, same, err := compareRemote(ctx, rl, job, url, &vars, usage)
+		if err != nil || same {
+			return body, header, err
+		}
+	}
+
+	spec, err := job.render(url, vars)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro no template da requisição: %w", err)
+	}
+
+	if job.Paginate != nil {
+		return fetchPages(ctx, rl, job, spec, usage)
+	}
+
+	body, header, status, err := job.Status.fetch(ctx, rl, spec)
+	fetchedAt := time.Now()
+	if err != nil || !job.Status.accepts(status) {
+		return nil, nil, requestError(job.ErrorEnvelope, status, body, err)
+	}
+	checkContentLanguage(job, spec, header)
+	if err := usage.add(1, 0, int64(len(body))); err != nil {
+		return nil, nil, err
+	}
+
+	if job.schema != nil {
+		if body, err = job.checkSchema(body, ""); err != nil {
+			return nil, nil, err
+		}
+	}
+	if job.Filter != nil {
+		if body, err = job.Filter.apply(job, body); err != nil {
+			return nil, nil, err
+		}
+	}
+	if job.Provenance != nil {
+		if body, err = annotateProvenance(job, body, spec.URL, fetchedAt); err != nil {
+			return nil, nil, err
+		}
+	}
+	return body, header, nil
+}
+
+func jobFailure(job JobConfig, err error) []ErrorResponse {
+	return []ErrorResponse{ErrorResponse{At: time.Now(), Attempt: 1, Item: job.Name, Error: err.Error()}.withEnvelope(err)}
+}
+
+// requestFailure é o jobFailure de uma requisição que falhou, com as
+// tentativas da última requisição feita (numa paginação, a da página que
+// falhou).
+func requestFailure(job JobConfig, err error, trace *utils.AttemptTrace) []ErrorResponse {
+	errs := jobFailure(job, err)
+	errs[0].Attempts = attemptDetails(trace)
+	return errs
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"apiconsume/utils"
+)
+
+// Os estados de um só job ficam em -state-dir/jobs/<job>/, sob o lock do
+// job; os compartilhados entre jobs (usage, profiles, waf, runs, tokens)
+// ficam na raiz.
+func init() {
+	utils.RegisterJobState("dedup", "delta", "deprecations", "done", "latency", "paginate",
+		"pagesize", "payload", "quality", "saferate", "slo", "stale", "watermark")
+}
+
+// lockState serializa com as outras invocações a atualização de um estado
+// compartilhado; sem o lock, a atualização segue como antes.
+func lockState(name string) func() {
+	unlock, err := stateStore.Lock(name)
+	if err != nil {
+		log.Printf("Erro ao travar o estado %s: %v", name, err)
+	}
+	return unlock
+}
+
+// skipStateLocked trata o job cujo diretório de estado outra invocação
+// travou: ignorado, para não intercalar as gravações das duas execuções.
+func skipStateLocked(job JobConfig, res *jobResult, err error) []ErrorResponse {
+	if !errors.Is(err, utils.ErrStateLocked) {
+		res.Error = err.Error()
+		return jobFailure(job, err)
+	}
+	log.Printf("[%s] Job ignorado: já em execução em outra invocação com o mesmo -state-dir", job.Name)
+	res.Skipped = true
+	res.Error = "ignorado: em execução em outra invocação"
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// JoinConfig desnormaliza as saídas de dois jobs da mesma execução (ex.:
+// pedidos e clientes) em response-<name>.json: cada registro de Left recebe,
+// em As, o registro de Right com a mesma chave (ou todos eles, com Many).
+type JoinConfig struct {
+	Name         string `json:"name"`
+	Left         string `json:"left"`
+	Right        string `json:"right"`
+	LeftKey      string `json:"left_key"`
+	RightKey     string `json:"right_key"`
+	LeftRecords  string `json:"left_records,omitempty"`
+	RightRecords string `json:"right_records,omitempty"`
+	As           string `json:"as,omitempty"`
+	Many         bool   `json:"many,omitempty"`
+	Inner        bool   `json:"inner,omitempty"`
+}
+
+func (c *JoinConfig) validate(jobs map[string]JobConfig) error {
+	if c.Name == "" || c.Left == "" || c.Right == "" || c.LeftKey == "" || c.RightKey == "" {
+		return fmt.Errorf("name, left, right, left_key e right_key são obrigatórios")
+	}
+	if bulkFileName(c.Name) != "response-"+c.Name+".json" {
+		return fmt.Errorf("nome %q inválido: use letras, números, '-', '_' ou '.'", c.Name)
+	}
+	if _, clash := jobs[c.Name]; clash {
+		return fmt.Errorf("nome %q já é usado por um job", c.Name)
+	}
+	for _, side := range []string{c.Left, c.Right} {
+		job, ok := jobs[side]
+		if !ok {
+			return fmt.Errorf("job %q não existe", side)
+		}
+		if job.BulkInput != "" {
+			return fmt.Errorf("job %q é bulk e não tem saída única", side)
+		}
+	}
+	for _, p := range []string{c.LeftKey, c.RightKey, c.LeftRecords, c.RightRecords} {
+		if p == "" {
+			continue
+		}
+		if _, err := utils.ParseJSONPath(p); err != nil {
+			return err
+		}
+	}
+	if c.As == "" {
+		c.As = c.Right
+	}
+	return nil
+}
+
+// runJoins roda depois de todos os jobs; um join só é gerado se os dois
+// lados tiveram sucesso nesta execução, para não misturar dados antigos.
+func runJoins(joins []JoinConfig, succeeded map[string]bool, outputDir string) []ErrorResponse {
+	var errors []ErrorResponse
+	for _, join := range joins {
+		if !succeeded[join.Left] || !succeeded[join.Right] {
+			log.Printf("[%s] Join ignorado: %s ou %s falhou", join.Name, join.Left, join.Right)
+			continue
+		}
+
+		data, count, err := join.run(outputDir)
+		if err != nil {
+			errors = append(errors, ErrorResponse{At: time.Now(), Attempt: 1, Item: join.Name, Error: err.Error()})
+			continue
+		}
+		writeOutput(filepath.Join(outputDir, "response-"+join.Name+".json"), data)
+		log.Printf("[%s] Join %s x %s: %d registros", join.Name, join.Left, join.Right, count)
+	}
+	return errors
+}
+
+func (c *JoinConfig) run(outputDir string) ([]byte, int, error) {
+	left, err := readJobRecords(outputDir, c.Left, c.LeftRecords)
+	if err != nil {
+		return nil, 0, err
+	}
+	right, err := readJobRecords(outputDir, c.Right, c.RightRecords)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rightKey, _ := utils.ParseJSONPath(c.RightKey)
+	index := map[string][]any{}
+	for _, rec := range right {
+		if value, ok := rightKey.First(rec); ok && value != nil {
+			k := fmt.Sprint(value)
+			index[k] = append(index[k], rec)
+		}
+	}
+
+	leftKey, _ := utils.ParseJSONPath(c.LeftKey)
+	out := make([]any, 0, len(left))
+	for _, rec := range left {
+		obj, ok := rec.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		var matches []any
+		if value, ok := leftKey.First(obj); ok && value != nil {
+			matches = index[fmt.Sprint(value)]
+		}
+		if len(matches) == 0 && c.Inner {
+			continue
+		}
+
+		merged := make(map[string]any, len(obj)+1)
+		for k, v := range obj {
+			merged[k] = v
+		}
+		switch {
+		case c.Many:
+			if matches == nil {
+				matches = []any{}
+			}
+			merged[c.As] = matches
+		case len(matches) > 0:
+			merged[c.As] = matches[0]
+		default:
+			merged[c.As] = nil
+		}
+		out = append(out, merged)
+	}
+
+	data, err := json.Marshal(out)
+	return data, len(out), err
+}
+
+func readJobRecords(outputDir, job, recordsPath string) ([]any, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, "response-"+job+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler saída de %s: %w", job, err)
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("saída de %s não é JSON: %w", job, err)
+	}
+	return utils.SelectRecords(doc, recordsPath), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var interleaveProviders = flag.Bool("interleave-providers", false, "jobs sem group que batem no mesmo provider dividem um rate limiter e só começam quando há capacidade para mais um, em vez de largarem juntos")
+
+// providerLane junta os jobs sem group de um mesmo provider (ver usageKey)
+// com -interleave-providers: eles dividem um rate limiter, e um job novo só
+// começa quando a capacidade projetada do limiter comporta mais um.
+type providerLane struct {
+	key   string
+	pacer *utils.RateLimitClient
+
+	mu     sync.Mutex
+	active int
+	// wake é fechado (e trocado) a cada job que termina
+	wake chan struct{}
+}
+
+// capacityPoll é de quanto em quanto tempo um job na fila reavalia a
+// capacidade, já que ela cresce com a exploração de taxa e o reset da cota.
+const capacityPoll = time.Second
+
+func newProviderLanes(jobs []JobConfig, defaults JobSettings) map[string]*providerLane {
+	count := map[string]int{}
+	for _, job := range jobs {
+		if job.Group == "" {
+			count[job.usageKey()]++
+		}
+	}
+
+	lanes := map[string]*providerLane{}
+	for _, job := range jobs {
+		key := job.usageKey()
+		if job.Group != "" || count[key] < 2 || lanes[key] != nil {
+			continue
+		}
+		pacer := newRateClient(defaults.merge(job.JobSettings))
+		pacer.Dialect = job.dialect
+		lanes[key] = &providerLane{key: key, pacer: pacer, wake: make(chan struct{})}
+		log.Printf("Provider %s: %d jobs intercalados num rate limiter compartilhado", key, count[key])
+	}
+	return lanes
+}
+
+// hasCapacity diz se o limiter comporta mais um job: cada job rodando
+// precisa de ao menos 1 req/s da taxa efetiva e, com cota informada pela
+// API, de uma requisição do que resta até o reset.
+func (l *providerLane) hasCapacity(now time.Time) bool {
+	s := l.pacer.Snapshot()
+	if s.Remaining != nil && *s.Remaining <= l.active && now.Before(s.Reset) {
+		return false
+	}
+	return l.active < s.EffectiveRate
+}
+
+func (l *providerLane) acquire(ctx context.Context, job JobConfig) (func(), error) {
+	logged := false
+	for {
+		l.mu.Lock()
+		if l.active == 0 || l.hasCapacity(time.Now()) {
+			l.active++
+			l.mu.Unlock()
+			return l.release, nil
+		}
+		wake, active := l.wake, l.active
+		l.mu.Unlock()
+
+		if !logged {
+			log.Printf("[%s] Aguardando capacidade do provider %s (%d jobs rodando a %d req/s)", job.Name, l.key, active, l.pacer.CurrentRate())
+			logged = true
+		}
+		select {
+		case <-wake:
+		case <-time.After(capacityPoll):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *providerLane) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	limiterSocket = flag.String("limiter-socket", defaultLimiterSocket(), "limiter/slip: socket local do limiter compartilhado")
+	limiterJob    = flag.String("limiter-job", "", "limiter: job de -config cujo limite e dialeto valem para as permissões")
+	slipTimeout   = flag.Duration("slip-timeout", 10*time.Minute, "slip: espera máxima pela permissão")
+)
+
+func init() {
+	commands["limiter"] = limiterCommand
+	commands["slip"] = slipCommand
+}
+
+// defaultLimiterSocket fica em $XDG_RUNTIME_DIR ou, sem ele, num diretório
+// do usuário dentro do temporário, e não direto no temporário, onde outro
+// usuário poderia criar o socket antes.
+func defaultLimiterSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "api-requester.sock")
+	}
+	return filepath.Join(userSocketDir(), "api-requester.sock")
+}
+
+func userSocketDir() string {
+	name := "api-requester"
+	if uid := os.Getuid(); uid >= 0 {
+		name += "-" + strconv.Itoa(uid)
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+const slipUsage = "uso: api-requester slip [report <status> [Header: valor]...]"
+
+// slipGrant é a resposta de POST /slip: a permissão para uma requisição.
+type slipGrant struct {
+	Waited Duration `json:"waited"`
+	Rate   int      `json:"rate"`
+}
+
+// slipReport é o body de POST /report: a resposta que o processo recebeu.
+type slipReport struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type slipVerdict struct {
+	Throttled  bool      `json:"throttled"`
+	PauseUntil time.Time `json:"pause_until,omitzero"`
+}
+
+type limiterStatus struct {
+	Granted    int64                 `json:"granted"`
+	Throttled  int64                 `json:"throttled"`
+	PauseUntil time.Time             `json:"pause_until,omitzero"`
+	Limiter    utils.LimiterSnapshot `json:"limiter"`
+}
+
+// slipServer dá, pelo socket local, permissões do rate limiter a scripts
+// que chamam a mesma API por conta própria: cada um pede a vez antes da
+// requisição e conta depois como ela foi, e o limite é respeitado por todos
+// juntos, sem migrá-los para jobs.
+type slipServer struct {
+	rl *utils.RateLimitClient
+
+	mu    sync.Mutex
+	pause time.Time
+
+	granted, throttled atomic.Int64
+}
+
+// limiterCommand roda o servidor de permissões em -limiter-socket até o
+// processo ser interrompido.
+func limiterCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("uso: api-requester limiter [-limiter-socket caminho] [-config jobs.json -limiter-job job]")
+	}
+	rl, _, err := sharedRateClient(*limiterJob)
+	if err != nil {
+		return err
+	}
+	ln, err := listenSocket(*limiterSocket)
+	if err != nil {
+		return err
+	}
+	s := &slipServer{rl: rl}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /slip", s.handleSlip)
+	mux.HandleFunc("POST /report", s.handleReport)
+	mux.HandleFunc("GET /status", s.handleStatus)
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	defer os.Remove(*limiterSocket)
+
+	log.Printf("Limiter compartilhado ouvindo em %s", *limiterSocket)
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// listenSocket abre o socket só para o usuário; um socket dele que sobrou
+// de um limiter que caiu é removido, mas um em uso, ou de outro usuário, é
+// erro.
+func listenSocket(path string) (net.Listener, error) {
+	if dir := filepath.Dir(path); dir == userSocketDir() {
+		if err := privateDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkSocket(path); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("já há um limiter em %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return utils.ListenUnixPrivate(path)
+}
+
+// privateDir cria o diretório do socket só para o usuário e recusa um que
+// outro usuário tenha criado antes, ou aberto a outros.
+func privateDir(dir string) error {
+	if err := os.Mkdir(dir, 0o700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || !utils.OwnedByCurrentUser(info) || info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s não é um diretório só do usuário (0700); remova-o ou use -limiter-socket", dir)
+	}
+	return nil
+}
+
+// checkSocket recusa, no caminho do socket, o que não for um socket do
+// usuário: o limiter não o apaga, e o slip não fala com ele.
+func checkSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != os.ModeSocket {
+		return fmt.Errorf("%s existe e não é um socket", path)
+	}
+	if !utils.OwnedByCurrentUser(info) {
+		return fmt.Errorf("o socket %s é de outro usuário", path)
+	}
+	return nil
+}
+
+// handleSlip segura a requisição até a vez dela; quem desiste (fecha a
+// conexão) sai da fila.
+func (s *slipServer) handleSlip(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	// a pausa pode crescer enquanto espera, com outro throttling informado
+	for {
+		s.mu.Lock()
+		pause := time.Until(s.pause)
+		s.mu.Unlock()
+		if pause <= 0 {
+			break
+		}
+		if err := utils.SleepContext(r.Context(), pause); err != nil {
+			return
+		}
+	}
+	if err := s.rl.Acquire(r.Context()); err != nil {
+		if r.Context().Err() == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	s.granted.Add(1)
+	writeJSON(w, http.StatusOK, slipGrant{Waited: Duration{time.Since(start)}, Rate: s.rl.CurrentRate()})
+}
+
+// handleReport aplica a resposta ao limiter; num throttling, as próximas
+// permissões de todos esperam o que a resposta pediu.
+func (s *slipServer) handleReport(w http.ResponseWriter, r *http.Request) {
+	var rep slipReport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&rep); err != nil || rep.Status == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "body inválido: informe status e headers da resposta"})
+		return
+	}
+	header := http.Header{}
+	for name, value := range rep.Headers {
+		header.Set(name, value)
+	}
+
+	var verdict slipVerdict
+	wait, throttled := s.rl.Report(rep.Status, header)
+	if throttled {
+		s.throttled.Add(1)
+		until := time.Now().Add(wait)
+		s.mu.Lock()
+		if until.After(s.pause) {
+			s.pause = until
+		}
+		verdict = slipVerdict{Throttled: true, PauseUntil: s.pause}
+		s.mu.Unlock()
+		log.Printf("%d informado por um cliente do limiter: permissões pausadas até %s", rep.Status, until.Format(time.TimeOnly))
+	}
+	writeJSON(w, http.StatusOK, verdict)
+}
+
+func (s *slipServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	pause := s.pause
+	s.mu.Unlock()
+	if time.Now().After(pause) {
+		pause = time.Time{}
+	}
+	writeJSON(w, http.StatusOK, limiterStatus{
+		Granted:    s.granted.Load(),
+		Throttled:  s.throttled.Load(),
+		PauseUntil: pause,
+		Limiter:    s.rl.Snapshot(),
+	})
+}
+
+// slipClient fala com o limiter pelo socket; o host da URL não importa.
+func slipClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			if err := checkSocket(*limiterSocket); err != nil {
+				return nil, err
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", *limiterSocket)
+		},
+	}}
+}
+
+// slipCommand pede uma permissão ao limiter e sai quando ela chega, para
+// scripts: api-requester slip && curl ...; com report, conta ao limiter a
+// resposta recebida, ex: api-requester slip report 429 "Retry-After: 30".
+func slipCommand(ctx context.Context, args []string) error {
+	ctx, cancel := context.WithTimeout(ctx, *slipTimeout)
+	defer cancel()
+
+	if len(args) == 0 {
+		var grant slipGrant
+		if err := slipCall(ctx, "/slip", nil, &grant); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Permissão concedida depois de %v (%d req/s)\n", grant.Waited.Duration.Round(time.Millisecond), grant.Rate)
+		return nil
+	}
+	if args[0] != "report" || len(args) < 2 {
+		return fmt.Errorf(slipUsage)
+	}
+	status, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("status inválido %q: %s", args[1], slipUsage)
+	}
+	rep := slipReport{Status: status, Headers: map[string]string{}}
+	for _, h := range args[2:] {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return fmt.Errorf("header inválido %q: use \"Nome: valor\"", h)
+		}
+		rep.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	var verdict slipVerdict
+	if err := slipCall(ctx, "/report", rep, &verdict); err != nil {
+		return err
+	}
+	if verdict.Throttled {
+		fmt.Fprintf(os.Stderr, "Throttling registrado: permissões pausadas até %s\n", verdict.PauseUntil.Local().Format(time.TimeOnly))
+	}
+	return nil
+}
+
+func slipCall(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://limiter"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := slipClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("limiter em %s: %w", *limiterSocket, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("limiter: status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
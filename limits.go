@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	maxPages    = flag.Int("max-pages", 0, "teto de páginas (ou requisições do bulk) por coleta (0 = sem limite)")
+	maxRecords  = flag.Int("max-records", 0, "teto de registros por coleta paginada (0 = sem limite)")
+	maxDuration = flag.Duration("max-duration", 0, "tempo máximo de cada coleta (0 = sem limite)")
+	maxBytes    ByteSize
+)
+
+func init() {
+	flag.Var(&maxBytes, "max-bytes", "teto de bytes baixados por coleta, ex: 500MB (vazio = sem limite)")
+}
+
+// ByteSize aceita "500MB", "2GB" ou bytes puros, em flags e no JSON.
+type ByteSize int64
+
+func (b *ByteSize) Set(s string) error {
+	n, err := utils.ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+func (b *ByteSize) String() string {
+	if b == nil || *b == 0 {
+		return ""
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("tamanho deve ser número ou string (ex: \"500MB\")")
+	}
+	return b.Set(s)
+}
+
+// crawlLimits são os tetos de uma coleta; zero é sem limite. Estourar
+// qualquer um falha o job sem gravar a saída parcial.
+type crawlLimits struct {
+	Pages    int
+	Records  int
+	Bytes    int64
+	Duration time.Duration
+}
+
+func (s JobSettings) limits() crawlLimits {
+	var l crawlLimits
+	if s.MaxPages != nil {
+		l.Pages = *s.MaxPages
+	}
+	if s.MaxRecords != nil {
+		l.Records = *s.MaxRecords
+	}
+	if s.MaxBytes != nil {
+		l.Bytes = int64(*s.MaxBytes)
+	}
+	if s.MaxDuration != nil {
+		l.Duration = s.MaxDuration.Duration
+	}
+	return l
+}
+
+type crawlLimitError struct {
+	msg string
+}
+
+func (e *crawlLimitError) Error() string {
+	return "limite de segurança atingido: " + e.msg
+}
+
+// withDeadline aplica max_duration ao contexto da coleta.
+func (l crawlLimits) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.Duration <= 0 {
+		return ctx, func() {}
+	}
+	cause := &crawlLimitError{msg: fmt.Sprintf("coleta passou de max_duration (%v)", l.Duration)}
+	return context.WithTimeoutCause(ctx, l.Duration, cause)
+}
+
+// explain troca o "context deadline exceeded" genérico pelo motivo quando
+// quem venceu foi max_duration.
+func explain(ctx context.Context, err error) error {
+	var limitErr *crawlLimitError
+	if err != nil && errors.As(context.Cause(ctx), &limitErr) {
+		return limitErr
+	}
+	return err
+}
+
+// crawlUsage acumula o consumo da coleta; é seguro entre goroutines do bulk.
+type crawlUsage struct {
+	limits crawlLimits
+
+	mu      sync.Mutex
+	pages   int
+	records int
+	bytes   int64
+}
+
+func (u *crawlUsage) add(pages, records int, bytes int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.pages += pages
+	u.records += records
+	u.bytes += bytes
+
+	l := u.limits
+	switch {
+	case l.Pages > 0 && u.pages > l.Pages:
+		return &crawlLimitError{msg: fmt.Sprintf("mais de %d páginas (max_pages)", l.Pages)}
+	case l.Records > 0 && u.records > l.Records:
+		return &crawlLimitError{msg: fmt.Sprintf("mais de %d registros (max_records)", l.Records)}
+	case l.Bytes > 0 && u.bytes > l.Bytes:
+		return &crawlLimitError{msg: fmt.Sprintf("mais de %d bytes baixados (max_bytes)", l.Bytes)}
+	}
+	return nil
+}
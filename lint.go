@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	commands["lint"] = lintCommand
+}
+
+var lintIgnore = flag.String("lint-ignore", "", "regras do lint da configuração ignoradas em todos os jobs, ex: no-retention,no-timeout")
+
+// lintRules são as regras de boas práticas do lint, com o que cada uma
+// cobra. Diferente da validação, o lint só avisa: a configuração roda
+// mesmo assim.
+var lintRules = map[string]string{
+	"no-timeout":           "tentativa ou coleta sem prazo",
+	"retry-not-idempotent": "POST/PATCH repetido sem Idempotency-Key",
+	"inline-secret":        "segredo escrito na configuração em vez de referenciado",
+	"no-retention":         "saída que se acumula sem limpeza",
+}
+
+type lintWarning struct {
+	Rule    string
+	Job     string
+	Message string
+}
+
+func (w lintWarning) String() string {
+	if w.Job == "" {
+		return fmt.Sprintf("[%s] %s", w.Rule, w.Message)
+	}
+	return fmt.Sprintf("[%s] job %q: %s", w.Rule, w.Job, w.Message)
+}
+
+func validateLintIgnore(rules []string) error {
+	for _, rule := range rules {
+		if _, ok := lintRules[rule]; !ok {
+			return fmt.Errorf("regra de lint desconhecida: %q (%s)", rule, strings.Join(lintRuleNames(), ", "))
+		}
+	}
+	return nil
+}
+
+func lintRuleNames() []string {
+	names := make([]string, 0, len(lintRules))
+	for name := range lintRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func splitRules(s string) []string {
+	var rules []string
+	for _, rule := range strings.Split(s, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// lintConfig aplica as regras a cada job, com as configurações já
+// herdadas de flags e defaults, tirando as ignoradas por -lint-ignore e
+// pelo lint_ignore do job.
+func lintConfig(cfg *MultiJobConfig) []lintWarning {
+	ignored := map[string]bool{}
+	for _, rule := range splitRules(*lintIgnore) {
+		ignored[rule] = true
+	}
+
+	var warnings []lintWarning
+	add := func(job JobConfig, rule, format string, a ...any) {
+		if ignored[rule] {
+			return
+		}
+		for _, r := range job.LintIgnore {
+			if r == rule {
+				return
+			}
+		}
+		warnings = append(warnings, lintWarning{Rule: rule, Job: job.Name, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if *archiveDir != "" {
+		add(JobConfig{}, "no-retention", "-archive-dir guarda uma cópia datada de cada resposta e não apaga nenhuma: limpe %s por fora (cron, lifecycle do bucket)", *archiveDir)
+	}
+
+	defaults := flagSettings().merge(cfg.Defaults)
+	for _, job := range cfg.Jobs {
+		settings := defaults.merge(job.JobSettings)
+
+		if settings.AttemptTimeout == nil || settings.AttemptTimeout.Duration <= 0 {
+			add(job, "no-timeout", "sem attempt_timeout: uma tentativa pendurada segura o job sem limite")
+		}
+		long := job.Paginate != nil || job.BulkInput != "" || job.Details != nil || job.Tenants != nil
+		if long && (settings.MaxDuration == nil || settings.MaxDuration.Duration <= 0) && *runDeadline <= 0 {
+			add(job, "no-timeout", "coleta com várias requisições sem max_duration nem -deadline")
+		}
+
+		method := strings.ToUpper(job.Method)
+		retries := 5
+		if settings.MaxRetries != nil {
+			retries = *settings.MaxRetries
+		}
+		if (method == "POST" || method == "PATCH") && retries > 0 && !hasHeader(job.Headers, "Idempotency-Key") {
+			add(job, "retry-not-idempotent", "%s com max_retries %d e sem header Idempotency-Key: uma nova tentativa pode repetir a escrita", method, retries)
+		}
+
+		for _, where := range inlineSecrets(job) {
+			add(job, "inline-secret", "%s com valor literal: use um template, ex: {{env \"API_TOKEN\"}}", where)
+		}
+
+		if job.Download != nil && job.Download.KeepName {
+			add(job, "no-retention", "download.keep_name grava com o nome do servidor, que pode mudar a cada execução, e não apaga os anteriores")
+		}
+	}
+	return warnings
+}
+
+// literalSecret diz se o valor foi escrito na configuração: sem template,
+// ele fica no arquivo, no git e nos backups da configuração.
+func literalSecret(value string) bool {
+	return value != "" && !strings.Contains(value, "{{")
+}
+
+// inlineSecrets lista onde o job tem segredos escritos por extenso: headers
+// e parâmetros com nome de segredo (ver utils.SensitiveName), na URL, em
+// query ou no auth, e os campos de credencial do auth e dos tenants.
+func inlineSecrets(job JobConfig) []string {
+	var found []string
+	check := func(where, value string) {
+		if literalSecret(value) {
+			found = append(found, where)
+		}
+	}
+
+	for name, value := range job.Headers {
+		if utils.SensitiveName(name) {
+			check("header "+name, value)
+		}
+	}
+	for name, p := range job.Query {
+		if utils.SensitiveName(name) {
+			for _, value := range p.Values {
+				check("query "+name, value)
+			}
+		}
+	}
+	if u, err := url.Parse(job.URL); err == nil {
+		if password, ok := u.User.Password(); ok {
+			check("senha da url", password)
+		}
+		for name, values := range u.Query() {
+			if utils.SensitiveName(name) {
+				for _, value := range values {
+					check("parâmetro "+name+" da url", value)
+				}
+			}
+		}
+	}
+
+	if a := job.Auth; a != nil {
+		for field, value := range map[string]string{
+			"auth.token":         a.Token,
+			"auth.password":      a.Password,
+			"auth.client_secret": a.ClientSecret,
+			"auth.refresh_token": a.RefreshToken,
+		} {
+			check(field, value)
+		}
+		for name, value := range a.Headers {
+			if utils.SensitiveName(name) {
+				check("auth.headers "+name, value)
+			}
+		}
+		for name, value := range a.Query {
+			if utils.SensitiveName(name) {
+				check("auth.query "+name, value)
+			}
+		}
+	}
+	if job.Tenants != nil {
+		for _, k := range job.Tenants.Keys {
+			check("tenants.keys "+k.Name+" token", k.Token)
+		}
+	}
+
+	sort.Strings(found)
+	return found
+}
+
+// logLint mostra os avisos do lint ao carregar a configuração para rodar.
+func logLint(cfg *MultiJobConfig) {
+	for _, w := range lintConfig(cfg) {
+		log.Printf("Aviso %s", w)
+	}
+}
+
+// lintCommand lista os avisos do lint de -config e sai com erro se houver
+// algum, para barrar a configuração no CI.
+func lintCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("uso: api-requester lint -config jobs.json [-lint-ignore regra,...]")
+	}
+	if *jobConfigPath == "" {
+		return fmt.Errorf("informe -config")
+	}
+	cfg, err := loadJobConfig(*jobConfigPath)
+	if err != nil {
+		return err
+	}
+
+	warnings := lintConfig(cfg)
+	if len(warnings) == 0 {
+		fmt.Println("Nenhum aviso.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REGRA\tJOB\tAVISO")
+	for _, warning := range warnings {
+		job := warning.Job
+		if job == "" {
+			job = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", warning.Rule, job, warning.Message)
+	}
+	w.Flush()
+	return fmt.Errorf("%d avisos", len(warnings))
+}
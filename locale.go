@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// LocaleConfig fixa o idioma e os parâmetros regionais das requisições,
+// para que rótulos de enums, moedas e datas venham sempre no mesmo formato;
+// em defaults vale para todos os jobs.
+type LocaleConfig struct {
+	AcceptLanguage string            `json:"accept_language,omitempty"`
+	Params         map[string]string `json:"params,omitempty"`
+}
+
+// localize aplica o locale ao job e à URL; headers e parâmetros que o job
+// já define prevalecem.
+func (job JobConfig) localize(s JobSettings, rawURL string) (JobConfig, string) {
+	c := s.Locale
+	if c == nil {
+		return job, rawURL
+	}
+
+	if c.AcceptLanguage != "" && !hasHeader(job.Headers, "Accept-Language") {
+		headers := make(map[string]string, len(job.Headers)+1)
+		for k, v := range job.Headers {
+			headers[k] = v
+		}
+		headers["Accept-Language"] = c.AcceptLanguage
+		job.Headers = headers
+	}
+
+	if len(c.Params) == 0 {
+		return job, rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return job, rawURL
+	}
+	q := u.Query()
+	names := make([]string, 0, len(c.Params))
+	for name := range c.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !q.Has(name) {
+			u.RawQuery += "&" + url.QueryEscape(name) + "=" + url.QueryEscape(c.Params[name])
+		}
+	}
+	u.RawQuery = strings.TrimPrefix(u.RawQuery, "&")
+	return job, u.String()
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContentLanguage avisa quando o provedor responde em outro idioma que
+// o pedido, o que mudaria os rótulos da saída sem erro aparente.
+func checkContentLanguage(job JobConfig, spec requestSpec, header http.Header) {
+	var want string
+	for k, v := range spec.Headers {
+		if strings.EqualFold(k, "Accept-Language") {
+			want = v
+		}
+	}
+	got := header.Get("Content-Language")
+	if want == "" || got == "" {
+		return
+	}
+
+	primary := func(tag string) string {
+		tag, _, _ = strings.Cut(strings.TrimSpace(tag), ",")
+		tag, _, _ = strings.Cut(tag, ";")
+		tag, _, _ = strings.Cut(tag, "-")
+		return strings.ToLower(strings.TrimSpace(tag))
+	}
+	if primary(want) != "*" && primary(want) != primary(got) {
+		log.Printf("[%s] AVISO: pedido Accept-Language %q, mas a resposta veio em %q", job.Name, want, got)
+	}
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -20,12 +21,68 @@ const (
 	requestTimeout = 60 * time.Second
 )
 
+var (
+	runDeadline    = flag.Duration("deadline", 0, "prazo total da execução, incluindo retries (0 = sem limite)")
+	attemptTimeout = flag.Duration("attempt-timeout", requestTimeout, "timeout de cada tentativa individual")
+	maxRetryAfter  = flag.Duration("max-retry-after", 10*time.Minute, "maior Retry-After que vale esperar; acima dele a requisição falha (0 = sem limite)")
+	cacheDir       = flag.String("cache-dir", "", "diretório do cache de respostas bem-sucedidas (vazio = desativado)")
+	cacheTTL       = flag.Duration("cache-ttl", 24*time.Hour, "validade das respostas em cache")
+	emptyPatience  = flag.Duration("empty-retry-for", 0, "por quanto tempo uma resposta 200 vazia é tratada como retentável (0 = aceita direto)")
+	emptyInterval  = flag.Duration("empty-retry-interval", time.Minute, "espera entre tentativas quando a resposta vem vazia")
+	allowedWindows = flag.String("allowed-windows", "", "janelas permitidas de execução, ex: 06:00-09:00,22:00-02:00 (vazio = sempre)")
+	startJitter    = flag.Duration("start-jitter", 0, "atraso aleatório, entre zero e o valor, no início da execução e a cada abertura de janela")
+	windowTZ       = flag.String("window-tz", "", "fuso horário do provedor usado nas janelas e no calendário de taxas (padrão: local)")
+	stateDir       = flag.String("state-dir", ".state", "diretório dos arquivos de estado entre execuções")
+	rateCalendar   = flag.String("rate-calendar", "", "teto de req/s por horário, ex: \"mon-fri 09:00-18:00=2; *=10\"")
+	slowStart      = flag.Duration("slow-start", 0, "depois de um início a frio, a taxa começa em 1/4 e sobe até a normal neste tempo (0 = desativado)")
+	slowStartIdle  = flag.Duration("slow-start-idle", 5*time.Minute, "ociosidade do rate limiter que conta como início a frio para -slow-start")
+	staggerStep    = flag.Duration("throttle-stagger", utils.DefaultThrottleStagger, "passo entre as retomadas dos workers parados pelo mesmo 429 ou reset, com jitter (0 = todos juntos)")
+	maxBandwidth   = flag.String("max-bandwidth", "", "limite total de download, ex: 5MB/s (vazio = sem limite)")
+	tempDir        = flag.String("temp-dir", "", "diretório dos arquivos temporários de escrita (padrão: o de saída)")
+	durability     = flag.String("durability", "strict", "strict faz fsync do arquivo e do diretório a cada saída e estado gravados; fast só renomeia, mais rápido em tmpfs ou scratch descartável")
+	fileMode       = flag.String("file-mode", "0600", "permissão dos arquivos gravados, em octal (ex: 0640)")
+	fileGroup      = flag.String("file-group", "", "grupo (nome ou gid) dos arquivos gravados")
+)
+
+var (
+	responseCache *utils.ResponseCache
+	execWindow    *utils.ExecutionWindow
+	rateCal       *utils.RateCalendar
+	stateStore    *utils.StateStore
+	bandwidth     *utils.BandwidthLimiter
+	outputPerm    os.FileMode = 0o600
+	outputGID     = -1
+)
+
 type ErrorResponse struct {
 	Attempt int    `json:"attempt"`
+	Item    string `json:"item,omitempty"`
 	Error   string `json:"error"`
+
+	Status  int    `json:"status,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	Attempts []attemptDetail `json:"attempts,omitempty"`
+
+	At      time.Time `json:"at,omitzero"`
+	Count   int       `json:"count,omitempty"`
+	FirstAt time.Time `json:"first_at,omitzero"`
+	LastAt  time.Time `json:"last_at,omitzero"`
+	Items   []string  `json:"items,omitempty"`
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			flag.CommandLine.Parse(os.Args[2:])
+			runCommand(os.Args[1], cmd)
+			return
+		}
+	}
+
+	flag.Parse()
+
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("Erro ao obter diretório atual: %v", err)
@@ -35,6 +92,32 @@ func main() {
 	responsePath := cwd + "/response.json"
 	errorLogPath := cwd + "/errors.json"
 
+	ctx, cancel := setupRuntime()
+	defer cancel()
+
+	if err := waitStartJitter(ctx); err != nil {
+		return
+	}
+
+	if *jobConfigPath != "" {
+		cfg, err := loadJobConfig(*jobConfigPath)
+		if err != nil {
+			log.Fatalf("Erro carregando configuração de jobs: %v", err)
+		}
+		if cfg, err = selectTagged(cfg); err != nil {
+			log.Fatal(err)
+		}
+		logLint(cfg)
+		if err := confirmDestructive(cfg.Jobs); err != nil {
+			log.Fatal(err)
+		}
+		if code := runJobs(ctx, cfg, cwd, errorLogPath); code != 0 {
+			cancel()
+			os.Exit(code)
+		}
+		return
+	}
+
 	urlBase, err := loadEnvValues(envPath)
 	if err != nil {
 		log.Fatalf("Erro carregando .env: %v", err)
@@ -42,91 +125,389 @@ func main() {
 
 	urlRequest := buildURL(urlBase)
 
-	rateClient := utils.NewRateLimitClient()
+	rateClient := newRateClient(flagSettings())
+	if rateClient.Auth, err = loadEnvAuth(envPath); err != nil {
+		log.Fatalf("Erro na autenticação do .env: %v", err)
+	}
+
+	if *bulkInput != "" {
+		ids, err := loadBulkInput(*bulkInput)
+		if err != nil {
+			log.Fatalf("Erro carregando entrada do bulk: %v", err)
+		}
+		ids, shardDone, err := claimShard(JobConfig{URL: urlRequest}.usageKey(), ids)
+		if err != nil {
+			log.Fatalf("Erro no shard: %v", err)
+		}
+		defer shardDone()
+		if *batchSize > 0 {
+			runBatch(ctx, rateClient, urlRequest, ids, cwd, errorLogPath)
+			return
+		}
+		opts := bulkOptionsFromFlags()
+		opts.Provider = JobConfig{URL: urlRequest}.usageKey()
+		ctx, cancel := opts.Limits.withDeadline(ctx)
+		defer cancel()
+		errors := runBulk(ctx, rateClient, requestSpec{URL: urlRequest}, ids, cwd, opts)
+		if len(errors) > 0 {
+			saveErrors(errorLogPath, errors)
+		}
+		return
+	}
+
 	var errors []ErrorResponse
+	var emptySince time.Time
 	attempt := 0
 
+	// o cache responderia a toda volta sem passar pelo rate limiter, e o
+	// loop giraria sem pausa até o TTL vencer
+	if responseCache != nil {
+		log.Printf("-cache-dir não vale no loop: cada requisição vai à API")
+		responseCache = nil
+	}
+
 	log.Println("Loop infinito iniciado! Apert Ctrl + C para parar.")
 
-	for {
+	for ctx.Err() == nil {
+		if waitExecutionWindow(ctx) != nil {
+			break
+		}
+
 		attempt++
 		log.Printf("Requisição #%d ...", attempt)
 
-		body, status, err := doSingleRequest(rateClient, urlRequest)
+		reqCtx, trace := utils.WithAttemptTrace(ctx)
+		body, status, err := doSingleRequest(reqCtx, rateClient, urlRequest)
 
 		if err == nil && status == 200 {
 			fmt.Printf("Resposta %d bytes | Status %d\n", len(body), status)
 
-			writeFile(responsePath, body)
+			if *emptyPatience > 0 && isEmptyResult(body) {
+				if emptySince.IsZero() {
+					emptySince = time.Now()
+				}
+				if waited := time.Since(emptySince); waited < *emptyPatience {
+					log.Printf("Resposta vazia, aguardando publicação dos dados (%v de %v)", waited.Round(time.Second), *emptyPatience)
+					if utils.SleepContext(ctx, *emptyInterval) != nil {
+						break
+					}
+					continue
+				}
+				log.Printf("Resposta continua vazia após %v, aceitando como final", *emptyPatience)
+			}
+			emptySince = time.Time{}
+
+			writeOutput(responsePath, body)
 
 			continue
 		}
 
-		msg := fmt.Sprintf("Status %d - %v", status, err)
-		errors = append(errors, ErrorResponse{Attempt: attempt, Error: msg})
+		failure := requestError(nil, status, body, err)
+		errors = append(errors, ErrorResponse{At: time.Now(), Attempt: attempt, Error: failure.Error(), Attempts: attemptDetails(trace)}.withEnvelope(failure))
 
 		saveErrors(errorLogPath, errors)
+
+		// na pausa de WAF o Do falha na hora: espera a pausa em vez de girar
+		if blocked := rateClient.WAFBlocked(); blocked != nil {
+			log.Printf("Desafio de WAF (%s): aguardando a pausa até %s", blocked.Vendor, blocked.Until.Format(time.RFC3339))
+			if utils.SleepContext(ctx, time.Until(blocked.Until)) != nil {
+				break
+			}
+		}
 	}
+
+	log.Printf("Prazo total de %v atingido, encerrando.", *runDeadline)
+}
+
+func setupRuntime() (context.Context, context.CancelFunc) {
+	var err error
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if *runDeadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *runDeadline)
+	}
+
+	discoverConfig()
+
+	if *cacheDir != "" {
+		responseCache, err = utils.NewResponseCache(*cacheDir, *cacheTTL)
+		if err != nil {
+			log.Fatalf("Erro inicializando cache: %v", err)
+		}
+	}
+
+	if *allowedWindows != "" {
+		execWindow, err = utils.ParseExecutionWindow(*allowedWindows, *windowTZ)
+		if err != nil {
+			log.Fatalf("Erro em -allowed-windows: %v", err)
+		}
+		execWindow.Jitter = *startJitter
+	}
+
+	stateStore, err = utils.NewStateStore(*stateDir)
+	if err != nil {
+		log.Fatalf("Erro inicializando estado: %v", err)
+	}
+	registerWriteCommands()
+	if key, err := stateKey(); err != nil {
+		log.Fatalf("Erro inicializando estado: %v", err)
+	} else if key != nil {
+		if err := stateStore.SetKey(key); err != nil {
+			log.Fatalf("Erro inicializando estado: %v", err)
+		}
+	}
+
+	if *rateCalendar != "" {
+		rateCal, err = utils.ParseRateCalendar(*rateCalendar, *windowTZ)
+		if err != nil {
+			log.Fatalf("Erro em -rate-calendar: %v", err)
+		}
+	}
+
+	configureDNS()
+	if err := configureProxy(); err != nil {
+		log.Fatalf("Erro configurando proxy: %v", err)
+	}
+
+	configureExpectContinue()
+	configureOverride()
+
+	if err := configureChaos(); err != nil {
+		log.Fatalf("Erro em -chaos: %v", err)
+	}
+	if err := validateShard(); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateLintIgnore(splitRules(*lintIgnore)); err != nil {
+		log.Fatalf("Erro em -lint-ignore: %v", err)
+	}
+
+	if *maxBandwidth != "" {
+		rate, err := utils.ParseBandwidth(*maxBandwidth)
+		if err != nil {
+			log.Fatalf("Erro em -max-bandwidth: %v", err)
+		}
+		bandwidth = utils.NewBandwidthLimiter(rate)
+	}
+
+	if utils.FileDurability, err = utils.ParseDurability(*durability); err != nil {
+		log.Fatalf("Erro em -durability: %v", err)
+	}
+	if err := parseOutputOwnership(); err != nil {
+		log.Fatalf("Erro em -file-mode/-file-group: %v", err)
+	}
+
+	if err := validateFailOn(); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateBackup(); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateHTMLPages(*htmlPages); err != nil {
+		log.Fatalf("-html-pages: %v", err)
+	}
+	if err := flagSettings().dialOptions().validate(); err != nil {
+		log.Fatalf("-bind/-ip-family: %v", err)
+	}
+
+	if err := setupArchive(); err != nil {
+		log.Fatalf("Erro em -archive-*: %v", err)
+	}
+
+	return ctx, cancel
+}
+
+func waitExecutionWindow(ctx context.Context) error {
+	if execWindow == nil {
+		return nil
+	}
+	return execWindow.Wait(ctx)
+}
+
+// waitStartJitter espalha o início de instâncias agendadas no mesmo horário,
+// para não dispararem juntas contra o provedor.
+func waitStartJitter(ctx context.Context) error {
+	delay := utils.RandomJitter(*startJitter)
+	if delay == 0 {
+		return nil
+	}
+	log.Printf("Atrasando o início em %v (-start-jitter)", delay.Round(time.Second))
+	return utils.SleepContext(ctx, delay)
+}
+
+func isEmptyResult(body []byte) bool {
+	switch string(bytes.TrimSpace(body)) {
+	case "", "[]", "{}", "null":
+		return true
+	}
+	return false
 }
 
 func loadEnvValues(path string) (string, error) {
+	values, err := loadEnvFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	urlBase := values["URL"]
+	if urlBase == "" {
+		return "", fmt.Errorf("URL não encontrada no .env")
+	}
+
+	return urlBase, nil
+}
+
+// loadEnvFile lê as linhas CHAVE=valor do .env; o valor vai até o fim da
+// linha, sem aspas.
+func loadEnvFile(path string) (map[string]string, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("erro ao abrir .env: %w", err)
+		return nil, fmt.Errorf("erro ao abrir .env: %w", err)
 	}
 	defer file.Close()
 
-	var urlBase string
+	values := map[string]string{}
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		if strings.HasPrefix(line, "URL=") {
-			urlBase = strings.TrimPrefix(line, "URL=")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(strings.TrimSpace(key), "#") {
 			continue
 		}
+		values[strings.TrimSpace(key)] = value
 	}
 
 	if err := scanner.Err(); err != nil {
-		return "", err
+		return nil, err
 	}
+	return values, nil
+}
 
-	if urlBase == "" {
-		return "", fmt.Errorf("URL não encontrada no .env")
+func buildURL(urlBase string) string {
+	return buildDateURL(urlBase, "dataBase")
+}
+
+func buildDateURL(urlBase, param string) string {
+	today := time.Now().Format("2006-01-02")
+	sep := "?"
+	if strings.Contains(urlBase, "?") {
+		sep = "&"
 	}
+	return fmt.Sprintf("%s%s%s=%sT00:00:00.000Z", urlBase, sep, param, today)
+}
 
-	return urlBase, nil
+type requestSpec struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    []byte
 }
 
-func buildURL(urlBase string) string {
-	today := time.Now().Format("2006-01-02")
-	return fmt.Sprintf("%s?dataBase=%sT00:00:00.000Z", urlBase, today)
+func doSingleRequest(ctx context.Context, rl *utils.RateLimitClient, url string) ([]byte, int, error) {
+	return doRequest(ctx, rl, requestSpec{Method: http.MethodGet, URL: url})
 }
 
-func doSingleRequest(rl *utils.RateLimitClient, url string) ([]byte, int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-	defer cancel()
+func newHTTPRequest(ctx context.Context, spec requestSpec) (*http.Request, error) {
+	var bodyReader io.Reader
+	if spec.Body != nil {
+		bodyReader = bytes.NewReader(spec.Body)
+	}
+
+	method := spec.Method
+	if method == "" {
+		method = http.MethodGet
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, spec.URL, bodyReader)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0")
+	if spec.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if expectsContinue(len(spec.Body)) {
+		req.Header.Set("Expect", "100-continue")
+	}
+	for k, v := range spec.Headers {
+		req.Header.Set(k, v)
+	}
+
+	return req, nil
+}
+
+func doRequest(ctx context.Context, rl *utils.RateLimitClient, spec requestSpec) ([]byte, int, error) {
+	// sem config de status, só o 202 com Location é seguido
+	body, _, status, err := (*StatusConfig)(nil).fetch(ctx, rl, spec)
+	return body, status, err
+}
+
+// doRequestHeaders também devolve os headers da resposta (nil quando vem do
+// cache).
+func doRequestHeaders(ctx context.Context, rl *utils.RateLimitClient, spec requestSpec) ([]byte, http.Header, int, error) {
+	req, err := newHTTPRequest(ctx, spec)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	var cacheKey string
+	if responseCache != nil {
+		cacheKey = utils.CacheKey(req.Method, req.URL.String(), spec.Body)
+		if body, ok := responseCache.Get(cacheKey); ok {
+			log.Printf("Resposta servida do cache: %s", req.URL)
+			return body, nil, http.StatusOK, nil
+		}
+	}
 
 	resp, err := rl.Do(req)
 	if err != nil {
-		return nil, 0, err
+		return nil, nil, 0, err
 	}
 	defer resp.Body.Close()
 
+	if resp.ContentLength > 0 {
+		if err := utils.EnsureSpace(writeDir(), resp.ContentLength); err != nil {
+			return nil, resp.Header, resp.StatusCode, err
+		}
+	}
+
 	body, err := io.ReadAll(resp.Body)
-	return body, resp.StatusCode, err
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		body, err = sniffBody(req.URL, resp.Header, body)
+	}
+	if err == nil && resp.StatusCode == 200 && responseCache != nil {
+		if err := responseCache.Put(cacheKey, req.Method, req.URL.String(), body); err != nil {
+			log.Printf("Erro ao gravar cache: %v", err)
+		}
+	}
+	return body, resp.Header, resp.StatusCode, err
 }
 
 func writeFile(path string, data []byte) {
+	writeFileWith(path, data, utils.MoveFile)
+}
+
+// writeFileWith grava data num temporário e o move para path com move
+// (publishFile, para as saídas que passam pelos hooks de escrita).
+func writeFileWith(path string, data []byte, move func(src, dst string) error) {
 	dir := filepath.Dir(path)
+	if *tempDir != "" {
+		dir = *tempDir
+	}
+
+	if err := utils.EnsureSpace(dir, int64(len(data))); err != nil {
+		log.Fatalf("Erro ao gravar %s: %v", path, err)
+	}
+	if dir != filepath.Dir(path) {
+		// no fallback entre filesystems o arquivo é copiado para o destino
+		if err := utils.EnsureSpace(filepath.Dir(path), int64(len(data))); err != nil {
+			log.Fatalf("Erro ao gravar %s: %v", path, err)
+		}
+	}
+
 	tmp, err := os.CreateTemp(dir, "tmp-*.tmp")
 	if err != nil {
 		log.Fatalf("Erro ao criar arquivo temporário: %v", err)
@@ -140,7 +521,10 @@ func writeFile(path string, data []byte) {
 	if _, err := tmp.Write(data); err != nil {
 		log.Fatalf("Erro ao escrever arquivo temporário: %v", err)
 	}
-	if err := tmp.Sync(); err != nil {
+	if err := applyOutputOwnership(tmp); err != nil {
+		log.Fatalf("Erro ao ajustar permissões de %s: %v", path, err)
+	}
+	if err := utils.SyncFile(tmp); err != nil {
 		log.Fatalf("Erro ao sincronizar arquivo temporário: %v", err)
 	}
 
@@ -148,20 +532,19 @@ func writeFile(path string, data []byte) {
 		log.Fatalf("Erro ao fechar arquivo temporário: %v", err)
 	}
 
-	if err := os.Rename(tmpName, path); err != nil {
+	if err := move(tmpName, path); err != nil {
 		log.Fatalf("Erro ao mover arquivo temporário: %v", err)
 	}
 }
 
-func saveErrors(path string, errors []ErrorResponse) {
-	file, err := os.Create(path)
-	if err != nil {
-		log.Printf("Erro ao criar arquivo de erros: %v", err)
-		return
+// writeDir é onde as respostas são gravadas primeiro.
+func writeDir() string {
+	if *tempDir != "" {
+		return *tempDir
 	}
-	defer file.Close()
+	return "."
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	encoder.Encode(errors)
+func saveErrors(path string, errors []ErrorResponse) {
+	writeErrorLog(path, errors)
 }
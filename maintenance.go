@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// MaintenanceConfig declara uma janela de manutenção conhecida de um
+// provedor, identificado como no consumo (provider do job ou host da URL)
+// ou pelo grupo. A janela é recorrente (Windows nos dias de Days, no fuso
+// Timezone) ou pontual (From e To, RFC 3339). Durante ela, o job não roda
+// (mode skip, padrão) ou roda e, se falhar, é marcado como ignorado por
+// manutenção em vez de alertar (mode tolerate).
+type MaintenanceConfig struct {
+	Provider string   `json:"provider"`
+	Windows  string   `json:"windows,omitempty"`
+	Days     []string `json:"days,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Mode     string   `json:"mode,omitempty"`
+	Note     string   `json:"note,omitempty"`
+
+	window   *utils.ExecutionWindow
+	days     map[time.Weekday]bool
+	from, to time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (c *MaintenanceConfig) validate() error {
+	if c.Provider == "" {
+		return fmt.Errorf("provider vazio")
+	}
+	switch c.Mode {
+	case "", "skip", "tolerate":
+	default:
+		return fmt.Errorf("mode deve ser skip ou tolerate")
+	}
+
+	if c.From != "" || c.To != "" {
+		if c.Windows != "" || len(c.Days) > 0 {
+			return fmt.Errorf("from/to e windows/days são exclusivos")
+		}
+		var err error
+		if c.from, err = time.Parse(time.RFC3339, c.From); err != nil {
+			return fmt.Errorf("from: use RFC 3339, ex: 2025-03-01T02:00:00Z")
+		}
+		if c.to, err = time.Parse(time.RFC3339, c.To); err != nil {
+			return fmt.Errorf("to: use RFC 3339, ex: 2025-03-01T06:00:00Z")
+		}
+		if !c.to.After(c.from) {
+			return fmt.Errorf("to deve ser depois de from")
+		}
+		return nil
+	}
+
+	if c.Windows == "" {
+		return fmt.Errorf("informe windows ou from/to")
+	}
+	w, err := utils.ParseExecutionWindow(c.Windows, c.Timezone)
+	if err != nil {
+		return err
+	}
+	c.window = w
+	for _, d := range c.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return fmt.Errorf("dia %q inválido: use sun, mon, tue, wed, thu, fri ou sat", d)
+		}
+		if c.days == nil {
+			c.days = map[time.Weekday]bool{}
+		}
+		c.days[day] = true
+	}
+	return nil
+}
+
+func (c *MaintenanceConfig) tolerate() bool {
+	return c.Mode == "tolerate"
+}
+
+// until diz se t cai na janela e quando ela termina. Numa janela que cruza
+// a meia-noite, o dia que vale é o do início.
+func (c *MaintenanceConfig) until(t time.Time) (time.Time, bool) {
+	if c.window == nil {
+		return c.to, !t.Before(c.from) && t.Before(c.to)
+	}
+	local := t.In(c.window.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.window.Location)
+	tod := local.Sub(midnight)
+	for _, tw := range c.window.Windows {
+		start := midnight
+		switch {
+		case tw.Start < tw.End && tod >= tw.Start && tod < tw.End:
+		case tw.Start > tw.End && tod >= tw.Start:
+		case tw.Start > tw.End && tod < tw.End:
+			start = midnight.AddDate(0, 0, -1)
+		default:
+			continue
+		}
+		if c.days != nil && !c.days[start.Weekday()] {
+			continue
+		}
+		end := start.Add(tw.End)
+		if tw.Start > tw.End {
+			end = start.AddDate(0, 0, 1).Add(tw.End)
+		}
+		return end, true
+	}
+	return time.Time{}, false
+}
+
+func (c *MaintenanceConfig) matches(job JobConfig) bool {
+	return c.Provider == job.usageKey() || (job.Group != "" && c.Provider == job.Group)
+}
+
+// maintenanceAt devolve a janela de manutenção do job em vigor em t, se
+// houver, e quando ela termina.
+func (job JobConfig) maintenanceAt(t time.Time) (*MaintenanceConfig, time.Time) {
+	for _, m := range job.maintenance {
+		if end, ok := m.until(t); ok {
+			return m, end
+		}
+	}
+	return nil, time.Time{}
+}
+
+func (c *MaintenanceConfig) describe(end time.Time) string {
+	msg := fmt.Sprintf("manutenção de %s até %s", c.Provider, end.Format(time.RFC3339))
+	if c.Note != "" {
+		msg += " (" + c.Note + ")"
+	}
+	return msg
+}
+
+// assignMaintenance liga cada job às janelas do seu provedor.
+func assignMaintenance(cfg *MultiJobConfig) error {
+	for i := range cfg.Maintenance {
+		if err := cfg.Maintenance[i].validate(); err != nil {
+			return fmt.Errorf("maintenance #%d: %w", i+1, err)
+		}
+	}
+	for i := range cfg.Jobs {
+		for j := range cfg.Maintenance {
+			if m := &cfg.Maintenance[j]; m.matches(cfg.Jobs[i]) {
+				cfg.Jobs[i].maintenance = append(cfg.Jobs[i].maintenance, m)
+			}
+		}
+	}
+	return nil
+}
+
+func skipMaintenance(job JobConfig, res *jobResult, m *MaintenanceConfig, end time.Time) {
+	log.Printf("[%s] Job ignorado: %s", job.Name, m.describe(end))
+	res.Skipped = true
+	res.Maintenance = true
+	res.Error = "ignorado: " + m.describe(end)
+}
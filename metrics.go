@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+var metricsFile = flag.String("metrics-file", "", "grava as métricas da execução no formato texto do Prometheus, ex: /var/lib/node_exporter/textfile/api-requester.prom")
+
+// writeMetrics grava em -metrics-file, para o textfile collector do
+// node_exporter, as novas tentativas de cada job por motivo e quanto do
+// orçamento de max_retries foi gasto.
+func writeMetrics(summary runSummary) {
+	if *metricsFile == "" {
+		return
+	}
+
+	var b strings.Builder
+	metric := func(name, help, kind string) {
+		fmt.Fprintf(&b, "# HELP apirequester_%s %s\n# TYPE apirequester_%s %s\n", name, help, name, kind)
+	}
+
+	metric("job_success", "1 se o job terminou com sucesso na última execução.", "gauge")
+	for _, r := range summary.Jobs {
+		fmt.Fprintf(&b, "apirequester_job_success{job=%q} %d\n", r.Job, boolMetric(r.Success))
+	}
+
+	metric("requests", "Requisições feitas pelo job na última execução.", "gauge")
+	eachRetries(summary, func(job string, s *utils.RetryStats) {
+		fmt.Fprintf(&b, "apirequester_requests{job=%q} %d\n", job, s.Requests)
+	})
+	metric("retries", "Novas tentativas do job na última execução, por motivo.", "gauge")
+	eachRetries(summary, func(job string, s *utils.RetryStats) {
+		reasons := make([]string, 0, len(s.ByReason))
+		for reason := range s.ByReason {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(&b, "apirequester_retries{job=%q,reason=%q} %d\n", job, reason, s.ByReason[reason])
+		}
+	})
+	metric("retries_exhausted", "Requisições que esgotaram max_retries na última execução.", "gauge")
+	eachRetries(summary, func(job string, s *utils.RetryStats) {
+		fmt.Fprintf(&b, "apirequester_retries_exhausted{job=%q} %d\n", job, s.Exhausted)
+	})
+	metric("retry_budget_used_ratio", "Fração do orçamento de novas tentativas (max_retries por requisição) gasta na última execução.", "gauge")
+	eachRetries(summary, func(job string, s *utils.RetryStats) {
+		fmt.Fprintf(&b, "apirequester_retry_budget_used_ratio{job=%q} %g\n", job, s.BudgetUsed)
+	})
+	metric("retry_budget_peak_ratio", "Fração de max_retries gasta pela requisição mais próxima do limite.", "gauge")
+	eachRetries(summary, func(job string, s *utils.RetryStats) {
+		fmt.Fprintf(&b, "apirequester_retry_budget_peak_ratio{job=%q} %g\n", job, s.PeakUsed)
+	})
+
+	metric("run_finished_timestamp_seconds", "Fim da última execução.", "gauge")
+	fmt.Fprintf(&b, "apirequester_run_finished_timestamp_seconds %d\n", summary.FinishedAt.Unix())
+
+	writeFile(*metricsFile, []byte(b.String()))
+}
+
+// eachRetries passa pelos jobs com requisições; o total da execução vem
+// com job "".
+func eachRetries(summary runSummary, fn func(job string, s *utils.RetryStats)) {
+	for _, r := range summary.Jobs {
+		if r.Retries != nil {
+			fn(r.Job, r.Retries)
+		}
+	}
+	if summary.Retries != nil {
+		fn("", summary.Retries)
+	}
+}
+
+func boolMetric(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
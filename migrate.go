@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func init() {
+	commands["migrate-config"] = migrateConfigCommand
+}
+
+// flags do modo .env que o modo -config não aplica aos jobs
+var envOnlyFlags = map[string]string{
+	"batch-size":           "lotes não são suportados em jobs; o bulk_input roda item a item",
+	"empty-retry-for":      "jobs aceitam a resposta vazia direto; para repeti-la e falhar, use non_empty no job",
+	"empty-retry-interval": "jobs aceitam a resposta vazia direto; para repeti-la e falhar, use non_empty no job",
+}
+
+// migrateConfigCommand converte cada .env (padrão: o do diretório atual) em
+// um job equivalente. As flags da instalação podem ser passadas junto:
+// -bulk-input vira campo do job, e as demais continuam valendo com -config.
+// referenceSecrets troca os segredos vindos do .env por referências ao
+// ambiente, para que não fiquem no arquivo de configuração, e devolve os
+// nomes das variáveis.
+func (c *AuthConfig) referenceSecrets() []string {
+	var names []string
+	ref := func(field *string, name string) {
+		if *field != "" {
+			*field = fmt.Sprintf("{{env %q}}", name)
+			names = append(names, name)
+		}
+	}
+	ref(&c.ClientSecret, "CLIENT_SECRET")
+	ref(&c.RefreshToken, "REFRESH_TOKEN")
+	if c.Type == "apikey" {
+		ref(&c.Token, "API_KEY")
+	} else {
+		ref(&c.Token, "TOKEN")
+	}
+	return names
+}
+
+func migrateConfigCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{".env"}
+	}
+	if *importJobName != "" && len(args) > 1 {
+		return fmt.Errorf("-name só pode ser usado com um .env")
+	}
+
+	names := map[string]int{}
+	var jobs []JobConfig
+	for _, path := range args {
+		urlBase, err := loadEnvValues(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		job := JobConfig{URL: urlBase, BulkInput: *bulkInput}
+		values, err := loadEnvFile(path)
+		if err != nil {
+			return err
+		}
+		if job.Auth, err = envAuthConfig(values); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if job.Auth != nil {
+			if secrets := job.Auth.referenceSecrets(); len(secrets) > 0 {
+				log.Printf("AVISO: %s: os segredos viraram {{env}}; exporte %s no ambiente da execução", path, strings.Join(secrets, ", "))
+			}
+		}
+		base := jobNameFromURL(urlBase)
+		if *importJobName != "" {
+			base = *importJobName
+		}
+		names[base]++
+		job.Name = base
+		if n := names[base]; n > 1 {
+			job.Name = fmt.Sprintf("%s-%d", base, n)
+		}
+		jobs = append(jobs, job)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if reason, ok := envOnlyFlags[f.Name]; ok {
+			log.Printf("AVISO: -%s não tem equivalente na configuração de jobs (%s)", f.Name, reason)
+		}
+	})
+	if *bulkInput == "" {
+		log.Printf("AVISO: o modo .env repete a requisição em loop; com -config cada execução roda os jobs uma vez, então agende-a (cron, timer)")
+	}
+
+	return emitImportedJobs(jobs)
+}
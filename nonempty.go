@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"apiconsume/utils"
+)
+
+const (
+	defaultNonEmptyRetryFor = 5 * time.Minute
+	defaultNonEmptyInterval = time.Minute
+)
+
+// NonEmptyConfig faz da resposta vazia uma falha, para feeds que nunca
+// ficam vazios de verdade: a resposta sem registros em Records (vazio = a
+// própria resposta; [], {}, null ou sem body) é buscada de novo a cada
+// Interval, por até RetryFor, e então falha o job, com o alerta e o stale
+// de qualquer falha. Sem non_empty, vazio continua sendo sucesso.
+type NonEmptyConfig struct {
+	Records  string    `json:"records,omitempty"`
+	RetryFor *Duration `json:"retry_for,omitempty"`
+	Interval *Duration `json:"interval,omitempty"`
+
+	path *utils.JSONPath
+}
+
+func (c *NonEmptyConfig) validate(job JobConfig) error {
+	if job.BulkInput != "" || job.Tenants != nil || job.Download != nil || job.Conditional != nil {
+		return fmt.Errorf("não se aplica a jobs com bulk_input, tenants, download ou conditional")
+	}
+	if job.Paginate != nil && job.Paginate.Stream != "" {
+		return fmt.Errorf("não se aplica a paginate.stream")
+	}
+	if c.Records != "" {
+		if job.Paginate != nil {
+			return fmt.Errorf("com paginate, a saída já é o array de registros; omita non_empty.records")
+		}
+		var err error
+		if c.path, err = utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	if c.RetryFor != nil && c.RetryFor.Duration < 0 {
+		return fmt.Errorf("retry_for não pode ser negativo")
+	}
+	if c.Interval != nil && c.Interval.Duration <= 0 {
+		return fmt.Errorf("interval deve ser positivo")
+	}
+	return nil
+}
+
+func (c *NonEmptyConfig) retryFor() time.Duration {
+	if c.RetryFor != nil {
+		return c.RetryFor.Duration
+	}
+	return defaultNonEmptyRetryFor
+}
+
+func (c *NonEmptyConfig) interval() time.Duration {
+	if c.Interval != nil {
+		return c.Interval.Duration
+	}
+	return defaultNonEmptyInterval
+}
+
+// empty diz se a resposta não tem registros.
+func (c *NonEmptyConfig) empty(body []byte) (bool, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return true, nil
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		// não é JSON, mas tem conteúdo
+		return false, nil
+	}
+	if c.path != nil {
+		found := c.path.Find(doc)
+		switch len(found) {
+		case 0:
+			return true, nil
+		case 1:
+			doc = found[0]
+		default:
+			return false, fmt.Errorf("non_empty.records %s não aponta para um único valor", c.Records)
+		}
+	}
+	switch v := doc.(type) {
+	case nil:
+		return true, nil
+	case []any:
+		return len(v) == 0, nil
+	case map[string]any:
+		return len(v) == 0, nil
+	case string:
+		return v == "", nil
+	}
+	return false, nil
+}
+
+// emptyResultError é a falha de non_empty: a resposta continuou vazia.
+type emptyResultError struct {
+	Records string
+	Waited  time.Duration
+}
+
+func (e *emptyResultError) Error() string {
+	msg := "resposta vazia"
+	if e.Records != "" {
+		msg = "resposta sem registros em " + e.Records
+	}
+	if e.Waited > 0 {
+		return fmt.Sprintf("%s depois de %v de novas tentativas (non_empty)", msg, e.Waited.Round(time.Second))
+	}
+	return msg + " (non_empty)"
+}
+
+// fetchNonEmpty é o fetchJob que, com non_empty, repete a busca enquanto a
+// resposta vem vazia. Os registros em quarentena ficam só os da última
+// busca, para a mesma resposta não contar de novo em quarantine.max.
+func fetchNonEmpty(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, url string, vars templateVars, limits crawlLimits) ([]byte, http.Header, error) {
+	c := job.NonEmpty
+	var since time.Time
+	rejected := len(job.rejects.list())
+	for {
+		body, header, err := fetchJob(ctx, rl, job, url, vars, limits)
+		if err != nil || c == nil {
+			return body, header, err
+		}
+		empty, err := c.empty(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !empty {
+			return body, header, nil
+		}
+
+		if since.IsZero() {
+			since = time.Now()
+		}
+		waited := time.Since(since)
+		if waited >= c.retryFor() {
+			return nil, nil, &emptyResultError{Records: c.Records, Waited: waited}
+		}
+		log.Printf("[%s] Resposta vazia, buscando de novo em %v (%v de %v)", job.Name, c.interval(), waited.Round(time.Second), c.retryFor())
+		if err := utils.SleepContext(ctx, c.interval()); err != nil {
+			return nil, nil, err
+		}
+		job.rejects.truncate(rejected)
+	}
+}
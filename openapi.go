@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+type OpenAPIRef struct {
+	Spec        string            `json:"spec"`
+	OperationID string            `json:"operation_id"`
+	Params      map[string]string `json:"params,omitempty"`
+	Server      string            `json:"server,omitempty"`
+}
+
+var openAPIMethods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+// resolveOpenAPIJob preenche método, URL, headers e o schema de resposta do
+// job a partir da operação declarada na spec. Apenas specs em JSON.
+func resolveOpenAPIJob(job *JobConfig, baseDir string) error {
+	ref := job.OpenAPI
+
+	specPath := ref.Spec
+	if !filepath.IsAbs(specPath) {
+		specPath = filepath.Join(baseDir, specPath)
+	}
+
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return fmt.Errorf("erro ao ler spec OpenAPI: %w", err)
+	}
+
+	var spec map[string]any
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("spec OpenAPI inválida (apenas JSON é suportado): %w", err)
+	}
+	resolver := utils.NewJSONSchema(nil, spec)
+
+	pathTemplate, method, op, pathItem := findOperation(spec, ref.OperationID)
+	if op == nil {
+		return fmt.Errorf("operationId %q não encontrado na spec", ref.OperationID)
+	}
+
+	server := ref.Server
+	if server == "" {
+		server = job.URL
+	}
+	if server == "" {
+		if servers, ok := spec["servers"].([]any); ok && len(servers) > 0 {
+			if s, ok := servers[0].(map[string]any); ok {
+				server, _ = s["url"].(string)
+			}
+		}
+	}
+	if server == "" {
+		return fmt.Errorf("spec sem servers; informe openapi.server")
+	}
+
+	headers := map[string]string{}
+	for k, v := range job.Headers {
+		headers[k] = v
+	}
+	query := url.Values{}
+	finalPath := pathTemplate
+
+	params := append(asList(pathItem["parameters"]), asList(op["parameters"])...)
+	for _, raw := range params {
+		p, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		if p, err = resolveSpecRef(resolver, p); err != nil {
+			return err
+		}
+
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		required, _ := p["required"].(bool)
+
+		value, ok := ref.Params[name]
+		if !ok {
+			if required || in == "path" {
+				return fmt.Errorf("parâmetro obrigatório %q (%s) não informado em openapi.params", name, in)
+			}
+			continue
+		}
+
+		switch in {
+		case "path":
+			finalPath = strings.ReplaceAll(finalPath, "{"+name+"}", url.PathEscape(value))
+		case "query":
+			query.Set(name, value)
+		case "header":
+			headers[name] = value
+		}
+	}
+
+	if err := applyOpenAPISecurity(spec, op, headers, query); err != nil {
+		return err
+	}
+
+	job.Method = strings.ToUpper(method)
+	job.URL = strings.TrimRight(server, "/") + finalPath
+	// os parâmetros são os da operação; dataBase só com date_param explícito
+	if job.DateParam == nil {
+		job.DateParam = ptr("")
+	}
+	if len(query) > 0 {
+		job.URL += "?" + query.Encode()
+	}
+	if len(headers) > 0 {
+		job.Headers = headers
+	}
+
+	if schema := successSchema(op); schema != nil {
+		job.schema = utils.NewJSONSchema(schema, spec)
+	}
+	return nil
+}
+
+func findOperation(spec map[string]any, operationID string) (string, string, map[string]any, map[string]any) {
+	paths, _ := spec["paths"].(map[string]any)
+	for p, rawItem := range paths {
+		item, ok := rawItem.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, m := range openAPIMethods {
+			op, ok := item[m].(map[string]any)
+			if ok && op["operationId"] == operationID {
+				return p, m, op, item
+			}
+		}
+	}
+	return "", "", nil, nil
+}
+
+func resolveSpecRef(resolver *utils.JSONSchema, node map[string]any) (map[string]any, error) {
+	ref, ok := node["$ref"].(string)
+	if !ok {
+		return node, nil
+	}
+	target, err := resolver.LookupRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func applyOpenAPISecurity(spec, op map[string]any, headers map[string]string, query url.Values) error {
+	security, ok := op["security"].([]any)
+	if !ok {
+		security, _ = spec["security"].([]any)
+	}
+	if len(security) == 0 {
+		return nil
+	}
+
+	requirement, _ := security[0].(map[string]any)
+	components, _ := spec["components"].(map[string]any)
+	schemes, _ := components["securitySchemes"].(map[string]any)
+
+	for name := range requirement {
+		scheme, ok := schemes[name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("securityScheme %q não definido na spec", name)
+		}
+
+		switch scheme["type"] {
+		case "http":
+			switch strings.ToLower(fmt.Sprint(scheme["scheme"])) {
+			case "bearer":
+				headers["Authorization"] = "Bearer " + requireEnv("ACCESS_TOKEN")
+			case "basic":
+				creds := requireEnv("API_USER") + ":" + requireEnv("API_PASSWORD")
+				headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+			}
+		case "apiKey":
+			keyName, _ := scheme["name"].(string)
+			switch scheme["in"] {
+			case "header":
+				headers[keyName] = requireEnv("API_KEY")
+			case "query":
+				query.Set(keyName, requireEnv("API_KEY"))
+			}
+		case "oauth2", "openIdConnect":
+			headers["Authorization"] = "Bearer " + requireEnv("ACCESS_TOKEN")
+		}
+	}
+	return nil
+}
+
+func requireEnv(name string) string {
+	v := os.Getenv(name)
+	if v == "" {
+		log.Printf("Aviso: variável %s não definida para a autenticação da spec", name)
+	}
+	return v
+}
+
+func successSchema(op map[string]any) map[string]any {
+	responses, _ := op["responses"].(map[string]any)
+	for _, code := range []string{"200", "201", "2XX", "default"} {
+		resp, ok := responses[code].(map[string]any)
+		if !ok {
+			continue
+		}
+		content, _ := resp["content"].(map[string]any)
+		for mediaType, rawMedia := range content {
+			if !strings.Contains(mediaType, "json") {
+				continue
+			}
+			media, _ := rawMedia.(map[string]any)
+			if schema, ok := media["schema"].(map[string]any); ok {
+				return schema
+			}
+		}
+	}
+	return nil
+}
+
+func asList(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
+
+func validateAgainstSchema(schema *utils.JSONSchema, body []byte) error {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("resposta não é JSON válido: %w", err)
+	}
+
+	if violations := schema.Validate(doc); len(violations) > 0 {
+		return fmt.Errorf("resposta fora do schema: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+
+	"apiconsume/utils"
+)
+
+var (
+	forceRate         = flag.Int("force-rate", 0, "emergência: fixa a taxa (req/s) de todas as requisições, acima de rate, da descoberta e de -rate-calendar (0 = desligado)")
+	ignoreRateHeaders = flag.Bool("ignore-rate-headers", false, "emergência: descarta limite, restante e reset informados pela API (o Retry-After continua valendo)")
+)
+
+func configureOverride() {
+	utils.Override.Set(*forceRate, *ignoreRateHeaders)
+	logOverride()
+}
+
+func logOverride() {
+	if rate := utils.Override.ForceRate(); rate > 0 {
+		log.Printf("AVISO: taxa forçada em %d req/s para todas as requisições", rate)
+	}
+	if utils.Override.IgnoreHeaders() {
+		log.Printf("AVISO: headers de rate limit da API ignorados")
+	}
+}
+
+// overrideState é o corpo de GET, PUT e DELETE /limiter no serve; no PUT,
+// campos omitidos ficam como estão.
+type overrideState struct {
+	ForceRate         *int                             `json:"force_rate,omitempty"`
+	IgnoreRateHeaders *bool                            `json:"ignore_rate_headers,omitempty"`
+	Limiters          map[string]utils.LimiterSnapshot `json:"limiters,omitempty"`
+}
+
+func (s *triggerServer) limiterState() overrideState {
+	rate, ignore := utils.Override.ForceRate(), utils.Override.IgnoreHeaders()
+	return overrideState{ForceRate: &rate, IgnoreRateHeaders: &ignore, Limiters: s.limiters.snapshots()}
+}
+
+func (s *triggerServer) handleLimiter(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.limiterState())
+}
+
+func (s *triggerServer) handleSetLimiter(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req overrideState
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "body inválido: " + err.Error()})
+		return
+	}
+	rate, ignore := utils.Override.ForceRate(), utils.Override.IgnoreHeaders()
+	if req.ForceRate != nil {
+		if *req.ForceRate < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "force_rate deve ser >= 0"})
+			return
+		}
+		rate = *req.ForceRate
+	}
+	if req.IgnoreRateHeaders != nil {
+		ignore = *req.IgnoreRateHeaders
+	}
+	utils.Override.Set(rate, ignore)
+	log.Printf("Override do rate limit alterado via API (%s): force_rate=%d ignore_rate_headers=%v", r.RemoteAddr, rate, ignore)
+	logOverride()
+	writeJSON(w, http.StatusOK, s.limiterState())
+}
+
+func (s *triggerServer) handleClearLimiter(w http.ResponseWriter, r *http.Request) {
+	utils.Override.Set(0, false)
+	log.Printf("Override do rate limit removido via API (%s)", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, s.limiterState())
+}
+
+func (p *limiterPool) snapshots() map[string]utils.LimiterSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make(map[string]utils.LimiterSnapshot, len(p.m))
+	for name, rl := range p.m {
+		out[name] = rl.Snapshot()
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// AdaptiveSizeConfig sonda páginas maiores para gastar menos requisições do
+// rate limit: o tamanho dobra a cada página cheia até Max e recua quando o
+// servidor erra ou devolve menos do que o pedido (limite do lado dele). O
+// tamanho aprendido fica em -state-dir para a próxima execução.
+type AdaptiveSizeConfig struct {
+	Max int `json:"max"`
+}
+
+// pageSizer guarda o tamanho atual, o maior que já veio cheio (confirmed) e
+// o menor que falhou (ceiling), para não sondá-lo de novo.
+type pageSizer struct {
+	job       string
+	size      int
+	min       int
+	max       int
+	confirmed int
+	ceiling   int
+	adaptive  bool
+}
+
+type pageSizeState struct {
+	Size int `json:"size"`
+}
+
+func newPageSizer(job JobConfig) *pageSizer {
+	c := job.Paginate
+	s := &pageSizer{job: job.Name, size: c.Size, min: c.Size, max: c.Size}
+	if c.Adaptive == nil {
+		return s
+	}
+
+	s.adaptive = true
+	s.max = c.Adaptive.Max
+
+	var st pageSizeState
+	if ok, err := stateStore.Load(s.stateName(), &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	} else if ok && st.Size >= s.min && st.Size <= s.max {
+		s.size = st.Size
+	}
+	return s
+}
+
+func (s *pageSizer) stateName() string {
+	return "pagesize-" + s.job
+}
+
+// shrink decide se um erro do servidor deve ser tentado de novo com uma
+// página menor.
+func (s *pageSizer) shrink(status int) bool {
+	if !s.adaptive || s.size <= s.min {
+		return false
+	}
+	if status != http.StatusRequestEntityTooLarge && status != http.StatusBadRequest && status < 500 {
+		return false
+	}
+
+	s.ceiling = s.size
+	next := s.size / 2
+	if next < s.confirmed {
+		next = s.confirmed
+	}
+	if next < s.min {
+		next = s.min
+	}
+	log.Printf("[%s] Status %d com %d registros por página; recuando para %d", s.job, status, s.size, next)
+	s.size = next
+	return true
+}
+
+// observe registra quantos registros vieram e diz se a página era a
+// última. Uma página curta durante a sondagem é tratada como limite do
+// servidor, e não como fim.
+func (s *pageSizer) observe(n int) bool {
+	if s.size <= 0 {
+		return n == 0
+	}
+	if n >= s.size {
+		s.confirmed = s.size
+		if s.adaptive {
+			s.grow()
+		}
+		return false
+	}
+	if !s.adaptive || n == 0 || s.size <= s.confirmed {
+		return true
+	}
+
+	log.Printf("[%s] Servidor devolveu %d de %d registros pedidos; usando %d por página", s.job, n, s.size, n)
+	s.max = n
+	s.confirmed = n
+	s.size = n
+	return false
+}
+
+func (s *pageSizer) grow() {
+	next := s.size * 2
+	if next > s.max {
+		next = s.max
+	}
+	if s.ceiling > 0 && next >= s.ceiling {
+		next = s.confirmed
+	}
+	if next != s.size {
+		log.Printf("[%s] Página cheia com %d registros; sondando %d", s.job, s.size, next)
+	}
+	s.size = next
+}
+
+// save persiste o maior tamanho que funcionou.
+func (s *pageSizer) save() {
+	if !s.adaptive || s.confirmed == 0 {
+		return
+	}
+	if err := stateStore.Save(s.stateName(), pageSizeState{Size: s.confirmed}); err != nil {
+		log.Printf("[%s] Erro ao salvar tamanho de página: %v", s.job, err)
+	}
+}
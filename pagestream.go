@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"apiconsume/utils"
+)
+
+// pageStream grava os registros de uma paginação direto em disco, página a
+// página, para coletas grandes demais para a memória: "ndjson" (um
+// registro por linha) ou "array" (um único array JSON). Enquanto a coleta
+// corre, o arquivo fica em <saída>.part e a posição em -state-dir; se o job
+// falhar, a execução seguinte continua da página seguinte à última gravada.
+type pageStream struct {
+	job    string
+	format string
+	path   string
+	file   *os.File
+	state  pageCheckpoint
+}
+
+// pageCheckpoint é o que o stream já gravou: a posição da próxima página,
+// quantos registros e até que byte do .part. URL é a da coleta, para não
+// retomar uma execução de outro dia.
+type pageCheckpoint struct {
+	URL     string     `json:"url"`
+	Cursor  pageCursor `json:"cursor"`
+	Records int        `json:"records"`
+	Offset  int64      `json:"offset"`
+}
+
+// streamConflict diz qual recurso do job precisa da saída inteira em
+// memória e por isso não combina com stream.
+func (c *PaginateConfig) streamConflict(job JobConfig) string {
+	switch {
+	case job.Quality != nil:
+		return "quality"
+	case job.Dedup != nil:
+		return "dedup"
+	case job.Watermark != nil:
+		return "watermark"
+	case job.Enrich != nil:
+		return "enrich"
+	case job.Details != nil:
+		return "details"
+	case job.Project != nil:
+		return "project"
+	case job.Sort != nil:
+		return "sort"
+	case job.Publish != nil:
+		return "publish"
+	case len(job.Sinks) > 0:
+		return "sinks"
+	case job.ResponseHeaders != nil && job.ResponseHeaders.Inject != "":
+		return "response_headers.inject"
+	}
+	return ""
+}
+
+func streamOutputPath(job JobConfig, outputDir string) string {
+	ext := ".json"
+	if job.Paginate.Stream == "ndjson" {
+		ext = ".ndjson"
+	}
+	return filepath.Join(outputDir, "response-"+job.Name+ext)
+}
+
+// streamPages faz a coleta paginada de um job com stream e devolve quantos
+// registros foram gravados e os headers da primeira página pedida.
+func streamPages(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, url string, limits crawlLimits, outputDir string) (int, http.Header, error) {
+	spec, err := job.render(url, templateVars{})
+	if err != nil {
+		return 0, nil, fmt.Errorf("erro no template da requisição: %w", err)
+	}
+
+	stream, resume, err := openPageStream(job, streamOutputPath(job, outputDir), spec.URL)
+	if err != nil {
+		return 0, nil, err
+	}
+	header, err := crawlPages(ctx, rl, job, spec, &crawlUsage{limits: limits}, stream, resume)
+	if err != nil {
+		stream.file.Close()
+		return 0, nil, err
+	}
+	if err := stream.finish(); err != nil {
+		return 0, nil, err
+	}
+	log.Printf("[%s] %d registros gravados em %s", job.Name, stream.state.Records, stream.path)
+	return stream.state.Records, header, nil
+}
+
+func (s *pageStream) stateName() string {
+	return "paginate-" + s.job
+}
+
+// openPageStream abre o .part: do ponto salvo, quando há uma coleta
+// interrompida da mesma URL, ou do zero.
+func openPageStream(job JobConfig, path, url string) (*pageStream, *pageCheckpoint, error) {
+	s := &pageStream{job: job.Name, format: job.Paginate.Stream, path: path, state: pageCheckpoint{URL: url}}
+	part := path + ".part"
+
+	var saved pageCheckpoint
+	ok, err := stateStore.Load(s.stateName(), &saved)
+	if err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+	if info, statErr := os.Stat(part); ok && saved.URL == url && statErr == nil && info.Size() >= saved.Offset && saved.Offset > 0 {
+		f, err := os.OpenFile(part, os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		// o que passou do último checkpoint é de uma página incompleta
+		if err := f.Truncate(saved.Offset); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		if _, err := f.Seek(saved.Offset, 0); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		s.file, s.state = f, saved
+		return s, &saved, nil
+	}
+
+	f, err := os.Create(part)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.file = f
+	if s.format == "array" {
+		if _, err := f.WriteString("["); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		s.state.Offset = 1
+	}
+	return s, nil, nil
+}
+
+func (s *pageStream) write(records []any, next pageCursor) error {
+	var buf []byte
+	for _, rec := range records {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if s.format == "array" {
+			if s.state.Records > 0 || len(buf) > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, '\n')
+			buf = append(buf, data...)
+		} else {
+			buf = append(buf, data...)
+			buf = append(buf, '\n')
+		}
+	}
+	n, err := s.file.Write(buf)
+	if err != nil {
+		return fmt.Errorf("erro ao gravar %s.part: %w", s.path, err)
+	}
+
+	s.state.Offset += int64(n)
+	s.state.Records += len(records)
+	s.state.Cursor = next
+	if err := stateStore.Save(s.stateName(), s.state); err != nil {
+		log.Printf("[%s] Erro ao salvar a posição da paginação: %v", s.job, err)
+	}
+	return nil
+}
+
+// finish fecha o array e troca o .part pela saída final.
+func (s *pageStream) finish() error {
+	if s.format == "array" {
+		if _, err := s.file.WriteString("\n]\n"); err != nil {
+			s.file.Close()
+			return err
+		}
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := publishFile(s.file.Name(), s.path); err != nil {
+		return err
+	}
+	if err := stateStore.Remove(s.stateName()); err != nil {
+		log.Printf("[%s] %v", s.job, err)
+	}
+	return nil
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// PaginateConfig percorre as páginas de um endpoint pelo parâmetro Param
+// (número da página, offset ou cursor) ou pelo Link rel="next", e grava os
+// registros de todas elas num único array.
+type PaginateConfig struct {
+	Param     string `json:"param,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+	Start     *int   `json:"start,omitempty"`
+	SizeParam string `json:"size_param,omitempty"`
+	Size      int    `json:"size,omitempty"`
+	Records   string `json:"records,omitempty"`
+
+	// No mode cursor, o próximo cursor vem do jsonpath Cursor na página ou
+	// do campo CursorField do último registro (ex: starting_after da
+	// Stripe); HasMore, quando informado, encerra a coleta ao vir false.
+	Cursor      string `json:"cursor,omitempty"`
+	CursorField string `json:"cursor_field,omitempty"`
+	HasMore     string `json:"has_more,omitempty"`
+
+	// Stream grava os registros em disco a cada página, sem juntá-los em
+	// memória: "ndjson" ou "array".
+	Stream string `json:"stream,omitempty"`
+
+	Adaptive *AdaptiveSizeConfig `json:"adaptive,omitempty"`
+
+	// Total de registros ou de páginas (jsonpath na primeira página); sem
+	// eles, valem X-Total-Count, X-Total-Pages ou o Link rel="last".
+	Total      string `json:"total,omitempty"`
+	TotalPages string `json:"total_pages,omitempty"`
+
+	// Deadline avisa quando a estimativa de término passa do tempo
+	// disponível até a próxima execução agendada.
+	Deadline *Duration `json:"deadline,omitempty"`
+
+	// VerifyTotal confere, ao fim, os registros coletados com o total
+	// informado na primeira página: "fail" falha o job, "warn" só avisa.
+	VerifyTotal string `json:"verify_total,omitempty"`
+}
+
+var (
+	paginateModes   = []string{"page", "offset", "cursor", "link"}
+	paginateStreams = []string{"ndjson", "array"}
+)
+
+func (c *PaginateConfig) validate() error {
+	if c.Mode == "" {
+		c.Mode = "page"
+	}
+	if !slices.Contains(paginateModes, c.Mode) {
+		return fmt.Errorf("paginate.mode deve ser page, offset, cursor ou link")
+	}
+	if c.Param == "" && c.Mode != "link" {
+		return fmt.Errorf("paginate.param é obrigatório")
+	}
+	if c.Mode == "cursor" && (c.Cursor == "") == (c.CursorField == "") {
+		return fmt.Errorf("paginate: mode cursor exige cursor ou cursor_field")
+	}
+	if c.Mode != "cursor" && (c.Cursor != "" || c.CursorField != "" || c.HasMore != "") {
+		return fmt.Errorf("paginate: cursor, cursor_field e has_more só se aplicam ao mode cursor")
+	}
+	if c.Stream != "" && !slices.Contains(paginateStreams, c.Stream) {
+		return fmt.Errorf("paginate.stream deve ser ndjson ou array")
+	}
+	if c.SizeParam != "" && c.Size <= 0 {
+		return fmt.Errorf("paginate.size_param exige size")
+	}
+	if c.Adaptive != nil {
+		if c.SizeParam == "" || c.Mode != "offset" {
+			return fmt.Errorf("paginate.adaptive exige mode offset e size_param")
+		}
+		if c.Adaptive.Max < c.Size {
+			return fmt.Errorf("paginate.adaptive.max deve ser >= size")
+		}
+	}
+	switch c.VerifyTotal {
+	case "", "warn", "fail":
+	default:
+		return fmt.Errorf("paginate.verify_total deve ser warn ou fail")
+	}
+	for _, p := range []string{c.Records, c.Total, c.TotalPages, c.Cursor, c.HasMore} {
+		if p == "" {
+			continue
+		}
+		if _, err := utils.ParseJSONPath(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *PaginateConfig) start() int {
+	if c.Start != nil {
+		return *c.Start
+	}
+	if c.Mode == "offset" {
+		return 0
+	}
+	return 1
+}
+
+// pageCursor é a posição da coleta: quantas páginas já vieram e o que pede
+// a próxima (o valor de Param, o cursor ou a URL do Link rel="next").
+type pageCursor struct {
+	Page   int    `json:"page"`
+	Value  int    `json:"value,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	Next   string `json:"next,omitempty"`
+}
+
+func (c *PaginateConfig) pageURL(raw string, pos pageCursor, size int) (string, error) {
+	if pos.Next != "" {
+		// o link já traz todos os parâmetros da próxima página
+		return pos.Next, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	switch c.Mode {
+	case "page", "offset":
+		q.Set(c.Param, strconv.Itoa(pos.Value))
+	case "cursor":
+		if pos.Cursor != "" {
+			q.Set(c.Param, pos.Cursor)
+		}
+	}
+	if c.SizeParam != "" {
+		q.Set(c.SizeParam, strconv.Itoa(size))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// pageWriter recebe os registros de cada página com a posição da próxima:
+// o array em memória de fetchPages ou o arquivo de um stream.
+type pageWriter interface {
+	write(records []any, next pageCursor) error
+}
+
+type memoryPages struct {
+	all []any
+}
+
+func (m *memoryPages) write(records []any, next pageCursor) error {
+	m.all = append(m.all, records...)
+	return nil
+}
+
+// fetchPages devolve os registros de todas as páginas e os headers da
+// primeira.
+func fetchPages(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, spec requestSpec, usage *crawlUsage) ([]byte, http.Header, error) {
+	pages := &memoryPages{all: []any{}}
+	first, err := crawlPages(ctx, rl, job, spec, usage, pages, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(pages.all)
+	return data, first, err
+}
+
+// crawlPages percorre as páginas entregando os registros a w, a partir do
+// início ou da posição de resume, e devolve os headers da primeira página
+// pedida.
+func crawlPages(ctx context.Context, rl *utils.RateLimitClient, job JobConfig, spec requestSpec, usage *crawlUsage, w pageWriter, resume *pageCheckpoint) (http.Header, error) {
+	c := job.Paginate
+	progress := &pageProgress{job: job.Name, start: time.Now()}
+	if c.Deadline != nil {
+		progress.deadline = c.Deadline.Duration
+	}
+
+	sizer := newPageSizer(job)
+	totalRecords := 0
+	guard := pageGuard{}
+
+	pos := pageCursor{Value: c.start()}
+	collected := 0
+	filtered := 0
+	if resume != nil {
+		pos, collected = resume.Cursor, resume.Records
+		log.Printf("[%s] Retomando a paginação na página %d (%d registros já gravados)", job.Name, pos.Page+1, collected)
+	}
+	firstPage := pos.Page + 1
+
+	var first http.Header
+	reported, hasTotal := 0, false
+	for page := firstPage; ; page++ {
+		pageSpec := spec
+		var err error
+		if pageSpec.URL, err = c.pageURL(spec.URL, pos, sizer.size); err != nil {
+			return nil, err
+		}
+
+		body, header, status, err := job.Status.fetch(ctx, rl, pageSpec)
+		fetchedAt := time.Now()
+		if err == nil && !job.Status.accepts(status) && sizer.shrink(status) {
+			page--
+			continue
+		}
+		if err != nil || !job.Status.accepts(status) {
+			return nil, fmt.Errorf("página %d: %w", page, requestError(job.ErrorEnvelope, status, body, err))
+		}
+		if page == firstPage {
+			checkContentLanguage(job, pageSpec, header)
+			first = header
+		}
+		if job.schema != nil {
+			if body, err = job.checkSchema(body, c.Records); err != nil {
+				return nil, fmt.Errorf("página %d: %w", page, err)
+			}
+		}
+
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("página %d não é JSON: %w", page, err)
+		}
+		if _, isArray := doc.([]any); !isArray && c.Records == "" {
+			return nil, fmt.Errorf("paginate.records é obrigatório quando a página não é um array")
+		}
+		records := utils.SelectRecords(doc, c.Records)
+		if err := guard.check(page, records, c.Param); err != nil {
+			return nil, err
+		}
+		if err := usage.add(1, len(records), int64(len(body))); err != nil {
+			return nil, fmt.Errorf("página %d: %w", page, err)
+		}
+		collected += len(records)
+
+		if page == firstPage {
+			reported, hasTotal = c.reportedTotal(doc, header)
+			progress.total, totalRecords = c.estimatePages(doc, header, sizer.size, len(records))
+		} else if sizer.adaptive && totalRecords > 0 && sizer.size > 0 {
+			// com o tamanho variando, o total de páginas é recalculado pelo
+			// que falta de registros
+			progress.total = page + int(math.Ceil(float64(totalRecords-collected)/float64(sizer.size)))
+		}
+		progress.report(page, collected, rl.CurrentRate())
+
+		var last bool
+		switch c.Mode {
+		case "cursor", "link":
+			if pos, last, err = c.follow(pos, doc, header, records, pageSpec.URL); err != nil {
+				return nil, fmt.Errorf("página %d: %w", page, err)
+			}
+		default:
+			last = sizer.observe(len(records)) ||
+				(!sizer.adaptive && progress.total > 0 && page >= progress.total)
+			if c.Mode == "offset" {
+				pos.Value += len(records)
+			} else {
+				pos.Value++
+			}
+		}
+		last = last || (totalRecords > 0 && collected >= totalRecords)
+		pos.Page = page
+
+		if job.Filter != nil {
+			n := len(records)
+			records = job.Filter.filter.Keep(records)
+			filtered += n - len(records)
+		}
+		if job.Provenance != nil {
+			job.Provenance.stamp(job, records, pageSpec.URL, fetchedAt)
+		}
+		if err := w.write(records, pos); err != nil {
+			return nil, err
+		}
+		if last {
+			break
+		}
+	}
+
+	sizer.save()
+	log.Printf("[%s] Paginação concluída: %d registros em %v", job.Name, collected, time.Since(progress.start).Round(time.Second))
+	if job.Filter != nil {
+		log.Printf("[%s] Filtro: %d registros descartados", job.Name, filtered)
+	}
+	if err := c.verifyTotal(job, reported, hasTotal, collected); err != nil {
+		return nil, err
+	}
+	return first, nil
+}
+
+// follow calcula a próxima posição dos modes cursor e link e diz se a
+// página era a última.
+func (c *PaginateConfig) follow(pos pageCursor, doc any, header http.Header, records []any, pageURL string) (pageCursor, bool, error) {
+	if c.Mode == "link" {
+		target, ok := linkTarget(header.Get("Link"), "next")
+		if !ok {
+			return pos, true, nil
+		}
+		next, err := resolveNext(pageURL, target)
+		pos.Next = next
+		return pos, false, err
+	}
+
+	if len(records) == 0 {
+		return pos, true, nil
+	}
+	if c.HasMore != "" {
+		p, _ := utils.ParseJSONPath(c.HasMore)
+		if more, ok := p.First(doc); ok && more == false {
+			return pos, true, nil
+		}
+	}
+
+	var cursor string
+	if c.CursorField != "" {
+		if rec, ok := records[len(records)-1].(map[string]any); ok {
+			cursor = cursorValue(rec[c.CursorField])
+		}
+	} else {
+		p, _ := utils.ParseJSONPath(c.Cursor)
+		value, _ := p.First(doc)
+		cursor = cursorValue(value)
+	}
+	pos.Cursor = cursor
+	return pos, cursor == "", nil
+}
+
+// cursorValue aceita cursores string ou numéricos (ids inteiros não viram
+// notação científica).
+func cursorValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// resolveNext resolve o link rel="next" contra a página atual; um link
+// para outro host não é seguido, para não levar a credencial do job.
+func resolveNext(pageURL, target string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("Link rel=\"next\" inválido: %w", err)
+	}
+	next := base.ResolveReference(ref)
+	if next.Host != base.Host {
+		return "", fmt.Errorf("Link rel=\"next\" aponta para outro host (%s)", next.Host)
+	}
+	return next.String(), nil
+}
+
+// pageGuard aborta quando uma página repete outra já recebida: sinal de
+// que a API ignora o parâmetro de paginação e a coleta nunca terminaria. O
+// hash é dos registros, não do corpo, que pode trazer timestamps da
+// resposta.
+type pageGuard map[[sha256.Size]byte]int
+
+func (g pageGuard) check(page int, records []any, param string) error {
+	if len(records) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if first, seen := g[sum]; seen {
+		return fmt.Errorf("página %d é idêntica à página %d: a API parece ignorar %q; coleta abortada para não repetir indefinidamente", page, first, param)
+	}
+	g[sum] = page
+	return nil
+}
+
+// estimatePages usa o que a API informar na primeira página: total de
+// páginas e, quando conhecido, de registros; 0 quando não há como saber.
+func (c *PaginateConfig) estimatePages(doc any, header http.Header, pageSize, firstCount int) (int, int) {
+	if pages, ok := c.bodyNumber(doc, c.TotalPages); ok {
+		return pages, 0
+	}
+	if pages, err := strconv.Atoi(header.Get("X-Total-Pages")); err == nil {
+		return pages, 0
+	}
+
+	if pageSize <= 0 {
+		pageSize = firstCount
+	}
+
+	total, ok := c.reportedTotal(doc, header)
+	if ok && pageSize > 0 {
+		return int(math.Ceil(float64(total) / float64(pageSize))), total
+	}
+
+	if last, ok := c.lastFromLink(header.Get("Link")); ok {
+		if c.Mode == "offset" {
+			if pageSize > 0 {
+				return (last-c.start())/pageSize + 1, 0
+			}
+			return 0, 0
+		}
+		return last - c.start() + 1, 0
+	}
+	return 0, 0
+}
+
+// reportedTotal é o total de registros informado pela API: o jsonpath de
+// Total ou o X-Total-Count.
+func (c *PaginateConfig) reportedTotal(doc any, header http.Header) (int, bool) {
+	if total, ok := c.bodyNumber(doc, c.Total); ok {
+		return total, true
+	}
+	n, err := strconv.Atoi(header.Get("X-Total-Count"))
+	return n, err == nil
+}
+
+func (c *PaginateConfig) verifyTotal(job JobConfig, reported int, known bool, collected int) error {
+	switch {
+	case c.VerifyTotal == "":
+		return nil
+	case !known:
+		log.Printf("[%s] AVISO: a API não informou o total de registros; conferência de verify_total ignorada", job.Name)
+		return nil
+	case reported == collected:
+		return nil
+	}
+	err := fmt.Errorf("coleta incompleta: %d registros coletados, a API informou %d", collected, reported)
+	if c.VerifyTotal == "fail" {
+		return err
+	}
+	log.Printf("[%s] AVISO: %v", job.Name, err)
+	return nil
+}
+
+func (c *PaginateConfig) bodyNumber(doc any, path string) (int, bool) {
+	if path == "" {
+		return 0, false
+	}
+	p, _ := utils.ParseJSONPath(path)
+	value, _ := p.First(doc)
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// lastFromLink lê o valor de Param no link rel="last".
+func (c *PaginateConfig) lastFromLink(link string) (int, bool) {
+	target, ok := linkTarget(link, "last")
+	if !ok || c.Param == "" {
+		return 0, false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(u.Query().Get(c.Param))
+	return n, err == nil
+}
+
+// linkTarget devolve a URL do link com a relação rel num header Link (RFC
+// 8288).
+func linkTarget(link, rel string) (string, bool) {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="`+rel+`"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(target), "<>"), true
+	}
+	return "", false
+}
+
+// pageProgress registra o andamento com ETA pela taxa atual do rate
+// limiter ou pelo ritmo observado, o que for mais lento.
+type pageProgress struct {
+	job      string
+	total    int
+	start    time.Time
+	deadline time.Duration
+	lastLog  time.Time
+	warned   bool
+}
+
+const progressInterval = 10 * time.Second
+
+func (p *pageProgress) report(page, records, rate int) {
+	now := time.Now()
+	done := p.total > 0 && page >= p.total
+	if page > 1 && !done && now.Sub(p.lastLog) < progressInterval {
+		return
+	}
+	p.lastLog = now
+
+	if p.total <= 0 {
+		log.Printf("[%s] Página %d, %d registros, %d req/s (total desconhecido)", p.job, page, records, rate)
+		return
+	}
+
+	eta := p.eta(page, rate, now)
+	log.Printf("[%s] Página %d/%d (%d%%), %d registros, %d req/s, ETA %v",
+		p.job, page, p.total, page*100/p.total, records, rate, eta.Round(time.Second))
+
+	if p.deadline > 0 && !p.warned && now.Sub(p.start)+eta > p.deadline {
+		p.warned = true
+		log.Printf("[%s] AVISO: término estimado em %v ultrapassa o deadline de %v", p.job, (now.Sub(p.start) + eta).Round(time.Second), p.deadline)
+	}
+}
+
+func (p *pageProgress) eta(page, rate int, now time.Time) time.Duration {
+	remaining := p.total - page
+	if remaining <= 0 {
+		return 0
+	}
+	perPage := now.Sub(p.start) / time.Duration(page)
+	if byRate := time.Second / time.Duration(rate); byRate > perPage {
+		perPage = byRate
+	}
+	return perPage * time.Duration(remaining)
+}
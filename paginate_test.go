@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"apiconsume/utils"
+)
+
+// pageServer responde cada página por page(q) e anota a URI pedida.
+func pageServer(t *testing.T, page func(q url.Values) (http.Header, string)) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		header, body := page(r.URL.Query())
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func testRateClient() *utils.RateLimitClient {
+	rl := utils.NewRateLimitClient()
+	rl.SafeRate, rl.DynamicRate = 1000, 1000
+	rl.MaxRetries = 0
+	return rl
+}
+
+// slicePage devolve a fatia [from, from+n) de ids 1..total como JSON.
+func slicePage(from, n, total int) string {
+	var ids []string
+	for id := from + 1; id <= min(from+n, total); id++ {
+		ids = append(ids, fmt.Sprintf(`{"id":%d}`, id))
+	}
+	return "[" + strings.Join(ids, ",") + "]"
+}
+
+func atoi(s string) int {
+	var n int
+	fmt.Sscan(s, &n)
+	return n
+}
+
+func TestFetchPages(t *testing.T) {
+	ids := func(n int) string { return slicePage(0, n, n) }
+
+	tests := []struct {
+		name     string
+		cfg      PaginateConfig
+		path     string
+		page     func(q url.Values) (http.Header, string)
+		want     string
+		requests []string
+	}{
+		{
+			name: "page: página curta encerra",
+			cfg:  PaginateConfig{Param: "page", SizeParam: "per_page", Size: 2},
+			page: func(q url.Values) (http.Header, string) {
+				return nil, slicePage((atoi(q.Get("page"))-1)*2, 2, 5)
+			},
+			want:     ids(5),
+			requests: []string{"/items?page=1&per_page=2", "/items?page=2&per_page=2", "/items?page=3&per_page=2"},
+		},
+		{
+			name: "page: X-Total-Pages encerra",
+			cfg:  PaginateConfig{Param: "page"},
+			page: func(q url.Values) (http.Header, string) {
+				return http.Header{"X-Total-Pages": {"2"}}, slicePage((atoi(q.Get("page"))-1)*2, 2, 10)
+			},
+			want:     ids(4),
+			requests: []string{"/items?page=1", "/items?page=2"},
+		},
+		{
+			name: "page: página vazia encerra sem size",
+			cfg:  PaginateConfig{Param: "p", Start: new(int)},
+			page: func(q url.Values) (http.Header, string) {
+				return nil, slicePage(atoi(q.Get("p"))*3, 3, 4)
+			},
+			want:     ids(4),
+			requests: []string{"/items?p=0", "/items?p=1", "/items?p=2"},
+		},
+		{
+			name: "offset: total no body",
+			cfg:  PaginateConfig{Mode: "offset", Param: "offset", SizeParam: "limit", Size: 2, Records: "$.items", Total: "$.total"},
+			page: func(q url.Values) (http.Header, string) {
+				return nil, `{"total":4,"items":` + slicePage(atoi(q.Get("offset")), 2, 4) + `}`
+			},
+			want:     ids(4),
+			requests: []string{"/items?limit=2&offset=0", "/items?limit=2&offset=2"},
+		},
+		{
+			name: "offset: avança pelos registros recebidos",
+			cfg:  PaginateConfig{Mode: "offset", Param: "skip", Records: "$.data"},
+			page: func(q url.Values) (http.Header, string) {
+				return http.Header{"X-Total-Count": {"5"}}, `{"data":` + slicePage(atoi(q.Get("skip")), 3, 5) + `}`
+			},
+			want:     ids(5),
+			requests: []string{"/items?skip=0", "/items?skip=3"},
+		},
+		{
+			name: "cursor: jsonpath com has_more false",
+			cfg:  PaginateConfig{Mode: "cursor", Param: "cursor", Cursor: "$.next", HasMore: "$.has_more", Records: "$.items"},
+			page: func(q url.Values) (http.Header, string) {
+				if q.Get("cursor") == "" {
+					return nil, `{"items":` + slicePage(0, 2, 3) + `,"next":"c2","has_more":true}`
+				}
+				return nil, `{"items":` + slicePage(2, 2, 3) + `,"next":"c3","has_more":false}`
+			},
+			want:     ids(3),
+			requests: []string{"/items", "/items?cursor=c2"},
+		},
+		{
+			name: "cursor: jsonpath sem próximo encerra",
+			cfg:  PaginateConfig{Mode: "cursor", Param: "after", Cursor: "$.meta.next", Records: "$.items"},
+			page: func(q url.Values) (http.Header, string) {
+				if q.Get("after") == "" {
+					return nil, `{"items":` + slicePage(0, 2, 3) + `,"meta":{"next":"abc=="}}`
+				}
+				return nil, `{"items":` + slicePage(2, 2, 3) + `,"meta":{"next":null}}`
+			},
+			want:     ids(3),
+			requests: []string{"/items", "/items?after=abc%3D%3D"},
+		},
+		{
+			name: "cursor: campo numérico do último registro",
+			cfg:  PaginateConfig{Mode: "cursor", Param: "starting_after", CursorField: "id"},
+			page: func(q url.Values) (http.Header, string) {
+				switch q.Get("starting_after") {
+				case "":
+					return nil, `[{"id":10000000001},{"id":10000000002}]`
+				case "10000000002":
+					return nil, `[{"id":10000000003}]`
+				}
+				return nil, `[]`
+			},
+			want:     `[{"id":10000000001},{"id":10000000002},{"id":10000000003}]`,
+			requests: []string{"/items", "/items?starting_after=10000000002", "/items?starting_after=10000000003"},
+		},
+		{
+			name: "link: segue rel=next relativo",
+			cfg:  PaginateConfig{Mode: "link"},
+			path: "/items?per_page=2",
+			page: func(q url.Values) (http.Header, string) {
+				switch q.Get("page") {
+				case "":
+					return http.Header{"Link": {`</items?per_page=2&page=2>; rel="next", </items?per_page=2&page=3>; rel="last"`}}, slicePage(0, 2, 5)
+				case "2":
+					return http.Header{"Link": {`<?per_page=2&page=3>; rel="next"`}}, slicePage(2, 2, 5)
+				}
+				return http.Header{"Link": {`</items?per_page=2&page=1>; rel="first"`}}, slicePage(4, 2, 5)
+			},
+			want:     ids(5),
+			requests: []string{"/items?per_page=2", "/items?per_page=2&page=2", "/items?per_page=2&page=3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := pageServer(t, tt.page)
+			cfg := tt.cfg
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			path := tt.path
+			if path == "" {
+				path = "/items"
+			}
+			job := JobConfig{Name: "t", Paginate: &cfg}
+
+			body, _, err := fetchPages(context.Background(), testRateClient(), job, requestSpec{Method: http.MethodGet, URL: srv.URL + path}, &crawlUsage{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("registros = %s; quer %s", body, tt.want)
+			}
+			if fmt.Sprint(*requests) != fmt.Sprint(tt.requests) {
+				t.Errorf("páginas pedidas = %v; quer %v", *requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestFetchPagesAborts(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PaginateConfig
+		limits  crawlLimits
+		page    func(q url.Values) (http.Header, string)
+		wantErr string
+	}{
+		{
+			name:    "API ignora o parâmetro",
+			cfg:     PaginateConfig{Param: "page"},
+			page:    func(q url.Values) (http.Header, string) { return nil, `[{"id":1},{"id":2}]` },
+			wantErr: "página 2 é idêntica à página 1",
+		},
+		{
+			name: "link para outro host",
+			cfg:  PaginateConfig{Mode: "link"},
+			page: func(q url.Values) (http.Header, string) {
+				return http.Header{"Link": {`<https://outro.exemplo/items?page=2>; rel="next"`}}, `[{"id":1}]`
+			},
+			wantErr: "outro host",
+		},
+		{
+			name:    "objeto sem records",
+			cfg:     PaginateConfig{Param: "page"},
+			page:    func(q url.Values) (http.Header, string) { return nil, `{"items":[]}` },
+			wantErr: "paginate.records é obrigatório",
+		},
+		{
+			name:    "max_pages",
+			cfg:     PaginateConfig{Param: "page"},
+			limits:  crawlLimits{Pages: 2},
+			page:    func(q url.Values) (http.Header, string) { return nil, slicePage(atoi(q.Get("page")), 1, 100) },
+			wantErr: "max_pages",
+		},
+		{
+			name: "verify_total fail",
+			cfg:  PaginateConfig{Param: "page", Size: 2, SizeParam: "n", VerifyTotal: "fail"},
+			page: func(q url.Values) (http.Header, string) {
+				return http.Header{"X-Total-Count": {"5"}}, slicePage((atoi(q.Get("page"))-1)*2, 2, 3)
+			},
+			wantErr: "3 registros coletados, a API informou 5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := pageServer(t, tt.page)
+			cfg := tt.cfg
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			job := JobConfig{Name: "t", Paginate: &cfg}
+
+			_, _, err := fetchPages(context.Background(), testRateClient(), job, requestSpec{Method: http.MethodGet, URL: srv.URL + "/items"}, &crawlUsage{limits: tt.limits})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("fetchPages = %v; quer erro com %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCrawlPagesResume(t *testing.T) {
+	srv, requests := pageServer(t, func(q url.Values) (http.Header, string) {
+		if q.Get("cursor") == "c2" {
+			return nil, `{"items":[{"id":3}],"next":null}`
+		}
+		return nil, `{"items":[{"id":1},{"id":2}],"next":"c2"}`
+	})
+	cfg := PaginateConfig{Mode: "cursor", Param: "cursor", Cursor: "$.next", Records: "$.items"}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	job := JobConfig{Name: "t", Paginate: &cfg}
+	pages := &memoryPages{}
+	resume := &pageCheckpoint{Cursor: pageCursor{Page: 1, Cursor: "c2"}, Records: 2}
+
+	if _, err := crawlPages(context.Background(), testRateClient(), job, requestSpec{Method: http.MethodGet, URL: srv.URL + "/items"}, &crawlUsage{}, pages, resume); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(*requests) != "[/items?cursor=c2]" || len(pages.all) != 1 {
+		t.Errorf("retomada pediu %v e gravou %v; quer só a página do cursor c2", *requests, pages.all)
+	}
+}
+
+func TestPaginateFollow(t *testing.T) {
+	records := []any{map[string]any{"id": "a"}, map[string]any{"id": 42.0}}
+	tests := []struct {
+		name     string
+		cfg      PaginateConfig
+		doc      any
+		records  []any
+		wantNext string
+		wantLast bool
+	}{
+		{"cursor do body", PaginateConfig{Mode: "cursor", Cursor: "$.next"}, map[string]any{"next": "x"}, records, "x", false},
+		{"cursor numérico", PaginateConfig{Mode: "cursor", Cursor: "$.next"}, map[string]any{"next": 1e15}, records, "1000000000000000", false},
+		{"cursor vazio", PaginateConfig{Mode: "cursor", Cursor: "$.next"}, map[string]any{"next": ""}, records, "", true},
+		{"cursor ausente", PaginateConfig{Mode: "cursor", Cursor: "$.next"}, map[string]any{}, records, "", true},
+		{"página vazia", PaginateConfig{Mode: "cursor", Cursor: "$.next"}, map[string]any{"next": "x"}, nil, "", true},
+		{"has_more false", PaginateConfig{Mode: "cursor", Cursor: "$.next", HasMore: "$.more"}, map[string]any{"next": "x", "more": false}, records, "", true},
+		{"has_more true", PaginateConfig{Mode: "cursor", Cursor: "$.next", HasMore: "$.more"}, map[string]any{"next": "x", "more": true}, records, "x", false},
+		{"campo do último registro", PaginateConfig{Mode: "cursor", CursorField: "id"}, nil, records, "42", false},
+		{"campo ausente", PaginateConfig{Mode: "cursor", CursorField: "cursor"}, nil, records, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, last, err := tt.cfg.follow(pageCursor{}, tt.doc, http.Header{}, tt.records, "https://api.exemplo/items")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last != tt.wantLast || (!last && pos.Cursor != tt.wantNext) {
+				t.Errorf("follow = %q, última %v; quer %q, última %v", pos.Cursor, last, tt.wantNext, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestLinkTarget(t *testing.T) {
+	link := `<https://api.exemplo/items?page=2>; rel="next", <https://api.exemplo/items?page=9>; rel = "last"`
+	tests := []struct {
+		rel, want string
+		ok        bool
+	}{
+		{"next", "https://api.exemplo/items?page=2", true},
+		{"last", "https://api.exemplo/items?page=9", true},
+		{"prev", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := linkTarget(link, tt.rel); got != tt.want || ok != tt.ok {
+			t.Errorf("linkTarget(%q) = %q, %v; quer %q, %v", tt.rel, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	c := &PaginateConfig{Param: "page"}
+	if last, ok := c.lastFromLink(link); !ok || last != 9 {
+		t.Errorf("lastFromLink = %d, %v; quer 9", last, ok)
+	}
+}
+
+func TestEstimatePages(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         PaginateConfig
+		doc         any
+		header      http.Header
+		size, first int
+		wantPages   int
+		wantRecords int
+	}{
+		{"total_pages no body", PaginateConfig{Param: "page", TotalPages: "$.pages"}, map[string]any{"pages": 7.0}, http.Header{}, 10, 10, 7, 0},
+		{"X-Total-Pages", PaginateConfig{Param: "page"}, nil, http.Header{"X-Total-Pages": {"4"}}, 10, 10, 4, 0},
+		{"total de registros pelo size", PaginateConfig{Param: "page", Total: "$.total"}, map[string]any{"total": "25"}, http.Header{}, 10, 10, 3, 25},
+		{"X-Total-Count pela primeira página", PaginateConfig{Param: "page"}, nil, http.Header{"X-Total-Count": {"9"}}, 0, 4, 3, 9},
+		{"rel=last", PaginateConfig{Param: "page"}, nil, http.Header{"Link": {`</x?page=6>; rel="last"`}}, 0, 5, 6, 0},
+		{"rel=last com offset", PaginateConfig{Mode: "offset", Param: "offset"}, nil, http.Header{"Link": {`</x?offset=40>; rel="last"`}}, 20, 20, 3, 0},
+		{"desconhecido", PaginateConfig{Param: "page"}, nil, http.Header{}, 10, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			pages, records := cfg.estimatePages(tt.doc, tt.header, tt.size, tt.first)
+			if pages != tt.wantPages || records != tt.wantRecords {
+				t.Errorf("estimatePages = %d, %d; quer %d, %d", pages, records, tt.wantPages, tt.wantRecords)
+			}
+		})
+	}
+}
+
+func TestPaginateValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  PaginateConfig
+	}{
+		{"mode desconhecido", PaginateConfig{Mode: "token", Param: "p"}},
+		{"sem param", PaginateConfig{Mode: "page"}},
+		{"cursor sem origem", PaginateConfig{Mode: "cursor", Param: "c"}},
+		{"cursor com as duas origens", PaginateConfig{Mode: "cursor", Param: "c", Cursor: "$.n", CursorField: "id"}},
+		{"has_more fora do cursor", PaginateConfig{Param: "p", HasMore: "$.more"}},
+		{"stream desconhecido", PaginateConfig{Param: "p", Stream: "csv"}},
+		{"size_param sem size", PaginateConfig{Param: "p", SizeParam: "n"}},
+		{"jsonpath inválido", PaginateConfig{Param: "p", Records: "$.data[0]x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); err == nil {
+				t.Error("validate: quer erro")
+			}
+		})
+	}
+
+	var limitErr *crawlLimitError
+	if err := (&crawlUsage{limits: crawlLimits{Records: 1}}).add(1, 2, 0); !errors.As(err, &limitErr) {
+		t.Errorf("crawlUsage.add além de max_records = %v; quer crawlLimitError", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	sinkTypes["parquet"] = newParquetSink
+}
+
+// parquetSink converte os registros da resposta em um arquivo Parquet. Sem
+// schema, as colunas são inferidas dos próprios registros a cada execução.
+type parquetSink struct {
+	Records     string `json:"records"`
+	Schema      string `json:"schema"`
+	Path        string `json:"path"`
+	Compression string `json:"compression"`
+
+	columns []utils.Column
+}
+
+func newParquetSink(raw json.RawMessage, baseDir string) (sink, error) {
+	s := &parquetSink{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+
+	switch s.Compression {
+	case "", "gzip", "none":
+	default:
+		return nil, fmt.Errorf("compressão %q não suportada (gzip, none)", s.Compression)
+	}
+
+	var err error
+	if s.columns, err = loadSchemaColumns(s.Schema, baseDir); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *parquetSink) Deliver(ctx context.Context, out sinkOutput) error {
+	records, columns, err := tabularRecords(out.Data, s.Records, s.columns)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := utils.WriteParquet(&buf, columns, records, s.Compression); err != nil {
+		return err
+	}
+
+	writeFile(sinkPath(s.Path, out.Path, ".parquet"), buf.Bytes())
+	return nil
+}
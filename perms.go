@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+func parseOutputOwnership() error {
+	mode, err := strconv.ParseUint(*fileMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return fmt.Errorf("modo %q inválido (use octal, ex: 0640)", *fileMode)
+	}
+	outputPerm = os.FileMode(mode)
+
+	if *fileGroup == "" {
+		return nil
+	}
+	if gid, err := strconv.Atoi(*fileGroup); err == nil {
+		outputGID = gid
+		return nil
+	}
+
+	group, err := user.LookupGroup(*fileGroup)
+	if err != nil {
+		return err
+	}
+	outputGID, err = strconv.Atoi(group.Gid)
+	return err
+}
+
+// applyOutputOwnership ajusta o temporário antes do rename, para que o
+// arquivo final já apareça com modo e grupo corretos.
+func applyOutputOwnership(f *os.File) error {
+	if err := f.Chmod(outputPerm); err != nil {
+		return err
+	}
+	if outputGID >= 0 {
+		return f.Chown(-1, outputGID)
+	}
+	return nil
+}
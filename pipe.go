@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"apiconsume/utils"
+)
+
+var (
+	stdoutMode    = flag.Bool("stdout", false, "fetch: envia o body da resposta para stdout (logs vão para stderr)")
+	stdinBody     = flag.Bool("stdin", false, "fetch: lê o body da requisição de stdin")
+	requestMethod = flag.String("method", "", "fetch: método HTTP (padrão: GET, ou POST quando há body)")
+)
+
+func init() {
+	commands["fetch"] = fetchCommand
+}
+
+// fetchCommand faz uma única busca da URL do .env, sem o loop contínuo,
+// para uso em scripts e pipelines.
+func fetchCommand(ctx context.Context, args []string) error {
+	if *stdoutMode {
+		utils.Output = os.Stderr
+		log.SetOutput(os.Stderr)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	urlBase, err := loadEnvValues(cwd + "/.env")
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	if *stdinBody {
+		payload, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("erro ao ler body de stdin: %w", err)
+		}
+	}
+
+	method := *requestMethod
+	if method == "" {
+		method = http.MethodGet
+		if payload != nil {
+			method = http.MethodPost
+		}
+	}
+
+	if err := waitExecutionWindow(ctx); err != nil {
+		return err
+	}
+
+	rl := newRateClient(flagSettings())
+	spec := requestSpec{Method: method, URL: buildURL(urlBase), Body: payload}
+
+	if *stdoutMode {
+		return streamRequest(ctx, rl, spec, os.Stdout)
+	}
+
+	body, status, err := doRequest(ctx, rl, spec)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("status %d", status)
+	}
+
+	writeOutput(cwd+"/response.json", body)
+	log.Printf("Resposta %d bytes | Status %d", len(body), status)
+	return nil
+}
+
+func streamRequest(ctx context.Context, rl *utils.RateLimitClient, spec requestSpec, w io.Writer) error {
+	req, err := newHTTPRequest(ctx, spec)
+	if err != nil {
+		return err
+	}
+
+	resp, err := rl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return fmt.Errorf("erro ao transmitir resposta: %w", err)
+	}
+
+	log.Printf("Resposta %d bytes | Status %d", n, resp.StatusCode)
+	return nil
+}
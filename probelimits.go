@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	probeStart = flag.Int("probe-start", 1, "probe-limits: taxa inicial, em req/s")
+	probeMax   = flag.Int("probe-max", 20, "probe-limits: taxa máxima testada, em req/s")
+	probeStep  = flag.Duration("probe-step", 10*time.Second, "probe-limits: duração de cada nível de taxa")
+)
+
+func init() {
+	commands["probe-limits"] = probeLimitsCommand
+}
+
+const probeLimitsUsage = "uso: api-requester probe-limits [-config jobs.json] [-probe-start 1] [-probe-max 20] [-probe-step 10s] <url|job> [arquivo]"
+
+// probeLevel é o que se viu em um nível de taxa.
+type probeLevel struct {
+	Rate      int
+	Requests  int
+	OK        int
+	Throttled int
+	Failed    int
+	Latency   time.Duration
+}
+
+// probeTarget é como cada requisição do probe é feita: a URL com a
+// autenticação e o dialeto do job (com -config) ou só a URL.
+type probeTarget struct {
+	key     string
+	url     string
+	headers map[string]string
+	client  *http.Client
+	auth    utils.AuthProvider
+	dialect *utils.RateDialect
+}
+
+// probeLimitsCommand sobe a taxa aos poucos contra um endpoint seguro (um
+// GET que só lê), de -probe-start até -probe-max req/s, ficando
+// -probe-step em cada nível e parando no primeiro 429, em falhas do
+// provedor ou quando a cota informada não comporta o nível seguinte. A
+// última taxa sem 429 vai para o perfil do provider (ver profiles), que a
+// exploração do limiter usa a partir da próxima execução.
+func probeLimitsCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf(probeLimitsUsage)
+	}
+	if *probeStart <= 0 || *probeMax < *probeStart || *probeStep <= 0 {
+		return fmt.Errorf("-probe-start, -probe-max e -probe-step devem ser positivos, com -probe-max >= -probe-start")
+	}
+	target, err := newProbeTarget(args[0])
+	if err != nil {
+		return err
+	}
+
+	observed := map[string]string{}
+	limit, remaining := -1, -1
+	var reset time.Time
+	var mu sync.Mutex
+	observe := func(resp *http.Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		for name, values := range resp.Header {
+			if isRateHeader(name) {
+				observed[name] = strings.Join(values, ", ")
+			}
+		}
+		l, r, at, _ := target.dialect.Quota(resp.Header, time.Now())
+		if l >= 0 {
+			limit = l
+		}
+		if r >= 0 {
+			remaining, reset = r, at
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAXA\tREQUISIÇÕES\tOK\t429\tFALHAS\tLATÊNCIA MÉDIA")
+	safe := 0
+	var stop string
+	for rate := *probeStart; rate <= *probeMax; rate = nextProbeRate(rate) {
+		log.Printf("Testando %d req/s por %v em %s", rate, *probeStep, target.key)
+		level := target.run(ctx, rate, *probeStep, observe)
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%v\n", level.Rate, level.Requests, level.OK, level.Throttled, level.Failed, level.Latency.Round(time.Millisecond))
+		if err := ctx.Err(); err != nil {
+			w.Flush()
+			return err
+		}
+		if level.Throttled > 0 {
+			stop = fmt.Sprintf("429 a partir de %d req/s", rate)
+			break
+		}
+		if level.Failed > level.Requests/10 {
+			stop = fmt.Sprintf("%d falhas em %d req/s; o provedor não aguenta ou recusa", level.Failed, rate)
+			break
+		}
+		safe = rate
+		next := nextProbeRate(rate)
+		// a cota volta no reset: o nível seguinte só precisa caber nela até lá.
+		window := *probeStep
+		mu.Lock()
+		if until := time.Until(reset); !reset.IsZero() && until < window {
+			window = max(until, time.Second)
+		}
+		short := remaining >= 0 && remaining < int(math.Ceil(float64(next)*window.Seconds()))
+		mu.Unlock()
+		if short && next <= *probeMax {
+			stop = fmt.Sprintf("cota restante (%d) não comporta %d req/s", remaining, next)
+			break
+		}
+	}
+	w.Flush()
+	if stop == "" {
+		stop = fmt.Sprintf("sem 429 até -probe-max (%d req/s)", *probeMax)
+	}
+	fmt.Printf("\nResultado: %s; taxa segura %d req/s\n", stop, safe)
+	if limit >= 0 {
+		fmt.Printf("Limite informado: %d\n", limit)
+	}
+	if len(observed) > 0 {
+		fmt.Println("Headers de rate limit observados:")
+		names := make([]string, 0, len(observed))
+		for name := range observed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, observed[name])
+		}
+	}
+
+	if safe == 0 {
+		return fmt.Errorf("nenhuma taxa segura a partir de %d req/s (%s); perfil não gravado", *probeStart, stop)
+	}
+	p := providerProfile{SafeRate: safe, UpdatedAt: time.Now(), Job: "probe-limits"}
+	if limit > 0 {
+		p.Limit = limit
+	}
+	return saveProbedProfile(target.key, p, args[1:])
+}
+
+func newProbeTarget(arg string) (*probeTarget, error) {
+	if *jobConfigPath != "" {
+		cfg, err := loadJobConfig(*jobConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		defaults := flagSettings().merge(cfg.Defaults)
+		for _, job := range cfg.Jobs {
+			if job.Name != arg {
+				continue
+			}
+			settings := defaults.merge(job.JobSettings)
+			job, rawURL := job.localize(settings, job.requestURL())
+			job, rawURL = job.versioned(rawURL)
+			spec, err := job.render(rawURL, templateVars{})
+			if err != nil {
+				return nil, fmt.Errorf("erro no template da requisição: %w", err)
+			}
+			rl := job.rateClient(settings)
+			return &probeTarget{key: job.usageKey(), url: spec.URL, headers: spec.Headers, client: rl.Client, auth: rl.Auth, dialect: rl.Dialect}, nil
+		}
+	}
+	u, err := url.Parse(arg)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%q não é uma URL nem um job de -config", arg)
+	}
+	return &probeTarget{key: u.Hostname(), url: arg, client: http.DefaultClient}, nil
+}
+
+// nextProbeRate sobe a taxa em 50% (ao menos 1 req/s).
+func nextProbeRate(rate int) int {
+	return max(rate+1, int(math.Ceil(float64(rate)*1.5)))
+}
+
+// run faz rate req/s por d, cada requisição no seu horário, sem esperar as
+// anteriores.
+func (t *probeTarget) run(ctx context.Context, rate int, d time.Duration, observe func(*http.Response)) probeLevel {
+	level := probeLevel{Rate: rate}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total time.Duration
+	)
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		wg.Add(1)
+		level.Requests++
+		go func() {
+			defer wg.Done()
+			started := time.Now()
+			status, err := t.send(ctx, observe)
+			mu.Lock()
+			defer mu.Unlock()
+			total += time.Since(started)
+			switch {
+			case err != nil:
+				level.Failed++
+			case status == http.StatusTooManyRequests:
+				level.Throttled++
+			case status >= 200 && status < 400:
+				level.OK++
+			default:
+				level.Failed++
+			}
+		}()
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	wg.Wait()
+	if level.Requests > 0 {
+		level.Latency = total / time.Duration(level.Requests)
+	}
+	return level
+}
+
+func (t *probeTarget) send(ctx context.Context, observe func(*http.Response)) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	if t.auth != nil {
+		if err := t.auth.Apply(req); err != nil {
+			return 0, err
+		}
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	observe(resp)
+	if t.dialect.Throttled(resp) {
+		return http.StatusTooManyRequests, nil
+	}
+	return resp.StatusCode, nil
+}
+
+// isRateHeader reconhece os headers de rate limit dos provedores comuns
+// (X-RateLimit-*, RateLimit-*, Retry-After, cotas e buckets).
+func isRateHeader(name string) bool {
+	n := strings.ToLower(name)
+	for _, m := range []string{"ratelimit", "rate-limit", "retry-after", "quota", "bucket", "throttl"} {
+		if strings.Contains(n, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveProbedProfile grava o perfil no estado (profiles.json) e, com
+// arquivo, também no formato de profiles export.
+func saveProbedProfile(key string, p providerProfile, args []string) error {
+	profileMu.Lock()
+	st, err := loadProfiles()
+	if err == nil {
+		unlock := lockState("profiles")
+		if cur, ok := st.Providers[key]; ok {
+			if p.Limit == 0 {
+				p.Limit = cur.Limit
+			}
+			p.ResetEvery, p.Concurrency = cur.ResetEvery, cur.Concurrency
+		}
+		st.Providers[key] = p
+		err = stateStore.Save("profiles", st)
+		unlock()
+	}
+	profileMu.Unlock()
+	if err != nil {
+		return err
+	}
+	log.Printf("Perfil de %s gravado em %s: taxa segura %d req/s", key, *stateDir, p.SafeRate)
+
+	if len(args) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(profileExport{ExportedAt: time.Now(), Providers: map[string]providerProfile{key: p}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	writeFile(args[0], append(data, '\n'))
+	log.Printf("Perfil exportado para %s (use profiles import em outra instalação)", args[0])
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	commands["profiles"] = profilesCommand
+}
+
+const profilesUsage = "uso: api-requester profiles [-state-dir <dir>] export [arquivo]|import <arquivo>"
+
+// providerProfile é o que se aprendeu sobre o rate limit de um provider
+// (ver usageKey): a taxa segura da exploração, o limite informado pela API,
+// o intervalo entre os resets da cota e a concorrência do bulk que rendeu
+// mais sem erros.
+type providerProfile struct {
+	SafeRate    int       `json:"safe_rate,omitempty"`
+	Limit       int       `json:"limit,omitempty"`
+	ResetEvery  Duration  `json:"reset_every,omitzero"`
+	Concurrency int       `json:"concurrency,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Job         string    `json:"job"`
+}
+
+type profileState struct {
+	Providers map[string]providerProfile `json:"providers"`
+}
+
+// profileExport é o arquivo de profiles export/import.
+type profileExport struct {
+	ExportedAt time.Time                  `json:"exported_at"`
+	Providers  map[string]providerProfile `json:"providers"`
+}
+
+var profileMu sync.Mutex
+
+func loadProfiles() (profileState, error) {
+	var st profileState
+	_, err := stateStore.Load("profiles", &st)
+	if st.Providers == nil {
+		st.Providers = map[string]providerProfile{}
+	}
+	return st, err
+}
+
+// seedProfile começa a exploração de taxa do job na taxa segura já
+// aprendida para o provider.
+func seedProfile(job JobConfig, rl *utils.RateLimitClient) {
+	seedProfileFor(job, rl, job.usageKey())
+}
+
+// seedProfileFor usa o primeiro dos perfis keys que existir (o de uma API
+// key, depois o do provider).
+func seedProfileFor(job JobConfig, rl *utils.RateLimitClient, keys ...string) {
+	profileMu.Lock()
+	st, err := loadProfiles()
+	profileMu.Unlock()
+	if err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+	for _, key := range keys {
+		if p, ok := st.Providers[key]; ok {
+			rl.SeedProfile(utils.LimiterProfile{SafeRate: p.SafeRate})
+			return
+		}
+	}
+}
+
+// recordProfile grava em -state-dir/profiles.json o que o cliente do job
+// aprendeu; o que não foi observado nesta execução fica como estava.
+func recordProfile(job JobConfig, rl *utils.RateLimitClient, now time.Time) {
+	recordProfileFor(job, job.usageKey(), rl, now)
+}
+
+// recordProfileFor é o recordProfile do perfil key.
+func recordProfileFor(job JobConfig, key string, rl *utils.RateLimitClient, now time.Time) {
+	learned := rl.Profile()
+	if learned.Empty() {
+		return
+	}
+
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	defer lockState("profiles")()
+
+	st, err := loadProfiles()
+	if err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+	p := st.Providers[key]
+	if learned.SafeRate > 0 {
+		p.SafeRate = learned.SafeRate
+	}
+	if learned.Limit > 0 {
+		p.Limit = learned.Limit
+	}
+	if learned.ResetEvery > 0 {
+		p.ResetEvery = Duration{learned.ResetEvery}
+	}
+	p.UpdatedAt, p.Job = now, job.Name
+	st.Providers[key] = p
+
+	if err := stateStore.Save("profiles", st); err != nil {
+		log.Printf("[%s] Erro ao salvar perfil do provider: %v", job.Name, err)
+	}
+}
+
+// bulkConcurrency é a concurrency aprendida para o provider, ou zero.
+func bulkConcurrency(key string) int {
+	profileMu.Lock()
+	st, err := loadProfiles()
+	profileMu.Unlock()
+	if err != nil {
+		log.Printf("Perfil de %s: %v", key, err)
+	}
+	return st.Providers[key].Concurrency
+}
+
+// recordConcurrency grava a concurrency do bulk que rendeu mais sem erros
+// (ver utils.AIMDController.Best), para a próxima execução já começar nela.
+func recordConcurrency(key string, concurrency int, now time.Time) {
+	if concurrency <= 0 {
+		return
+	}
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	defer lockState("profiles")()
+
+	st, err := loadProfiles()
+	if err != nil {
+		log.Printf("Perfil de %s: %v", key, err)
+	}
+	p := st.Providers[key]
+	p.Concurrency, p.UpdatedAt = concurrency, now
+	if p.Job == "" {
+		p.Job = "bulk"
+	}
+	st.Providers[key] = p
+	if err := stateStore.Save("profiles", st); err != nil {
+		log.Printf("Erro ao salvar perfil de %s: %v", key, err)
+	}
+}
+
+// profilesCommand exporta os perfis aprendidos, para que uma instalação
+// nova já comece com a taxa ajustada, e importa o arquivo exportado.
+func profilesCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(profilesUsage)
+	}
+	switch args[0] {
+	case "export":
+		if len(args) > 2 {
+			return fmt.Errorf(profilesUsage)
+		}
+		return exportProfiles(args[1:])
+	case "import":
+		if len(args) != 2 {
+			return fmt.Errorf(profilesUsage)
+		}
+		return importProfiles(args[1])
+	}
+	return fmt.Errorf(profilesUsage)
+}
+
+func exportProfiles(args []string) error {
+	st, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(profileExport{ExportedAt: time.Now(), Providers: st.Providers}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if len(args) == 0 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	writeFile(args[0], data)
+	log.Printf("%d perfis exportados para %s", len(st.Providers), args[0])
+	return nil
+}
+
+// importProfiles junta os perfis do arquivo aos do estado; onde os dois
+// têm o provider, fica o atualizado por último.
+func importProfiles(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var in profileExport
+	if err := json.Unmarshal(data, &in); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	defer lockState("profiles")()
+
+	st, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(in.Providers))
+	for key := range in.Providers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	imported := 0
+	for _, key := range keys {
+		p := in.Providers[key]
+		if cur, ok := st.Providers[key]; ok && !cur.UpdatedAt.Before(p.UpdatedAt) {
+			log.Printf("Perfil de %s mantido: o local é de %s", key, cur.UpdatedAt.Format(time.RFC3339))
+			continue
+		}
+		st.Providers[key] = p
+		imported++
+		log.Printf("Perfil de %s importado (taxa segura %d req/s, limite %d, reset a cada %v)", key, p.SafeRate, p.Limit, p.ResetEvery.Duration)
+	}
+	if err := stateStore.Save("profiles", st); err != nil {
+		return err
+	}
+	log.Printf("%d de %d perfis importados", imported, len(in.Providers))
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"apiconsume/utils"
+)
+
+// ProjectConfig mantém o contrato da saída estável quando o provedor renomeia
+// campos. Fields seleciona e renomeia (destino -> jsonpath de origem no
+// registro; os demais campos são descartados e origens ausentes viram null).
+// Rename só troca nomes de primeiro nível (origem -> destino), mantendo o
+// resto do registro.
+type ProjectConfig struct {
+	Fields  map[string]string `json:"fields,omitempty"`
+	Rename  map[string]string `json:"rename,omitempty"`
+	Records string            `json:"records,omitempty"`
+}
+
+func (c *ProjectConfig) validate() error {
+	if len(c.Fields) == 0 && len(c.Rename) == 0 {
+		return fmt.Errorf("informe fields ou rename")
+	}
+	if len(c.Fields) > 0 && len(c.Rename) > 0 {
+		return fmt.Errorf("use fields ou rename, não ambos")
+	}
+	for target, source := range c.Fields {
+		if _, err := utils.ParseJSONPath(source); err != nil {
+			return fmt.Errorf("campo %q: %w", target, err)
+		}
+	}
+
+	targets := map[string]string{}
+	for source, target := range c.Rename {
+		if other, dup := targets[target]; dup {
+			return fmt.Errorf("rename: %q e %q viram %q", other, source, target)
+		}
+		targets[target] = source
+	}
+
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func projectOutput(c *ProjectConfig, body []byte) ([]byte, error) {
+	return transformOutput(body, c.Records, "project", c.projectRecords)
+}
+
+func (c *ProjectConfig) projectRecords(records []any) ([]any, error) {
+	paths := make(map[string]*utils.JSONPath, len(c.Fields))
+	for target, source := range c.Fields {
+		paths[target], _ = utils.ParseJSONPath(source)
+	}
+
+	for i, rec := range records {
+		obj, ok := rec.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if len(paths) > 0 {
+			out := make(map[string]any, len(paths))
+			for target, p := range paths {
+				value, _ := p.First(obj)
+				out[target] = value
+			}
+			records[i] = out
+			continue
+		}
+
+		// remove todas as origens antes de gravar os destinos, para que trocas
+		// (a -> b, b -> a) funcionem
+		values := make(map[string]any, len(c.Rename))
+		for source := range c.Rename {
+			if value, ok := obj[source]; ok {
+				values[source] = value
+				delete(obj, source)
+			}
+		}
+		for source, value := range values {
+			obj[c.Rename[source]] = value
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// ProvenanceConfig grava em cada registro de onde ele veio: _fetched_at
+// (quando a resposta chegou), _source_url (a URL da requisição, ou da
+// página, sem segredos) e _run_id (a execução, ver rerun). Fields escolhe
+// quais (padrão: os três). Os campos entram logo depois da coleta, antes
+// de dedup, details, enrich e project, e ficam fora da comparação do
+// delta.
+type ProvenanceConfig struct {
+	Fields  []string `json:"fields,omitempty"`
+	Records string   `json:"records,omitempty"`
+}
+
+var provenanceFields = []string{"fetched_at", "source_url", "run_id"}
+
+func (c *ProvenanceConfig) validate(job JobConfig) error {
+	for _, f := range c.Fields {
+		known := false
+		for _, k := range provenanceFields {
+			known = known || f == k
+		}
+		if !known {
+			return fmt.Errorf("campo desconhecido %q (use fetched_at, source_url ou run_id)", f)
+		}
+	}
+	if len(c.Fields) == 0 {
+		c.Fields = provenanceFields
+	}
+	switch {
+	case job.BulkInput != "" || job.Download != nil || job.Tenants != nil:
+		return fmt.Errorf("não se aplica a jobs com bulk_input, download ou tenants")
+	case job.Paginate != nil && c.Records != "":
+		return fmt.Errorf("records não se aplica a paginate (os registros são os de paginate.records)")
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	// project.fields descarta o que não lista: os campos de origem passam
+	// adiante com o mesmo nome, se o job não os mapeou
+	if job.Project != nil && len(job.Project.Fields) > 0 {
+		for _, name := range c.names() {
+			if _, ok := job.Project.Fields[name]; !ok {
+				job.Project.Fields[name] = "$." + name
+			}
+		}
+	}
+	return nil
+}
+
+func (c *ProvenanceConfig) names() []string {
+	names := make([]string, len(c.Fields))
+	for i, f := range c.Fields {
+		names[i] = "_" + f
+	}
+	return names
+}
+
+// stamp grava os campos em cada registro de records.
+func (c *ProvenanceConfig) stamp(job JobConfig, records []any, sourceURL string, fetchedAt time.Time) {
+	values := map[string]any{}
+	for _, f := range c.Fields {
+		switch f {
+		case "fetched_at":
+			values["_"+f] = fetchedAt.UTC().Format(time.RFC3339Nano)
+		case "source_url":
+			values["_"+f] = sourceURL
+			if u, err := url.Parse(sourceURL); err == nil {
+				values["_"+f] = utils.RedactURL(*u)
+			}
+		case "run_id":
+			values["_"+f] = job.runID
+		}
+	}
+	for _, rec := range records {
+		if obj, ok := rec.(map[string]any); ok {
+			for k, v := range values {
+				obj[k] = v
+			}
+		}
+	}
+}
+
+// annotateProvenance grava os campos nos registros da resposta de uma
+// requisição só; na paginação, cada página é marcada em crawlPages.
+func annotateProvenance(job JobConfig, body []byte, sourceURL string, fetchedAt time.Time) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("provenance: resposta não é JSON válido: %w", err)
+	}
+	job.Provenance.stamp(job, utils.SelectRecords(doc, job.Provenance.Records), sourceURL, fetchedAt)
+	return json.Marshal(doc)
+}
+
+// withoutProvenance é o registro sem os campos de origem, que mudam a cada
+// execução, para a comparação do delta.
+func withoutProvenance(job JobConfig, rec any) any {
+	obj, ok := rec.(map[string]any)
+	if !ok || job.Provenance == nil {
+		return rec
+	}
+	out := make(map[string]any, len(obj))
+	for k, v := range obj {
+		out[k] = v
+	}
+	for _, name := range job.Provenance.names() {
+		delete(out, name)
+	}
+	return out
+}
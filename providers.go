@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// providerPreset reúne o dialeto de um provedor conhecido: headers fixos,
+// o header de credencial lido do ambiente, como ele informa o rate limit e
+// os nomes dos parâmetros de paginação. O job sempre pode sobrescrever.
+type providerPreset struct {
+	Headers    map[string]string
+	AuthHeader string
+	AuthPrefix string
+	TokenEnv   string
+	Dialect    *utils.RateDialect
+	Paginate   *PaginateConfig
+
+	// VersionHeader é o header de api_version quando o job só informa a
+	// versão
+	VersionHeader string
+}
+
+var providerPresets = map[string]providerPreset{
+	// limite primário em X-RateLimit-* com reset em epoch; estourado, vem 403
+	// com a cota zerada (ou Retry-After, no limite secundário)
+	"github": {
+		Headers: map[string]string{
+			"Accept":               "application/vnd.github+json",
+			"X-GitHub-Api-Version": "2022-11-28",
+		},
+		VersionHeader: "X-GitHub-Api-Version",
+		AuthHeader:    "Authorization",
+		AuthPrefix:    "Bearer ",
+		TokenEnv:      "GITHUB_TOKEN",
+		Dialect: &utils.RateDialect{
+			Limit:          "X-RateLimit-Limit",
+			Remaining:      "X-RateLimit-Remaining",
+			Reset:          "X-RateLimit-Reset",
+			ThrottleStatus: []int{http.StatusForbidden},
+		},
+		Paginate: &PaginateConfig{Param: "page", SizeParam: "per_page", Size: 100},
+	},
+	// sem headers de cota: o 429 é tratado com backoff e a taxa é explorada;
+	// as listas seguem pelo id do último registro em starting_after
+	"stripe": {
+		AuthHeader: "Authorization",
+		AuthPrefix: "Bearer ",
+		TokenEnv:   "STRIPE_API_KEY",
+		// sem Stripe-Version, vale a versão fixada na conta
+		VersionHeader: "Stripe-Version",
+		Paginate: &PaginateConfig{
+			Mode: "cursor", Param: "starting_after", CursorField: "id", HasMore: "$.has_more",
+			Records: "$.data", SizeParam: "limit", Size: 100,
+		},
+	},
+	// leaky bucket de 40 chamadas que esvazia 2 por segundo
+	"shopify": {
+		AuthHeader: "X-Shopify-Access-Token",
+		TokenEnv:   "SHOPIFY_ACCESS_TOKEN",
+		Dialect: &utils.RateDialect{
+			Bucket:   "X-Shopify-Shop-Api-Call-Limit",
+			LeakRate: 2,
+		},
+		// page_info só vem no Link rel="next"
+		Paginate: &PaginateConfig{Mode: "link", SizeParam: "limit", Size: 250},
+	},
+}
+
+func providerNames() string {
+	names := make([]string, 0, len(providerPresets))
+	for name := range providerPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// applyProvider completa o job com o preset de provider sem tocar no que
+// ele já define.
+func applyProvider(job *JobConfig) error {
+	preset, ok := providerPresets[job.Provider]
+	if !ok {
+		return fmt.Errorf("provider %q desconhecido (%s)", job.Provider, providerNames())
+	}
+
+	headers := make(map[string]string, len(job.Headers)+len(preset.Headers)+1)
+	for k, v := range preset.Headers {
+		headers[k] = v
+	}
+	if preset.TokenEnv != "" && job.Auth == nil && os.Getenv(preset.TokenEnv) != "" {
+		headers[preset.AuthHeader] = preset.AuthPrefix + `{{env "` + preset.TokenEnv + `"}}`
+	}
+	for k, v := range job.Headers {
+		for existing := range headers {
+			if strings.EqualFold(existing, k) {
+				delete(headers, existing)
+			}
+		}
+		headers[k] = v
+	}
+	job.Headers = headers
+
+	job.dialect = preset.Dialect
+
+	if v := job.APIVersion; v != nil && v.Header == "" && v.Param == "" {
+		v.Header = preset.VersionHeader
+	}
+
+	if p, c := preset.Paginate, job.Paginate; p != nil && c != nil {
+		if c.Mode == "" {
+			c.Mode = p.Mode
+		}
+		if c.Param == "" {
+			c.Param = p.Param
+		}
+		if c.Mode == p.Mode && c.Cursor == "" && c.CursorField == "" {
+			c.CursorField, c.HasMore = p.CursorField, p.HasMore
+		}
+		if c.Records == "" {
+			c.Records = p.Records
+		}
+		if c.SizeParam == "" {
+			c.SizeParam = p.SizeParam
+		}
+		if c.Size == 0 && c.SizeParam == p.SizeParam {
+			c.Size = p.Size
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"apiconsume/utils"
+)
+
+var (
+	proxyURL  = flag.String("proxy", "", "proxy HTTP (padrão: HTTPS_PROXY/HTTP_PROXY do ambiente)")
+	proxyAuth = flag.String("proxy-auth", "", "autenticação no proxy: basic ou ntlm (senha em PROXY_PASSWORD)")
+	proxyUser = flag.String("proxy-user", "", "usuário do proxy, ex: DOMINIO\\usuario")
+)
+
+// configureProxy troca o http.DefaultTransport, usado por todos os clients
+// (requisições, tokens e filas), para passar pelo proxy autenticado. É
+// independente da autenticação dos endpoints.
+func configureProxy() error {
+	if *proxyAuth == "" && *proxyURL == "" {
+		return nil
+	}
+
+	raw := *proxyURL
+	if raw == "" {
+		raw = os.Getenv("HTTPS_PROXY")
+	}
+	if raw == "" {
+		raw = os.Getenv("HTTP_PROXY")
+	}
+	if raw == "" {
+		return fmt.Errorf("-proxy-auth exige -proxy ou HTTPS_PROXY")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("proxy inválido %q", raw)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	password := os.Getenv("PROXY_PASSWORD")
+
+	switch *proxyAuth {
+	case "":
+		transport.Proxy = http.ProxyURL(u)
+	case "basic":
+		u.User = url.UserPassword(*proxyUser, password)
+		transport.Proxy = http.ProxyURL(u)
+	case "ntlm":
+		if u.Scheme != "http" {
+			return fmt.Errorf("NTLM exige proxy http://")
+		}
+		domain, user := utils.ParseNTLMUser(*proxyUser)
+		creds := utils.NTLMCredentials{Domain: domain, User: user, Password: password}
+		proxyAddr := u.Host
+		if u.Port() == "" {
+			proxyAddr = net.JoinHostPort(u.Hostname(), "80")
+		}
+
+		// todo destino (http ou https) vai por túnel CONNECT autenticado
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return utils.DialNTLMProxy(ctx, proxyAddr, addr, creds)
+		}
+	default:
+		return fmt.Errorf("-proxy-auth %q não suportado (basic, ntlm)", *proxyAuth)
+	}
+
+	http.DefaultTransport = transport
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// PublishConfig ativa o commit em duas fases: a saída é gravada em staging,
+// validada e/ou aprovada pelo consumidor, e só então publicada.
+type PublishConfig struct {
+	StagingDir      string    `json:"staging_dir,omitempty"`
+	ValidateCommand []string  `json:"validate_command,omitempty"`
+	AckURL          string    `json:"ack_url,omitempty"`
+	AckFile         bool      `json:"ack_file,omitempty"`
+	AckTimeout      *Duration `json:"ack_timeout,omitempty"`
+	FlagFile        bool      `json:"flag_file,omitempty"`
+	Webhook         string    `json:"webhook,omitempty"`
+}
+
+type publishNotice struct {
+	RunID  string `json:"run_id,omitempty"`
+	Job    string `json:"job"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Bytes  int    `json:"bytes"`
+	Stage  string `json:"stage"`
+}
+
+const defaultAckTimeout = 10 * time.Minute
+
+func publishOutput(ctx context.Context, job JobConfig, finalPath string, data []byte) error {
+	cfg := job.Publish
+	if cfg == nil {
+		writeOutput(finalPath, data)
+		return nil
+	}
+
+	stagingDir := cfg.StagingDir
+	if stagingDir == "" {
+		stagingDir = filepath.Join(filepath.Dir(finalPath), "staging")
+	}
+	if err := os.MkdirAll(stagingDir, 0o755); err != nil {
+		return fmt.Errorf("erro ao criar staging: %w", err)
+	}
+
+	staged := filepath.Join(stagingDir, filepath.Base(finalPath))
+	writeFile(staged, data)
+
+	sum := sha256.Sum256(data)
+	notice := publishNotice{RunID: job.runID, Job: job.Name, Path: staged, SHA256: hex.EncodeToString(sum[:]), Bytes: len(data), Stage: "staged"}
+
+	if len(cfg.ValidateCommand) > 0 {
+		args := append(append([]string{}, cfg.ValidateCommand[1:]...), staged)
+		cmd := exec.CommandContext(ctx, cfg.ValidateCommand[0], args...)
+		cmd.Env = append(os.Environ(), "STAGED_FILE="+staged, "JOB_NAME="+job.Name)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("validação do staging falhou (%s mantido): %w", staged, err)
+		}
+	}
+
+	timeout := defaultAckTimeout
+	if cfg.AckTimeout != nil {
+		timeout = cfg.AckTimeout.Duration
+	}
+
+	if cfg.AckURL != "" {
+		if err := postNotice(ctx, cfg.AckURL, notice, timeout); err != nil {
+			return fmt.Errorf("consumidor não aprovou a publicação: %w", err)
+		}
+	}
+
+	if cfg.AckFile {
+		if err := waitAckFile(ctx, staged+".ack", timeout); err != nil {
+			return err
+		}
+		os.Remove(staged + ".ack")
+	}
+
+	if err := publishFile(staged, finalPath); err != nil {
+		return fmt.Errorf("erro ao publicar %s: %w", finalPath, err)
+	}
+	archiveOutput(finalPath, data)
+
+	if cfg.FlagFile {
+		writeFile(finalPath+".ready", []byte(time.Now().Format(time.RFC3339)+"\n"))
+	}
+
+	if cfg.Webhook != "" {
+		notice.Path = finalPath
+		notice.Stage = "published"
+		if err := postNotice(ctx, cfg.Webhook, notice, 30*time.Second); err != nil {
+			log.Printf("[%s] Erro ao notificar publicação: %v", job.Name, err)
+		}
+	}
+
+	log.Printf("[%s] Saída publicada em %s", job.Name, finalPath)
+	return nil
+}
+
+func postNotice(ctx context.Context, url string, notice any, timeout time.Duration) error {
+	if auditSkip("POST %s", url) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	payload, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func waitAckFile(ctx context.Context, path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	log.Printf("Aguardando confirmação do consumidor em %s", path)
+
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("sem confirmação em %s após %v", path, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"apiconsume/utils"
+)
+
+type qualityState struct {
+	Count int `json:"count"`
+}
+
+// checkQuality avalia as regras do job e grava quality-<job>.json. Quando
+// nenhuma regra "fail" quebrou, devolve o commit que atualiza a contagem de
+// referência, a chamar só depois que a saída foi publicada.
+func checkQuality(job JobConfig, body []byte, outputDir string) (func(), error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("resposta não é JSON válido: %w", err)
+	}
+
+	records := utils.SelectRecords(doc, job.Quality.Records)
+	stateName := "quality-" + job.Name
+
+	var previous *int
+	var st qualityState
+	if ok, err := stateStore.Load(stateName, &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	} else if ok {
+		previous = &st.Count
+	}
+
+	report := utils.EvaluateQuality(job.Quality, records, previous)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	writeFile(filepath.Join(outputDir, "quality-"+job.Name+".json"), append(data, '\n'))
+
+	for _, res := range report.Results {
+		if !res.Passed {
+			log.Printf("[%s] Qualidade (%s): %s", job.Name, res.Severity, res.Message)
+		}
+	}
+
+	if report.Failed {
+		return nil, fmt.Errorf("regras de qualidade falharam, veja quality-%s.json", job.Name)
+	}
+
+	count := len(records)
+	return func() {
+		if err := stateStore.Save(stateName, qualityState{Count: count}); err != nil {
+			log.Printf("[%s] Erro ao salvar estado de qualidade: %v", job.Name, err)
+		}
+	}, nil
+}
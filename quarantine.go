@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"apiconsume/utils"
+)
+
+// QuarantineConfig separa os registros que falham no pós-processamento
+// (schema, enrich com unmatched fail) em rejects.ndjson, com o motivo, em
+// vez de falhar o job inteiro por uma linha malformada. Records é o
+// jsonpath do array de registros (vazio = a resposta é o array); acima de
+// Max registros rejeitados (0 = sem limite) o job falha mesmo assim.
+type QuarantineConfig struct {
+	Records string `json:"records,omitempty"`
+	Max     int    `json:"max,omitempty"`
+}
+
+func (c *QuarantineConfig) validate() error {
+	if c.Max < 0 {
+		return fmt.Errorf("max deve ser >= 0")
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rejectedRecord é uma linha de rejects.ndjson.
+type rejectedRecord struct {
+	Job    string `json:"job"`
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+	Record any    `json:"record"`
+}
+
+// rejectLog junta os registros rejeitados de uma execução do job.
+type rejectLog struct {
+	mu      sync.Mutex
+	records []rejectedRecord
+}
+
+func (l *rejectLog) add(r rejectedRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, r)
+}
+
+func (l *rejectLog) list() []rejectedRecord {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.records
+}
+
+// truncate descarta os registros depois dos n primeiros, os de uma resposta
+// que vai ser buscada de novo.
+func (l *rejectLog) truncate(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n < len(l.records) {
+		l.records = l.records[:n]
+	}
+}
+
+// reject põe o registro em quarentena e falha quando passa de max.
+func (job JobConfig) reject(stage, reason string, record any) error {
+	job.rejects.add(rejectedRecord{Job: job.Name, Stage: stage, Reason: reason, Record: record})
+	if n := len(job.rejects.list()); job.Quarantine.Max > 0 && n > job.Quarantine.Max {
+		return fmt.Errorf("%d registros rejeitados, acima do máximo de %d (quarantine.max)", n, job.Quarantine.Max)
+	}
+	return nil
+}
+
+// checkSchema valida a resposta contra o schema do job. Com quarantine, os
+// registros em records (quarantine.records, se informado) que violam o
+// schema vão para a quarentena e a resposta volta sem eles.
+func (job JobConfig) checkSchema(body []byte, records string) ([]byte, error) {
+	if job.Quarantine == nil {
+		return body, validateAgainstSchema(job.schema, body)
+	}
+	if job.Quarantine.Records != "" {
+		records = job.Quarantine.Records
+	}
+	return quarantineSchema(job, body, records)
+}
+
+var schemaIndex = regexp.MustCompile(`^\[(\d+)\]`)
+
+// quarantineSchema tira do array de registros os que violam o schema, até
+// que só restem violações fora dos registros (que falham o job) ou
+// nenhuma. O validador para em poucas violações por passada, então cada
+// uma tira um lote.
+func quarantineSchema(job JobConfig, body []byte, records string) ([]byte, error) {
+	prefix := "$" + strings.TrimPrefix(records, "$")
+
+	for {
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("resposta não é JSON válido: %w", err)
+		}
+		violations := job.schema.Validate(doc)
+		if len(violations) == 0 {
+			return body, nil
+		}
+
+		reasons := map[int][]string{}
+		for _, v := range violations {
+			m := schemaIndex.FindStringSubmatch(strings.TrimPrefix(v, prefix))
+			if !strings.HasPrefix(v, prefix) || m == nil {
+				return nil, fmt.Errorf("resposta fora do schema: %s", strings.Join(violations, "; "))
+			}
+			i, _ := strconv.Atoi(m[1])
+			reasons[i] = append(reasons[i], v)
+		}
+
+		var rejectErr error
+		out, err := transformOutput(body, records, "quarantine", func(records []any) ([]any, error) {
+			kept := records[:0:0]
+			for i, rec := range records {
+				if rs, bad := reasons[i]; bad {
+					if err := job.reject("schema", strings.Join(rs, "; "), rec); err != nil {
+						rejectErr = err
+					}
+					continue
+				}
+				kept = append(kept, rec)
+			}
+			return kept, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if rejectErr != nil {
+			return nil, rejectErr
+		}
+		body = out
+	}
+}
+
+// saveRejects grava os registros rejeitados da execução, um JSON por
+// linha, ordenados por job.
+func saveRejects(path string, rejects []rejectedRecord) {
+	if len(rejects) == 0 {
+		return
+	}
+	sort.SliceStable(rejects, func(i, j int) bool { return rejects[i].Job < rejects[j].Job })
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range rejects {
+		if err := enc.Encode(r); err != nil {
+			log.Printf("Erro ao gravar registro rejeitado de %s: %v", r.Job, err)
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		log.Printf("Erro ao criar %s: %v", path, err)
+		return
+	}
+	log.Printf("%d registros em quarentena em %s", len(rejects), path)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	queryRepeat   = "repeat"
+	queryComma    = "comma"
+	queryBrackets = "brackets"
+)
+
+// QueryParam é um parâmetro de query do job: um valor ("ativo"), uma lista
+// (["1", "2"]) ou, para escolher a codificação da lista, um objeto
+// {"values": [...], "style": "brackets"}. Os estilos são repeat
+// (id=1&id=2, o padrão), comma (id=1,2) e brackets (id[]=1&id[]=2); o
+// query_style do job vale para as listas sem style próprio.
+type QueryParam struct {
+	Values []string `json:"values"`
+	Style  string   `json:"style,omitempty"`
+
+	// scalar marca o valor escrito como string, que não recebe o
+	// query_style do job
+	scalar bool
+}
+
+func (p *QueryParam) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*p = QueryParam{Values: []string{s}, scalar: true}
+		return nil
+	}
+	var list []any
+	if json.Unmarshal(b, &list) == nil {
+		values, err := queryValues(list)
+		if err != nil {
+			return err
+		}
+		*p = QueryParam{Values: values}
+		return nil
+	}
+	var obj struct {
+		Values []any  `json:"values"`
+		Style  string `json:"style"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return fmt.Errorf("parâmetro de query deve ser string, lista ou {\"values\", \"style\"}")
+	}
+	values, err := queryValues(obj.Values)
+	if err != nil {
+		return err
+	}
+	*p = QueryParam{Values: values, Style: obj.Style}
+	return nil
+}
+
+func (p QueryParam) MarshalJSON() ([]byte, error) {
+	switch {
+	case p.Style != "":
+		type plain QueryParam
+		return json.Marshal(plain(p))
+	case p.scalar:
+		return json.Marshal(p.Values[0])
+	}
+	return json.Marshal(p.Values)
+}
+
+// queryValues aceita strings, números e booleanos, como no JSON escrito à
+// mão ("id": [1, 2]).
+func queryValues(list []any) ([]string, error) {
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		switch v.(type) {
+		case string, float64, bool:
+			values = append(values, fmt.Sprint(v))
+		default:
+			return nil, fmt.Errorf("valor de query %v não é escalar", v)
+		}
+	}
+	return values, nil
+}
+
+func validateQueryStyle(style string) error {
+	switch style {
+	case "", queryRepeat, queryComma, queryBrackets:
+		return nil
+	}
+	return fmt.Errorf("style %q inválido (use repeat, comma ou brackets)", style)
+}
+
+func (job JobConfig) validateQuery() error {
+	if err := validateQueryStyle(job.QueryStyle); err != nil {
+		return fmt.Errorf("query_style: %w", err)
+	}
+	for name, p := range job.Query {
+		if err := validateQueryStyle(p.Style); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// encodeQuery monta a query string em ordem de nome, renderizando cada
+// valor. Em brackets, os colchetes vão sem escape, como os provedores
+// costumam documentar (id[]=1), e em comma a vírgula separa os valores já
+// escapados.
+func (job JobConfig) encodeQuery(vars templateVars) (string, error) {
+	names := make([]string, 0, len(job.Query))
+	for name := range job.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		p := job.Query[name]
+		values := make([]string, len(p.Values))
+		for i, v := range p.Values {
+			rendered, err := renderTemplate(v, vars)
+			if err != nil {
+				return "", err
+			}
+			values[i] = url.QueryEscape(rendered)
+		}
+
+		key := url.QueryEscape(name)
+		style := p.Style
+		if style == "" && !p.scalar {
+			style = job.QueryStyle
+		}
+		switch style {
+		case queryComma:
+			parts = append(parts, key+"="+strings.Join(values, ","))
+		case queryBrackets:
+			for _, v := range values {
+				parts = append(parts, key+"[]="+v)
+			}
+		default:
+			for _, v := range values {
+				parts = append(parts, key+"="+v)
+			}
+		}
+	}
+	return strings.Join(parts, "&"), nil
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordedCall é uma requisição recebida pela fila de mentira, com o body
+// normalizado (chaves em ordem) para comparação.
+type recordedCall struct {
+	target string
+	body   string
+}
+
+func sortedJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("body não é JSON: %s", data)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+func TestSQSQueueRequests(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXEMPLO")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "segredo")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	var calls []recordedCall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/" {
+			t.Errorf("requisição %s %s; quer POST /", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-amz-json-1.0" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		auth := r.Header.Get("Authorization")
+		date := r.Header.Get("X-Amz-Date")
+		if len(date) < 8 || !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXEMPLO/"+date[:8]+"/us-east-1/sqs/aws4_request") {
+			t.Errorf("Authorization = %q (X-Amz-Date %q)", auth, date)
+		}
+		body, _ := io.ReadAll(r.Body)
+		target := r.Header.Get("X-Amz-Target")
+		calls = append(calls, recordedCall{target, sortedJSON(t, body)})
+
+		switch target {
+		case "AmazonSQS.ReceiveMessage":
+			fmt.Fprint(w, `{"Messages":[{"MessageId":"1","Body":"{\"job\":\"a\"}","ReceiptHandle":"h1"}]}`)
+		case "AmazonSQS.SendMessage":
+			if strings.Contains(string(body), "recusar") {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"__type":"InvalidMessageContents"}`)
+				return
+			}
+			fmt.Fprint(w, `{"MessageId":"2"}`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer srv.Close()
+
+	queueURL := srv.URL + "/123456789012/jobs"
+	q, err := newSQSQueue(queueURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	msgs, err := q.Receive(ctx, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Body != `{"job":"a"}` || msgs[0].handle != "h1" {
+		t.Fatalf("Receive = %+v", msgs)
+	}
+	if err := q.Extend(ctx, msgs[0], 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ack(ctx, msgs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Publish(ctx, []byte(`{"status":"succeeded"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Publish(ctx, []byte("recusar")); err == nil || !strings.Contains(err.Error(), "InvalidMessageContents") {
+		t.Errorf("Publish com 400 = %v; quer o erro do SQS", err)
+	}
+
+	want := []recordedCall{
+		{"AmazonSQS.ReceiveMessage", `{"MaxNumberOfMessages":10,"QueueUrl":"` + queueURL + `","VisibilityTimeout":120,"WaitTimeSeconds":20}`},
+		{"AmazonSQS.ChangeMessageVisibility", `{"QueueUrl":"` + queueURL + `","ReceiptHandle":"h1","VisibilityTimeout":90}`},
+		{"AmazonSQS.DeleteMessage", `{"QueueUrl":"` + queueURL + `","ReceiptHandle":"h1"}`},
+		{"AmazonSQS.SendMessage", `{"MessageBody":"{\"status\":\"succeeded\"}","QueueUrl":"` + queueURL + `"}`},
+		{"AmazonSQS.SendMessage", `{"MessageBody":"recusar","QueueUrl":"` + queueURL + `"}`},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("%d chamadas; quer %d: %+v", len(calls), len(want), calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("chamada %d = %+v; quer %+v", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestSQSQueueRegion(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXEMPLO")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "segredo")
+	t.Setenv("AWS_REGION", "")
+
+	q, err := newSQSQueue("https://sqs.sa-east-1.amazonaws.com/123456789012/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.region != "sa-east-1" || q.endpoint != "https://sqs.sa-east-1.amazonaws.com/" {
+		t.Errorf("região %q, endpoint %q", q.region, q.endpoint)
+	}
+	if _, err := newSQSQueue("https://fila.interna/jobs"); err == nil {
+		t.Error("host sem região e sem AWS_REGION: quer erro")
+	}
+}
+
+func TestRabbitQueueRequests(t *testing.T) {
+	var calls []recordedCall
+	routed := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "guest" || pass != "s3nha" {
+			t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, recordedCall{r.Method + " " + r.URL.EscapedPath(), sortedJSON(t, body)})
+
+		if strings.HasSuffix(r.URL.Path, "/get") {
+			fmt.Fprint(w, `[{"payload":"{\"job\":\"a\"}","payload_encoding":"string","message_count":1},{"payload":"{}","payload_encoding":"string","message_count":0}]`)
+			return
+		}
+		fmt.Fprintf(w, `{"routed":%v}`, routed)
+	}))
+	defer srv.Close()
+
+	q, err := newRabbitQueue(strings.Replace(srv.URL, "://", "://guest:s3nha@", 1) + "/%2F/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	msgs, err := q.Receive(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Body != `{"job":"a"}` || msgs[1].Body != "{}" {
+		t.Fatalf("Receive = %+v", msgs)
+	}
+	if err := q.Publish(ctx, []byte(`{"status":"failed"}`)); err != nil {
+		t.Fatal(err)
+	}
+	routed = false
+	if err := q.Publish(ctx, []byte(`{}`)); err == nil {
+		t.Error("Publish não roteado: quer erro")
+	}
+
+	want := []recordedCall{
+		{"POST /api/queues/%2F/jobs/get", `{"ackmode":"ack_requeue_false","count":3,"encoding":"auto"}`},
+		{"POST /api/exchanges/%2F/amq.default/publish", `{"payload":"{\"status\":\"failed\"}","payload_encoding":"string","properties":{"content_type":"application/json"},"routing_key":"jobs"}`},
+		{"POST /api/exchanges/%2F/amq.default/publish", `{"payload":"{}","payload_encoding":"string","properties":{"content_type":"application/json"},"routing_key":"jobs"}`},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("%d chamadas; quer %d: %+v", len(calls), len(want), calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("chamada %d = %+v; quer %+v", i, calls[i], want[i])
+		}
+	}
+
+	if _, err := newRabbitQueue("http://host:15672/jobs"); err == nil {
+		t.Error("URL sem vhost: quer erro")
+	}
+}
+
+// memQueue é uma fila em memória que anota o max de cada Receive.
+type memQueue struct {
+	mu      sync.Mutex
+	pending []queueMessage
+	maxes   []int
+	acked   []string
+}
+
+func (q *memQueue) Receive(ctx context.Context, max int) ([]queueMessage, error) {
+	q.mu.Lock()
+	q.maxes = append(q.maxes, max)
+	n := min(max, len(q.pending))
+	msgs := q.pending[:n]
+	q.pending = q.pending[n:]
+	q.mu.Unlock()
+
+	if n == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return msgs, nil
+}
+
+func (q *memQueue) Ack(ctx context.Context, msg queueMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.acked = append(q.acked, msg.handle)
+	return nil
+}
+
+func (q *memQueue) Publish(ctx context.Context, body []byte) error {
+	return nil
+}
+
+func (q *memQueue) receives() []int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]int{}, q.maxes...)
+}
+
+// heldResults segura cada Publish até release, deixando o worker ocupado.
+type heldResults struct {
+	started chan queueResult
+	release chan struct{}
+}
+
+func (q *heldResults) Receive(ctx context.Context, max int) ([]queueMessage, error) {
+	return nil, nil
+}
+
+func (q *heldResults) Ack(ctx context.Context, msg queueMessage) error {
+	return nil
+}
+
+func (q *heldResults) Publish(ctx context.Context, body []byte) error {
+	var res queueResult
+	json.Unmarshal(body, &res)
+	q.started <- res
+	select {
+	case <-q.release:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func TestConsumeQueueReceivesOnlyForFreeWorkers(t *testing.T) {
+	*runsDir = t.TempDir()
+
+	const total = 5
+	source := &memQueue{}
+	for i := range total {
+		body := `{"job":"nao-existe"}`
+		if i == 0 {
+			body = "não é JSON"
+		}
+		source.pending = append(source.pending, queueMessage{Body: body, handle: fmt.Sprint("h", i)})
+	}
+	results := &heldResults{started: make(chan queueResult), release: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		consumeQueue(ctx, source, results, map[string]JobConfig{"api": {Name: "api"}, "outro": {Name: "outro"}}, 2)
+		close(done)
+	}()
+
+	wait := func() queueResult {
+		t.Helper()
+		select {
+		case res := <-results.started:
+			return res
+		case <-time.After(5 * time.Second):
+			t.Fatal("nenhum worker publicou")
+			return queueResult{}
+		}
+	}
+
+	var got []queueResult
+	got = append(got, wait(), wait())
+	// os dois workers estão ocupados: nada mais pode ser recebido
+	time.Sleep(50 * time.Millisecond)
+	if maxes := source.receives(); len(maxes) != 1 || maxes[0] != 2 {
+		t.Fatalf("Receive com os dois workers ocupados: %v; quer [2]", maxes)
+	}
+
+	for len(got) < total {
+		results.release <- struct{}{}
+		got = append(got, wait())
+	}
+	results.release <- struct{}{}
+	results.release <- struct{}{}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		source.mu.Lock()
+		acked := len(source.acked)
+		source.mu.Unlock()
+		if acked == total || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// cada worker liberado vira um Receive de uma mensagem só; o último, que
+	// encontra a fila vazia, depende de quantos terminaram antes dele
+	maxes := source.receives()
+	if len(maxes) != 5 || fmt.Sprint(maxes[:4]) != "[2 1 1 1]" || maxes[4] > 2 {
+		t.Errorf("max dos Receive = %v; quer [2 1 1 1 n] com n <= 2", maxes)
+	}
+	if len(source.acked) != total {
+		t.Errorf("%d mensagens confirmadas; quer %d", len(source.acked), total)
+	}
+
+	var invalid, missing int
+	for _, res := range got {
+		if res.Status != "failed" || res.RunID == "" {
+			t.Errorf("resultado %+v; quer failed com run_id", res)
+		}
+		switch {
+		case strings.HasPrefix(res.Error, "mensagem inválida"):
+			invalid++
+		case res.Error == `job "nao-existe" não existe` && res.Job == "nao-existe":
+			missing++
+		}
+	}
+	if invalid != 1 || missing != total-1 {
+		t.Errorf("%d inválidas e %d de job inexistente; quer 1 e %d: %+v", invalid, missing, total-1, got)
+	}
+}
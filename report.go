@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	sinkTypes["report"] = newReportSink
+}
+
+// reportSink renderiza um relatório legível (HTML ou Markdown) a partir de
+// um template Go do usuário, ao lado dos dados brutos.
+type reportSink struct {
+	Template string `json:"template"`
+	Path     string `json:"path"`
+	Records  string `json:"records"`
+	Top      int    `json:"top"`
+	SortBy   string `json:"sort_by"`
+
+	html   bool
+	ext    string
+	text   *template.Template
+	markup *htmltemplate.Template
+	sortBy *utils.JSONPath
+}
+
+// reportData é o que o template recebe.
+type reportData struct {
+	Job         string
+	File        string
+	GeneratedAt time.Time
+	Count       int
+	Records     []any
+	Top         []any
+	Columns     []reportColumn
+}
+
+// reportColumn resume uma coluna: preenchimento e, quando numérica,
+// mínimo, máximo, soma e média.
+type reportColumn struct {
+	Name    string
+	Type    string
+	Filled  int
+	Numeric bool
+	Min     float64
+	Max     float64
+	Sum     float64
+	Avg     float64
+}
+
+func newReportSink(raw json.RawMessage, baseDir string) (sink, error) {
+	s := &reportSink{Top: 10}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	if s.Template == "" {
+		return nil, fmt.Errorf("report exige template")
+	}
+
+	path := s.Template
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler template: %w", err)
+	}
+
+	// relatorio.html.tmpl -> .html; o HTML usa html/template para escapar
+	// os valores vindos da API.
+	s.ext = filepath.Ext(strings.TrimSuffix(filepath.Base(path), ".tmpl"))
+	if s.ext == "" || s.ext == ".tmpl" {
+		s.ext = ".md"
+	}
+	s.html = s.ext == ".html" || s.ext == ".htm"
+
+	if s.html {
+		s.markup, err = htmltemplate.New(filepath.Base(path)).Funcs(htmltemplate.FuncMap(reportFuncs())).Parse(string(text))
+	} else {
+		s.text, err = template.New(filepath.Base(path)).Funcs(reportFuncs()).Parse(string(text))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("template inválido: %w", err)
+	}
+
+	if s.SortBy != "" {
+		if s.sortBy, err = utils.ParseJSONPath(s.SortBy); err != nil {
+			return nil, fmt.Errorf("sort_by: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func reportFuncs() template.FuncMap {
+	return template.FuncMap{
+		"get": func(record any, path string) (any, error) {
+			p, err := utils.ParseJSONPath(path)
+			if err != nil {
+				return nil, err
+			}
+			value, _ := p.First(record)
+			return value, nil
+		},
+		"json": func(v any) string {
+			b, _ := json.Marshal(v)
+			return string(b)
+		},
+		// cell deixa o valor seguro numa célula de tabela Markdown.
+		"cell": func(v any) string {
+			var s string
+			switch v := v.(type) {
+			case nil:
+			case string:
+				s = v
+			default:
+				b, _ := json.Marshal(v)
+				s = string(b)
+			}
+			s = strings.ReplaceAll(s, "|", "\\|")
+			return strings.ReplaceAll(s, "\n", " ")
+		},
+		"env": os.Getenv,
+	}
+}
+
+func (s *reportSink) Deliver(ctx context.Context, out sinkOutput) error {
+	var doc any
+	if err := json.Unmarshal(out.Data, &doc); err != nil {
+		return fmt.Errorf("resposta não é JSON: %w", err)
+	}
+	records := utils.SelectRecords(doc, s.Records)
+
+	data := reportData{
+		Job:         out.Job.Name,
+		File:        filepath.Base(out.Path),
+		GeneratedAt: time.Now(),
+		Count:       len(records),
+		Records:     records,
+		Top:         s.top(records),
+	}
+	if len(records) > 0 {
+		if _, columns, err := tabularRecords(out.Data, s.Records, nil); err == nil {
+			data.Columns = summarizeColumns(records, columns)
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if s.html {
+		err = s.markup.Execute(&buf, data)
+	} else {
+		err = s.text.Execute(&buf, data)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao renderizar relatório: %w", err)
+	}
+
+	writeFile(sinkPath(s.Path, out.Path, s.ext), buf.Bytes())
+	return nil
+}
+
+// top devolve os primeiros Top registros, em ordem decrescente de sort_by
+// quando configurado.
+func (s *reportSink) top(records []any) []any {
+	top := append([]any(nil), records...)
+	if s.sortBy != nil {
+		sort.SliceStable(top, func(i, j int) bool {
+			a, _ := s.sortBy.First(top[i])
+			b, _ := s.sortBy.First(top[j])
+			return reportLess(b, a)
+		})
+	}
+	if s.Top > 0 && len(top) > s.Top {
+		top = top[:s.Top]
+	}
+	return top
+}
+
+// reportLess ordena números numericamente, depois textos; nulos ficam por
+// último na ordem decrescente.
+func reportLess(a, b any) bool {
+	fa, aNum := a.(float64)
+	fb, bNum := b.(float64)
+	switch {
+	case aNum && bNum:
+		return fa < fb
+	case a == nil:
+		return b != nil
+	case b == nil:
+		return false
+	case aNum != bNum:
+		return bNum
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func summarizeColumns(records []any, columns []utils.Column) []reportColumn {
+	summary := make([]reportColumn, len(columns))
+	for i, col := range columns {
+		rc := reportColumn{Name: col.Name, Type: col.Type, Numeric: col.Type == "int64" || col.Type == "double"}
+		numbers := 0
+		for _, record := range records {
+			obj, _ := record.(map[string]any)
+			value, ok := obj[col.Name]
+			if !ok || value == nil {
+				continue
+			}
+			rc.Filled++
+
+			n, isNum := value.(float64)
+			if !rc.Numeric || !isNum {
+				continue
+			}
+			if numbers == 0 || n < rc.Min {
+				rc.Min = n
+			}
+			if numbers == 0 || n > rc.Max {
+				rc.Max = n
+			}
+			rc.Sum += n
+			numbers++
+		}
+		if numbers > 0 {
+			rc.Avg = rc.Sum / float64(numbers)
+		}
+		summary[i] = rc
+	}
+	return summary
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	commands["rerun"] = rerunCommand
+}
+
+const rerunUsage = "uso: api-requester rerun -config jobs.json <run-id> [job]"
+
+// rerunCommand repete, para o suporte do provedor, as requisições exatas
+// de uma execução anterior (o run_id do run-summary.json): mesma URL,
+// método e headers, com autenticação, segredos e body vindos da
+// configuração atual. O body só é enviado se o sha256 bate com o da
+// execução. As respostas vão para rerun-<run-id>/.
+func rerunCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf(rerunUsage)
+	}
+	if *jobConfigPath == "" {
+		return fmt.Errorf("informe -config")
+	}
+	runID := args[0]
+	run, found, err := loadRunRequests(runID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("execução %s não encontrada em %s (só as últimas -keep-runs ficam guardadas)", runID, *stateDir)
+	}
+	cfg, err := loadJobConfig(*jobConfigPath)
+	if err != nil {
+		return err
+	}
+	jobs := map[string]JobConfig{}
+	for _, job := range cfg.Jobs {
+		jobs[job.Name] = job
+	}
+
+	names := run.jobNames()
+	if len(args) == 2 {
+		if _, ok := run.Jobs[args[1]]; !ok {
+			return fmt.Errorf("job %q não fez requisições na execução %s", args[1], runID)
+		}
+		names = []string{args[1]}
+	}
+
+	outDir := "rerun-" + runID
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	defaults := flagSettings().merge(cfg.Defaults)
+	failed, total := 0, 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tN\tMÉTODO\tURL\tSTATUS\tORIGINAL")
+	for _, name := range names {
+		job, ok := jobs[name]
+		if !ok {
+			log.Printf("[%s] Job não existe mais em %s; requisições ignoradas", name, *jobConfigPath)
+			failed++
+			continue
+		}
+		reqs := run.Jobs[name]
+		if reqs.Dropped > 0 {
+			log.Printf("[%s] %d requisições da execução não foram guardadas (limite de %d)", name, reqs.Dropped, maxRunRequests)
+		}
+		rl := job.rateClient(defaults.merge(job.JobSettings))
+		for i, rec := range reqs.Requests {
+			total++
+			status := "-"
+			code, body, err := replayRequest(ctx, job, rl, rec)
+			if err != nil {
+				failed++
+				status = "FALHA: " + err.Error()
+			} else {
+				status = strconv.Itoa(code)
+				writeFile(filepath.Join(outDir, fmt.Sprintf("%s-%d.response", name, i+1)), body)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", name, i+1, rec.Method, rec.URL, status, rec.Time.Format("2006-01-02 15:04:05"))
+		}
+	}
+	w.Flush()
+
+	log.Printf("Respostas da repetição de %s em %s/", runID, outDir)
+	if failed > 0 {
+		return fmt.Errorf("%d de %d requisições não puderam ser repetidas", failed, total)
+	}
+	return nil
+}
+
+// replayRequest refaz a requisição rec. Os valores removidos (segredos em
+// headers e query) voltam da configuração atual do job.
+func replayRequest(ctx context.Context, job JobConfig, rl *utils.RateLimitClient, rec utils.RequestRecord) (int, []byte, error) {
+	current, err := job.render(job.requestURL(), templateVars{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	target, err := restoreQuery(rec.URL, current.URL)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var body io.Reader
+	if rec.BodySHA256 != "" {
+		sum := sha256.Sum256(current.Body)
+		if hex.EncodeToString(sum[:]) != rec.BodySHA256 {
+			return 0, nil, fmt.Errorf("o body atual do job difere do enviado (sha256 %s)", rec.BodySHA256[:12])
+		}
+		body = bytes.NewReader(current.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, rec.Method, target, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	for name, value := range rec.Headers {
+		if value == utils.Redacted {
+			if value = headerValue(current.Headers, name); value == "" {
+				continue
+			}
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := rl.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+// restoreQuery devolve recorded com os parâmetros removidos preenchidos
+// pelos da URL atual do job.
+func restoreQuery(recorded, current string) (string, error) {
+	if !strings.Contains(recorded, url.QueryEscape(utils.Redacted)) {
+		return recorded, nil
+	}
+	u, err := url.Parse(recorded)
+	if err != nil {
+		return "", err
+	}
+	cur, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	q, curQuery := u.Query(), cur.Query()
+	for name, values := range q {
+		if len(values) == 1 && values[0] == utils.Redacted {
+			if !curQuery.Has(name) {
+				return "", fmt.Errorf("parâmetro %s não está mais na URL do job", name)
+			}
+			q[name] = curQuery[name]
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// statusList é a lista de status de -retry-status, ex: "408,425".
+type statusList []int
+
+var retryStatus = statusList(utils.DefaultRetryStatus)
+
+func init() {
+	flag.Var(&retryStatus, "retry-status", "status 4xx repetidos com backoff, sem reduzir a taxa (vazio = nenhum)")
+}
+
+func (l *statusList) Set(s string) error {
+	out := statusList{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		status, err := strconv.Atoi(part)
+		if err != nil {
+			return fmt.Errorf("status inválido: %q", part)
+		}
+		out = append(out, status)
+	}
+	if err := validateRetryStatus(out); err != nil {
+		return err
+	}
+	*l = out
+	return nil
+}
+
+func (l *statusList) String() string {
+	if l == nil {
+		return ""
+	}
+	parts := make([]string, len(*l))
+	for i, status := range *l {
+		parts[i] = strconv.Itoa(status)
+	}
+	return strings.Join(parts, ",")
+}
+
+// validateRetryStatus aceita só 4xx: 429 já é o throttling do rate limiter,
+// e os 5xx, os ThrottleStatus do dialeto.
+func validateRetryStatus(statuses []int) error {
+	for _, status := range statuses {
+		if status < 400 || status > 499 || status == http.StatusTooManyRequests {
+			return fmt.Errorf("retry_status aceita status 4xx, exceto 429: %d", status)
+		}
+	}
+	return nil
+}
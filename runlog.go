@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var keepRuns = flag.Int("keep-runs", 30, "execuções de -config cujas requisições ficam em -state-dir para o comando rerun (0 = não guarda); no serve, as terminadas que ficam em GET /runs")
+
+// maxRunRequests limita as requisições guardadas por job em cada execução
+// (um bulk grande não vira um estado gigante).
+const maxRunRequests = 1000
+
+// runRequests são as requisições de uma execução de -config, guardadas em
+// -state-dir/run-<id>.json para o rerun.
+type runRequests struct {
+	RunID     string                 `json:"run_id"`
+	StartedAt time.Time              `json:"started_at"`
+	Config    string                 `json:"config"`
+	Jobs      map[string]jobRequests `json:"jobs"`
+}
+
+type jobRequests struct {
+	Requests []utils.RequestRecord `json:"requests"`
+	// Dropped conta as requisições além de maxRunRequests, não guardadas.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// runIndex são as execuções guardadas, da mais antiga para a mais nova.
+type runIndex struct {
+	Runs []string `json:"runs"`
+}
+
+func newRequestLog() *utils.RequestLog {
+	if *keepRuns <= 0 {
+		return nil
+	}
+	return &utils.RequestLog{Max: maxRunRequests}
+}
+
+// saveRunRequests grava as requisições da execução e apaga as execuções
+// além das últimas -keep-runs.
+// runIDHeader leva a execução nas entregas (upload, e-mail), para ligar
+// cada destino à coleta que o gerou.
+const runIDHeader = "X-Run-Id"
+
+func saveRunRequests(runID string, started time.Time, results []jobResult) {
+	if *keepRuns <= 0 {
+		return
+	}
+	run := runRequests{RunID: runID, StartedAt: started, Config: *jobConfigPath, Jobs: map[string]jobRequests{}}
+	for _, res := range results {
+		if len(res.requests.Requests) > 0 {
+			run.Jobs[res.Job] = res.requests
+		}
+	}
+	if len(run.Jobs) == 0 {
+		return
+	}
+	if err := stateStore.Save("run-"+runID, run); err != nil {
+		log.Printf("Erro ao guardar as requisições da execução %s: %v", runID, err)
+		return
+	}
+
+	defer lockState("runs")()
+	var idx runIndex
+	if _, err := stateStore.Load("runs", &idx); err != nil {
+		log.Printf("%v", err)
+	}
+	idx.Runs = append(idx.Runs, runID)
+	for len(idx.Runs) > *keepRuns {
+		if err := stateStore.Remove("run-" + idx.Runs[0]); err != nil {
+			log.Printf("Erro ao apagar a execução %s: %v", idx.Runs[0], err)
+		}
+		idx.Runs = idx.Runs[1:]
+	}
+	if err := stateStore.Save("runs", idx); err != nil {
+		log.Printf("Erro ao salvar o índice de execuções: %v", err)
+	}
+}
+
+func loadRunRequests(runID string) (runRequests, bool, error) {
+	var run runRequests
+	found, err := stateStore.Load("run-"+runID, &run)
+	return run, found, err
+}
+
+func (r runRequests) jobNames() []string {
+	names := make([]string, 0, len(r.Jobs))
+	for name := range r.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
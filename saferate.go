@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	warnSafeRate     = flag.Int("warn-safe-rate", 1, "aviso quando a exploração de taxa trava a taxa segura neste valor (req/s) ou abaixo por -warn-safe-rate-runs execuções seguidas (0 = desativado)")
+	warnSafeRateRuns = flag.Int("warn-safe-rate-runs", 3, "execuções seguidas com a taxa segura baixa antes do aviso de -warn-safe-rate")
+)
+
+const warningSafeRateDowngrade = "safe_rate_downgrade"
+
+// safeRateState conta as execuções seguidas do job em que a exploração
+// travou a taxa segura em ou abaixo de -warn-safe-rate.
+type safeRateState struct {
+	Runs  int       `json:"runs"`
+	Rate  int       `json:"rate"`
+	Since time.Time `json:"since"`
+}
+
+// checkSafeRate avisa quando a taxa segura segue baixa há
+// -warn-safe-rate-runs execuções: em geral o provedor mudou os limites e
+// a janela noturna não vai dar conta do volume.
+func checkSafeRate(job JobConfig, rl *utils.RateLimitClient, now time.Time) {
+	if *warnSafeRate <= 0 || rl.RetryStats().Requests == 0 {
+		return
+	}
+	stateName := "saferate-" + job.Name
+	var st safeRateState
+	if _, err := stateStore.Load(stateName, &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+
+	rate := rl.LearnedRate()
+	if rate > 0 && rate <= *warnSafeRate {
+		if st.Runs == 0 {
+			st.Since = now
+		}
+		st.Runs++
+		st.Rate = rate
+	} else {
+		if st.Runs == 0 {
+			return
+		}
+		st = safeRateState{}
+	}
+	if err := stateStore.Save(stateName, st); err != nil {
+		log.Printf("[%s] Erro ao salvar estado da taxa segura: %v", job.Name, err)
+	}
+
+	if st.Runs >= *warnSafeRateRuns {
+		msg := fmt.Sprintf("taxa segura travada em %d req/s há %d execuções seguidas (desde %s): o provedor pode ter mudado os limites e a janela de execução pode estourar",
+			st.Rate, st.Runs, st.Since.Format(time.RFC3339))
+		log.Printf("[%s] AVISO: %s", job.Name, msg)
+		rl.Warnings.Add(warningSafeRateDowngrade, "", msg)
+	}
+}
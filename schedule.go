@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	scheduleFormat = flag.String("schedule-format", "crontab", "schedule: formato da agenda, crontab ou ical")
+	scheduleDays   = flag.Int("schedule-days", 7, "schedule: dias de execuções listados no ical")
+)
+
+func init() {
+	commands["schedule"] = scheduleCommand
+}
+
+const scheduleUsage = "uso: api-requester schedule -config jobs.json [-schedule-format crontab|ical] [-schedule-days 7] [arquivo]"
+
+// ScheduleConfig é um agendamento de -config: a expressão cron (5 campos,
+// no fuso Timezone) em que o cron chama api-requester com -tags Tags (sem
+// tags, todos os jobs). Serve para o comando schedule gerar o crontab e a
+// agenda das execuções.
+type ScheduleConfig struct {
+	Name     string   `json:"name,omitempty"`
+	Cron     string   `json:"cron"`
+	Tags     []string `json:"tags,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+
+	spec *cronSpec
+	loc  *time.Location
+}
+
+func (c *ScheduleConfig) validate() error {
+	var err error
+	if c.spec, err = parseCron(c.Cron); err != nil {
+		return err
+	}
+	c.loc = time.Local
+	if c.Timezone != "" {
+		if c.loc, err = time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("timezone: %w", err)
+		}
+	}
+	if c.Name == "" {
+		c.Name = strings.Join(c.Tags, ",")
+	}
+	if c.Name == "" {
+		c.Name = "todos"
+	}
+	return nil
+}
+
+func validateSchedules(cfg *MultiJobConfig) error {
+	for i := range cfg.Schedules {
+		if err := cfg.Schedules[i].validate(); err != nil {
+			return fmt.Errorf("schedule #%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// jobs são os jobs que a execução do agendamento seleciona, como -tags.
+func (c *ScheduleConfig) jobs(cfg *MultiJobConfig) []JobConfig {
+	var out []JobConfig
+	for _, job := range cfg.Jobs {
+		if len(c.Tags) == 0 || slices.ContainsFunc(c.Tags, job.hasTag) {
+			out = append(out, job)
+		}
+	}
+	return out
+}
+
+// cronSpec é uma expressão cron de 5 campos: minuto, hora, dia do mês, mês
+// e dia da semana (0 ou 7 = domingo). Como no cron, com dia do mês e dia
+// da semana restritos, vale qualquer um dos dois.
+type cronSpec struct {
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool
+}
+
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: use 5 campos (minuto hora dia mês dia-da-semana)", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]map[int]bool
+	for i, f := range fields {
+		set, err := parseCronField(f, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: campo %d: %w", expr, i+1, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSpec{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField aceita *, valores, intervalos (a-b), passos (*/n, a-b/n)
+// e listas separadas por vírgula.
+func parseCronField(f string, lo, hi int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(f, ",") {
+		rng, step := part, 1
+		if r, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("passo inválido em %q", part)
+			}
+			rng, step = r, n
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("valor inválido em %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("valor inválido em %q", part)
+				}
+			} else if step > 1 {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return nil, fmt.Errorf("%q fora de %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func (s *cronSpec) matches(t time.Time) bool {
+	if !s.minute[t.Minute()] || !s.hour[t.Hour()] || !s.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// occurrences são os horários do agendamento de from até to.
+func (c *ScheduleConfig) occurrences(from, to time.Time) []time.Time {
+	var out []time.Time
+	for t := from.In(c.loc).Truncate(time.Minute); t.Before(to); t = t.Add(time.Minute) {
+		if t.Before(from) {
+			continue
+		}
+		if c.spec.matches(t.In(c.loc)) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// scheduleCommand mostra quando cada job de -config vai rodar: as linhas
+// do crontab dos agendamentos ou, em ical, as execuções dos próximos
+// -schedule-days dias, com as janelas de manutenção que as afetam.
+func scheduleCommand(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf(scheduleUsage)
+	}
+	if *jobConfigPath == "" {
+		return fmt.Errorf("informe -config")
+	}
+	cfg, err := loadJobConfig(*jobConfigPath)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Schedules) == 0 {
+		return fmt.Errorf("nenhum schedule em %s", *jobConfigPath)
+	}
+
+	var out string
+	switch *scheduleFormat {
+	case "crontab":
+		out, err = scheduleCrontab(cfg)
+	case "ical":
+		now := time.Now()
+		out = scheduleICal(cfg, now, now.AddDate(0, 0, *scheduleDays))
+	default:
+		return fmt.Errorf("-schedule-format deve ser crontab ou ical")
+	}
+	if err != nil {
+		return err
+	}
+
+	scheduled := map[string]bool{}
+	for _, s := range cfg.Schedules {
+		for _, job := range s.jobs(cfg) {
+			scheduled[job.Name] = true
+		}
+	}
+	for _, job := range cfg.Jobs {
+		if !scheduled[job.Name] {
+			log.Printf("AVISO: job %s não entra em nenhum schedule", job.Name)
+		}
+	}
+
+	if len(args) == 0 {
+		_, err = os.Stdout.WriteString(out)
+		return err
+	}
+	writeFile(args[0], []byte(out))
+	log.Printf("Agenda gravada em %s", args[0])
+	return nil
+}
+
+func scheduleCrontab(cfg *MultiJobConfig) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	config, err := filepath.Abs(*jobConfigPath)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# gerado por api-requester schedule a partir de %s\n", config)
+	// CRON_TZ (cronie, systemd-cron) vale para as linhas seguintes: os
+	// agendamentos no fuso da máquina vêm antes
+	schedules := slices.Clone(cfg.Schedules)
+	slices.SortStableFunc(schedules, func(a, b ScheduleConfig) int { return strings.Compare(a.Timezone, b.Timezone) })
+	tz := ""
+	for _, s := range schedules {
+		b.WriteString("\n")
+		if s.Timezone != tz {
+			fmt.Fprintf(&b, "CRON_TZ=%s\n", s.Timezone)
+			tz = s.Timezone
+		}
+		fmt.Fprintf(&b, "# %s: %s\n", s.Name, jobNames(s.jobs(cfg)))
+		line := fmt.Sprintf("%s %s -config %s", s.Cron, shellQuote(exe), shellQuote(config))
+		if len(s.Tags) > 0 {
+			line += " -tags " + shellQuote(strings.Join(s.Tags, ","))
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String(), nil
+}
+
+// scheduleICal lista as execuções de cada agendamento entre from e to, com
+// os jobs que elas rodam e os que cairiam numa janela de manutenção.
+func scheduleICal(cfg *MultiJobConfig, from, to time.Time) string {
+	const stamp = "20060102T150405Z"
+	var b strings.Builder
+	line := func(format string, a ...any) {
+		fmt.Fprintf(&b, format+"\r\n", a...)
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//api-requester//schedule//PT")
+	line("CALSCALE:GREGORIAN")
+	for _, s := range cfg.Schedules {
+		jobs := s.jobs(cfg)
+		for _, t := range s.occurrences(from, to) {
+			desc := []string{"jobs: " + jobNames(jobs)}
+			for _, job := range jobs {
+				if m, end := job.maintenanceAt(t); m != nil {
+					desc = append(desc, job.Name+": "+m.describe(end))
+				}
+			}
+			line("BEGIN:VEVENT")
+			line("UID:%s-%s@api-requester", strings.ReplaceAll(icalText(s.Name), " ", "-"), t.UTC().Format(stamp))
+			line("DTSTAMP:%s", from.UTC().Format(stamp))
+			line("DTSTART:%s", t.UTC().Format(stamp))
+			line("SUMMARY:%s", icalText("api-requester: "+s.Name))
+			line("DESCRIPTION:%s", icalText(strings.Join(desc, "\n")))
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+func jobNames(jobs []JobConfig) string {
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.Name
+	}
+	if len(names) == 0 {
+		return "(nenhum job)"
+	}
+	return strings.Join(names, ", ")
+}
+
+// icalText escapa um valor TEXT do iCalendar (RFC 5545, 3.3.11).
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`*?[]#~=%;&|<>(){}") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	listenAddr = flag.String("listen", "127.0.0.1:8080", "serve: endereço do servidor HTTP de gatilhos")
+	runsDir    = flag.String("runs-dir", "runs", "serve: diretório onde as saídas de cada execução são gravadas")
+	serveToken = flag.String("serve-token-env", "SERVE_TOKEN", "serve: variável com o token exigido em Authorization: Bearer (obrigatório fora do loopback; sem ela, um token é gerado em -runs-dir/serve.token)")
+)
+
+func init() {
+	commands["serve"] = serveCommand
+}
+
+type runStatus struct {
+	ID         string            `json:"id"`
+	Job        string            `json:"job"`
+	Params     map[string]string `json:"params,omitempty"`
+	IDs        int               `json:"ids,omitempty"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Output     string            `json:"output,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+type triggerServer struct {
+	ctx  context.Context
+	jobs map[string]JobConfig
+
+	mu       sync.Mutex
+	runs     map[string]*runStatus
+	limiters *limiterPool
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// serveCommand expõe POST /run para disparar jobs sob demanda e
+// GET /runs, GET /runs/{id} para acompanhar as execuções (em memória, as
+// últimas -keep-runs terminadas).
+func serveCommand(ctx context.Context, args []string) error {
+	token, err := listenToken(*serveToken, *listenAddr, false)
+	if err != nil {
+		return err
+	}
+	jobs, err := loadTriggerJobs()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*runsDir, 0o755); err != nil {
+		return err
+	}
+	if token == "" {
+		path := filepath.Join(*runsDir, "serve.token")
+		if token, err = generateToken(path); err != nil {
+			return err
+		}
+		log.Printf("Sem %s: token gerado em %s", *serveToken, path)
+	}
+
+	s := &triggerServer{
+		ctx:      ctx,
+		jobs:     jobs,
+		runs:     map[string]*runStatus{},
+		limiters: newLimiterPool(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /run", s.handleRun)
+	mux.HandleFunc("GET /runs", s.handleList)
+	mux.HandleFunc("GET /runs/{id}", s.handleStatus)
+	mux.HandleFunc("GET /jobs", s.handleJobs)
+	mux.HandleFunc("GET /limiter", s.handleLimiter)
+	mux.HandleFunc("PUT /limiter", s.handleSetLimiter)
+	mux.HandleFunc("DELETE /limiter", s.handleClearLimiter)
+
+	srv := &http.Server{Addr: *listenAddr, Handler: requireLoopbackHost(*listenAddr, requireBearer(token, "Authorization", mux)), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Servidor de gatilhos ouvindo em %s", *listenAddr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *triggerServer) handleRun(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var trig TriggerFile
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&trig); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "body inválido: " + err.Error()})
+		return
+	}
+
+	if trig.Job == "" && len(s.jobs) == 1 {
+		for name := range s.jobs {
+			trig.Job = name
+		}
+	}
+	job, ok := s.jobs[trig.Job]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("job %q não existe", trig.Job)})
+		return
+	}
+
+	run := &runStatus{
+		ID:        newRunID(),
+		Job:       trig.Job,
+		Params:    trig.Params,
+		IDs:       len(trig.IDs),
+		Status:    "queued",
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.runs[run.ID] = run
+	s.pruneRuns()
+	s.mu.Unlock()
+
+	go s.execute(run, job, trig)
+
+	w.Header().Set("Location", "/runs/"+run.ID)
+	writeJSON(w, http.StatusAccepted, s.snapshot(run.ID))
+}
+
+func (s *triggerServer) execute(run *runStatus, job JobConfig, trig TriggerFile) {
+	base := filepath.Join(*runsDir, run.ID)
+
+	s.mu.Lock()
+	now := time.Now()
+	run.Status = "running"
+	run.StartedAt = &now
+	s.mu.Unlock()
+
+	err := executeTrigger(s.ctx, s.jobs, trig, base, s.limiters.get(job))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	done := time.Now()
+	run.FinishedAt = &done
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		log.Printf("Execução %s (%s) falhou: %v", run.ID, run.Job, err)
+		return
+	}
+
+	run.Status = "succeeded"
+	run.Output = base + ".response.json"
+	if len(trig.IDs) > 0 {
+		run.Output = base + ".results"
+	}
+	log.Printf("Execução %s (%s) concluída", run.ID, run.Job)
+}
+
+// pruneRuns descarta as execuções terminadas mais antigas além das últimas
+// -keep-runs; as em andamento ficam sempre. Chamada com s.mu travado.
+func (s *triggerServer) pruneRuns() {
+	var finished []*runStatus
+	for _, run := range s.runs {
+		if run.FinishedAt != nil {
+			finished = append(finished, run)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].CreatedAt.Before(finished[j].CreatedAt) })
+	for excess := len(finished) - max(*keepRuns, 0); excess > 0; excess-- {
+		delete(s.runs, finished[0].ID)
+		finished = finished[1:]
+	}
+}
+
+func (s *triggerServer) snapshot(id string) *runStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.runs[id]
+	if !ok {
+		return nil
+	}
+	cp := *run
+	return &cp
+}
+
+func (s *triggerServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	run := s.snapshot(r.PathValue("id"))
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "execução não encontrada"})
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (s *triggerServer) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := make([]runStatus, 0, len(s.runs))
+	for _, run := range s.runs {
+		list = append(list, *run)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *triggerServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
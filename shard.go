@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shardSpec é o -shard "i/n": a instância i (de 1 a n) fica com os itens do
+// bulk cujo hash, módulo n, é i-1.
+type shardSpec struct {
+	Index int
+	Total int
+}
+
+var (
+	shard     shardSpec
+	shardHash = flag.String("shard-hash", "fnv", "hash que distribui os itens entre os shards: fnv, crc32 ou sha256 (igual em todas as instâncias)")
+	shardTTL  = flag.Duration("shard-ttl", 6*time.Hour, "tempo em que o shard de outra instância ainda em andamento conta na detecção de sobreposição")
+)
+
+func init() {
+	flag.Var(&shard, "shard", "divide a entrada do bulk entre instâncias: i/n fica com a parte i de n, ex: 2/5 (vazio = tudo)")
+}
+
+// shardHashes são os hashes de -shard-hash; todos dão a mesma partição em
+// qualquer máquina, para a mesma entrada.
+var shardHashes = map[string]func(string) uint64{
+	"fnv": func(s string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		return h.Sum64()
+	},
+	"crc32": func(s string) uint64 {
+		return uint64(crc32.ChecksumIEEE([]byte(s)))
+	},
+	"sha256": func(s string) uint64 {
+		sum := sha256.Sum256([]byte(s))
+		return binary.BigEndian.Uint64(sum[:8])
+	},
+}
+
+func (s *shardSpec) Set(v string) error {
+	if v = strings.TrimSpace(v); v == "" {
+		*s = shardSpec{}
+		return nil
+	}
+	i, n, ok := strings.Cut(v, "/")
+	index, err1 := strconv.Atoi(strings.TrimSpace(i))
+	total, err2 := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || err1 != nil || err2 != nil {
+		return fmt.Errorf("shard inválido %q: use i/n, ex: 2/5", v)
+	}
+	if total < 1 || index < 1 || index > total {
+		return fmt.Errorf("shard inválido %q: i vai de 1 a n", v)
+	}
+	*s = shardSpec{Index: index, Total: total}
+	return nil
+}
+
+func (s *shardSpec) String() string {
+	if s == nil || !s.active() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Total)
+}
+
+func (s shardSpec) active() bool {
+	return s.Total > 1
+}
+
+func validateShard() error {
+	if _, ok := shardHashes[*shardHash]; !ok {
+		return fmt.Errorf("-shard-hash desconhecido: %q (use fnv, crc32 ou sha256)", *shardHash)
+	}
+	return nil
+}
+
+// split devolve os itens deste shard, na ordem da entrada.
+func (s shardSpec) split(ids []string) []string {
+	if !s.active() {
+		return ids
+	}
+	hash := shardHashes[*shardHash]
+	var out []string
+	for _, id := range ids {
+		if hash(id)%uint64(s.Total) == uint64(s.Index-1) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// lockName é o nome do job em LockJob e no done_window: com -shard, cada
+// shard do mesmo job roda e conclui por conta própria.
+func (s shardSpec) lockName(job JobConfig) string {
+	if !s.active() || job.BulkInput == "" {
+		return job.Name
+	}
+	return fmt.Sprintf("%s.shard%d-%d", job.Name, s.Index, s.Total)
+}
+
+// shardState guarda, por entrada do bulk (o job, ou o provider fora do
+// modo jobs), o shard que cada instância pegou, para detectar instâncias
+// com partições que se sobrepõem: o mesmo shard em duas máquinas, ou
+// -shard, -shard-hash ou a entrada diferentes entre elas.
+type shardState struct {
+	Inputs map[string]map[string]shardClaim `json:"inputs"`
+}
+
+type shardClaim struct {
+	Total      int       `json:"total"`
+	Hash       string    `json:"hash"`
+	Input      string    `json:"input"`
+	Host       string    `json:"host"`
+	Items      int       `json:"items"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+func (c shardClaim) live(now time.Time) bool {
+	return c.FinishedAt.IsZero() && now.Sub(c.StartedAt) < *shardTTL
+}
+
+// inputDigest identifica a entrada inteira: instâncias com listas
+// diferentes não dividem o trabalho, mesmo com o mesmo -shard.
+func inputDigest(ids []string) string {
+	h := sha256.New()
+	for _, id := range ids {
+		h.Write([]byte(id))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func shardHost() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "desconhecido"
+	}
+	return host
+}
+
+// claimShard filtra ids para o shard desta instância e registra o shard no
+// estado compartilhado. Um shard em andamento (dentro de -shard-ttl) em
+// outra máquina com a mesma parte, ou com outra partição da entrada, é
+// sobreposição e vira erro, para o trabalho não ser repetido. done, chamado
+// no fim, marca o shard como concluído.
+func claimShard(key string, ids []string) (mine []string, done func(), err error) {
+	noop := func() {}
+	if !shard.active() {
+		return ids, noop, nil
+	}
+	mine = shard.split(ids)
+	now := time.Now()
+	claim := shardClaim{
+		Total:     shard.Total,
+		Hash:      *shardHash,
+		Input:     inputDigest(ids),
+		Host:      shardHost(),
+		Items:     len(mine),
+		StartedAt: now,
+	}
+	index := strconv.Itoa(shard.Index)
+
+	unlock := lockState("shards")
+	defer unlock()
+
+	st, err := loadShardState()
+	if err != nil {
+		return nil, noop, err
+	}
+	claims := st.Inputs[key]
+	if claims == nil {
+		claims = map[string]shardClaim{}
+	}
+	var others []string
+	for i, c := range claims {
+		if !c.live(now) || (i == index && c.Host == claim.Host) {
+			continue
+		}
+		switch {
+		case c.Total != claim.Total || c.Hash != claim.Hash || c.Input != claim.Input:
+			return nil, noop, fmt.Errorf("%s: shard %s/%d em %s (desde %s) com outra partição (-shard %s/%d, -shard-hash %s, entrada %s): as partes se sobrepõem",
+				key, i, c.Total, c.Host, c.StartedAt.Format(time.RFC3339), index, claim.Total, claim.Hash, claim.Input)
+		case i == index:
+			return nil, noop, fmt.Errorf("%s: shard %s/%d já em andamento em %s desde %s", key, i, c.Total, c.Host, c.StartedAt.Format(time.RFC3339))
+		}
+		others = append(others, i)
+	}
+	claims[index] = claim
+	st.Inputs[key] = claims
+	if err := stateStore.Save("shards", st); err != nil {
+		return nil, noop, fmt.Errorf("erro ao registrar o shard: %w", err)
+	}
+
+	sort.Strings(others)
+	active := "nenhum"
+	if len(others) > 0 {
+		active = strings.Join(others, ",")
+	}
+	log.Printf("%s: shard %s, %d de %d itens (outros shards em andamento: %s)", key, shard.String(), len(mine), len(ids), active)
+
+	return mine, func() { finishShard(key, index, claim) }, nil
+}
+
+func finishShard(key, index string, claim shardClaim) {
+	unlock := lockState("shards")
+	defer unlock()
+
+	st, err := loadShardState()
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return
+	}
+	if c, ok := st.Inputs[key][index]; !ok || c.Host != claim.Host || !c.StartedAt.Equal(claim.StartedAt) {
+		return
+	}
+	claim.FinishedAt = time.Now()
+	st.Inputs[key][index] = claim
+	if err := stateStore.Save("shards", st); err != nil {
+		log.Printf("%s: erro ao salvar o shard concluído: %v", key, err)
+	}
+}
+
+func loadShardState() (shardState, error) {
+	var st shardState
+	if _, err := stateStore.Load("shards", &st); err != nil {
+		return st, err
+	}
+	if st.Inputs == nil {
+		st.Inputs = map[string]map[string]shardClaim{}
+	}
+	return st, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// SignURLConfig descreve a assinatura por query string exigida por alguns
+// provedores. O segredo vem sempre de variável de ambiente.
+type SignURLConfig struct {
+	Key            string    `json:"key,omitempty"`
+	KeyEnv         string    `json:"key_env,omitempty"`
+	SecretEnv      string    `json:"secret_env"`
+	KeyParam       string    `json:"key_param,omitempty"`
+	ExpiresParam   string    `json:"expires_param,omitempty"`
+	SignatureParam string    `json:"signature_param,omitempty"`
+	TTL            *Duration `json:"ttl,omitempty"`
+	Algorithm      string    `json:"algorithm,omitempty"`
+	Encoding       string    `json:"encoding,omitempty"`
+}
+
+func (c *SignURLConfig) signer() (*utils.URLSigner, error) {
+	if c.SecretEnv == "" {
+		return nil, fmt.Errorf("secret_env é obrigatório")
+	}
+
+	s := &utils.URLSigner{
+		Key:            c.Key,
+		Secret:         []byte(os.Getenv(c.SecretEnv)),
+		KeyParam:       c.KeyParam,
+		ExpiresParam:   c.ExpiresParam,
+		SignatureParam: c.SignatureParam,
+		TTL:            5 * time.Minute,
+		Algorithm:      c.Algorithm,
+		Encoding:       c.Encoding,
+	}
+	if c.KeyEnv != "" {
+		s.Key = os.Getenv(c.KeyEnv)
+	}
+	if s.KeyParam == "" {
+		s.KeyParam = "key"
+	}
+	if s.ExpiresParam == "" {
+		s.ExpiresParam = "expires"
+	}
+	if s.SignatureParam == "" {
+		s.SignatureParam = "signature"
+	}
+	if c.TTL != nil {
+		s.TTL = c.TTL.Duration
+	}
+
+	if len(s.Secret) == 0 {
+		return nil, fmt.Errorf("variável %s não definida", c.SecretEnv)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
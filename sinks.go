@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// sink recebe a saída já publicada de um job e a entrega em outro formato
+// ou destino.
+type sink interface {
+	Deliver(ctx context.Context, out sinkOutput) error
+}
+
+// sinkOutput é o que cada sink recebe: o caminho do JSON publicado e o seu
+// conteúdo.
+type sinkOutput struct {
+	Job  JobConfig
+	Path string
+	Data []byte
+}
+
+// sinkTypes é preenchido pelo init() de cada arquivo de sink; baseDir é o
+// diretório do arquivo de configuração, para resolver caminhos relativos.
+var sinkTypes = map[string]func(raw json.RawMessage, baseDir string) (sink, error){}
+
+// SinkConfig guarda a entrada crua para que cada tipo decodifique seus
+// próprios campos.
+type SinkConfig struct {
+	Type string
+	raw  json.RawMessage
+}
+
+func (c *SinkConfig) UnmarshalJSON(b []byte) error {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return err
+	}
+	c.Type = head.Type
+	c.raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+func (c SinkConfig) MarshalJSON() ([]byte, error) {
+	if c.raw != nil {
+		return c.raw, nil
+	}
+	return json.Marshal(map[string]string{"type": c.Type})
+}
+
+func buildSinks(configs []SinkConfig, baseDir string) ([]sink, error) {
+	var sinks []sink
+	for i, cfg := range configs {
+		factory, ok := sinkTypes[cfg.Type]
+		if !ok {
+			return nil, fmt.Errorf("sink #%d: tipo %q não suportado (%s)", i+1, cfg.Type, strings.Join(sinkTypeNames(), ", "))
+		}
+		s, err := factory(cfg.raw, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("sink #%d (%s): %w", i+1, cfg.Type, err)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+func sinkTypeNames() []string {
+	names := make([]string, 0, len(sinkTypes))
+	for name := range sinkTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deliverSinks entrega a saída a cada sink. Com -spool-dir, um sink que
+// continua falhando depois das novas tentativas vai para o spool e o job
+// segue como sucesso; sem spool, a falha derruba o job.
+func deliverSinks(ctx context.Context, job JobConfig, path string, data []byte) error {
+	if len(job.sinks) > 0 && auditSkip("[%s] entrega em %d sinks", job.Name, len(job.sinks)) {
+		return nil
+	}
+	out := sinkOutput{Job: job, Path: path, Data: data}
+	spooled := 0
+	for i := range job.sinks {
+		if spoolPending(job, i) {
+			spoolDelivery(job, i, out, fmt.Errorf("entregas anteriores ainda pendentes"))
+			spooled++
+			continue
+		}
+		if err := deliverWithRetry(ctx, job, i, out); err != nil {
+			if *spoolDir == "" || ctx.Err() != nil {
+				return fmt.Errorf("sink %s: %w", job.Sinks[i].Type, err)
+			}
+			spoolDelivery(job, i, out, err)
+			spooled++
+		}
+	}
+	if len(job.sinks) > 0 {
+		log.Printf("[%s] %d sinks entregues, %d no spool", job.Name, len(job.sinks)-spooled, spooled)
+	}
+	return nil
+}
+
+// loadSchemaColumns lê as colunas de um JSON Schema em disco; sem caminho,
+// devolve nil para que sejam inferidas a cada entrega.
+func loadSchemaColumns(path, baseDir string) ([]utils.Column, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler schema: %w", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("schema inválido: %w", err)
+	}
+	return utils.ColumnsFromSchema(schema)
+}
+
+// tabularRecords seleciona os registros da resposta e, se columns for nil,
+// infere as colunas a partir deles.
+func tabularRecords(data []byte, recordsPath string, columns []utils.Column) ([]any, []utils.Column, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("resposta não é JSON: %w", err)
+	}
+	records := utils.SelectRecords(doc, recordsPath)
+
+	if columns == nil {
+		inferrer := utils.NewSchemaInferrer()
+		inferrer.Observe(records)
+		var err error
+		if columns, err = utils.ColumnsFromSchema(inferrer.Schema()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return records, columns, nil
+}
+
+// sinkPath usa o caminho configurado ou troca a extensão da saída JSON.
+func sinkPath(configured, outputPath, ext string) string {
+	if configured != "" {
+		return configured
+	}
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ext
+}
+
+// failureNotifier é implementado pelos sinks que também avisam quando o job
+// falha.
+type failureNotifier interface {
+	NotifyFailure(ctx context.Context, job JobConfig, errs []ErrorResponse) error
+}
+
+func notifyFailure(ctx context.Context, job JobConfig, errs []ErrorResponse) {
+	for i, s := range job.sinks {
+		n, ok := s.(failureNotifier)
+		if !ok {
+			continue
+		}
+		if err := n.NotifyFailure(ctx, job, errs); err != nil {
+			log.Printf("[%s] Erro ao notificar falha via %s: %v", job.Name, job.Sinks[i].Type, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"apiconsume/utils"
+)
+
+const (
+	defaultSLOWindow = 30 * 24 * time.Hour
+
+	warningSLOViolation = "slo_violation"
+)
+
+// SLOConfig declara o SLO de latência do endpoint do job: Target (ex: 0.99)
+// das respostas em até Latency, apurado numa janela móvel (padrão 30 dias)
+// que fica em -state-dir entre execuções.
+type SLOConfig struct {
+	Latency Duration  `json:"latency"`
+	Target  float64   `json:"target"`
+	Window  *Duration `json:"window,omitempty"`
+}
+
+func (c *SLOConfig) validate() error {
+	if c.Latency.Duration <= 0 {
+		return fmt.Errorf("latency deve ser positivo")
+	}
+	if c.Target <= 0 || c.Target > 1 {
+		return fmt.Errorf("target deve estar entre 0 e 1 (ex: 0.99)")
+	}
+	if c.Window != nil && c.Window.Duration < time.Hour {
+		return fmt.Errorf("window deve ser de pelo menos 1h")
+	}
+	return nil
+}
+
+func (c *SLOConfig) window() time.Duration {
+	if c.Window != nil {
+		return c.Window.Duration
+	}
+	return defaultSLOWindow
+}
+
+// sloBucket soma as respostas de uma hora; a janela é apurada por hora.
+type sloBucket struct {
+	Hour  time.Time `json:"hour"`
+	Total int       `json:"total"`
+	Good  int       `json:"good"`
+}
+
+type sloState struct {
+	Buckets []sloBucket `json:"buckets"`
+}
+
+// sloReport é a apuração do SLO no resumo da execução.
+type sloReport struct {
+	Latency    Duration `json:"latency"`
+	Target     float64  `json:"target"`
+	Window     Duration `json:"window"`
+	Responses  int      `json:"responses"`
+	Attainment float64  `json:"attainment"`
+	Violated   bool     `json:"violated"`
+
+	// da execução atual
+	RunResponses int `json:"run_responses"`
+	RunSlow      int `json:"run_slow"`
+}
+
+// trackSLO soma as amostras da execução à janela do job e apura o SLO.
+func trackSLO(job JobConfig, samples []time.Duration, now time.Time, warnings *utils.WarningLog) *sloReport {
+	c := job.SLO
+	stateName := "slo-" + job.Name
+	var st sloState
+	if _, err := stateStore.Load(stateName, &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+
+	good := 0
+	for _, d := range samples {
+		if d <= c.Latency.Duration {
+			good++
+		}
+	}
+	hour := now.UTC().Truncate(time.Hour)
+	if n := len(st.Buckets); n > 0 && st.Buckets[n-1].Hour.Equal(hour) {
+		st.Buckets[n-1].Total += len(samples)
+		st.Buckets[n-1].Good += good
+	} else if len(samples) > 0 {
+		st.Buckets = append(st.Buckets, sloBucket{Hour: hour, Total: len(samples), Good: good})
+	}
+
+	cutoff := hour.Add(-c.window())
+	kept := st.Buckets[:0]
+	report := &sloReport{Latency: c.Latency, Target: c.Target, Window: Duration{c.window()}, RunResponses: len(samples), RunSlow: len(samples) - good}
+	totalGood := 0
+	for _, b := range st.Buckets {
+		if !b.Hour.After(cutoff) {
+			continue
+		}
+		kept = append(kept, b)
+		report.Responses += b.Total
+		totalGood += b.Good
+	}
+	st.Buckets = kept
+	if report.Responses > 0 {
+		report.Attainment = float64(totalGood) / float64(report.Responses)
+		report.Violated = report.Attainment < c.Target
+	}
+
+	if err := stateStore.Save(stateName, st); err != nil {
+		log.Printf("[%s] Erro ao salvar estado do SLO: %v", job.Name, err)
+	}
+
+	if report.Violated {
+		msg := fmt.Sprintf("SLO de latência violado: %.2f%% das respostas em até %v na janela de %v (meta %.2f%%)",
+			report.Attainment*100, c.Latency.Duration, c.window(), c.Target*100)
+		log.Printf("[%s] AVISO: %s", job.Name, msg)
+		warnings.Add(warningSLOViolation, "", msg)
+	}
+	return report
+}
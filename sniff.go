@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"apiconsume/utils"
+)
+
+var sniffMax = ByteSize(1 << 30)
+
+func init() {
+	flag.Var(&sniffMax, "sniff-max", "tamanho máximo de uma resposta comprimida sem aviso (gzip, bzip2) depois de aberta, ex: 1GB (0 = sem limite)")
+}
+
+// sniffBody confere pelos primeiros bytes (ver utils.SniffContent) se a
+// resposta 2xx é mesmo o que vai ser validado como JSON. Um payload
+// comprimido que veio com Content-Type genérico, sem um Content-Encoding que
+// o transporte abrisse, é aberto aqui; um arquivo (ZIP, PDF...) vira erro
+// apontando para download, em vez de falhar no parse ou ser gravado como
+// response-<job>.json.
+func sniffBody(u *url.URL, header http.Header, body []byte) ([]byte, error) {
+	s, ok := utils.SniffContent(body)
+	if !ok {
+		return body, nil
+	}
+	contentType := header.Get("Content-Type")
+	if s.Decode == nil {
+		return nil, fmt.Errorf("resposta de %s é %s (Content-Type %q), não JSON: use download no job para gravar o arquivo", utils.RedactURL(*u), s.Name, contentType)
+	}
+	plain, err := utils.DecodeSniffed(s, body, int64(sniffMax))
+	if err != nil {
+		return nil, fmt.Errorf("resposta de %s: %w", utils.RedactURL(*u), err)
+	}
+	log.Printf("Resposta de %s em %s (Content-Type %q): %d bytes abertos em %d", utils.RedactURL(*u), s.Name, contentType, len(body), len(plain))
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(plain)))
+	return plain, nil
+}
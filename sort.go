@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"apiconsume/utils"
+)
+
+// SortConfig ordena os registros antes de gravar, para que o diff entre
+// execuções reflita mudanças reais e não a ordem devolvida pela API.
+// Cada chave é um jsonpath relativo ao registro; "-" na frente inverte a
+// ordem. Empates (e "by" vazio) são desfeitos pelo JSON do registro.
+type SortConfig struct {
+	By      []string `json:"by"`
+	Records string   `json:"records,omitempty"`
+}
+
+type sortKey struct {
+	path *utils.JSONPath
+	desc bool
+}
+
+func (c *SortConfig) validate() error {
+	_, err := c.keys()
+	if err == nil && c.Records != "" {
+		_, err = utils.ParseJSONPath(c.Records)
+	}
+	return err
+}
+
+func (c *SortConfig) keys() ([]sortKey, error) {
+	keys := make([]sortKey, 0, len(c.By))
+	for _, raw := range c.By {
+		key := sortKey{}
+		if strings.HasPrefix(raw, "-") {
+			key.desc = true
+			raw = raw[1:]
+		}
+		p, err := utils.ParseJSONPath(raw)
+		if err != nil {
+			return nil, err
+		}
+		key.path = p
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func sortOutput(c *SortConfig, body []byte) ([]byte, error) {
+	return transformOutput(body, c.Records, "sort", c.sortRecords)
+}
+
+func (c *SortConfig) sortRecords(records []any) ([]any, error) {
+	keys, _ := c.keys()
+	canonical := make([]string, len(records))
+	for i, rec := range records {
+		b, _ := json.Marshal(rec)
+		canonical[i] = string(b)
+	}
+
+	idx := make([]int, len(records))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		a, b := records[idx[i]], records[idx[j]]
+		for _, key := range keys {
+			va, _ := key.path.First(a)
+			vb, _ := key.path.First(b)
+			if cmp := compareValues(va, vb); cmp != 0 {
+				if key.desc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+		}
+		return canonical[idx[i]] < canonical[idx[j]]
+	})
+
+	sorted := make([]any, len(records))
+	for i, k := range idx {
+		sorted[i] = records[k]
+	}
+	return sorted, nil
+}
+
+// compareValues ordena números numericamente e textos lexicograficamente;
+// tipos diferentes seguem bool < número < texto < objeto/array, e ausentes
+// ou nulos vão para o fim.
+func compareValues(a, b any) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch va := a.(type) {
+	case bool:
+		vb := b.(bool)
+		if va == vb {
+			return 0
+		}
+		if !va {
+			return -1
+		}
+		return 1
+	case float64:
+		vb := b.(float64)
+		switch {
+		case va < vb:
+			return -1
+		case va > vb:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(va, b.(string))
+	case nil:
+		return 0
+	}
+
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return strings.Compare(string(ja), string(jb))
+}
+
+func valueRank(v any) int {
+	switch v.(type) {
+	case bool:
+		return 0
+	case float64:
+		return 1
+	case string:
+		return 2
+	case nil:
+		return 4
+	}
+	return 3
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	spoolDir     = flag.String("spool-dir", "", "diretório das entregas de sinks que falharam, reentregues sem refazer a coleta (vazio = desativado)")
+	sinkRetries  = flag.Int("sink-retries", 2, "novas tentativas imediatas de um sink que falha")
+	sinkBackoff  = flag.Duration("sink-backoff", 2*time.Second, "espera base entre as tentativas imediatas de um sink (dobra a cada uma)")
+	spoolBackoff = flag.Duration("spool-backoff", time.Minute, "espera base entre reentregas do spool (dobra a cada falha, até 6h)")
+)
+
+const maxSpoolBackoff = 6 * time.Hour
+
+// spoolEntry é uma entrega pendente: o payload fica ao lado, em <id>.data,
+// e Sink é a posição do sink em sinks do job.
+type spoolEntry struct {
+	ID          string    `json:"id"`
+	RunID       string    `json:"run_id,omitempty"`
+	Job         string    `json:"job"`
+	Sink        int       `json:"sink"`
+	SinkType    string    `json:"sink_type"`
+	Path        string    `json:"path"`
+	Bytes       int       `json:"bytes"`
+	Created     time.Time `json:"created"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+func spoolJobDir(job string) string {
+	return filepath.Join(*spoolDir, job)
+}
+
+func (e spoolEntry) metaPath() string { return filepath.Join(spoolJobDir(e.Job), e.ID+".json") }
+func (e spoolEntry) dataPath() string { return filepath.Join(spoolJobDir(e.Job), e.ID+".data") }
+
+// deliverWithRetry tenta o sink com as novas tentativas imediatas, antes de
+// desistir para o spool.
+func deliverWithRetry(ctx context.Context, job JobConfig, i int, out sinkOutput) error {
+	wait := *sinkBackoff
+	for attempt := 0; ; attempt++ {
+		err := job.sinks[i].Deliver(ctx, out)
+		if err == nil || attempt >= *sinkRetries || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("[%s] Sink %s falhou (%v); nova tentativa em %v", job.Name, job.Sinks[i].Type, err, wait)
+		if err := utils.SleepContext(ctx, wait); err != nil {
+			return err
+		}
+		wait *= 2
+	}
+}
+
+func spoolDelivery(job JobConfig, i int, out sinkOutput, cause error) {
+	now := time.Now()
+	e := spoolEntry{
+		ID:          now.UTC().Format("20060102T150405.000000000") + "-" + strconv.Itoa(i),
+		RunID:       job.runID,
+		Job:         job.Name,
+		Sink:        i,
+		SinkType:    job.Sinks[i].Type,
+		Path:        out.Path,
+		Bytes:       len(out.Data),
+		Created:     now,
+		Attempts:    1,
+		LastError:   cause.Error(),
+		NextAttempt: now.Add(*spoolBackoff),
+	}
+	if err := os.MkdirAll(spoolJobDir(job.Name), 0o755); err != nil {
+		log.Fatalf("Erro ao criar spool: %v", err)
+	}
+	// o payload vai antes: uma entrada sem .data nunca é vista como pronta
+	writeFile(e.dataPath(), out.Data)
+	e.save()
+	log.Printf("[%s] Entrega via %s guardada no spool (%s): %v", job.Name, e.SinkType, e.ID, cause)
+}
+
+func (e spoolEntry) save() {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		log.Fatalf("Erro ao gerar entrada do spool: %v", err)
+	}
+	writeFile(e.metaPath(), data)
+}
+
+func (e spoolEntry) remove() error {
+	if err := os.Remove(e.metaPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(e.dataPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// loadSpool lista as entregas pendentes de um job (ou de todos, com job
+// vazio), da mais antiga para a mais nova.
+func loadSpool(job string) ([]spoolEntry, error) {
+	if *spoolDir == "" {
+		return nil, nil
+	}
+	pattern := filepath.Join(*spoolDir, "*", "*.json")
+	if job != "" {
+		pattern = filepath.Join(spoolJobDir(job), "*.json")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []spoolEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var e spoolEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("entrada do spool %s inválida: %w", path, err)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Job != entries[j].Job {
+			return entries[i].Job < entries[j].Job
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// retrySpoolEntry reentrega uma entrada; falhando, agenda a próxima com o
+// backoff do spool.
+func retrySpoolEntry(ctx context.Context, job JobConfig, e spoolEntry) error {
+	if e.Sink >= len(job.sinks) || job.Sinks[e.Sink].Type != e.SinkType {
+		return fmt.Errorf("o sink #%d (%s) não existe mais na configuração do job", e.Sink+1, e.SinkType)
+	}
+	data, err := os.ReadFile(e.dataPath())
+	if err != nil {
+		return err
+	}
+
+	// a reentrega leva a execução que coletou o payload, não a atual
+	job.runID = e.RunID
+	err = job.sinks[e.Sink].Deliver(ctx, sinkOutput{Job: job, Path: e.Path, Data: data})
+	if err == nil {
+		log.Printf("[%s] Entrega %s via %s concluída após %d tentativas", job.Name, e.ID, e.SinkType, e.Attempts+1)
+		return e.remove()
+	}
+
+	e.Attempts++
+	e.LastError = err.Error()
+	wait := *spoolBackoff << min(e.Attempts-1, 20)
+	if wait <= 0 || wait > maxSpoolBackoff {
+		wait = maxSpoolBackoff
+	}
+	e.NextAttempt = time.Now().Add(wait)
+	e.save()
+	return err
+}
+
+// drainSpool reentrega, antes da coleta, as entregas pendentes do job cujo
+// backoff já venceu. Elas saem em ordem por sink: a primeira que falha
+// segura as seguintes do mesmo sink.
+func drainSpool(ctx context.Context, job JobConfig) {
+	entries, err := loadSpool(job.Name)
+	if err != nil {
+		log.Printf("[%s] Erro ao ler spool: %v", job.Name, err)
+		return
+	}
+	if len(entries) > 0 && auditSkip("[%s] reentrega de %d itens do spool", job.Name, len(entries)) {
+		return
+	}
+	redeliver(ctx, job, entries, false)
+}
+
+// redeliver tenta as entradas de um job em ordem e devolve quantas foram
+// entregues; force ignora o backoff (spool retry).
+func redeliver(ctx context.Context, job JobConfig, entries []spoolEntry, force bool) (delivered, failed int) {
+	blocked := map[int]bool{}
+	for _, e := range entries {
+		if blocked[e.Sink] || (!force && time.Now().Before(e.NextAttempt)) {
+			blocked[e.Sink] = true
+			continue
+		}
+		if err := retrySpoolEntry(ctx, job, e); err != nil {
+			log.Printf("[%s] Reentrega %s via %s falhou (tentativa %d): %v", job.Name, e.ID, e.SinkType, e.Attempts+1, err)
+			blocked[e.Sink] = true
+			failed++
+			continue
+		}
+		delivered++
+	}
+	return delivered, failed
+}
+
+// spoolPending diz se o sink tem entregas anteriores no spool; a nova
+// entra na fila atrás delas para não chegar fora de ordem.
+func spoolPending(job JobConfig, i int) bool {
+	if *spoolDir == "" {
+		return false
+	}
+	paths, _ := filepath.Glob(filepath.Join(spoolJobDir(job.Name), "*-"+strconv.Itoa(i)+".json"))
+	return len(paths) > 0
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	commands["spool"] = spoolCommand
+}
+
+const spoolUsage = "uso: api-requester spool -spool-dir <dir> [-config jobs.json] list|retry|purge [job|job/id ...]"
+
+// spoolCommand deixa o operador ver e decidir sobre as entregas pendentes
+// depois de uma indisponibilidade longa de um sink: list mostra, retry
+// reentrega na hora (ignorando o backoff) e purge descarta.
+func spoolCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(spoolUsage)
+	}
+	if *spoolDir == "" {
+		return fmt.Errorf("informe -spool-dir")
+	}
+
+	entries, err := loadSpool("")
+	if err != nil {
+		return err
+	}
+	entries = selectSpoolEntries(entries, args[1:])
+
+	switch args[0] {
+	case "list":
+		return listSpool(entries)
+	case "retry":
+		return retrySpool(ctx, entries)
+	case "purge":
+		if len(args) < 2 {
+			return fmt.Errorf("purge exige o job ou job/id a descartar")
+		}
+		for _, e := range entries {
+			if err := e.remove(); err != nil {
+				return err
+			}
+			log.Printf("Descartada %s/%s (%s, %d bytes)", e.Job, e.ID, e.SinkType, e.Bytes)
+		}
+		log.Printf("%d entregas descartadas", len(entries))
+		return nil
+	}
+	return fmt.Errorf(spoolUsage)
+}
+
+// selectSpoolEntries filtra por "job" ou "job/id"; sem seletores, todas.
+func selectSpoolEntries(entries []spoolEntry, selectors []string) []spoolEntry {
+	if len(selectors) == 0 {
+		return entries
+	}
+	var out []spoolEntry
+	for _, e := range entries {
+		for _, sel := range selectors {
+			job, id, hasID := strings.Cut(sel, "/")
+			if e.Job == job && (!hasID || e.ID == strings.TrimSuffix(id, ".json")) {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func listSpool(entries []spoolEntry) error {
+	if len(entries) == 0 {
+		fmt.Println("Nenhuma entrega pendente")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tID\tSINK\tBYTES\tTENTATIVAS\tCRIADA\tPRÓXIMA\tÚLTIMO ERRO")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t#%d %s\t%d\t%d\t%s\t%s\t%s\n", e.Job, e.ID, e.Sink+1, e.SinkType, e.Bytes, e.Attempts,
+			e.Created.Format(time.DateTime), e.NextAttempt.Format(time.DateTime), e.LastError)
+	}
+	return w.Flush()
+}
+
+func retrySpool(ctx context.Context, entries []spoolEntry) error {
+	if *jobConfigPath == "" {
+		return fmt.Errorf("retry exige -config, de onde vêm os sinks dos jobs")
+	}
+	cfg, err := loadJobConfig(*jobConfigPath)
+	if err != nil {
+		return err
+	}
+	jobs := make(map[string]JobConfig, len(cfg.Jobs))
+	for _, job := range cfg.Jobs {
+		jobs[job.Name] = job
+	}
+
+	byJob := map[string][]spoolEntry{}
+	var order []string
+	for _, e := range entries {
+		if _, seen := byJob[e.Job]; !seen {
+			order = append(order, e.Job)
+		}
+		byJob[e.Job] = append(byJob[e.Job], e)
+	}
+
+	var delivered, failed int
+	for _, name := range order {
+		job, ok := jobs[name]
+		if !ok {
+			log.Printf("Job %q não está em %s; %d entregas mantidas", name, *jobConfigPath, len(byJob[name]))
+			failed += len(byJob[name])
+			continue
+		}
+		d, f := redeliver(ctx, job, byJob[name], true)
+		delivered += d
+		failed += f
+	}
+
+	log.Printf("%d entregas reenviadas, %d falharam, %d aguardando as anteriores", delivered, failed, len(entries)-delivered-failed)
+	if failed > 0 {
+		return fmt.Errorf("%d entregas continuam pendentes", len(entries)-delivered)
+	}
+	return nil
+}
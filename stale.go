@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// StaleConfig entrega aos sinks, quando o job falha, a última resposta
+// publicada, marcada com "stale": true e "fetched_at" (quando foi
+// coletada), para que os dashboards mostrem o dado antigo em vez de nada.
+// A marca vai em cada registro de Records (vazio = a resposta é o array ou
+// o objeto); respostas mais velhas que MaxAge (0 = sem limite) não são
+// entregues.
+type StaleConfig struct {
+	Records string   `json:"records,omitempty"`
+	MaxAge  Duration `json:"max_age,omitzero"`
+}
+
+func (c *StaleConfig) validate(job JobConfig) error {
+	if c.MaxAge.Duration < 0 {
+		return fmt.Errorf("max_age deve ser >= 0")
+	}
+	if job.BulkInput != "" || job.Download != nil {
+		return fmt.Errorf("não se aplica a jobs com bulk_input ou download")
+	}
+	if len(job.Sinks) == 0 {
+		return fmt.Errorf("o job não tem sinks")
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staleState é quando a resposta publicada do job foi coletada.
+type staleState struct {
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// recordFetched é o commit que guarda quando a saída publicada foi
+// coletada.
+func recordFetched(job JobConfig, at time.Time) func() {
+	return func() {
+		if err := stateStore.Save("stale-"+job.Name, staleState{FetchedAt: at}); err != nil {
+			log.Printf("[%s] Erro ao salvar a data da última coleta: %v", job.Name, err)
+		}
+	}
+}
+
+// serveStale entrega aos sinks a última resposta publicada do job que
+// falhou. O job continua como falha; res registra de quando é o dado.
+func serveStale(ctx context.Context, job JobConfig, outputDir string, res *jobResult, now time.Time) {
+	if job.Stale == nil || ctx.Err() != nil {
+		return
+	}
+	var st staleState
+	if ok, err := stateStore.Load("stale-"+job.Name, &st); err != nil || !ok {
+		if err != nil {
+			log.Printf("[%s] %v", job.Name, err)
+		}
+		log.Printf("[%s] Sem resposta anterior para entregar como stale", job.Name)
+		return
+	}
+	if age := now.Sub(st.FetchedAt); job.Stale.MaxAge.Duration > 0 && age > job.Stale.MaxAge.Duration {
+		log.Printf("[%s] Resposta anterior de %s, mais velha que stale.max_age; nada entregue", job.Name, st.FetchedAt.Format(time.RFC3339))
+		return
+	}
+
+	path := filepath.Join(outputDir, "response-"+job.Name+".json")
+	body, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[%s] Erro ao ler a resposta anterior: %v", job.Name, err)
+		return
+	}
+	out, err := transformOutput(body, job.Stale.Records, "stale", func(records []any) ([]any, error) {
+		for _, rec := range records {
+			if m, ok := rec.(map[string]any); ok {
+				m["stale"] = true
+				m["fetched_at"] = st.FetchedAt.Format(time.RFC3339)
+			}
+		}
+		return records, nil
+	})
+	if err != nil {
+		log.Printf("[%s] Erro ao marcar a resposta anterior: %v", job.Name, err)
+		return
+	}
+	if err := deliverSinks(ctx, job, path, out); err != nil {
+		log.Printf("[%s] Erro ao entregar a resposta anterior: %v", job.Name, err)
+		return
+	}
+	log.Printf("[%s] Resposta de %s entregue aos sinks como stale", job.Name, st.FetchedAt.Format(time.RFC3339))
+	res.StaleFrom = st.FetchedAt
+}
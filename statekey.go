@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	stateKeyEnv     = flag.String("state-key-env", "", "variável de ambiente com a chave que cifra os arquivos de -state-dir (vazio = sem cifra)")
+	stateKeyCommand = flag.String("state-key-command", "", "comando cujo stdout é a chave que cifra os arquivos de -state-dir (KMS, Vault...), ex: \"vault kv get -field=key secret/api-requester\"")
+)
+
+// stateKey lê a chave de cifra do estado, se configurada: cursores,
+// watermarks e tokens guardados entre execuções podem trazer
+// identificadores sensíveis.
+func stateKey() ([]byte, error) {
+	switch {
+	case *stateKeyEnv != "" && *stateKeyCommand != "":
+		return nil, fmt.Errorf("use -state-key-env ou -state-key-command, não os dois")
+	case *stateKeyEnv != "":
+		key := os.Getenv(*stateKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("-state-key-env: variável %s vazia", *stateKeyEnv)
+		}
+		return []byte(key), nil
+	case *stateKeyCommand != "":
+		args := strings.Fields(*stateKeyCommand)
+		cmd := exec.Command(args[0], args[1:]...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("-state-key-command: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return bytes.TrimSpace(out), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	statsFrom = flag.String("stats-from", "24h", "stats: início do período: duração até agora (ex: 168h) ou data (2006-01-02, RFC3339)")
+	statsTo   = flag.String("stats-to", "", "stats: fim do período, no mesmo formato (padrão: agora)")
+)
+
+// latencyRetention é por quanto tempo os histogramas por hora ficam no
+// estado.
+const latencyRetention = 90 * 24 * time.Hour
+
+func init() {
+	commands["stats"] = statsCommand
+}
+
+type latencyHour struct {
+	Hour      time.Time              `json:"hour"`
+	Histogram utils.LatencyHistogram `json:"histogram"`
+}
+
+// latencyState guarda, por hora, o histograma dos tempos de resposta do
+// endpoint de um job.
+type latencyState struct {
+	Hours []latencyHour `json:"hours"`
+}
+
+// recordLatency soma as amostras da execução ao histograma da hora em
+// -state-dir/latency-<job>.json.
+func recordLatency(job JobConfig, samples []time.Duration, now time.Time) {
+	if len(samples) == 0 {
+		return
+	}
+	stateName := "latency-" + job.Name
+	var st latencyState
+	if _, err := stateStore.Load(stateName, &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+
+	hour := now.UTC().Truncate(time.Hour)
+	if n := len(st.Hours); n == 0 || !st.Hours[n-1].Hour.Equal(hour) {
+		st.Hours = append(st.Hours, latencyHour{Hour: hour})
+	}
+	h := &st.Hours[len(st.Hours)-1].Histogram
+	for _, d := range samples {
+		h.Record(d)
+	}
+
+	cutoff := hour.Add(-latencyRetention)
+	for len(st.Hours) > 0 && !st.Hours[0].Hour.After(cutoff) {
+		st.Hours = st.Hours[1:]
+	}
+
+	if err := stateStore.Save(stateName, st); err != nil {
+		log.Printf("[%s] Erro ao salvar histograma de latência: %v", job.Name, err)
+	}
+}
+
+// statsCommand imprime os percentis de latência dos jobs (todos, ou os
+// informados) no período entre -stats-from e -stats-to.
+func statsCommand(ctx context.Context, args []string) error {
+	now := time.Now()
+	from, err := parseStatsTime(*statsFrom, now)
+	if err != nil {
+		return fmt.Errorf("-stats-from: %w", err)
+	}
+	to := now
+	if *statsTo != "" {
+		if to, err = parseStatsTime(*statsTo, now); err != nil {
+			return fmt.Errorf("-stats-to: %w", err)
+		}
+	}
+
+	jobs := args
+	if len(jobs) == 0 {
+		names, err := stateStore.List("latency-")
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			jobs = append(jobs, strings.TrimPrefix(name, "latency-"))
+		}
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("nenhum histograma em %s", *stateDir)
+	}
+
+	fmt.Printf("Período: %s a %s\n", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tRESPOSTAS\tP50\tP90\tP95\tP99\tMÁX")
+	for _, job := range jobs {
+		var st latencyState
+		ok, err := stateStore.Load("latency-"+job, &st)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("job %q sem histograma", job)
+		}
+
+		var h utils.LatencyHistogram
+		for _, hr := range st.Hours {
+			// a hora entra se se sobrepõe ao período
+			if hr.Hour.Add(time.Hour).After(from) && hr.Hour.Before(to) {
+				h.Merge(&hr.Histogram)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d", job, h.Total)
+		for _, q := range []float64{0.5, 0.9, 0.95, 0.99, 1} {
+			fmt.Fprintf(w, "\t%v", roundLatency(h.Quantile(q)))
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func parseStatsTime(v string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q não é duração nem data (ex: 168h, 2026-10-01)", v)
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond)
+	}
+	return d
+}
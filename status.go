@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+const (
+	defaultPollInterval    = 5 * time.Second
+	defaultPollMaxInterval = time.Minute
+	defaultPollTimeout     = 10 * time.Minute
+)
+
+// StatusConfig define o que conta como sucesso para o job, no lugar do
+// status 200 fixo: OK são os status aceitos com o body como veio (padrão:
+// 200), Empty os aceitos como resposta vazia ([]) e Poll os de
+// processamento assíncrono (ex: 202), consultados de novo no Location (ou
+// na mesma URL) até virarem outro status. Qualquer outro status é falha.
+// Um 202 com Location é sempre seguido, a menos que 202 esteja em OK ou
+// Empty.
+type StatusConfig struct {
+	OK              []int     `json:"ok,omitempty"`
+	Empty           []int     `json:"empty,omitempty"`
+	Poll            []int     `json:"poll,omitempty"`
+	PollInterval    *Duration `json:"poll_interval,omitempty"`
+	PollMaxInterval *Duration `json:"poll_max_interval,omitempty"`
+	PollTimeout     *Duration `json:"poll_timeout,omitempty"`
+}
+
+func (c *StatusConfig) validate() error {
+	seen := map[int]string{}
+	for name, codes := range map[string][]int{"ok": c.OK, "empty": c.Empty, "poll": c.Poll} {
+		for _, code := range codes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("%s: status %d inválido", name, code)
+			}
+			if other, dup := seen[code]; dup {
+				return fmt.Errorf("status %d aparece em %s e em %s", code, other, name)
+			}
+			seen[code] = name
+		}
+	}
+	return nil
+}
+
+// accepts diz se o status encerra a requisição com sucesso; sem ok, 200
+// continua aceito.
+func (c *StatusConfig) accepts(status int) bool {
+	if c == nil || len(c.OK) == 0 {
+		if status == http.StatusOK {
+			return true
+		}
+	}
+	return c != nil && (slices.Contains(c.OK, status) || slices.Contains(c.Empty, status))
+}
+
+func (c *StatusConfig) empty(status int) bool {
+	return c != nil && slices.Contains(c.Empty, status)
+}
+
+func (c *StatusConfig) polling(status int, header http.Header) bool {
+	if c != nil && slices.Contains(c.Poll, status) {
+		return true
+	}
+	if status != http.StatusAccepted || header.Get("Location") == "" {
+		return false
+	}
+	return c == nil || !(slices.Contains(c.OK, status) || slices.Contains(c.Empty, status))
+}
+
+// fetch faz a requisição seguindo o poll e troca o body dos status Empty
+// por [].
+func (c *StatusConfig) fetch(ctx context.Context, rl *utils.RateLimitClient, spec requestSpec) ([]byte, http.Header, int, error) {
+	poll := c.newPoll()
+	for {
+		body, header, status, err := doRequestHeaders(ctx, rl, spec)
+		if err != nil || !poll.continues(c, status, header) {
+			if err == nil && c.empty(status) {
+				body = []byte("[]")
+			}
+			return body, header, status, err
+		}
+		if spec, err = poll.next(ctx, spec, header, status); err != nil {
+			return nil, header, status, err
+		}
+	}
+}
+
+// send é o fetch das respostas lidas em streaming (download): devolve a
+// resposta do primeiro status que não é de poll.
+func (c *StatusConfig) send(ctx context.Context, rl *utils.RateLimitClient, spec requestSpec) (*http.Response, error) {
+	poll := c.newPoll()
+	for {
+		req, err := newHTTPRequest(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := rl.Do(req)
+		if err != nil || !poll.continues(c, resp.StatusCode, resp.Header) {
+			return resp, err
+		}
+		resp.Body.Close()
+		if spec, err = poll.next(ctx, spec, resp.Header, resp.StatusCode); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type statusPoll struct {
+	following   bool
+	interval    time.Duration
+	maxInterval time.Duration
+	deadline    time.Time
+	timeout     time.Duration
+}
+
+func (c *StatusConfig) newPoll() *statusPoll {
+	p := &statusPoll{interval: defaultPollInterval, maxInterval: defaultPollMaxInterval, timeout: defaultPollTimeout}
+	if c != nil && c.PollInterval != nil {
+		p.interval = c.PollInterval.Duration
+	}
+	if c != nil && c.PollMaxInterval != nil {
+		p.maxInterval = c.PollMaxInterval.Duration
+	}
+	if c != nil && c.PollTimeout != nil {
+		p.timeout = c.PollTimeout.Duration
+	}
+	p.deadline = time.Now().Add(p.timeout)
+	return p
+}
+
+// continues diz se a resposta pede outra consulta; depois de um 202 com
+// Location, os 202 seguintes do recurso de status também pedem, mesmo sem
+// Location.
+func (p *statusPoll) continues(c *StatusConfig, status int, header http.Header) bool {
+	if p.following && status == http.StatusAccepted {
+		return true
+	}
+	if !c.polling(status, header) {
+		return false
+	}
+	p.following = p.following || (status == http.StatusAccepted && header.Get("Location") != "")
+	return true
+}
+
+// next espera o Retry-After (ou o intervalo, que dobra a cada consulta até
+// poll_max_interval) e devolve a próxima consulta: um GET no Location,
+// quando a resposta indica um, ou a mesma requisição.
+func (p *statusPoll) next(ctx context.Context, spec requestSpec, header http.Header, status int) (requestSpec, error) {
+	wait := p.interval
+	if d, ok := utils.ParseRetryAfter(header.Get("Retry-After")); ok && d > 0 {
+		wait = d
+	} else {
+		p.interval = min(p.interval*2, max(p.maxInterval, p.interval))
+	}
+	if time.Now().Add(wait).After(p.deadline) {
+		return spec, fmt.Errorf("status %d ainda em processamento após %v (poll_timeout)", status, p.timeout)
+	}
+
+	if loc := header.Get("Location"); loc != "" {
+		base, err := url.Parse(spec.URL)
+		if err != nil {
+			return spec, err
+		}
+		ref, err := url.Parse(strings.TrimSpace(loc))
+		if err != nil {
+			return spec, fmt.Errorf("Location %q inválido: %w", loc, err)
+		}
+		spec = requestSpec{Method: http.MethodGet, URL: base.ResolveReference(ref).String(), Headers: spec.Headers}
+	}
+
+	log.Printf("Status %d: processamento em andamento, nova consulta em %v", status, wait)
+	if err := utils.SleepContext(ctx, wait); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
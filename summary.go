@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var failOn = flag.String("fail-on", "critical", "quando a execução de -config sai com código 1: critical (algum job critical falhou), any ou never")
+
+// jobResult é a linha de um job no resumo da execução.
+type jobResult struct {
+	Job         string            `json:"job"`
+	Success     bool              `json:"success"`
+	Skipped     bool              `json:"skipped,omitempty"`
+	Maintenance bool              `json:"maintenance,omitempty"`
+	Critical    bool              `json:"critical,omitempty"`
+	Optional    bool              `json:"optional,omitempty"`
+	Records     int               `json:"records"`
+	Duration    Duration          `json:"duration"`
+	Attempts    int64             `json:"attempts"`
+	Error       string            `json:"error,omitempty"`
+	SLO         *sloReport        `json:"slo,omitempty"`
+	Retries     *utils.RetryStats `json:"retries,omitempty"`
+	Rejected    int               `json:"rejected,omitempty"`
+	StaleFrom   time.Time         `json:"stale_from,omitzero"`
+
+	warnings []utils.Warning
+	rejects  []rejectedRecord
+	requests jobRequests
+}
+
+// runSummary é gravado em run-summary.json ao fim de cada execução com
+// -config.
+type runSummary struct {
+	RunID      string       `json:"run_id"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Jobs       []jobResult  `json:"jobs"`
+	Failed     int          `json:"failed"`
+	Skipped    int          `json:"skipped"`
+	Warnings   []runWarning `json:"warnings,omitempty"`
+	ExitCode   int          `json:"exit_code"`
+
+	Retries *utils.RetryStats `json:"retries,omitempty"`
+}
+
+func validateFailOn() error {
+	switch *failOn {
+	case "critical", "any", "never":
+		return nil
+	}
+	return fmt.Errorf("-fail-on deve ser critical, any ou never")
+}
+
+// countRecords conta os registros da saída final: o tamanho do array, ou 1
+// para um objeto.
+func countRecords(data []byte) int {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0
+	}
+	switch v := doc.(type) {
+	case []any:
+		return len(v)
+	case nil:
+		return 0
+	}
+	return 1
+}
+
+func summarizeRun(runID string, results []jobResult, started time.Time, outputDir string) int {
+	sort.Slice(results, func(i, j int) bool { return results[i].Job < results[j].Job })
+
+	summary := runSummary{RunID: runID, StartedAt: started, FinishedAt: time.Now(), Jobs: results, Warnings: runWarnings(results)}
+	criticalFailed, requiredFailed := false, false
+	for _, r := range results {
+		if r.Retries != nil {
+			if summary.Retries == nil {
+				summary.Retries = &utils.RetryStats{}
+			}
+			summary.Retries.Merge(*r.Retries)
+		}
+		if r.Skipped {
+			// ficar sem tempo só reprova a execução se o job era critical;
+			// a manutenção do provedor, nunca
+			summary.Skipped++
+			criticalFailed = criticalFailed || (r.Critical && !r.Maintenance)
+		} else if !r.Success {
+			// a falha de um job optional entra no resumo, mas nunca no
+			// código de saída
+			summary.Failed++
+			criticalFailed = criticalFailed || r.Critical
+			requiredFailed = requiredFailed || !r.Optional
+		}
+	}
+	switch {
+	case *failOn == "any" && requiredFailed, *failOn == "critical" && criticalFailed:
+		summary.ExitCode = 1
+	}
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSTATUS\tREGISTROS\tDURAÇÃO\tTENTATIVAS\tERRO")
+	for _, r := range results {
+		status := "ok"
+		if r.Maintenance {
+			status = "MANUTENÇÃO"
+		} else if r.Skipped {
+			status = "IGNORADO"
+		} else if !r.Success {
+			var tags []string
+			if r.Critical {
+				tags = append(tags, "critical")
+			} else if r.Optional {
+				tags = append(tags, "optional")
+			}
+			if !r.StaleFrom.IsZero() {
+				tags = append(tags, "stale")
+			}
+			status = "FALHA"
+			if len(tags) > 0 {
+				status += " (" + strings.Join(tags, ", ") + ")"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%d\t%s\n", r.Job, status, r.Records, r.Duration.Round(time.Millisecond), r.Attempts, r.Error)
+	}
+	w.Flush()
+
+	if s := summary.Retries; s != nil && s.Retries > 0 {
+		fmt.Fprintf(os.Stderr, "NOVAS TENTATIVAS: %d em %d requisições (%.0f%% do orçamento de max_retries; pior requisição %.0f%%)\n",
+			s.Retries, s.Requests, 100*s.BudgetUsed, 100*s.PeakUsed)
+	}
+
+	if len(summary.Warnings) > 0 {
+		fmt.Fprintln(os.Stderr, "AVISOS")
+		for _, warn := range summary.Warnings {
+			fmt.Fprintf(os.Stderr, "  [%s] %s: %s (%dx)\n", warn.Job, warn.Kind, warn.Message, warn.Count)
+		}
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		log.Printf("Erro ao gerar resumo: %v", err)
+		return summary.ExitCode
+	}
+	writeFile(filepath.Join(outputDir, "run-summary.json"), data)
+	appendHistory(outputDir, summary)
+	writeMetrics(summary)
+	return summary.ExitCode
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+var jobTags = flag.String("tags", "", "executa só os jobs de -config com alguma dessas tags, ex: critical,hourly (vazio = todos)")
+
+func (job JobConfig) hasTag(tag string) bool {
+	return slices.Contains(job.Tags, tag)
+}
+
+func (job JobConfig) isCritical() bool {
+	return job.Critical || job.hasTag("critical")
+}
+
+// selectTagged restringe a configuração aos jobs com alguma das tags de
+// -tags; joins só ficam se os dois lados foram selecionados.
+func selectTagged(cfg *MultiJobConfig) (*MultiJobConfig, error) {
+	var tags []string
+	for _, tag := range strings.Split(*jobTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return cfg, nil
+	}
+
+	selected := *cfg
+	selected.Jobs = nil
+	names := map[string]bool{}
+	for _, job := range cfg.Jobs {
+		if slices.ContainsFunc(tags, job.hasTag) {
+			selected.Jobs = append(selected.Jobs, job)
+			names[job.Name] = true
+		}
+	}
+	if len(selected.Jobs) == 0 {
+		return nil, fmt.Errorf("nenhum job com as tags %s", strings.Join(tags, ", "))
+	}
+
+	selected.Joins = nil
+	for _, join := range cfg.Joins {
+		if names[join.Left] && names[join.Right] {
+			selected.Joins = append(selected.Joins, join)
+		}
+	}
+	log.Printf("Tags %s: %d de %d jobs selecionados", strings.Join(tags, ", "), len(selected.Jobs), len(cfg.Jobs))
+	return &selected, nil
+}
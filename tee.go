@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+)
+
+// streamSink é o sink que aceita o body de um download enquanto ele é
+// baixado, na mesma passada que grava o arquivo e calcula o sha256, sem
+// reler o arquivo do disco depois.
+type streamSink interface {
+	sink
+	Begin(ctx context.Context, job JobConfig, contentType string, size int64) (sinkStream, error)
+}
+
+// sinkStream recebe os bytes; Close conclui a entrega e Abort a descarta,
+// quando o download falha.
+type sinkStream interface {
+	io.Writer
+	Close() error
+	Abort(err error)
+}
+
+// teeStream isola a falha de um sink: a cópia do download continua e o
+// sink é entregue depois a partir do arquivo.
+type teeStream struct {
+	index  int
+	stream sinkStream
+	err    error
+}
+
+func (t *teeStream) Write(p []byte) (int, error) {
+	if t.err == nil {
+		if _, err := t.stream.Write(p); err != nil {
+			t.err = err
+		}
+	}
+	return len(p), nil
+}
+
+// openSinkStreams abre um stream por sink do job; em -audit, nenhum.
+func openSinkStreams(ctx context.Context, job JobConfig, contentType string, size int64) []*teeStream {
+	if len(job.sinks) == 0 || auditSkip("[%s] entrega em %d sinks", job.Name, len(job.sinks)) {
+		return nil
+	}
+	var streams []*teeStream
+	for i, s := range job.sinks {
+		t := &teeStream{index: i}
+		// validado em loadJobConfig: com download, todo sink é streamSink
+		if t.stream, t.err = s.(streamSink).Begin(ctx, job, contentType, size); t.err != nil {
+			t.stream = nil
+		}
+		streams = append(streams, t)
+	}
+	return streams
+}
+
+func teeWriters(streams []*teeStream) []io.Writer {
+	writers := make([]io.Writer, len(streams))
+	for i, t := range streams {
+		writers[i] = t
+	}
+	return writers
+}
+
+func abortSinkStreams(streams []*teeStream, cause error) {
+	for _, t := range streams {
+		if t.stream != nil {
+			t.stream.Abort(cause)
+		}
+	}
+}
+
+// finishSinkStreams fecha os streams do download gravado em path; o sink
+// que falhou no meio é entregue de novo, com as novas tentativas de
+// -sink-retries, relendo o arquivo.
+func finishSinkStreams(ctx context.Context, job JobConfig, path string, streams []*teeStream) error {
+	resent := 0
+	for _, t := range streams {
+		if t.stream != nil {
+			if err := t.stream.Close(); t.err == nil {
+				t.err = err
+			}
+		}
+		if t.err == nil {
+			continue
+		}
+		log.Printf("[%s] Sink %s falhou durante o download (%v); reenviando do arquivo", job.Name, job.Sinks[t.index].Type, t.err)
+		if err := deliverWithRetry(ctx, job, t.index, sinkOutput{Job: job, Path: path}); err != nil {
+			return fmt.Errorf("sink %s: %w", job.Sinks[t.index].Type, err)
+		}
+		resent++
+	}
+	if len(streams) > 0 {
+		log.Printf("[%s] %d sinks entregues junto com o download, %d reenviados do arquivo", job.Name, len(streams)-resent, resent)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var captureHeaders = flag.String("capture-headers", "", "headers de resposta guardados de cada tentativa nos metadados do job, ex: X-Request-Id,Sunset")
+
+func captureHeaderNames() []string {
+	var names []string
+	for _, name := range strings.Split(*captureHeaders, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// runMetadata acompanha a saída do job em response-<job>.meta.json, também
+// quando o job falha.
+type runMetadata struct {
+	Job        string                `json:"job"`
+	StartedAt  time.Time             `json:"started_at"`
+	FinishedAt time.Time             `json:"finished_at"`
+	Success    bool                  `json:"success"`
+	Attempts   []utils.AttemptRecord `json:"attempts,omitempty"`
+
+	Deprecations []utils.DeprecationNotice `json:"deprecations,omitempty"`
+
+	Download *downloadInfo `json:"download,omitempty"`
+
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+
+	// Limiter é o estado do rate limiter ao fim do job.
+	Limiter utils.LimiterSnapshot `json:"limiter"`
+}
+
+// writeRunMetadata completa meta (download e headers já preenchidos pelo
+// job) e grava o arquivo.
+func writeRunMetadata(job JobConfig, rl *utils.RateLimitClient, outputDir string, started time.Time, errs []ErrorResponse, meta runMetadata) {
+	var notices []utils.DeprecationNotice
+	if rl.Deprecations != nil {
+		notices = rl.Deprecations.Notices()
+	}
+
+	meta.Limiter = rl.Snapshot()
+	meta.Job = job.Name
+	meta.StartedAt = started
+	meta.FinishedAt = time.Now()
+	meta.Success = len(errs) == 0
+	meta.Deprecations = notices
+	if rl.Capture != nil {
+		meta.Attempts = rl.Capture.Drain()
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		log.Printf("[%s] Erro ao gerar metadados: %v", job.Name, err)
+		return
+	}
+	writeFile(filepath.Join(outputDir, "response-"+job.Name+".meta.json"), data)
+}
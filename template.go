@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand/v2"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+)
+
+// requestSeq alimenta {{seq}}: contador crescente durante toda a execução,
+// compartilhado entre jobs.
+var requestSeq atomic.Int64
+
+// templateVars reúne os valores disponíveis para os templates de URL,
+// headers e body de um job.
+type templateVars struct {
+	Watermark string
+	Params    map[string]string
+	Current   any
+
+	// inURL: o template é a URL do job, onde watermark e param vão
+	// escapados (o + de um offset RFC3339 chegaria ao servidor como espaço,
+	// e um & ou / de quem disparou mudaria a query ou o caminho)
+	inURL bool
+}
+
+func (v templateVars) funcs() template.FuncMap {
+	return template.FuncMap{
+		"watermark": func() string {
+			if v.inURL {
+				return escapeURLValue(v.Watermark)
+			}
+			return v.Watermark
+		},
+		"param": func(name string) (string, error) {
+			value, ok := v.Params[name]
+			if !ok {
+				return "", fmt.Errorf("parâmetro %q não informado", name)
+			}
+			if v.inURL {
+				if value == "." || value == ".." {
+					return "", fmt.Errorf("parâmetro %q: %q não pode ir na URL", name, value)
+				}
+				return escapeURLValue(value), nil
+			}
+			return value, nil
+		},
+		"uuid": newUUID,
+		"randInt": func(lo, hi int) (int, error) {
+			if hi < lo {
+				return 0, fmt.Errorf("randInt: %d > %d", lo, hi)
+			}
+			// em uint64 o intervalo não estoura; 0 é o intervalo inteiro
+			span := uint64(hi) - uint64(lo) + 1
+			if span == 0 {
+				return int(mrand.Uint64()), nil
+			}
+			return int(uint64(lo) + mrand.Uint64N(span)), nil
+		},
+		"seq": func() int64 { return requestSeq.Add(1) },
+		"env": os.Getenv,
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}
+}
+
+// escapeURLValue escapa um valor para qualquer ponto da URL: na query e
+// num segmento do caminho (com o espaço como %20, e não +).
+func escapeURLValue(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// newUUID gera um UUID v4 aleatório.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func renderTemplate(text string, vars templateVars) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("request").Funcs(vars.funcs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (job JobConfig) render(rawURL string, vars templateVars) (requestSpec, error) {
+	spec := job.spec(rawURL)
+
+	urlVars := vars
+	urlVars.inURL = true
+	var err error
+	if spec.URL, err = renderTemplate(spec.URL, urlVars); err != nil {
+		return spec, err
+	}
+	if len(job.Query) > 0 {
+		query, err := job.encodeQuery(vars)
+		if err != nil {
+			return spec, err
+		}
+		sep := "?"
+		if strings.Contains(spec.URL, "?") {
+			sep = "&"
+		}
+		spec.URL += sep + query
+	}
+
+	if len(spec.Headers) > 0 {
+		headers := make(map[string]string, len(spec.Headers))
+		for k, v := range spec.Headers {
+			if headers[k], err = renderTemplate(v, vars); err != nil {
+				return spec, err
+			}
+		}
+		spec.Headers = headers
+	}
+
+	if spec.Body != nil {
+		body, err := renderTemplate(string(spec.Body), vars)
+		if err != nil {
+			return spec, err
+		}
+		spec.Body = []byte(body)
+	}
+
+	return spec, nil
+}
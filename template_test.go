@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestRandInt(t *testing.T) {
+	randInt := templateVars{}.funcs()["randInt"].(func(int, int) (int, error))
+	tests := []struct {
+		name   string
+		lo, hi int
+	}{
+		{"pequeno", 1, 6},
+		{"um valor", 7, 7},
+		{"negativos", -10, -5},
+		{"metade de cima", 0, math.MaxInt},
+		{"metade de baixo", math.MinInt, 0},
+		{"intervalo inteiro", math.MinInt, math.MaxInt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				n, err := randInt(tt.lo, tt.hi)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n < tt.lo || n > tt.hi {
+					t.Fatalf("randInt(%d, %d) = %d, fora do intervalo", tt.lo, tt.hi, n)
+				}
+			}
+		})
+	}
+
+	if _, err := randInt(5, 1); err == nil {
+		t.Error("randInt(5, 1): quer erro")
+	}
+}
+
+func TestRandIntTemplate(t *testing.T) {
+	out, err := renderTemplate("{{randInt 0 9223372036854775807}}", templateVars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strconv.ParseInt(out, 10, 64); err != nil {
+		t.Errorf("randInt renderizou %q", out)
+	}
+}
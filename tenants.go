@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// TenantsConfig roda o mesmo endpoint para vários tenants, cada um chamado
+// com a API key da conta a que pertence: {tenant} na URL, nos headers e no
+// body vira o id do tenant, e a resposta vai para
+// <job>/response-<tenant>.json, como no bulk. Cada chave tem o próprio rate
+// limit (taxa, cota e reset lidos das respostas dela, e o perfil aprendido
+// em profiles.json), e as requisições são distribuídas em rodízio entre as
+// chaves, para que a cota de uma não segure os tenants das outras.
+type TenantsConfig struct {
+	// onde a chave vai, como no auth apikey: o header (padrão X-API-Key)
+	// ou, com param, a query
+	Header string `json:"header,omitempty"`
+	Param  string `json:"param,omitempty"`
+
+	Keys []TenantKey `json:"keys"`
+
+	// Concurrency é o total de requisições em andamento (padrão: uma por
+	// chave); KeyConcurrency, o de cada chave (padrão: 1).
+	Concurrency    int `json:"concurrency,omitempty"`
+	KeyConcurrency int `json:"key_concurrency,omitempty"`
+}
+
+// TenantKey é uma API key e os tenants que ela acessa.
+type TenantKey struct {
+	Name    string   `json:"name"`
+	Token   string   `json:"token"`
+	Rate    int      `json:"rate,omitempty"`
+	Tenants []string `json:"tenants"`
+
+	auth utils.AuthProvider
+}
+
+func (c *TenantsConfig) validate(job JobConfig) error {
+	if len(c.Keys) == 0 {
+		return fmt.Errorf("keys é obrigatório")
+	}
+	if c.Concurrency < 0 || c.KeyConcurrency < 0 {
+		return fmt.Errorf("concurrency e key_concurrency não podem ser negativos")
+	}
+	for name, set := range map[string]bool{
+		"bulk_input": job.BulkInput != "",
+		"paginate":   job.Paginate != nil,
+		"download":   job.Download != nil,
+		"auth":       job.Auth != nil,
+		"dedup":      job.Dedup != nil,
+		"delta":      job.Delta != nil,
+		"watermark":  job.Watermark != nil,
+		"stale":      job.Stale != nil,
+	} {
+		if set {
+			return fmt.Errorf("não se aplica com %s", name)
+		}
+	}
+
+	names, tenants := map[string]bool{}, map[string]string{}
+	for i := range c.Keys {
+		k := &c.Keys[i]
+		if k.Name == "" {
+			return fmt.Errorf("keys[%d]: name é obrigatório", i)
+		}
+		if names[k.Name] {
+			return fmt.Errorf("chave %q repetida", k.Name)
+		}
+		names[k.Name] = true
+		if len(k.Tenants) == 0 {
+			return fmt.Errorf("chave %q: tenants é obrigatório", k.Name)
+		}
+		for _, t := range k.Tenants {
+			if other, dup := tenants[t]; dup {
+				return fmt.Errorf("tenant %q em duas chaves (%s e %s)", t, other, k.Name)
+			}
+			tenants[t] = k.Name
+		}
+		auth, err := (&AuthConfig{Type: "apikey", Token: k.Token, Header: c.Header, Param: c.Param}).apiKeyAuth()
+		if err != nil {
+			return fmt.Errorf("chave %q: %w", k.Name, err)
+		}
+		k.auth = auth
+	}
+	return nil
+}
+
+func (c *TenantsConfig) count() int {
+	n := 0
+	for _, k := range c.Keys {
+		n += len(k.Tenants)
+	}
+	return n
+}
+
+// tenantQueue são os tenants ainda não buscados de uma chave.
+type tenantQueue struct {
+	key     *TenantKey
+	rl      *utils.RateLimitClient
+	pending []string
+	busy    int
+}
+
+// keyClient é o cliente da chave: as configurações do job com a
+// autenticação e o ritmo próprios, e os mesmos medidores de rl (consumo,
+// latência, avisos, requisições), que seguem valendo para o job todo.
+func (job JobConfig) keyClient(settings JobSettings, k *TenantKey, rl *utils.RateLimitClient) *utils.RateLimitClient {
+	kc := job.rateClient(settings)
+	kc.Auth, kc.Pacer = k.auth, nil
+	if k.Rate > 0 {
+		kc.SafeRate, kc.DynamicRate = k.Rate, k.Rate
+	} else {
+		seedProfileFor(job, kc, job.tenantKey(k), job.usageKey())
+	}
+	kc.Usage, kc.Latency, kc.Warnings, kc.Requests = rl.Usage, rl.Latency, rl.Warnings, rl.Requests
+	kc.Capture, kc.Deprecations = rl.Capture, rl.Deprecations
+	return kc
+}
+
+// tenantKey é a chave do perfil de rate limit de uma API key.
+func (job JobConfig) tenantKey(k *TenantKey) string {
+	return job.usageKey() + "/" + k.Name
+}
+
+// runTenants busca cada tenant com o cliente da sua chave, passando pelas
+// chaves em rodízio: a próxima requisição é sempre da chave seguinte que
+// ainda tem tenants e não chegou a key_concurrency, e só espera quando
+// todas estão ocupadas. Devolve também os clientes das chaves, para as
+// tentativas entrarem no resumo do job.
+func runTenants(ctx context.Context, job JobConfig, settings JobSettings, rl *utils.RateLimitClient, base requestSpec, outputDir string, opts bulkOptions) ([]ErrorResponse, []*utils.RateLimitClient) {
+	cfg := job.Tenants
+	queues := make([]*tenantQueue, len(cfg.Keys))
+	clients := make([]*utils.RateLimitClient, len(cfg.Keys))
+	for i := range cfg.Keys {
+		k := &cfg.Keys[i]
+		clients[i] = job.keyClient(settings, k, rl)
+		queues[i] = &tenantQueue{key: k, rl: clients[i], pending: k.Tenants}
+	}
+	total := cfg.count()
+	perKey := max(cfg.KeyConcurrency, 1)
+	concurrency := cfg.Concurrency
+	if concurrency == 0 {
+		concurrency = len(queues) * perKey
+	}
+	slots := make(chan struct{}, concurrency)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errors []ErrorResponse
+	)
+	ready := sync.NewCond(&mu)
+	next, left := 0, total
+	// pick é a próxima chave, a partir de next, com um tenant para buscar
+	pick := func() *tenantQueue {
+		for i := range queues {
+			q := queues[(next+i)%len(queues)]
+			if len(q.pending) > 0 && q.busy < perKey {
+				next = (next + i + 1) % len(queues)
+				return q
+			}
+		}
+		return nil
+	}
+
+	log.Printf("[%s] %d tenants em %d chaves, até %d requisições simultâneas", job.Name, total, len(queues), concurrency)
+
+	for left > 0 && ctx.Err() == nil {
+		if err := waitExecutionWindow(ctx); err != nil {
+			break
+		}
+		mu.Lock()
+		q := pick()
+		for q == nil {
+			ready.Wait()
+			q = pick()
+		}
+		tenant := q.pending[0]
+		q.pending = q.pending[1:]
+		q.busy++
+		left--
+		mu.Unlock()
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			left++
+			break
+		}
+
+		wg.Add(1)
+		go func(q *tenantQueue, tenant string) {
+			defer wg.Done()
+			defer func() {
+				<-slots
+				mu.Lock()
+				q.busy--
+				ready.Broadcast()
+				mu.Unlock()
+			}()
+
+			spec := base
+			spec.URL = strings.ReplaceAll(base.URL, "{tenant}", url.PathEscape(tenant))
+			if len(base.Headers) > 0 {
+				spec.Headers = make(map[string]string, len(base.Headers))
+				for k, v := range base.Headers {
+					spec.Headers[k] = strings.ReplaceAll(v, "{tenant}", tenant)
+				}
+			}
+			if len(base.Body) > 0 {
+				spec.Body = []byte(strings.ReplaceAll(string(base.Body), "{tenant}", tenant))
+			}
+
+			reqCtx, trace := utils.WithAttemptTrace(ctx)
+			body, _, status, err := opts.Status.fetch(reqCtx, q.rl, spec)
+			if err == nil && opts.Status.accepts(status) {
+				writeOutput(filepath.Join(outputDir, bulkFileName(tenant)), body)
+				return
+			}
+
+			failure := requestError(opts.ErrorEnvelope, status, body, explain(ctx, err))
+			mu.Lock()
+			errors = append(errors, ErrorResponse{
+				At:       time.Now(),
+				Attempt:  1,
+				Item:     tenant,
+				Error:    fmt.Sprintf("chave %s: %v", q.key.Name, failure),
+				Attempts: attemptDetails(trace),
+			}.withEnvelope(failure))
+			mu.Unlock()
+		}(q, tenant)
+	}
+	wg.Wait()
+
+	for _, q := range queues {
+		recordProfileFor(job, job.tenantKey(q.key), q.rl, time.Now())
+		log.Printf("[%s] Chave %s: %d tenants, %d tentativas, taxa final %d req/s", job.Name, q.key.Name, len(q.key.Tenants), q.rl.Attempts(), q.rl.CurrentRate())
+	}
+	log.Printf("[%s] Tenants finalizados: %d de %d buscados, %d falhas", job.Name, total-left, total, len(errors))
+	return errors, clients
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"apiconsume/utils"
+)
+
+// transformOutput decodifica a saída, troca o array de registros pelo que fn
+// devolver (preservando o envelope da resposta) e serializa de volta. Um
+// documento que não é array é tratado como um registro só.
+func transformOutput(body []byte, recordsPath, section string, fn func(records []any) ([]any, error)) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("resposta não é JSON válido: %w", err)
+	}
+
+	if recordsPath == "" {
+		records, isArray := doc.([]any)
+		if !isArray {
+			records = []any{doc}
+		}
+		out, err := fn(records)
+		if err != nil {
+			return nil, err
+		}
+		if !isArray {
+			if len(out) == 0 {
+				return []byte("null"), nil
+			}
+			return json.Marshal(out[0])
+		}
+		return json.Marshal(out)
+	}
+
+	p, _ := utils.ParseJSONPath(recordsPath)
+	found := p.Find(doc)
+	if len(found) != 1 {
+		return nil, fmt.Errorf("%s.records %s não aponta para um único array", section, recordsPath)
+	}
+	records, ok := found[0].([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s.records %s não é um array", section, recordsPath)
+	}
+
+	out, err := fn(records)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = p.Set(doc, out); err != nil {
+		return nil, fmt.Errorf("%s.records: %w", section, err)
+	}
+	return json.Marshal(doc)
+}
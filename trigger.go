@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+const triggerSuffix = ".trigger.json"
+
+var watchInterval = flag.Duration("watch-interval", 5*time.Second, "watch: intervalo de varredura do diretório de gatilhos")
+
+func init() {
+	commands["watch"] = watchCommand
+}
+
+// TriggerFile é o conteúdo de um arquivo <nome>.trigger.json deixado no
+// diretório observado por sistemas legados.
+type TriggerFile struct {
+	Job    string            `json:"job"`
+	Params map[string]string `json:"params,omitempty"`
+	IDs    []string          `json:"ids,omitempty"`
+}
+
+// watchCommand observa o diretório (por varredura, sem depender de inotify)
+// e executa um fetch para cada gatilho novo, gravando o resultado ao lado.
+// O gatilho é renomeado para .done ou .failed para não ser reprocessado.
+func watchCommand(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("uso: api-requester watch [-config jobs.json] [-watch-interval 5s] <diretório>")
+	}
+	dir := args[0]
+
+	jobs, err := loadTriggerJobs()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Observando %s por arquivos *%s", dir, triggerSuffix)
+
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), triggerSuffix) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < time.Second {
+				// ainda pode estar sendo escrito
+				continue
+			}
+			handleTrigger(ctx, filepath.Join(dir, entry.Name()), jobs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*watchInterval):
+		}
+	}
+}
+
+func loadTriggerJobs() (map[string]JobConfig, error) {
+	jobs := map[string]JobConfig{}
+
+	if *jobConfigPath == "" {
+		urlBase, err := loadEnvValues(".env")
+		if err != nil {
+			return nil, err
+		}
+		jobs["default"] = JobConfig{Name: "default", URL: urlBase}
+		return jobs, nil
+	}
+
+	cfg, err := loadJobConfig(*jobConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := confirmDestructive(cfg.Jobs); err != nil {
+		return nil, err
+	}
+	defaults := flagSettings().merge(cfg.Defaults)
+	for _, job := range cfg.Jobs {
+		job.JobSettings = defaults.merge(job.JobSettings)
+		jobs[job.Name] = job
+	}
+	return jobs, nil
+}
+
+func handleTrigger(ctx context.Context, path string, jobs map[string]JobConfig) {
+	base := strings.TrimSuffix(path, triggerSuffix)
+	err := runTrigger(ctx, path, base, jobs)
+
+	final := base + ".done"
+	if err != nil {
+		final = base + ".failed"
+		log.Printf("Gatilho %s falhou: %v", filepath.Base(path), err)
+		saveErrors(base+".errors.json", []ErrorResponse{{At: time.Now(), Attempt: 1, Item: filepath.Base(base), Error: err.Error()}})
+	} else {
+		log.Printf("Gatilho %s concluído", filepath.Base(path))
+	}
+
+	if err := os.Rename(path, final); err != nil {
+		log.Printf("Erro ao marcar gatilho %s: %v", path, err)
+	}
+}
+
+func runTrigger(ctx context.Context, path, base string, jobs map[string]JobConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var trig TriggerFile
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &trig); err != nil {
+			return fmt.Errorf("gatilho inválido: %w", err)
+		}
+	}
+	return executeTrigger(ctx, jobs, trig, base, nil)
+}
+
+// executeTrigger roda o job pedido por um gatilho (arquivo, HTTP...) e grava
+// as saídas com o prefixo base. rl pode ser nil para usar um limiter novo.
+func executeTrigger(ctx context.Context, jobs map[string]JobConfig, trig TriggerFile, base string, rl *utils.RateLimitClient) error {
+	if trig.Job == "" && len(jobs) == 1 {
+		for name := range jobs {
+			trig.Job = name
+		}
+	}
+
+	job, ok := jobs[trig.Job]
+	if !ok {
+		return fmt.Errorf("job %q não existe", trig.Job)
+	}
+	if m, end := job.maintenanceAt(time.Now()); m != nil && !m.tolerate() {
+		return fmt.Errorf("job %q em %s", job.Name, m.describe(end))
+	}
+	if blocked := wafCooldownFor(job, time.Now()); blocked != nil {
+		return blocked
+	}
+
+	if err := waitExecutionWindow(ctx); err != nil {
+		return err
+	}
+
+	if rl == nil {
+		rl = job.rateClient(job.JobSettings)
+	}
+	defer recordWAFCooldown(job, rl)
+	settings := flagSettings().merge(job.JobSettings)
+	limits := settings.limits()
+	ctx, cancel := limits.withDeadline(ctx)
+	defer cancel()
+
+	vars := templateVars{Params: trig.Params}
+	job, url := job.localize(settings, job.requestURL())
+	job, url = job.versioned(url)
+
+	if len(trig.IDs) > 0 {
+		spec, err := job.render(url, vars)
+		if err != nil {
+			return err
+		}
+		outDir := base + ".results"
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return err
+		}
+		opts := bulkOptionsFromFlags()
+		opts.Limits = limits
+		opts.Status = job.Status
+		opts.ErrorEnvelope = job.ErrorEnvelope
+		opts.Provider = job.usageKey()
+		if errs := runBulk(ctx, rl, spec, trig.IDs, outDir, opts); len(errs) > 0 {
+			saveErrors(base+".errors.json", errs)
+			return fmt.Errorf("%d de %d IDs falharam", len(errs), len(trig.IDs))
+		}
+		return nil
+	}
+
+	job.rejects = &rejectLog{}
+	body, _, err := fetchNonEmpty(ctx, rl, job, url, vars, limits)
+	saveRejects(base+".rejects.ndjson", job.rejects.list())
+	if err != nil {
+		return explain(ctx, err)
+	}
+	writeOutput(base+".response.json", body)
+	return nil
+}
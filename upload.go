@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	sinkTypes["upload"] = newUploadSink
+}
+
+// uploadSink envia o arquivo de saída como body de um PUT (ou do método
+// configurado) para URL. Em jobs de download, o body é enviado enquanto
+// ainda está sendo baixado (ver streamSink).
+type uploadSink struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method"`
+	Headers     map[string]string `json:"headers"`
+	ContentType string            `json:"content_type"`
+	// RunIDHeader é o header com a execução (padrão X-Run-Id; "-" não
+	// envia). No S3, x-amz-meta-run-id vira metadado do objeto.
+	RunIDHeader string `json:"run_id_header"`
+}
+
+func newUploadSink(raw json.RawMessage, baseDir string) (sink, error) {
+	s := &uploadSink{Method: http.MethodPut, RunIDHeader: runIDHeader}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	if s.URL == "" {
+		return nil, fmt.Errorf("upload exige url")
+	}
+	s.Method = strings.ToUpper(s.Method)
+
+	fields, err := renderAll(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	s.URL = fields[0]
+	if s.Headers, err = renderMap(s.Headers); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Deliver envia out.Data ou, sem ela (download), relê o arquivo em
+// out.Path.
+func (s *uploadSink) Deliver(ctx context.Context, out sinkOutput) error {
+	var body io.Reader = bytes.NewReader(out.Data)
+	size := int64(len(out.Data))
+	contentType := "application/json"
+	if out.Data == nil {
+		f, err := os.Open(out.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		body, size = f, info.Size()
+		if contentType = mime.TypeByExtension(filepath.Ext(out.Path)); contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+	return s.send(ctx, out.Job, body, size, contentType)
+}
+
+func (s *uploadSink) Begin(ctx context.Context, job JobConfig, contentType string, size int64) (sinkStream, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := s.send(ctx, job, pr, size, contentType)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	return &uploadStream{pw: pw, done: done}, nil
+}
+
+func (s *uploadSink) send(ctx context.Context, job JobConfig, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, s.Method, s.URL, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if s.ContentType != "" {
+		contentType = s.ContentType
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if s.RunIDHeader != "" && s.RunIDHeader != "-" && job.runID != "" {
+		req.Header.Set(s.RunIDHeader, job.runID)
+	}
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+type uploadStream struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (u *uploadStream) Write(p []byte) (int, error) {
+	return u.pw.Write(p)
+}
+
+func (u *uploadStream) Close() error {
+	u.pw.Close()
+	return <-u.done
+}
+
+func (u *uploadStream) Abort(err error) {
+	u.pw.CloseWithError(err)
+	<-u.done
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+// usageDays é quantos dias de consumo diário ficam no estado: o suficiente
+// para conferir a fatura do mês anterior.
+const usageDays = 62
+
+// providerUsage acumula o consumo de um provider por dia, por mês e desde
+// o início, para reconciliar com a fatura.
+type providerUsage struct {
+	Days   map[string]utils.Usage `json:"days"`
+	Months map[string]utils.Usage `json:"months"`
+	Total  utils.Usage            `json:"total"`
+}
+
+type usageState struct {
+	Providers map[string]*providerUsage `json:"providers"`
+}
+
+var usageMu sync.Mutex
+
+// usageKey agrupa o consumo pelo provider do job ou, sem ele, pelo host da
+// URL.
+func (job JobConfig) usageKey() string {
+	if job.Provider != "" {
+		return job.Provider
+	}
+	if u, err := url.Parse(job.URL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return job.Name
+}
+
+// recordUsage soma o consumo do job ao estado em -state-dir/usage.json.
+func recordUsage(job JobConfig, usage utils.Usage, now time.Time) {
+	if usage == (utils.Usage{}) {
+		return
+	}
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	defer lockState("usage")()
+
+	var st usageState
+	if _, err := stateStore.Load("usage", &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+	if st.Providers == nil {
+		st.Providers = map[string]*providerUsage{}
+	}
+	key := job.usageKey()
+	p := st.Providers[key]
+	if p == nil {
+		p = &providerUsage{Days: map[string]utils.Usage{}, Months: map[string]utils.Usage{}}
+		st.Providers[key] = p
+	}
+
+	day, month := now.Format(time.DateOnly), now.Format("2006-01")
+	d, m := p.Days[day], p.Months[month]
+	d.Add(usage)
+	m.Add(usage)
+	p.Days[day], p.Months[month] = d, m
+	p.Total.Add(usage)
+	p.pruneDays()
+
+	if err := stateStore.Save("usage", st); err != nil {
+		log.Printf("[%s] Erro ao salvar consumo do provider: %v", job.Name, err)
+	}
+}
+
+// pruneDays mantém só os usageDays dias mais recentes; os meses ficam.
+func (p *providerUsage) pruneDays() {
+	if len(p.Days) <= usageDays {
+		return
+	}
+	days := make([]string, 0, len(p.Days))
+	for day := range p.Days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days[:len(days)-usageDays] {
+		delete(p.Days, day)
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const WarningAPIVersion = "api_version"
+
+// VersionCheck compara a versão da API pedida com a que o provedor diz ter
+// usado no Header da resposta, e recolhe os headers Warning que falam de
+// versão (ex: 299 - "API version 2023-01 is deprecated").
+type VersionCheck struct {
+	Want   string
+	Header string
+}
+
+func (rl *RateLimitClient) observeVersion(resp *http.Response) {
+	c := rl.Version
+	if c == nil || rl.Warnings == nil {
+		return
+	}
+	if c.Header != "" {
+		if got := strings.TrimSpace(resp.Header.Get(c.Header)); got != "" && got != c.Want {
+			rl.Warnings.Add(WarningAPIVersion, got,
+				fmt.Sprintf("versão %s da API pedida, mas a resposta veio na %s (%s)", c.Want, got, c.Header))
+		}
+	}
+	for _, w := range resp.Header.Values("Warning") {
+		if strings.Contains(strings.ToLower(w), "version") {
+			rl.Warnings.Add(WarningAPIVersion, w, "aviso do provedor: "+strings.TrimSpace(w))
+		}
+	}
+}
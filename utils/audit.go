@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// readOnlyMethod diz se o método só lê: o que o modo audit deixa passar.
+func readOnlyMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// auditResponse é a resposta de uma requisição que o modo audit não enviou:
+// 200 com body {} e o header X-Audit, sem passar pelo rate limit.
+func auditResponse(req *http.Request) *http.Response {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	fmt.Fprintf(Output, "[audit] não executado: %s %s\n", req.Method, req.URL.Redacted())
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}, "X-Audit": {"noop"}},
+		Body:          io.NopCloser(strings.NewReader("{}")),
+		ContentLength: 2,
+		Request:       req,
+	}
+}
@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthProvider aplica credenciais a cada tentativa. Refresh é chamado
+// quando a API responde 401/403, para renovar o token sem abortar a
+// execução.
+type AuthProvider interface {
+	Apply(req *http.Request) error
+	Refresh(ctx context.Context) error
+}
+
+// TokenSource busca um token novo junto ao provedor de identidade.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// ExpiringTokenSource é o TokenSource que sabe a validade do token (o
+// expires_in do OAuth2), para que ele seja renovado antes de expirar.
+type ExpiringTokenSource interface {
+	TokenSource
+	TokenWithExpiry(ctx context.Context) (string, time.Time, error)
+}
+
+// TokenCache guarda o token entre execuções, para que cada execução não
+// precise autenticar de novo.
+type TokenCache interface {
+	Load() (token string, expiry time.Time, ok bool)
+	Save(token string, expiry time.Time)
+}
+
+// tokenExpirySkew é a antecedência com que um token com validade conhecida
+// é renovado.
+const tokenExpirySkew = time.Minute
+
+// TokenAuth guarda o token obtido de Source e o envia no header
+// configurado (padrão "Authorization: Bearer <token>").
+type TokenAuth struct {
+	Source TokenSource
+	Header string
+	Prefix string
+	Cache  TokenCache
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	loaded bool
+}
+
+func NewTokenAuth(source TokenSource) *TokenAuth {
+	return &TokenAuth{Source: source, Header: "Authorization", Prefix: "Bearer "}
+}
+
+func (a *TokenAuth) Apply(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loaded && a.Cache != nil {
+		a.loaded = true
+		if token, expiry, ok := a.Cache.Load(); ok {
+			a.token, a.expiry = token, expiry
+		}
+	}
+
+	if a.token == "" || a.expiring() {
+		if err := a.fetch(req.Context()); err != nil {
+			return fmt.Errorf("erro ao obter token: %w", err)
+		}
+	}
+
+	req.Header.Set(a.Header, a.Prefix+a.token)
+	return nil
+}
+
+func (a *TokenAuth) expiring() bool {
+	return !a.expiry.IsZero() && time.Now().Add(tokenExpirySkew).After(a.expiry)
+}
+
+// fetch busca o token com a.mu travado.
+func (a *TokenAuth) fetch(ctx context.Context) error {
+	var (
+		token  string
+		expiry time.Time
+		err    error
+	)
+	if src, ok := a.Source.(ExpiringTokenSource); ok {
+		token, expiry, err = src.TokenWithExpiry(ctx)
+	} else {
+		token, err = a.Source.Token(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	a.token, a.expiry = token, expiry
+	if a.Cache != nil {
+		a.Cache.Save(token, expiry)
+	}
+	return nil
+}
+
+func (a *TokenAuth) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.fetch(ctx); err != nil {
+		return fmt.Errorf("erro ao renovar token: %w", err)
+	}
+	return nil
+}
+
+// OAuth2Refresh troca o refresh token por um access token (grant
+// refresh_token). Se o servidor rotacionar o refresh token, o novo passa a
+// ser usado nas próximas renovações e é entregue a Rotated, para que
+// sobreviva à execução.
+type OAuth2Refresh struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	Scope        string
+	Client       *http.Client
+	Rotated      func(refreshToken string)
+
+	mu sync.Mutex
+}
+
+func (o *OAuth2Refresh) Token(ctx context.Context) (string, error) {
+	token, _, err := o.TokenWithExpiry(ctx)
+	return token, err
+}
+
+func (o *OAuth2Refresh) TokenWithExpiry(ctx context.Context) (string, time.Time, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {o.RefreshToken},
+	}
+	out, err := requestOAuth2Token(ctx, o.Client, o.TokenURL, o.ClientID, o.ClientSecret, o.Scope, form)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if out.RefreshToken != "" && out.RefreshToken != o.RefreshToken {
+		o.RefreshToken = out.RefreshToken
+		if o.Rotated != nil {
+			o.Rotated(out.RefreshToken)
+		}
+	}
+	return out.AccessToken, out.expiry(), nil
+}
+
+// OAuth2ClientCredentials obtém tokens de aplicação (grant
+// client_credentials), sem usuário nem refresh token.
+type OAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	Client       *http.Client
+}
+
+func (o *OAuth2ClientCredentials) Token(ctx context.Context) (string, error) {
+	token, _, err := o.TokenWithExpiry(ctx)
+	return token, err
+}
+
+func (o *OAuth2ClientCredentials) TokenWithExpiry(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	out, err := requestOAuth2Token(ctx, o.Client, o.TokenURL, o.ClientID, o.ClientSecret, o.Scope, form)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return out.AccessToken, out.expiry(), nil
+}
+
+type oauth2TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+
+	received time.Time
+}
+
+// expiry é zero quando o servidor não informa expires_in: o token vale até
+// a API responder 401.
+func (r *oauth2TokenResponse) expiry() time.Time {
+	if r.ExpiresIn <= 0 {
+		return time.Time{}
+	}
+	return r.received.Add(time.Duration(r.ExpiresIn) * time.Second)
+}
+
+func requestOAuth2Token(ctx context.Context, client *http.Client, tokenURL, clientID, clientSecret, scope string, form url.Values) (*oauth2TokenResponse, error) {
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	sent := time.Now()
+	data, err := fetchBody(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &oauth2TokenResponse{received: sent}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("resposta do token endpoint inválida: %w", err)
+	}
+	if out.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint não retornou access_token")
+	}
+	return out, nil
+}
+
+// LoginToken executa uma requisição de login e extrai o token da resposta
+// JSON pelo caminho TokenPath.
+type LoginToken struct {
+	Method    string
+	URL       string
+	Headers   map[string]string
+	Body      []byte
+	TokenPath *JSONPath
+	Client    *http.Client
+}
+
+func (l *LoginToken) Token(ctx context.Context) (string, error) {
+	method := l.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.URL, bytes.NewReader(l.Body))
+	if err != nil {
+		return "", err
+	}
+	if len(l.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range l.Headers {
+		req.Header.Set(k, v)
+	}
+
+	data, err := fetchBody(l.Client, req)
+	if err != nil {
+		return "", err
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("resposta do login inválida: %w", err)
+	}
+
+	value, ok := l.TokenPath.First(doc)
+	token, isString := value.(string)
+	if !ok || !isString || token == "" {
+		return "", fmt.Errorf("token não encontrado em %s", l.TokenPath)
+	}
+	return token, nil
+}
+
+func fetchBody(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+)
+
+var avroInvalidName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// AvroName adapta um nome JSON às regras de nomes do Avro.
+func AvroName(s string) string {
+	name := avroInvalidName.ReplaceAllString(s, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+var avroTypes = map[string]string{
+	"int64":   "long",
+	"double":  "double",
+	"boolean": "boolean",
+	"string":  "string",
+	"json":    "string",
+}
+
+// AvroSchema monta um record com um campo por coluna; colunas opcionais
+// viram ["null", tipo] com default null.
+func AvroSchema(name string, cols []Column) ([]byte, error) {
+	fields := make([]map[string]any, 0, len(cols))
+	for _, col := range cols {
+		field := map[string]any{"name": AvroName(col.Name)}
+		if col.Required {
+			field["type"] = avroTypes[col.Type]
+		} else {
+			field["type"] = []any{"null", avroTypes[col.Type]}
+			field["default"] = nil
+		}
+		if AvroName(col.Name) != col.Name {
+			field["doc"] = "campo original: " + col.Name
+		}
+		fields = append(fields, field)
+	}
+
+	return json.Marshal(map[string]any{
+		"type":   "record",
+		"name":   AvroName(name),
+		"fields": fields,
+	})
+}
+
+// ColumnsFromAvroSchema lê um schema de record plano (como os devolvidos
+// pelo schema registry). Só tipos primitivos e uniões ["null", tipo].
+func ColumnsFromAvroSchema(schema []byte) ([]Column, error) {
+	var rec struct {
+		Type   string `json:"type"`
+		Fields []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(schema, &rec); err != nil {
+		return nil, fmt.Errorf("schema Avro inválido: %w", err)
+	}
+	if rec.Type != "record" || len(rec.Fields) == 0 {
+		return nil, fmt.Errorf("schema Avro precisa ser um record com campos")
+	}
+
+	cols := make([]Column, 0, len(rec.Fields))
+	for _, f := range rec.Fields {
+		var (
+			single string
+			union  []string
+		)
+		col := Column{Name: f.Name}
+
+		switch {
+		case json.Unmarshal(f.Type, &single) == nil:
+			col.Required = true
+		case json.Unmarshal(f.Type, &union) == nil && len(union) == 2 && union[0] == "null":
+			single = union[1]
+		default:
+			return nil, fmt.Errorf("campo %s: apenas tipos primitivos ou [\"null\", tipo] são suportados", f.Name)
+		}
+
+		switch single {
+		case "long":
+			col.Type = "int64"
+		case "double":
+			col.Type = "double"
+		case "boolean":
+			col.Type = "boolean"
+		case "string":
+			col.Type = "string"
+		default:
+			return nil, fmt.Errorf("campo %s: tipo %q não suportado (long, double, boolean, string)", f.Name, single)
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// WriteAvro grava um Object Container File com um único bloco. codec:
+// "deflate" (padrão) ou "null". meta entra nos metadados do arquivo.
+func WriteAvro(w io.Writer, schema []byte, cols []Column, records []any, codec string, meta map[string]string) error {
+	if codec == "" {
+		codec = "deflate"
+	}
+	if codec != "deflate" && codec != "null" {
+		return fmt.Errorf("codec Avro %q não suportado (deflate, null)", codec)
+	}
+
+	var block bytes.Buffer
+	for i, rec := range records {
+		obj, ok := rec.(map[string]any)
+		if !ok {
+			return fmt.Errorf("registro %d não é um objeto", i)
+		}
+		for _, col := range cols {
+			if err := encodeAvroValue(&block, col, obj[col.Name]); err != nil {
+				return fmt.Errorf("registro %d: %w", i, err)
+			}
+		}
+	}
+
+	data := block.Bytes()
+	if codec == "deflate" {
+		var buf bytes.Buffer
+		fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		fw.Write(data)
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	sync := make([]byte, 16)
+	rand.Read(sync)
+
+	var out bytes.Buffer
+	out.WriteString("Obj\x01")
+
+	header := map[string]string{"avro.schema": string(schema), "avro.codec": codec}
+	for k, v := range meta {
+		header[k] = v
+	}
+	avroLong(&out, int64(len(header)))
+	for k, v := range header {
+		avroBytes(&out, []byte(k))
+		avroBytes(&out, []byte(v))
+	}
+	avroLong(&out, 0)
+	out.Write(sync)
+
+	if len(records) > 0 {
+		avroLong(&out, int64(len(records)))
+		avroLong(&out, int64(len(data)))
+		out.Write(data)
+		out.Write(sync)
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func encodeAvroValue(buf *bytes.Buffer, col Column, v any) error {
+	if v == nil {
+		if col.Required {
+			return fmt.Errorf("campo %s obrigatório está nulo", col.Name)
+		}
+		avroLong(buf, 0)
+		return nil
+	}
+	if !col.Required {
+		avroLong(buf, 1)
+	}
+
+	switch col.Type {
+	case "int64":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("campo %s: %v não é inteiro", col.Name, v)
+		}
+		avroLong(buf, int64(n))
+	case "double":
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("campo %s: %v não é número", col.Name, v)
+		}
+		buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(n)))
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("campo %s: %v não é booleano", col.Name, v)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("campo %s: %v não é string", col.Name, v)
+		}
+		avroBytes(buf, []byte(s))
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		avroBytes(buf, data)
+	}
+	return nil
+}
+
+func avroLong(buf *bytes.Buffer, v int64) {
+	buf.Write(binary.AppendVarint(nil, v))
+}
+
+func avroBytes(buf *bytes.Buffer, b []byte) {
+	avroLong(buf, int64(len(b)))
+	buf.Write(b)
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter é um token bucket em bytes/s compartilhado por todas as
+// leituras de body, para limitar o consumo total do link.
+type BandwidthLimiter struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewBandwidthLimiter(bytesPerSecond float64) *BandwidthLimiter {
+	return &BandwidthLimiter{rate: bytesPerSecond, tokens: bytesPerSecond, last: time.Now()}
+}
+
+// ParseBandwidth interpreta valores como "5MB/s", "500KB", "1.5GB/s" ou
+// bytes puros. Unidades são binárias (1KB = 1024 bytes).
+func ParseBandwidth(s string) (float64, error) {
+	n, err := parseBytes(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "/S"))
+	if err != nil {
+		return 0, fmt.Errorf("banda inválida %q (ex: 5MB/s)", s)
+	}
+	return n, nil
+}
+
+// ParseSize interpreta tamanhos como "500MB" ou "2GB", com as mesmas
+// unidades de ParseBandwidth.
+func ParseSize(s string) (int64, error) {
+	n, err := parseBytes(strings.ToUpper(strings.TrimSpace(s)))
+	if err != nil {
+		return 0, fmt.Errorf("tamanho inválido %q (ex: 500MB)", s)
+	}
+	return int64(n), nil
+}
+
+func parseBytes(v string) (float64, error) {
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "IB"), "B")
+
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(v, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(v, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(v, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		v = v[:len(v)-1]
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("valor inválido")
+	}
+	return n * mult, nil
+}
+
+func (b *BandwidthLimiter) wait(ctx context.Context, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+	b.tokens -= float64(n)
+
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if d > 0 {
+		return SleepContext(ctx, d)
+	}
+	return nil
+}
+
+// Wrap limita as leituras de body; leituras grandes são fatiadas para o
+// fluxo ficar uniforme.
+func (b *BandwidthLimiter) Wrap(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	chunk := int(b.rate / 10)
+	chunk = max(min(chunk, 32<<10), 512)
+	return &throttledBody{ReadCloser: body, limiter: b, ctx: ctx, chunk: chunk}
+}
+
+type throttledBody struct {
+	io.ReadCloser
+	limiter *BandwidthLimiter
+	ctx     context.Context
+	chunk   int
+}
+
+func (t *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > t.chunk {
+		p = p[:t.chunk]
+	}
+
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := t.limiter.wait(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
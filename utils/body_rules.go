@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+const (
+	BodyActionRetry = "retry"
+	BodyActionFail  = "fail"
+)
+
+// BodyRule classifica uma resposta 2xx pelo conteúdo, para APIs que
+// devolvem 200 com {"error": {"code": "TRY_AGAIN"}}: quando o valor em Path
+// existe (e, com Equals, é um dos valores), Action decide se a resposta é
+// retentada ou vira falha.
+type BodyRule struct {
+	Path   string   `json:"path"`
+	Equals []string `json:"equals,omitempty"`
+	Action string   `json:"action"`
+
+	path *JSONPath
+}
+
+// BodyVerdict é a regra que casou com a resposta.
+type BodyVerdict struct {
+	Action string
+	Path   string
+	Value  string
+}
+
+func (v *BodyVerdict) String() string {
+	return fmt.Sprintf("%s = %s", v.Path, v.Value)
+}
+
+// BodyRuleError é a falha terminal marcada por uma regra de body.
+type BodyRuleError struct {
+	Status  int
+	Verdict *BodyVerdict
+}
+
+func (e *BodyRuleError) Error() string {
+	return fmt.Sprintf("resposta %d marcada como falha pelo body (%s)", e.Status, e.Verdict)
+}
+
+// CompileBodyRules valida as regras e prepara os jsonpaths.
+func CompileBodyRules(rules []BodyRule) ([]BodyRule, error) {
+	out := make([]BodyRule, len(rules))
+	for i, r := range rules {
+		if r.Action != BodyActionRetry && r.Action != BodyActionFail {
+			return nil, fmt.Errorf("regra #%d: action deve ser retry ou fail", i+1)
+		}
+		p, err := ParseJSONPath(r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("regra #%d: %w", i+1, err)
+		}
+		r.path = p
+		out[i] = r
+	}
+	return out, nil
+}
+
+// evaluateBody devolve a primeira regra que casa com o body; bodies que
+// não são JSON não casam com nenhuma.
+func evaluateBody(rules []BodyRule, body []byte) *BodyVerdict {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil
+	}
+	for _, r := range rules {
+		if r.path == nil {
+			continue
+		}
+		v, ok := r.path.First(doc)
+		if !ok || v == nil {
+			continue
+		}
+		value := fmt.Sprint(v)
+		if len(r.Equals) > 0 && !slices.Contains(r.Equals, value) {
+			continue
+		}
+		return &BodyVerdict{Action: r.Action, Path: r.Path, Value: value}
+	}
+	return nil
+}
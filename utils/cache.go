@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type ResponseCache struct {
+	Dir string
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	Method   string    `json:"method"`
+	URL      string    `json:"url"`
+	StoredAt time.Time `json:"stored_at"`
+	Body     []byte    `json:"body"`
+}
+
+func NewResponseCache(dir string, ttl time.Duration) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório de cache: %w", err)
+	}
+	return &ResponseCache{Dir: dir, TTL: ttl}, nil
+}
+
+func CacheKey(method, finalURL string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%x", method, finalURL, bodyHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+func (c *ResponseCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+
+	if c.TTL > 0 && time.Since(entry.StoredAt) > c.TTL {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+
+	return entry.Body, true
+}
+
+func (c *ResponseCache) Put(key, method, finalURL string, body []byte) error {
+	data, err := json.Marshal(cacheEntry{
+		Method:   method,
+		URL:      finalURL,
+		StoredAt: time.Now(),
+		Body:     body,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, "tmp-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, c.path(key))
+}
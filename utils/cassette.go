@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Interaction é uma requisição gravada e a resposta que o Cassette devolve
+// para ela.
+type Interaction struct {
+	Request struct {
+		Method string `json:"method,omitempty"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int               `json:"status,omitempty"`
+		Headers map[string]string `json:"headers,omitempty"`
+		// Body é devolvido como está (JSON); BodyText, para respostas que
+		// não são JSON.
+		Body     json.RawMessage `json:"body,omitempty"`
+		BodyText string          `json:"body_text,omitempty"`
+	} `json:"response"`
+
+	url  *url.URL
+	used int
+}
+
+// Cassette é um http.RoundTripper que responde com interações gravadas, sem
+// tocar a rede. Uma requisição casa com a interação de mesmo método,
+// esquema, host e path cujos parâmetros de query estão todos presentes
+// (parâmetros a mais, como a data do dia, são ignorados). Entre as que
+// casam, vale a primeira ainda não usada, e a última se repete quando todas
+// já foram, então respostas em sequência (429 e depois 200) funcionam.
+type Cassette struct {
+	mu           sync.Mutex
+	interactions []*Interaction
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Interactions []*Interaction `json:"interactions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cassette %s inválido: %w", path, err)
+	}
+	for i, it := range file.Interactions {
+		u, err := url.Parse(it.Request.URL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("cassette %s: interação #%d: url inválida %q", path, i+1, it.Request.URL)
+		}
+		it.url = u
+		if it.Request.Method == "" {
+			it.Request.Method = http.MethodGet
+		}
+		if it.Response.Status == 0 {
+			it.Response.Status = http.StatusOK
+		}
+	}
+	return &Cassette{interactions: file.Interactions}, nil
+}
+
+func (it *Interaction) matches(req *http.Request) bool {
+	if !strings.EqualFold(it.Request.Method, req.Method) {
+		return false
+	}
+	u := req.URL
+	if it.url.Scheme != u.Scheme || it.url.Host != u.Host || strings.TrimSuffix(it.url.Path, "/") != strings.TrimSuffix(u.Path, "/") {
+		return false
+	}
+	q := u.Query()
+	for k, want := range it.url.Query() {
+		got := q[k]
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	c.mu.Lock()
+	var match, last *Interaction
+	for _, it := range c.interactions {
+		if !it.matches(req) {
+			continue
+		}
+		if it.used == 0 {
+			match = it
+			break
+		}
+		last = it
+	}
+	if match == nil {
+		match = last
+	}
+	if match != nil {
+		match.used++
+	}
+	c.mu.Unlock()
+
+	if match == nil {
+		return nil, fmt.Errorf("cassette: nenhuma interação para %s %s", req.Method, req.URL)
+	}
+
+	body := []byte(match.Response.Body)
+	if match.Response.BodyText != "" {
+		body = []byte(match.Response.BodyText)
+	}
+	header := http.Header{}
+	for k, v := range match.Response.Headers {
+		header.Set(k, v)
+	}
+	if header.Get("Content-Type") == "" && len(match.Response.Body) > 0 {
+		header.Set("Content-Type", "application/json")
+	}
+	status := match.Response.Status
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Unused lista as interações que nenhuma requisição usou.
+func (c *Cassette) Unused() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []string
+	for _, it := range c.interactions {
+		if it.used == 0 {
+			out = append(out, it.Request.Method+" "+it.Request.URL)
+		}
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ChaosTransport injeta falhas no caminho do cliente para testar retries,
+// rate limit e failover sem abusar da API real: atrasos aleatórios,
+// conexões derrubadas e respostas sintéticas (429, 500...), cada uma com a
+// sua probabilidade por tentativa.
+type ChaosTransport struct {
+	Base http.RoundTripper
+
+	DelayRate float64
+	MaxDelay  time.Duration
+	DropRate  float64
+	// Statuses são as respostas sintéticas e suas probabilidades.
+	Statuses map[int]float64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// ParseChaos interpreta specs como "delay=0.2:3s,drop=0.05,429=0.1,500=0.05";
+// seed 0 sorteia uma semente.
+func ParseChaos(spec string, seed uint64) (*ChaosTransport, error) {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	c := &ChaosTransport{Statuses: map[int]float64{}, rnd: rand.New(rand.NewPCG(seed, seed))}
+
+	total := 0.0
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("item %q inválido (ex: 429=0.1)", item)
+		}
+		switch name {
+		case "delay":
+			rate, max, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, fmt.Errorf("delay deve ser probabilidade:máximo (ex: delay=0.2:3s)")
+			}
+			d, err := time.ParseDuration(max)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("delay: duração inválida %q", max)
+			}
+			if c.DelayRate, err = parseRate(name, rate); err != nil {
+				return nil, err
+			}
+			c.MaxDelay = d
+		case "drop":
+			p, err := parseRate(name, value)
+			if err != nil {
+				return nil, err
+			}
+			c.DropRate = p
+		default:
+			status, err := strconv.Atoi(name)
+			if err != nil || status < 400 || status > 599 {
+				return nil, fmt.Errorf("%q não é delay, drop nem status 4xx/5xx", name)
+			}
+			p, err := parseRate(name, value)
+			if err != nil {
+				return nil, err
+			}
+			c.Statuses[status] = p
+			total += p
+		}
+	}
+	if total+c.DropRate > 1 {
+		return nil, fmt.Errorf("drop e status somam mais de 1")
+	}
+	return c, nil
+}
+
+func parseRate(name, v string) (float64, error) {
+	p, err := strconv.ParseFloat(v, 64)
+	if err != nil || p < 0 || p > 1 {
+		return 0, fmt.Errorf("%s: probabilidade inválida %q (entre 0 e 1)", name, v)
+	}
+	return p, nil
+}
+
+func (c *ChaosTransport) String() string {
+	parts := []string{}
+	if c.DelayRate > 0 {
+		parts = append(parts, fmt.Sprintf("delay=%g:%v", c.DelayRate, c.MaxDelay))
+	}
+	if c.DropRate > 0 {
+		parts = append(parts, fmt.Sprintf("drop=%g", c.DropRate))
+	}
+	for _, status := range c.statusOrder() {
+		parts = append(parts, fmt.Sprintf("%d=%g", status, c.Statuses[status]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (c *ChaosTransport) statusOrder() []int {
+	statuses := make([]int, 0, len(c.Statuses))
+	for status := range c.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	return statuses
+}
+
+func (c *ChaosTransport) roll() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rnd.Float64()
+}
+
+// Over aplica as mesmas falhas (e o mesmo sorteio) sobre outro transporte.
+func (c *ChaosTransport) Over(base http.RoundTripper) http.RoundTripper {
+	return chaosOver{chaos: c, base: base}
+}
+
+type chaosOver struct {
+	chaos *ChaosTransport
+	base  http.RoundTripper
+}
+
+func (o chaosOver) RoundTrip(req *http.Request) (*http.Response, error) {
+	return o.chaos.roundTrip(req, o.base)
+}
+
+func (c *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return c.roundTrip(req, c.Base)
+}
+
+func (c *ChaosTransport) roundTrip(req *http.Request, base http.RoundTripper) (*http.Response, error) {
+	if c.DelayRate > 0 && c.roll() < c.DelayRate {
+		d := time.Duration(c.roll() * float64(c.MaxDelay))
+		fmt.Fprintf(Output, "Chaos: atrasando %s em %v\n", req.URL.Host, d.Round(time.Millisecond))
+		if err := SleepContext(req.Context(), d); err != nil {
+			return nil, err
+		}
+	}
+
+	// um único sorteio decide entre derrubar, responder com status
+	// sintético ou seguir para o servidor
+	r := c.roll()
+	if r < c.DropRate {
+		closeBody(req)
+		fmt.Fprintf(Output, "Chaos: derrubando conexão com %s\n", req.URL.Host)
+		return nil, fmt.Errorf("chaos: %w", syscall.ECONNRESET)
+	}
+	r -= c.DropRate
+	for _, status := range c.statusOrder() {
+		if r < c.Statuses[status] {
+			closeBody(req)
+			fmt.Fprintf(Output, "Chaos: respondendo %d para %s\n", status, req.URL.Host)
+			return syntheticResponse(req, status), nil
+		}
+		r -= c.Statuses[status]
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func syntheticResponse(req *http.Request, status int) *http.Response {
+	body := fmt.Sprintf(`{"error":{"code":"chaos","message":"resposta %d injetada"}}`, status)
+	header := http.Header{"Content-Type": {"application/json"}}
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		header.Set("Retry-After", "1")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ClockSkew é o relógio das assinaturas (URLSigner, SigV4): o relógio local
+// mais o offset aprendido do header Date de um servidor que recusou a
+// requisição por diferença de horário.
+type ClockSkew struct {
+	offset atomic.Int64
+}
+
+// SigningClock é compartilhado por todos os clientes: o relógio da máquina
+// é um só, e o offset aprendido com um servidor vale para os outros.
+var SigningClock = &ClockSkew{}
+
+func (c *ClockSkew) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+func (c *ClockSkew) Offset() time.Duration {
+	return time.Duration(c.offset.Load())
+}
+
+// minSkew ignora diferenças dentro da precisão do Date (1s) e da latência.
+const minSkew = 2 * time.Second
+
+// skewMarkers são trechos das mensagens de erro de relógio dos provedores
+// mais comuns (AWS, Azure, gateways HMAC).
+var skewMarkers = []string{
+	"skew", "requesttimetooskewed", "clock", "timestamp", "request time",
+	"signature expired", "signature has expired", "expired signature", "request has expired",
+}
+
+// signsWithClock diz se as tentativas levam uma assinatura com horário.
+func (rl *RateLimitClient) signsWithClock() bool {
+	if rl.URLSigner != nil {
+		return true
+	}
+	_, sigv4 := rl.Auth.(*SigV4Auth)
+	return sigv4
+}
+
+// correctSkew reconhece a recusa por relógio (401/403 de uma requisição
+// assinada com menção a skew ou timestamp no body), calcula o offset pelo
+// Date da resposta, ou de um HEAD na mesma URL quando ela não traz um, e
+// diz se vale reassinar. O body é devolvido à resposta.
+func (rl *RateLimitClient) correctSkew(ctx context.Context, req *http.Request, resp *http.Response, sent time.Time) bool {
+	if !rl.signsWithClock() || (resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden) {
+		return false
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	if !mentionsSkew(data) {
+		return false
+	}
+
+	received := time.Now()
+	date := resp.Header.Get("Date")
+	if date == "" {
+		if date = rl.serverDate(ctx, req); date == "" {
+			return false
+		}
+		received = time.Now()
+	}
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return false
+	}
+
+	// o Date corresponde mais ou menos ao meio do caminho
+	local := sent.Add(received.Sub(sent) / 2)
+	offset := server.Sub(local)
+	if d := offset - SigningClock.Offset(); d > -minSkew && d < minSkew {
+		return false
+	}
+	SigningClock.offset.Store(int64(offset))
+	fmt.Fprintf(Output, "%d por diferença de relógio (servidor %+v em relação ao relógio local). Reassinando...\n", resp.StatusCode, offset.Round(time.Second))
+	return true
+}
+
+func mentionsSkew(body []byte) bool {
+	text := strings.ToLower(string(body))
+	for _, m := range skewMarkers {
+		if strings.Contains(text, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// WarningClockSkew é o reset do rate limit fora do razoável ou um relógio
+// local diferente do Date do servidor.
+const WarningClockSkew = "clock_skew"
+
+// maxResetAhead é o reset mais distante aceito: uma cota diária, com folga.
+const maxResetAhead = 25 * time.Hour
+
+// saneReset limita o reset a [agora, agora+25h], avisando quando o corta, e
+// avisa quando o Date da resposta mostra o relógio local fora do servidor
+// (o reset em epoch já foi lido em relação ao Date, ver resetAt).
+func (rl *RateLimitClient) saneReset(resp *http.Response, reset, now time.Time) time.Time {
+	host := ""
+	if resp.Request != nil {
+		host = resp.Request.URL.Host
+	}
+	if server, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		if skew := server.Sub(now); skew > minSkew || skew < -minSkew {
+			rl.Warnings.Add(WarningClockSkew, host+"|date", fmt.Sprintf("relógio local difere de %s em %v; reset calculado pelo Date do servidor", host, (-skew).Round(time.Second)))
+		}
+	}
+
+	switch wait := reset.Sub(now); {
+	case wait < -minSkew:
+		msg := fmt.Sprintf("reset do rate limit de %s há %v, no passado; ignorado", host, (-wait).Round(time.Second))
+		fmt.Fprintln(Output, "AVISO: "+msg)
+		rl.Warnings.Add(WarningClockSkew, host+"|reset", msg)
+		return now
+	case wait > maxResetAhead:
+		msg := fmt.Sprintf("reset do rate limit de %s daqui a %v; limitado a %v", host, wait.Round(time.Second), maxResetAhead)
+		fmt.Fprintln(Output, "AVISO: "+msg)
+		rl.Warnings.Add(WarningClockSkew, host+"|reset", msg)
+		return now.Add(maxResetAhead)
+	}
+	return reset
+}
+
+// serverDate faz um HEAD sem credenciais só para ler o Date do servidor.
+func (rl *RateLimitClient) serverDate(ctx context.Context, req *http.Request) string {
+	head, err := http.NewRequestWithContext(ctx, http.MethodHead, req.URL.String(), nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := rl.Client.Do(head)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Date")
+}
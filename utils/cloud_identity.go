@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// GCPIdentityToken obtém um ID token do metadata server (GCE, GKE, Cloud
+// Run, Cloud Functions) para a audiência informada, como exigido pelo IAP.
+type GCPIdentityToken struct {
+	Audience string
+	Client   *http.Client
+}
+
+func (g *GCPIdentityToken) Token(ctx context.Context) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+
+	endpoint := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/identity?" +
+		url.Values{"audience": {g.Audience}, "format": {"full"}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	data, err := fetchBody(g.Client, req)
+	if err != nil {
+		return "", fmt.Errorf("metadata GCP: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// AzureManagedIdentity obtém um access token da identidade gerenciada do
+// host para o recurso (App ID URI) informado. Usa IDENTITY_ENDPOINT quando
+// presente (App Service/Functions) e o IMDS da VM caso contrário.
+// ClientID seleciona uma identidade atribuída pelo usuário.
+type AzureManagedIdentity struct {
+	Resource string
+	ClientID string
+	Client   *http.Client
+}
+
+func (a *AzureManagedIdentity) Token(ctx context.Context) (string, error) {
+	params := url.Values{"resource": {a.Resource}}
+	if a.ClientID != "" {
+		params.Set("client_id", a.ClientID)
+	}
+
+	endpoint := os.Getenv("IDENTITY_ENDPOINT")
+	header, headerValue := "Metadata", "true"
+	if endpoint != "" {
+		params.Set("api-version", "2019-08-01")
+		header, headerValue = "X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER")
+	} else {
+		endpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+		params.Set("api-version", "2018-02-01")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(header, headerValue)
+
+	data, err := fetchBody(a.Client, req)
+	if err != nil {
+		return "", fmt.Errorf("identidade gerenciada Azure: %w", err)
+	}
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("resposta da identidade gerenciada inválida: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", fmt.Errorf("identidade gerenciada não retornou access_token")
+	}
+	return out.AccessToken, nil
+}
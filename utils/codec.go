@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// Codec comprime um arquivo inteiro. gzip usa a biblioteca padrão; zstd e
+// xz delegam aos binários de mesmo nome, que precisam estar no PATH.
+type Codec struct {
+	Name         string
+	Ext          string
+	DefaultLevel int
+	MinLevel     int
+	MaxLevel     int
+	compress     func(data []byte, level int) ([]byte, error)
+}
+
+var codecs = map[string]Codec{
+	"gzip": {Name: "gzip", Ext: ".gz", DefaultLevel: gzip.DefaultCompression, MinLevel: gzip.HuffmanOnly, MaxLevel: gzip.BestCompression, compress: gzipCompress},
+	"zstd": {Name: "zstd", Ext: ".zst", DefaultLevel: 3, MinLevel: 1, MaxLevel: 22, compress: commandCompress("zstd")},
+	"xz":   {Name: "xz", Ext: ".xz", DefaultLevel: 6, MinLevel: 0, MaxLevel: 9, compress: commandCompress("xz")},
+}
+
+func LookupCodec(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return Codec{}, fmt.Errorf("codec %q não suportado (gzip, zstd, xz)", name)
+	}
+	return c, nil
+}
+
+func (c Codec) Compress(data []byte, level int) ([]byte, error) {
+	if level < c.MinLevel || level > c.MaxLevel {
+		return nil, fmt.Errorf("nível %d inválido para %s (%d a %d)", level, c.Name, c.MinLevel, c.MaxLevel)
+	}
+	return c.compress(data, level)
+}
+
+func gzipCompress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func commandCompress(bin string) func([]byte, int) ([]byte, error) {
+	return func(data []byte, level int) ([]byte, error) {
+		args := []string{"-q", "-c", "-" + strconv.Itoa(level)}
+		if bin == "zstd" && level > 19 {
+			args = append(args, "--ultra")
+		}
+
+		cmd := exec.Command(bin, args...)
+		cmd.Stdin = bytes.NewReader(data)
+
+		var out, stderr bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+				return nil, fmt.Errorf("%s: %w: %s", bin, err, msg)
+			}
+			return nil, fmt.Errorf("%s: %w", bin, err)
+		}
+		return out.Bytes(), nil
+	}
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Column é uma coluna plana dos formatos tabulares (Parquet, Avro, XLSX).
+// Type: int64, double, boolean, string ou json (objetos, arrays e tipos
+// mistos serializados). Required indica que o valor não pode ser nulo.
+type Column struct {
+	Name     string
+	Type     string
+	Required bool
+}
+
+// ColumnsFromSchema deriva as colunas das propriedades de primeiro
+// nível de um JSON Schema (inferido ou fornecido), em ordem alfabética.
+func ColumnsFromSchema(schema map[string]any) ([]Column, error) {
+	if items, ok := schema["items"].(map[string]any); ok {
+		schema = items
+	}
+	props, ok := schema["properties"].(map[string]any)
+	if !ok || len(props) == 0 {
+		return nil, fmt.Errorf("schema sem propriedades: os registros precisam ser objetos")
+	}
+
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cols := make([]Column, 0, len(names))
+	for _, name := range names {
+		prop, _ := props[name].(map[string]any)
+		cols = append(cols, Column{Name: name, Type: columnTypeFor(prop)})
+	}
+	return cols, nil
+}
+
+func columnTypeFor(prop map[string]any) string {
+	var types []string
+	switch t := prop["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+
+	var nonNull []string
+	for _, t := range types {
+		if t != "null" {
+			nonNull = append(nonNull, t)
+		}
+	}
+	if len(nonNull) != 1 {
+		return "json"
+	}
+
+	switch nonNull[0] {
+	case "integer":
+		return "int64"
+	case "number":
+		return "double"
+	case "boolean":
+		return "boolean"
+	case "string":
+		return "string"
+	}
+	return "json"
+}
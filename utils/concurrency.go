@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AIMDController limita o número de requisições simultâneas, aumentando o
+// limite em 1 a cada janela de sucessos e reduzindo-o multiplicativamente
+// quando há erros ou latência acima do alvo.
+type AIMDController struct {
+	Min           int
+	Max           int
+	TargetLatency time.Duration
+	DecreaseRatio float64
+
+	mu        sync.Mutex
+	limit     int
+	inFlight  int
+	successes int
+	changed   chan struct{}
+	lastDrop  time.Time
+
+	// levels é o que cada limite rendeu enquanto esteve em vigor (ver Best)
+	levels     map[int]*levelStats
+	levelSince time.Time
+}
+
+type levelStats struct {
+	ok, failed int
+	elapsed    time.Duration
+}
+
+func NewAIMDController(min, max int, targetLatency time.Duration) *AIMDController {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &AIMDController{
+		Min:           min,
+		Max:           max,
+		TargetLatency: targetLatency,
+		DecreaseRatio: 0.5,
+		limit:         min,
+		changed:       make(chan struct{}),
+		levels:        map[int]*levelStats{},
+		levelSince:    time.Now(),
+	}
+}
+
+// Seed começa o controle em limit (dentro de Min e Max) em vez de Min, como
+// o nível aprendido numa execução anterior.
+func (c *AIMDController) Seed(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLimit(max(c.Min, min(limit, c.Max)))
+}
+
+// Best é o limite que rendeu mais respostas por segundo sem nenhum erro ou
+// resposta lenta, entre os que ficaram em vigor por ao menos uma janela
+// (limit respostas) e um segundo; entre vazões a menos de 5% uma da outra,
+// fica o menor limite. Zero se nenhum.
+func (c *AIMDController) Best() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLimit(c.limit)
+
+	rates := map[int]float64{}
+	top := 0.0
+	for limit, s := range c.levels {
+		if s.failed > 0 || s.ok < limit || s.elapsed < time.Second {
+			continue
+		}
+		rates[limit] = float64(s.ok) / s.elapsed.Seconds()
+		top = max(top, rates[limit])
+	}
+	best := 0
+	for limit, rate := range rates {
+		if rate >= top*0.95 && (best == 0 || limit < best) {
+			best = limit
+		}
+	}
+	return best
+}
+
+// setLimit troca o limite, fechando o tempo do nível anterior.
+func (c *AIMDController) setLimit(next int) {
+	now := time.Now()
+	c.level(c.limit).elapsed += now.Sub(c.levelSince)
+	c.limit, c.levelSince = next, now
+}
+
+func (c *AIMDController) level(limit int) *levelStats {
+	s, ok := c.levels[limit]
+	if !ok {
+		s = &levelStats{}
+		c.levels[limit] = s
+	}
+	return s
+}
+
+func (c *AIMDController) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+func (c *AIMDController) Acquire(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if c.inFlight < c.limit {
+			c.inFlight++
+			c.mu.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release devolve a vaga e alimenta o controle com o resultado observado.
+// overloaded indica 429, 5xx ou timeout da tentativa.
+func (c *AIMDController) Release(latency time.Duration, overloaded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	slow := c.TargetLatency > 0 && latency > c.TargetLatency
+
+	if overloaded || slow {
+		c.level(c.limit).failed++
+		c.successes = 0
+		// evita cortes em cascata das requisições que já estavam em voo
+		if time.Since(c.lastDrop) >= latency {
+			next := int(float64(c.limit) * c.DecreaseRatio)
+			if next < c.Min {
+				next = c.Min
+			}
+			if next != c.limit {
+				fmt.Fprintf(Output, "Concorrência reduzida de %d para %d\n", c.limit, next)
+				c.setLimit(next)
+			}
+			c.lastDrop = time.Now()
+		}
+	} else {
+		c.level(c.limit).ok++
+		c.successes++
+		if c.successes >= c.limit && c.limit < c.Max {
+			c.setLimit(c.limit + 1)
+			c.successes = 0
+			fmt.Fprintf(Output, "Concorrência aumentada para %d\n", c.limit)
+		}
+	}
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
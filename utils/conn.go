@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// connInfo é a conexão usada por uma tentativa, vista pelo httptrace.
+type connInfo struct {
+	got    bool
+	reused bool
+	idle   time.Duration
+	remote string
+}
+
+func (c *connInfo) String() string {
+	switch {
+	case !c.got:
+		return "sem conexão"
+	case c.reused:
+		return fmt.Sprintf("conexão reaproveitada com %s, ociosa há %v", c.remote, c.idle.Round(time.Millisecond))
+	}
+	return "conexão nova com " + c.remote
+}
+
+// traceConn registra em c a conexão que a requisição obtiver.
+func traceConn(req *http.Request, c *connInfo) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.got, c.reused, c.idle = true, info.Reused, info.IdleTime
+			if info.Conn != nil {
+				c.remote = info.Conn.RemoteAddr().String()
+			}
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// freshRetryable diz se a requisição pode ser repetida numa conexão nova
+// depois de falhar numa reaproveitada: só as idempotentes, para não
+// duplicar um POST que o servidor talvez tenha recebido.
+func freshRetryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return readOnlyMethod(req.Method) || req.Header.Get("Idempotency-Key") != ""
+}
+
+func (s *attemptSpan) connection(c *connInfo) {
+	if !c.got {
+		return
+	}
+	s.update(func(r *AttemptTiming) {
+		r.Conn = "new"
+		if c.reused {
+			r.Conn, r.Idle = "reused", c.idle
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Ações de RetryDecision.
+const (
+	DecisionReturn    = "return"
+	DecisionRetry     = "retry"
+	DecisionFail      = "fail"
+	DecisionExhausted = "exhausted"
+)
+
+// Regras de RetryDecision: o que decidiu a ação e a espera.
+const (
+	RuleSuccess        = "success"
+	RuleNotRetried     = "not-retried"
+	RuleRetryAfter     = "retry-after"
+	RuleQuotaReset     = "quota-reset"
+	RuleSafeRate       = "safe-rate"
+	RuleBackoff        = "backoff"
+	RuleMaxRetryAfter  = "max-retry-after"
+	RuleRetryStatus    = "retry-status"
+	RuleFreshConn      = "fresh-connection"
+	RuleNetworkError   = "network-error"
+	RuleAuthRefresh    = "auth-refresh"
+	RuleClockSkew      = "clock-skew"
+	RuleWAFChallenge   = "waf-challenge"
+	RuleHTMLPage       = "html-page"
+	RuleBodyRule       = "body-rule"
+	RuleResponseReject = "response-rejected"
+)
+
+// freeRetryRules repetem a tentativa sem contar em MaxRetries (attempt--):
+// mesmo na última, a decisão é retry de fato.
+var freeRetryRules = map[string]bool{
+	RuleFreshConn:   true,
+	RuleClockSkew:   true,
+	RuleAuthRefresh: true,
+}
+
+// RetryDecision é o que o cliente decidiu depois de uma tentativa: o
+// status (0 sem resposta), o motivo classificado (os de
+// RetryStats.ByReason, ou auth, clock, waf, success, status), a ação, a
+// espera escolhida e a regra que decidiu, para quem embute o pacote mostrar
+// na própria interface por que uma requisição demorou ou falhou.
+type RetryDecision struct {
+	Attempt int           `json:"attempt"`
+	Status  int           `json:"status,omitempty"`
+	Reason  string        `json:"reason"`
+	Error   string        `json:"error,omitempty"`
+	Action  string        `json:"action"`
+	Wait    time.Duration `json:"wait,omitempty"`
+	Rule    string        `json:"rule"`
+	At      time.Time     `json:"at"`
+}
+
+// RetryDecisions guarda as decisões da última requisição feita com o
+// contexto (cada Do recomeça o registro) e as entrega, uma a uma, a OnDecision.
+type RetryDecisions struct {
+	// OnDecision, se definido, recebe cada decisão assim que é tomada, antes
+	// da espera; roda na goroutine da requisição e não deve demorar.
+	OnDecision func(RetryDecision)
+
+	mu        sync.Mutex
+	decisions []RetryDecision
+}
+
+type retryDecisionsKey struct{}
+
+// WithRetryDecisions liga o registro de decisões nas requisições feitas com
+// o contexto devolvido; onDecision pode ser nil.
+func WithRetryDecisions(ctx context.Context, onDecision func(RetryDecision)) (context.Context, *RetryDecisions) {
+	d := &RetryDecisions{OnDecision: onDecision}
+	return context.WithValue(ctx, retryDecisionsKey{}, d), d
+}
+
+func retryDecisionsFrom(ctx context.Context) *RetryDecisions {
+	d, _ := ctx.Value(retryDecisionsKey{}).(*RetryDecisions)
+	return d
+}
+
+// Decisions devolve uma cópia do registro; seguro com nil.
+func (d *RetryDecisions) Decisions() []RetryDecision {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]RetryDecision(nil), d.decisions...)
+}
+
+func (d *RetryDecisions) reset() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.decisions = nil
+	d.mu.Unlock()
+}
+
+func (d *RetryDecisions) record(dec RetryDecision) {
+	if d == nil {
+		return
+	}
+	dec.At = time.Now()
+	d.mu.Lock()
+	d.decisions = append(d.decisions, dec)
+	d.mu.Unlock()
+	if d.OnDecision != nil {
+		d.OnDecision(dec)
+	}
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Decryptor abre o payload cifrado de uma resposta (JWE, PGP...) antes que
+// ele seja validado ou gravado. contentType é o tipo do texto aberto, quando
+// o formato informa.
+type Decryptor interface {
+	Decrypt(ctx context.Context, data []byte) (plain []byte, contentType string, err error)
+}
+
+// DecryptorFactory cria um Decryptor a partir das opções do bloco decrypt
+// do job, para formatos registrados por quem usa a biblioteca.
+type DecryptorFactory func(options map[string]string) (Decryptor, error)
+
+var (
+	decryptMu          sync.RWMutex
+	decryptorFactories = map[string]DecryptorFactory{}
+)
+
+// RegisterDecryptor registra um tipo de decrypt; registrar o mesmo nome
+// duas vezes é erro de programação.
+func RegisterDecryptor(name string, factory DecryptorFactory) {
+	decryptMu.Lock()
+	defer decryptMu.Unlock()
+
+	if _, dup := decryptorFactories[name]; dup {
+		panic(fmt.Sprintf("decrypt %q registrado duas vezes", name))
+	}
+	decryptorFactories[name] = factory
+}
+
+func LookupDecryptor(name string) (DecryptorFactory, bool) {
+	decryptMu.RLock()
+	defer decryptMu.RUnlock()
+
+	f, ok := decryptorFactories[name]
+	return f, ok
+}
+
+// RegisteredDecryptors lista os tipos registrados, em ordem.
+func RegisteredDecryptors() []string {
+	decryptMu.RLock()
+	defer decryptMu.RUnlock()
+
+	names := make([]string, 0, len(decryptorFactories))
+	for name := range decryptorFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CommandDecryptor entrega o payload no stdin de um comando externo e usa
+// o stdout como texto aberto (ex: gpg --batch --decrypt, para PGP).
+type CommandDecryptor struct {
+	Command []string
+}
+
+func (d *CommandDecryptor) Decrypt(ctx context.Context, data []byte) ([]byte, string, error) {
+	cmd := exec.CommandContext(ctx, d.Command[0], d.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("erro ao decifrar com %s: %w: %s", d.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, "", nil
+}
+
+// decryptBody troca o body de uma resposta 2xx pelo texto aberto; falhas
+// não são retentadas, já que a mesma resposta falharia de novo.
+func (rl *RateLimitClient) decryptBody(ctx context.Context, resp *http.Response) error {
+	if rl.Decryptor == nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	plain, contentType, err := rl.Decryptor.Decrypt(ctx, data)
+	if err != nil {
+		return fmt.Errorf("erro ao decifrar resposta: %w", err)
+	}
+	if contentType == "" && json.Valid(plain) {
+		contentType = "application/json"
+	}
+	// o tipo da resposta era o do envelope cifrado (application/jose...)
+	if contentType != "" {
+		resp.Header.Set("Content-Type", contentType)
+	} else {
+		resp.Header.Del("Content-Type")
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(plain)))
+	resp.ContentLength = int64(len(plain))
+	resp.Body = io.NopCloser(bytes.NewReader(plain))
+	return nil
+}
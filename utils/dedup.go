@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SeenKeys registra quando cada chave foi vista pela última vez, para que
+// APIs com janela móvel (ex: últimos 7 dias) só produzam o delta.
+type SeenKeys struct {
+	Keys map[string]time.Time `json:"keys"`
+}
+
+func keyString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// Filter devolve os registros cuja chave ainda não foi vista e marca todas
+// as chaves da execução como vistas em now. Registros sem chave passam.
+func (s *SeenKeys) Filter(records []any, key *JSONPath, now time.Time) []any {
+	if s.Keys == nil {
+		s.Keys = map[string]time.Time{}
+	}
+
+	fresh := make([]any, 0, len(records))
+	batch := map[string]bool{}
+	for _, rec := range records {
+		v, ok := key.First(rec)
+		if !ok {
+			fresh = append(fresh, rec)
+			continue
+		}
+		k := keyString(v)
+		if _, seen := s.Keys[k]; !seen && !batch[k] {
+			fresh = append(fresh, rec)
+		}
+		batch[k] = true
+	}
+
+	for k := range batch {
+		s.Keys[k] = now
+	}
+	return fresh
+}
+
+func (s *SeenKeys) Prune(retention time.Duration, now time.Time) int {
+	removed := 0
+	for k, t := range s.Keys {
+		if now.Sub(t) > retention {
+			delete(s.Keys, k)
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeprecationNotice resume os headers Deprecation (RFC 9745, ou o "true"
+// dos rascunhos) e Sunset (RFC 8594) de uma resposta, com o link de
+// documentação quando o provedor manda Link rel="deprecation"/"sunset".
+type DeprecationNotice struct {
+	URL        string     `json:"url"`
+	Deprecated bool       `json:"deprecated"`
+	Since      *time.Time `json:"deprecated_since,omitempty"`
+	Sunset     *time.Time `json:"sunset,omitempty"`
+	Link       string     `json:"link,omitempty"`
+}
+
+// Key identifica o aviso: o mesmo endpoint com outra data é um aviso novo.
+func (n DeprecationNotice) Key() string {
+	key := n.URL
+	if n.Sunset != nil {
+		key += "|sunset=" + n.Sunset.UTC().Format(time.RFC3339)
+	}
+	if n.Since != nil {
+		key += "|since=" + n.Since.UTC().Format(time.RFC3339)
+	}
+	return key
+}
+
+func (n DeprecationNotice) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "endpoint %s", n.URL)
+	if n.Deprecated {
+		b.WriteString(" descontinuado")
+		if n.Since != nil {
+			fmt.Fprintf(&b, " desde %s", n.Since.Format("2006-01-02"))
+		}
+	}
+	if n.Sunset != nil {
+		days := int(time.Until(*n.Sunset).Hours() / 24)
+		if n.Deprecated {
+			b.WriteString(";")
+		}
+		fmt.Fprintf(&b, " desativação (Sunset) em %s (%d dias)", n.Sunset.Format("2006-01-02"), days)
+	}
+	if n.Link != "" {
+		fmt.Fprintf(&b, "; detalhes: %s", n.Link)
+	}
+	return b.String()
+}
+
+func ParseDeprecation(resp *http.Response) (DeprecationNotice, bool) {
+	var n DeprecationNotice
+	dep := strings.TrimSpace(resp.Header.Get("Deprecation"))
+	sunset := strings.TrimSpace(resp.Header.Get("Sunset"))
+	if dep == "" && sunset == "" {
+		return n, false
+	}
+
+	if resp.Request != nil {
+		u := *resp.Request.URL
+		u.RawQuery, u.User = "", nil
+		n.URL = u.String()
+	}
+
+	switch {
+	case dep == "":
+	case strings.HasPrefix(dep, "@"):
+		if sec, err := strconv.ParseInt(dep[1:], 10, 64); err == nil {
+			t := time.Unix(sec, 0)
+			n.Since = &t
+		}
+		n.Deprecated = true
+	case strings.EqualFold(dep, "false"):
+	default:
+		if t, err := http.ParseTime(dep); err == nil {
+			n.Since = &t
+		}
+		n.Deprecated = true
+	}
+
+	if t, err := http.ParseTime(sunset); err == nil {
+		n.Sunset = &t
+	}
+	n.Link = deprecationLink(resp.Header.Values("Link"))
+
+	return n, n.Deprecated || n.Sunset != nil
+}
+
+func deprecationLink(values []string) string {
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+			params = strings.ReplaceAll(params, " ", "")
+			if ok && (strings.Contains(params, `rel="deprecation"`) || strings.Contains(params, `rel="sunset"`)) {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// DeprecationMonitor avisa uma vez por execução para cada aviso distinto de
+// Deprecation/Sunset; OnNotice permite notificar fora do log.
+type DeprecationMonitor struct {
+	OnNotice func(DeprecationNotice)
+
+	mu      sync.Mutex
+	seen    map[string]bool
+	notices []DeprecationNotice
+}
+
+func NewDeprecationMonitor() *DeprecationMonitor {
+	return &DeprecationMonitor{seen: map[string]bool{}}
+}
+
+func (m *DeprecationMonitor) Observe(resp *http.Response) {
+	notice, ok := ParseDeprecation(resp)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	if m.seen[notice.Key()] {
+		m.mu.Unlock()
+		return
+	}
+	m.seen[notice.Key()] = true
+	m.notices = append(m.notices, notice)
+	m.mu.Unlock()
+
+	fmt.Fprintf(Output, "AVISO: %s\n", notice)
+	if m.OnNotice != nil {
+		m.OnNotice(notice)
+	}
+}
+
+// Notices devolve os avisos distintos vistos até agora.
+func (m *DeprecationMonitor) Notices() []DeprecationNotice {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DeprecationNotice(nil), m.notices...)
+}
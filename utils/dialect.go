@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateDialect descreve como um provedor informa o rate limit. Sem dialeto,
+// valem X-RateLimit-Limit/Remaining/Reset com o reset em epoch.
+type RateDialect struct {
+	Limit     string
+	Remaining string
+	Reset     string
+
+	// ResetDelta indica que Reset traz os segundos até o reset, e não epoch.
+	ResetDelta bool
+
+	// Bucket é um header "usados/limite" de leaky bucket (Shopify), que
+	// esvazia LeakRate requisições por segundo.
+	Bucket   string
+	LeakRate float64
+
+	// ThrottleStatus são status além do 429 que indicam rate limit quando
+	// a cota zerou ou veio Retry-After (o 403 do GitHub).
+	ThrottleStatus []int
+}
+
+var DefaultRateDialect = RateDialect{
+	Limit:     "X-RateLimit-Limit",
+	Remaining: "X-RateLimit-Remaining",
+	Reset:     "X-RateLimit-Reset",
+}
+
+// quota lê limite, restante e reset do header; ok é falso quando a
+// resposta não traz nenhum deles.
+func (d *RateDialect) quota(h http.Header, now time.Time) (limit, remaining int, reset time.Time, ok bool) {
+	if d.Bucket != "" {
+		used, max, found := strings.Cut(h.Get(d.Bucket), "/")
+		u, err1 := strconv.Atoi(strings.TrimSpace(used))
+		m, err2 := strconv.Atoi(strings.TrimSpace(max))
+		if found && err1 == nil && err2 == nil {
+			limit, remaining = m, m-u
+			if remaining <= 0 && d.LeakRate > 0 {
+				reset = now.Add(time.Duration(float64(time.Second) / d.LeakRate))
+			}
+			return limit, remaining, reset, true
+		}
+	}
+
+	limit, remaining = -1, -1
+	if n, err := strconv.Atoi(h.Get(d.Limit)); err == nil {
+		limit, ok = n, true
+	}
+	if n, err := strconv.Atoi(h.Get(d.Remaining)); err == nil {
+		remaining, ok = n, true
+	}
+	if ts, err := strconv.ParseInt(h.Get(d.Reset), 10, 64); err == nil {
+		reset, ok = resetAt(ts, d.ResetDelta, h.Get("Date"), now), true
+	}
+	return limit, remaining, reset, ok
+}
+
+// epochFloor separa segundos até o reset (abaixo) de epoch (acima: depois de
+// 2001); epochMillis, epoch em segundos de epoch em milissegundos.
+const (
+	epochFloor  = 1_000_000_000
+	epochMillis = 100_000_000_000
+)
+
+// resetAt converte o valor do header de reset. Um epoch é lido em relação
+// ao Date da resposta, quando houver: com o relógio local adiantado ou
+// atrasado, o epoch direto daria esperas negativas ou enormes.
+func resetAt(ts int64, delta bool, date string, now time.Time) time.Time {
+	if delta || ts < epochFloor {
+		return now.Add(time.Duration(ts) * time.Second)
+	}
+	at := time.Unix(ts, 0)
+	if ts >= epochMillis {
+		at = time.UnixMilli(ts)
+	}
+	if server, err := http.ParseTime(date); err == nil {
+		return now.Add(at.Sub(server))
+	}
+	return at
+}
+
+// Quota lê limite, restante e reset dos headers como o rate limiter, para
+// quem só observa as respostas (probe-limits); um dialeto nil usa DefaultRateDialect.
+func (d *RateDialect) Quota(h http.Header, now time.Time) (limit, remaining int, reset time.Time, ok bool) {
+	if d == nil {
+		d = &DefaultRateDialect
+	}
+	return d.quota(h, now)
+}
+
+// Throttled diz se a resposta é um rate limit do provedor (429 ou um dos
+// ThrottleStatus); um dialeto nil usa DefaultRateDialect.
+func (d *RateDialect) Throttled(resp *http.Response) bool {
+	if d == nil {
+		d = &DefaultRateDialect
+	}
+	return d.throttled(resp)
+}
+
+func (d *RateDialect) throttled(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	for _, status := range d.ThrottleStatus {
+		if resp.StatusCode != status {
+			continue
+		}
+		if resp.Header.Get("Retry-After") != "" {
+			return true
+		}
+		_, remaining, _, ok := d.quota(resp.Header, time.Now())
+		return ok && remaining == 0
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResetAt(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	epoch := now.Add(10 * time.Minute).Unix()
+	// servidor 5 minutos atrasado em relação ao relógio local
+	behind := now.Add(-5 * time.Minute).Format(http.TimeFormat)
+
+	tests := []struct {
+		name  string
+		ts    int64
+		delta bool
+		date  string
+		want  time.Time
+	}{
+		{"segundos até o reset", 60, false, "", now.Add(time.Minute)},
+		{"zero", 0, false, "", now},
+		{"abaixo do piso de epoch", epochFloor - 1, false, "", now.Add((epochFloor - 1) * time.Second)},
+		{"delta mesmo acima do piso", 1_500_000_000, true, "", now.Add(1_500_000_000 * time.Second)},
+		{"epoch em segundos", epoch, false, "", time.Unix(epoch, 0)},
+		{"epoch em milissegundos", epoch * 1000, false, "", time.Unix(epoch, 0)},
+		{"epoch pelo Date do servidor", epoch, false, behind, now.Add(15 * time.Minute)},
+		{"epoch em ms pelo Date", epoch * 1000, false, behind, now.Add(15 * time.Minute)},
+		{"Date inválido", epoch, false, "ontem", time.Unix(epoch, 0)},
+		{"delta ignora o Date", 60, true, behind, now.Add(time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resetAt(tt.ts, tt.delta, tt.date, now); !got.Equal(tt.want) {
+				t.Errorf("resetAt(%d, %v, %q) = %v; quer %v", tt.ts, tt.delta, tt.date, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Motivos de nova tentativa dos status de RetryStatus.
+const (
+	Retry408 = "408"
+	Retry425 = "425"
+	Retry4xx = "4xx"
+)
+
+// DefaultRetryStatus são os status repetidos por padrão: 408 (o servidor
+// desistiu de esperar a requisição, que não foi processada) e 425 (a
+// requisição chegou em TLS early data e o servidor não quis arriscar um
+// replay).
+var DefaultRetryStatus = []int{http.StatusRequestTimeout, http.StatusTooEarly}
+
+// retriesStatus diz se a resposta é de um status de RetryStatus, que não
+// é throttling: a taxa não é reduzida.
+func (rl *RateLimitClient) retriesStatus(status int) bool {
+	return slices.Contains(rl.RetryStatus, status)
+}
+
+// statusRetry é o motivo, a regra e a espera da nova tentativa depois de um
+// status de RetryStatus: o Retry-After, se vier; sem ele, o 425 é repetido
+// logo, já que a conexão está estabelecida e a nova tentativa não vai em
+// early data, e os demais esperam o backoff.
+func (rl *RateLimitClient) statusRetry(resp *http.Response, attempt int) (reason, rule string, wait time.Duration, err error) {
+	switch resp.StatusCode {
+	case http.StatusTooEarly:
+		rl.disableEarlyData()
+		if resp.Header.Get("Retry-After") == "" {
+			return Retry425, RuleRetryStatus, 0, nil
+		}
+		wait, rule, err := rl.pacer().waitRule(resp, attempt)
+		return Retry425, rule, wait, err
+	case http.StatusRequestTimeout:
+		wait, rule, err := rl.pacer().waitRule(resp, attempt)
+		return Retry408, rule, wait, err
+	}
+	wait, rule, err = rl.pacer().waitRule(resp, attempt)
+	return Retry4xx, rule, wait, err
+}
+
+// disableEarlyData, depois do primeiro 425, mantém as novas tentativas nas
+// conexões já estabelecidas: o cliente não manda early data, mas um proxy
+// ou CDN no meio manda em conexões novas, e FreshRetry deixa de descartar
+// as ociosas.
+func (rl *RateLimitClient) disableEarlyData() {
+	if !rl.noEarlyData.Swap(true) {
+		fmt.Fprintf(Output, "425 Too Early: novas tentativas ficam nas conexões já estabelecidas, sem early data\n")
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Job é a requisição feita por Fetch. Client é o rate limiter a usar, com
+// seus retries, autenticação e ritmo; nil usa um novo NewRateLimitClient.
+// Compartilhe o mesmo Client entre chamadas ao mesmo provedor para que a
+// taxa aprendida e a cota valham para todas.
+type Job struct {
+	Client *RateLimitClient
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+	// Paginate é usado só por Pages.
+	Paginate *Paginate
+}
+
+// Result é a resposta que Fetch decodificou, para quem precisa de mais que
+// o corpo tipado.
+type Result struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	Attempts int64
+	Duration time.Duration
+	// Decisions diz, tentativa a tentativa, por que o cliente repetiu e
+	// quanto esperou. Para tê-las também quando Fetch falha, ou conforme
+	// acontecem, passe um contexto de WithRetryDecisions.
+	Decisions []RetryDecision
+}
+
+// StatusError é a resposta não 2xx que sobrou depois dos retries.
+type StatusError struct {
+	Status int
+	Body   []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.Status, bytes.TrimSpace(e.Body[:min(len(e.Body), 200)]))
+}
+
+// Fetch faz a requisição do job pelo rate limiter e decodifica o JSON da
+// resposta em T. Uma resposta não 2xx volta como *StatusError, com o
+// Result preenchido; um JSON que não cabe em T, como o erro do decoder.
+func Fetch[T any](ctx context.Context, job Job) (T, *Result, error) {
+	var out T
+	rl := job.Client
+	if rl == nil {
+		rl = NewRateLimitClient()
+	}
+	res, err := rl.fetch(ctx, job, job.URL)
+	if err != nil || len(bytes.TrimSpace(res.Body)) == 0 {
+		return out, res, err
+	}
+	if err := json.Unmarshal(res.Body, &out); err != nil {
+		return out, res, fmt.Errorf("resposta de %s não cabe no tipo: %w", job.URL, err)
+	}
+	return out, res, nil
+}
+
+// fetch faz a requisição do job em rawURL e lê a resposta inteira.
+func (rl *RateLimitClient) fetch(ctx context.Context, job Job, rawURL string) (*Result, error) {
+	decisions := retryDecisionsFrom(ctx)
+	if decisions == nil {
+		ctx, decisions = WithRetryDecisions(ctx, nil)
+	}
+	method := job.Method
+	if method == "" {
+		method = http.MethodGet
+		if job.Body != nil {
+			method = http.MethodPost
+		}
+	}
+
+	var body io.Reader
+	if job.Body != nil {
+		body = bytes.NewReader(job.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range job.Header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	started, before := time.Now(), rl.Attempts()
+	resp, err := rl.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{Status: resp.StatusCode, Header: resp.Header, Body: data, Attempts: rl.Attempts() - before, Duration: time.Since(started), Decisions: decisions.Decisions()}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, &StatusError{Status: resp.StatusCode, Body: data}
+	}
+	return res, nil
+}
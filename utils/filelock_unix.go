@@ -0,0 +1,31 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errLockBusy = errors.New("lock ocupado")
+
+// lockFile trava f com flock; o lock vai embora com o Close (ou com o fim
+// do processo).
+func lockFile(f *os.File, wait bool) error {
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return errLockBusy
+		case !errors.Is(err, syscall.EINTR):
+			return err
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RecordFilter é um predicado sobre um registro, como
+// `status == "ACTIVE" && amount > 0`. Campos são caminhos relativos ao
+// registro (customer.id, items[0].sku ou $.x); valores aceitos são strings
+// entre aspas simples ou duplas, números, true, false e null. Operadores:
+// ==, !=, <, <=, >, >=, &&, || e !, com parênteses. Um campo sozinho vale
+// como verdadeiro quando existe e não é false, null, 0 ou "". Comparar tipos
+// diferentes (ou ordenar o que não é número nem string) é falso, e um campo
+// ausente é null.
+type RecordFilter struct {
+	expr string
+	eval func(rec any) any
+}
+
+func (f *RecordFilter) String() string {
+	return f.expr
+}
+
+// CompileFilter analisa a expressão uma vez, para avaliá-la em cada
+// registro.
+func CompileFilter(expr string) (*RecordFilter, error) {
+	toks, err := filterTokens(expr)
+	if err != nil {
+		return nil, fmt.Errorf("filtro %q: %w", expr, err)
+	}
+	p := &filterParser{toks: toks}
+	eval, err := p.or()
+	if err == nil && p.pos < len(p.toks) {
+		err = fmt.Errorf("%q inesperado", p.toks[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filtro %q: %w", expr, err)
+	}
+	return &RecordFilter{expr: expr, eval: eval}, nil
+}
+
+// Match diz se o registro passa no filtro.
+func (f *RecordFilter) Match(rec any) bool {
+	return truthy(f.eval(rec))
+}
+
+// Keep devolve os registros que passam no filtro, no mesmo array.
+func (f *RecordFilter) Keep(records []any) []any {
+	kept := records[:0]
+	for _, rec := range records {
+		if f.Match(rec) {
+			kept = append(kept, rec)
+		}
+	}
+	return kept
+}
+
+type filterToken struct {
+	kind string // op, str, num, ident
+	text string
+}
+
+func filterTokens(expr string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"),
+			strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="),
+			strings.HasPrefix(expr[i:], "<="), strings.HasPrefix(expr[i:], ">="):
+			toks = append(toks, filterToken{"op", expr[i : i+2]})
+			i += 2
+		case strings.ContainsRune("<>!()", rune(c)):
+			toks = append(toks, filterToken{"op", string(c)})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("string sem fechamento")
+			}
+			s := expr[i+1 : end]
+			if c == '"' {
+				var err error
+				if s, err = strconv.Unquote(expr[i : end+1]); err != nil {
+					return nil, fmt.Errorf("string inválida %s", expr[i:end+1])
+				}
+			} else {
+				s = strings.ReplaceAll(strings.ReplaceAll(s, `\'`, `'`), `\\`, `\`)
+			}
+			toks = append(toks, filterToken{"str", s})
+			i = end + 1
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(expr) {
+				ch := expr[end]
+				exponentSign := (ch == '+' || ch == '-') && (expr[end-1] == 'e' || expr[end-1] == 'E')
+				if !exponentSign && !strings.ContainsRune("0123456789.eE", rune(ch)) {
+					break
+				}
+				end++
+			}
+			if _, err := strconv.ParseFloat(expr[i:end], 64); err != nil {
+				return nil, fmt.Errorf("número inválido %q", expr[i:end])
+			}
+			toks = append(toks, filterToken{"num", expr[i:end]})
+			i = end
+		case c == '$' || c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(expr) && !strings.ContainsRune(" \t\n=!<>&|()", rune(expr[end])) {
+				if expr[end] == '[' {
+					close := strings.IndexByte(expr[end:], ']')
+					if close < 0 {
+						return nil, fmt.Errorf("colchete sem fechamento")
+					}
+					end += close
+				}
+				end++
+			}
+			toks = append(toks, filterToken{"ident", expr[i:end]})
+			i = end
+		default:
+			return nil, fmt.Errorf("caractere %q inesperado", c)
+		}
+	}
+	return toks, nil
+}
+
+type filterParser struct {
+	toks []filterToken
+	pos  int
+}
+
+func (p *filterParser) peek(op string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == "op" && p.toks[p.pos].text == op
+}
+
+func (p *filterParser) or() (func(any) any, error) {
+	left, err := p.and()
+	for err == nil && p.peek("||") {
+		p.pos++
+		var right func(any) any
+		if right, err = p.and(); err == nil {
+			l, r := left, right
+			left = func(rec any) any { return truthy(l(rec)) || truthy(r(rec)) }
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) and() (func(any) any, error) {
+	left, err := p.unary()
+	for err == nil && p.peek("&&") {
+		p.pos++
+		var right func(any) any
+		if right, err = p.unary(); err == nil {
+			l, r := left, right
+			left = func(rec any) any { return truthy(l(rec)) && truthy(r(rec)) }
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) unary() (func(any) any, error) {
+	if p.peek("!") {
+		p.pos++
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(rec any) any { return !truthy(inner(rec)) }, nil
+	}
+	return p.comparison()
+}
+
+func (p *filterParser) comparison() (func(any) any, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.peek(op) {
+			continue
+		}
+		p.pos++
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(rec any) any { return compare(op, left(rec), right(rec)) }, nil
+	}
+	return left, nil
+}
+
+func (p *filterParser) operand() (func(any) any, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("expressão incompleta")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	switch t.kind {
+	case "str":
+		return func(any) any { return t.text }, nil
+	case "num":
+		n, _ := strconv.ParseFloat(t.text, 64)
+		return func(any) any { return n }, nil
+	case "ident":
+		switch t.text {
+		case "true", "false":
+			v := t.text == "true"
+			return func(any) any { return v }, nil
+		case "null":
+			return func(any) any { return nil }, nil
+		}
+		path, err := ParseJSONPath(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return func(rec any) any {
+			v, _ := path.First(rec)
+			return v
+		}, nil
+	}
+	if t.text == "(" {
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("parêntese sem fechamento")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return nil, fmt.Errorf("%q inesperado", t.text)
+}
+
+func compare(op string, a, b any) bool {
+	switch op {
+	case "==":
+		return equalValues(a, b)
+	case "!=":
+		return !equalValues(a, b)
+	}
+	var c int
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return false
+		}
+		c = strings.Compare(x, y)
+	default:
+		return false
+	}
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// equalValues compara escalares; objetos e arrays nunca são iguais.
+func equalValues(a, b any) bool {
+	switch a.(type) {
+	case nil, bool, float64, string:
+		return a == b
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type InsufficientSpaceError struct {
+	Dir       string
+	Needed    int64
+	Available int64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("espaço insuficiente em %s: necessário %d bytes, disponível %d", e.Dir, e.Needed, e.Available)
+}
+
+// EnsureSpace falha antes da escrita quando o filesystem de dir não
+// comporta size bytes.
+func EnsureSpace(dir string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	free, err := FreeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("erro ao verificar espaço em %s: %w", dir, err)
+	}
+	if free >= 0 && free < size {
+		return &InsufficientSpaceError{Dir: dir, Needed: size, Available: free}
+	}
+	return nil
+}
+
+// Durability é quanto as escritas atômicas (MoveFile, StateStore.Save)
+// garantem contra queda de energia.
+type Durability string
+
+const (
+	// DurabilityStrict faz fsync do temporário antes do rename e do
+	// diretório depois dele: publicado, o arquivo sobrevive a uma queda.
+	DurabilityStrict Durability = "strict"
+	// DurabilityFast só renomeia: a troca continua atômica para quem lê,
+	// mas uma queda pode perder a última escrita. Para scratch em tmpfs ou
+	// discos descartáveis, onde o fsync só custa tempo.
+	DurabilityFast Durability = "fast"
+)
+
+// FileDurability vale para todas as escritas do processo.
+var FileDurability = DurabilityStrict
+
+func ParseDurability(s string) (Durability, error) {
+	switch d := Durability(s); d {
+	case DurabilityStrict, DurabilityFast:
+		return d, nil
+	}
+	return "", fmt.Errorf("durabilidade desconhecida: %q (use strict ou fast)", s)
+}
+
+// SyncFile faz o fsync de um temporário antes do rename, em DurabilityStrict.
+func SyncFile(f *os.File) error {
+	if FileDurability == DurabilityFast {
+		return nil
+	}
+	return f.Sync()
+}
+
+// SyncDir persiste no diretório a entrada de um rename, em DurabilityStrict.
+func SyncDir(dir string) error {
+	if FileDurability == DurabilityFast {
+		return nil
+	}
+	return syncDir(dir)
+}
+
+// MoveFile renomeia src para dst e sincroniza o diretório de destino. Se
+// estiverem em filesystems diferentes, copia para um temporário no
+// diretório de dst, faz fsync e renomeia lá, mantendo a troca atômica para
+// quem lê dst; src só é removido depois disso.
+func MoveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err != nil && isCrossDevice(err) {
+		err = copyAndRename(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	return SyncDir(filepath.Dir(dst))
+}
+
+func copyAndRename(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := EnsureSpace(filepath.Dir(dst), info.Size()); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "tmp-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := SyncFile(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpName, dst); err != nil {
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+// syncDir persiste a entrada criada pelo rename, sem a qual uma queda de
+// energia pode desfazer a troca mesmo com o conteúdo já sincronizado.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package utils
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ERROR_NOT_SAME_DEVICE: MoveFileEx sem MOVEFILE_COPY_ALLOWED entre volumes.
+const errNotSameDevice = syscall.Errno(17)
+
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errNotSameDevice) || errors.Is(err, syscall.EXDEV)
+}
+
+// syncDir não se aplica: no Windows não dá para abrir diretórios para fsync
+// e o rename já é durável no NTFS.
+func syncDir(dir string) error {
+	return nil
+}
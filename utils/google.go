@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GoogleServiceAccount obtém access tokens com a chave de uma conta de
+// serviço (grant jwt-bearer), sem interação do usuário.
+type GoogleServiceAccount struct {
+	Email    string
+	TokenURI string
+	Scopes   []string
+	Client   *http.Client
+
+	key *rsa.PrivateKey
+}
+
+// LoadGoogleServiceAccount lê o JSON de credenciais baixado do console.
+func LoadGoogleServiceAccount(path string, scopes ...string) (*GoogleServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler credenciais: %w", err)
+	}
+
+	var creds struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("credenciais inválidas: %w", err)
+	}
+	if creds.Type != "service_account" || creds.ClientEmail == "" || creds.PrivateKey == "" {
+		return nil, fmt.Errorf("%s não é uma chave de conta de serviço", path)
+	}
+
+	key, err := parseRSAKey(creds.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenURI := creds.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	return &GoogleServiceAccount{Email: creds.ClientEmail, TokenURI: tokenURI, Scopes: scopes, key: key}, nil
+}
+
+func parseRSAKey(text string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return nil, fmt.Errorf("private_key não está em PEM")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("private_key inválida: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private_key não é RSA")
+	}
+	return key, nil
+}
+
+func (g *GoogleServiceAccount) Token(ctx context.Context) (string, error) {
+	assertion, err := g.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	data, err := fetchBody(g.Client, req)
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil || out.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint não retornou access_token")
+	}
+	return out.AccessToken, nil
+}
+
+// assertion monta o JWT RS256 assinado com a chave da conta de serviço.
+func (g *GoogleServiceAccount) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   g.Email,
+		"scope": strings.Join(g.Scopes, " "),
+		"aud":   g.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, g.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HeaderTimeoutError é a tentativa cujos headers não chegaram em
+// HeaderTimeout.
+type HeaderTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *HeaderTimeoutError) Error() string {
+	return fmt.Sprintf("resposta sem headers em %v (header timeout)", e.Timeout)
+}
+
+// timedOut diz se a tentativa falhou pelo prazo dela (HeaderTimeout ou
+// AttemptTimeout), e não porque quem chamou desistiu.
+func timedOut(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var headerTimeout *HeaderTimeoutError
+	return errors.As(err, &headerTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// headerTimeoutCause é o HeaderTimeoutError que cancelou ctx, se foi ele.
+func headerTimeoutCause(ctx context.Context) *HeaderTimeoutError {
+	var headerTimeout *HeaderTimeoutError
+	if errors.As(context.Cause(ctx), &headerTimeout) {
+		return headerTimeout
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// histogramSubBuckets divide cada potência de 2 (em microssegundos) em
+// faixas lineares, como no HDR Histogram: o erro relativo fica abaixo de
+// 1/16 em qualquer escala, de microssegundos a minutos.
+const histogramSubBuckets = 16
+
+// LatencyHistogram conta tempos de resposta em faixas log-lineares; é
+// pequeno o bastante para ser guardado por hora no estado.
+type LatencyHistogram struct {
+	Counts map[int]int64 `json:"counts"`
+	Total  int64         `json:"total"`
+}
+
+func histogramIndex(d time.Duration) int {
+	v := uint64(max(d.Microseconds(), 0))
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - 1
+	sub := int(v>>(exp-4)) - histogramSubBuckets
+	return histogramSubBuckets + (exp-4)*histogramSubBuckets + sub
+}
+
+// histogramUpper é o limite superior da faixa.
+func histogramUpper(idx int) time.Duration {
+	next := idx + 1
+	if next <= histogramSubBuckets {
+		return time.Duration(next) * time.Microsecond
+	}
+	e := (next-histogramSubBuckets)/histogramSubBuckets + 4
+	s := (next - histogramSubBuckets) % histogramSubBuckets
+	return time.Duration(uint64(histogramSubBuckets+s)<<(e-4)) * time.Microsecond
+}
+
+func (h *LatencyHistogram) Record(d time.Duration) {
+	if h.Counts == nil {
+		h.Counts = map[int]int64{}
+	}
+	h.Counts[histogramIndex(d)]++
+	h.Total++
+}
+
+func (h *LatencyHistogram) Merge(o *LatencyHistogram) {
+	if o == nil {
+		return
+	}
+	if h.Counts == nil {
+		h.Counts = map[int]int64{}
+	}
+	for idx, n := range o.Counts {
+		h.Counts[idx] += n
+	}
+	h.Total += o.Total
+}
+
+// Quantile devolve o limite superior da faixa onde cai o quantil q (0 a
+// 1); Quantile(1) é o máximo.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Total == 0 {
+		return 0
+	}
+	idxs := make([]int, 0, len(h.Counts))
+	for idx := range h.Counts {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+
+	target := int64(q*float64(h.Total) + 0.5)
+	target = min(max(target, 1), h.Total)
+	var seen int64
+	for _, idx := range idxs {
+		seen += h.Counts[idx]
+		if seen >= target {
+			return histogramUpper(idx)
+		}
+	}
+	return histogramUpper(idxs[len(idxs)-1])
+}
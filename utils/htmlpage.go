@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	HTMLPagesRetry = "retry"
+	HTMLPagesFail  = "fail"
+	HTMLPagesAllow = "allow"
+
+	HTMLPageLogin     = "login"
+	HTMLPageChallenge = "challenge"
+	HTMLPageOther     = "error"
+)
+
+// htmlSniffSize é quanto do body é lido para reconhecer e classificar a
+// página.
+const htmlSniffSize = 64 << 10
+
+// HTMLPageError é a resposta 2xx que, em vez de JSON, trouxe uma página
+// HTML: tela de login (sessão ou SSO expirado), desafio de WAF/CDN ou outra
+// página de erro.
+type HTMLPageError struct {
+	Status int
+	Kind   string
+	Title  string
+}
+
+func (e *HTMLPageError) Error() string {
+	msg := fmt.Sprintf("resposta %d é uma página HTML (%s) em vez de JSON", e.Status, e.describe())
+	if e.Title != "" {
+		msg += ": " + e.Title
+	}
+	return msg
+}
+
+func (e *HTMLPageError) describe() string {
+	switch e.Kind {
+	case HTMLPageLogin:
+		return "tela de login"
+	case HTMLPageChallenge:
+		return "desafio de WAF"
+	}
+	return "página de erro"
+}
+
+var (
+	htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	challengeMarkers = []string{
+		"cf-chl", "challenge-platform", "cf-browser-verification", "attention required",
+		"captcha", "_incapsula_resource", "px-captcha", "ddos-guard", "request rejected",
+		"access denied", "request blocked", "web application firewall",
+	}
+	loginMarkers = []string{
+		`type="password"`, `type='password'`, "type=password", "samlrequest",
+		"/oauth2/authorize", "/oauth/authorize", "/saml2/", "/adfs/ls",
+	}
+)
+
+// DetectHTMLPage diz se o início do body é uma página HTML e qual o tipo.
+func DetectHTMLPage(header http.Header, prefix []byte) (kind, title string, ok bool) {
+	doc := bytes.ToLower(bytes.TrimLeft(bytes.TrimPrefix(prefix, []byte("\xef\xbb\xbf")), " \t\r\n"))
+	if !bytes.HasPrefix(doc, []byte("<!doctype html")) && !bytes.HasPrefix(doc, []byte("<html")) &&
+		!(bytes.HasPrefix(doc, []byte("<!--")) && bytes.Contains(doc[:min(len(doc), 1024)], []byte("<html"))) {
+		return "", "", false
+	}
+
+	if m := htmlTitle.FindSubmatch(prefix); m != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+		if r := []rune(title); len(r) > 80 {
+			title = string(r[:80]) + "…"
+		}
+	}
+
+	text := string(doc)
+	switch {
+	case header.Get("Cf-Mitigated") != "" || containsAny(text, challengeMarkers):
+		kind = HTMLPageChallenge
+	case containsAny(text, loginMarkers):
+		kind = HTMLPageLogin
+	default:
+		kind = HTMLPageOther
+	}
+	return kind, title, true
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectHTMLPage confere o início de uma resposta 2xx; o body é devolvido
+// inteiro a quem chamou.
+func (rl *RateLimitClient) detectHTMLPage(resp *http.Response) (*HTMLPageError, error) {
+	if rl.HTMLPages == HTMLPagesAllow || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil
+	}
+	// só bodies que se dizem HTML, JSON ou texto; XML e binários passam
+	if ct := strings.ToLower(resp.Header.Get("Content-Type")); ct != "" &&
+		!strings.Contains(ct, "html") && !strings.Contains(ct, "json") && !strings.HasPrefix(ct, "text/plain") {
+		return nil, nil
+	}
+	prefix, err := io.ReadAll(io.LimitReader(resp.Body, htmlSniffSize))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+
+	kind, title, ok := DetectHTMLPage(resp.Header, prefix)
+	if !ok {
+		return nil, nil
+	}
+	return &HTMLPageError{Status: resp.StatusCode, Kind: kind, Title: title}, nil
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const maxSchemaExamples = 3
+
+// SchemaInferrer acumula observações de um ou mais documentos e gera um JSON
+// Schema com tipos, nulabilidade, campos obrigatórios e exemplos.
+type SchemaInferrer struct {
+	root *inferNode
+}
+
+type inferNode struct {
+	seen     int
+	types    map[string]int
+	examples []any
+	objects  int
+	props    map[string]*inferNode
+	order    []string
+	items    *inferNode
+}
+
+type FieldReport struct {
+	Path     string
+	Types    []string
+	Nullable bool
+	Presence float64
+	Example  string
+}
+
+func NewSchemaInferrer() *SchemaInferrer {
+	return &SchemaInferrer{root: newInferNode()}
+}
+
+func newInferNode() *inferNode {
+	return &inferNode{types: map[string]int{}}
+}
+
+func (si *SchemaInferrer) Observe(doc any) {
+	si.root.observe(doc)
+}
+
+func (n *inferNode) observe(v any) {
+	n.seen++
+	t := jsonTypeName(v)
+	n.types[t]++
+
+	switch val := v.(type) {
+	case map[string]any:
+		n.objects++
+		if n.props == nil {
+			n.props = map[string]*inferNode{}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child, ok := n.props[k]
+			if !ok {
+				child = newInferNode()
+				n.props[k] = child
+				n.order = append(n.order, k)
+			}
+			child.observe(val[k])
+		}
+	case []any:
+		if n.items == nil {
+			n.items = newInferNode()
+		}
+		for _, item := range val {
+			n.items.observe(item)
+		}
+	case nil:
+	default:
+		if len(n.examples) < maxSchemaExamples && !containsExample(n.examples, val) {
+			n.examples = append(n.examples, val)
+		}
+	}
+}
+
+func containsExample(list []any, v any) bool {
+	for _, e := range list {
+		if jsonEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *inferNode) typeNames() []string {
+	var names []string
+	for t := range n.types {
+		if t == "null" {
+			continue
+		}
+		names = append(names, t)
+	}
+	// inteiro e número juntos viram apenas number
+	if n.types["integer"] > 0 && n.types["number"] > 0 {
+		filtered := names[:0]
+		for _, t := range names {
+			if t != "integer" {
+				filtered = append(filtered, t)
+			}
+		}
+		names = filtered
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (si *SchemaInferrer) Schema() map[string]any {
+	s := si.root.schema()
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return s
+}
+
+func (n *inferNode) schema() map[string]any {
+	out := map[string]any{}
+
+	types := n.typeNames()
+	nullable := n.types["null"] > 0
+	switch {
+	case len(types) == 1 && !nullable:
+		out["type"] = types[0]
+	case len(types) > 0:
+		list := make([]any, 0, len(types)+1)
+		for _, t := range types {
+			list = append(list, t)
+		}
+		if nullable {
+			list = append(list, "null")
+		}
+		out["type"] = list
+	case nullable:
+		out["type"] = "null"
+	}
+
+	if n.props != nil {
+		props := map[string]any{}
+		var required []any
+		for _, k := range n.order {
+			child := n.props[k]
+			props[k] = child.schema()
+			if child.seen == n.objects {
+				required = append(required, k)
+			}
+		}
+		out["properties"] = props
+		if len(required) > 0 {
+			out["required"] = required
+		}
+	}
+
+	if n.items != nil && n.items.seen > 0 {
+		out["items"] = n.items.schema()
+	}
+
+	if len(n.examples) > 0 {
+		out["examples"] = n.examples
+	}
+	return out
+}
+
+func (si *SchemaInferrer) Report() []FieldReport {
+	var out []FieldReport
+	si.root.report("$", 0, &out)
+	return out
+}
+
+func (n *inferNode) report(path string, parentObjects int, out *[]FieldReport) {
+	presence := 1.0
+	if parentObjects > 0 {
+		presence = float64(n.seen) / float64(parentObjects)
+	}
+
+	example := ""
+	if len(n.examples) > 0 {
+		example = fmt.Sprint(n.examples[0])
+		if len(example) > 40 {
+			example = example[:37] + "..."
+		}
+	}
+
+	*out = append(*out, FieldReport{
+		Path:     path,
+		Types:    n.typeNames(),
+		Nullable: n.types["null"] > 0,
+		Presence: presence,
+		Example:  example,
+	})
+
+	for _, k := range n.order {
+		name := "." + k
+		if strings.ContainsAny(k, ".[] ") {
+			name = "['" + k + "']"
+		}
+		n.props[k].report(path+name, n.objects, out)
+	}
+	if n.items != nil && n.items.seen > 0 {
+		n.items.report(path+"[*]", 0, out)
+	}
+}
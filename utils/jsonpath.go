@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath implementa o subconjunto usado nas configurações:
+// $.a.b, $.a[0], $.a[*].b, $['chave com espaço'] e $.a.*
+type JSONPath struct {
+	raw   string
+	steps []pathStep
+}
+
+type pathStep struct {
+	key      string
+	index    int
+	wildcard bool
+	isIndex  bool
+}
+
+func ParseJSONPath(path string) (*JSONPath, error) {
+	p := &JSONPath{raw: path}
+	s := strings.TrimSpace(path)
+	s = strings.TrimPrefix(s, "$")
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("jsonpath inválido %q: nome vazio", path)
+			}
+			if name == "*" {
+				p.steps = append(p.steps, pathStep{wildcard: true})
+			} else {
+				p.steps = append(p.steps, pathStep{key: name})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath inválido %q: colchete sem fechamento", path)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+
+			switch {
+			case inner == "*":
+				p.steps = append(p.steps, pathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"'):
+				p.steps = append(p.steps, pathStep{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath inválido %q: índice %q", path, inner)
+				}
+				p.steps = append(p.steps, pathStep{index: n, isIndex: true})
+			}
+		default:
+			if len(p.steps) == 0 {
+				s = "." + s
+				continue
+			}
+			return nil, fmt.Errorf("jsonpath inválido %q", path)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *JSONPath) String() string {
+	return p.raw
+}
+
+// Keys devolve as chaves de um caminho feito só de nomes ($.a.b); ok é
+// false quando há índice ou curinga.
+func (p *JSONPath) Keys() (keys []string, ok bool) {
+	for _, step := range p.steps {
+		if step.wildcard || step.isIndex {
+			return nil, false
+		}
+		keys = append(keys, step.key)
+	}
+	return keys, true
+}
+
+func (p *JSONPath) Find(doc any) []any {
+	current := []any{doc}
+
+	for _, step := range p.steps {
+		var next []any
+		for _, node := range current {
+			switch v := node.(type) {
+			case map[string]any:
+				if step.wildcard {
+					for _, child := range v {
+						next = append(next, child)
+					}
+				} else if !step.isIndex {
+					if child, ok := v[step.key]; ok {
+						next = append(next, child)
+					}
+				}
+			case []any:
+				if step.wildcard {
+					next = append(next, v...)
+				} else if step.isIndex {
+					i := step.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+		current = next
+	}
+
+	return current
+}
+
+func (p *JSONPath) First(doc any) (any, bool) {
+	found := p.Find(doc)
+	if len(found) == 0 {
+		return nil, false
+	}
+	return found[0], true
+}
+
+// Set substitui o valor no caminho e devolve o documento (que muda quando o
+// caminho é a raiz). Só aceita caminhos sem curinga que já existam.
+func (p *JSONPath) Set(doc any, value any) (any, error) {
+	if len(p.steps) == 0 {
+		return value, nil
+	}
+
+	node := doc
+	for i, step := range p.steps {
+		if step.wildcard {
+			return nil, fmt.Errorf("jsonpath %s: curinga não suportado", p.raw)
+		}
+		last := i == len(p.steps)-1
+
+		switch v := node.(type) {
+		case map[string]any:
+			if step.isIndex {
+				return nil, fmt.Errorf("jsonpath %s: índice em objeto", p.raw)
+			}
+			if last {
+				v[step.key] = value
+				return doc, nil
+			}
+			child, ok := v[step.key]
+			if !ok {
+				return nil, fmt.Errorf("jsonpath %s: %q não encontrado", p.raw, step.key)
+			}
+			node = child
+		case []any:
+			idx := step.index
+			if idx < 0 {
+				idx += len(v)
+			}
+			if !step.isIndex || idx < 0 || idx >= len(v) {
+				return nil, fmt.Errorf("jsonpath %s: índice fora do array", p.raw)
+			}
+			if last {
+				v[idx] = value
+				return doc, nil
+			}
+			node = v[idx]
+		default:
+			return nil, fmt.Errorf("jsonpath %s: caminho não encontrado", p.raw)
+		}
+	}
+	return doc, nil
+}
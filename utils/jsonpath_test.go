@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{
+		"data": {"items": [{"id": 1}, {"id": 2}, {"id": 3}]},
+		"chave com espaço": "ok",
+		"meta": {"a": 1}
+	}`), &doc)
+
+	tests := []struct {
+		path    string
+		want    []any
+		wantErr bool
+	}{
+		{path: "$", want: []any{doc}},
+		{path: "$.meta.a", want: []any{1.0}},
+		{path: "meta.a", want: []any{1.0}},
+		{path: "$.data.items[0].id", want: []any{1.0}},
+		{path: "$.data.items[-1].id", want: []any{3.0}},
+		{path: "$.data.items[*].id", want: []any{1.0, 2.0, 3.0}},
+		{path: "$.data.items.*.id", want: []any{1.0, 2.0, 3.0}},
+		{path: "$['chave com espaço']", want: []any{"ok"}},
+		{path: `$["meta"].a`, want: []any{1.0}},
+		{path: "$.data.items[9]", want: nil},
+		{path: "$.nada.id", want: nil},
+		{path: "$.meta[0]", want: nil},
+		{path: "$..a", wantErr: true},
+		{path: "$.data[0", wantErr: true},
+		{path: "$.data[x]", wantErr: true},
+		{path: "$.data[0]x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := ParseJSONPath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseJSONPath(%q): quer erro", tt.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJSONPath(%q): %v", tt.path, err)
+			}
+			if got := p.Find(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q) = %v; quer %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
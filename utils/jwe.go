@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// JWEDecryptor abre JWE na serialização compacta (RFC 7516). A chave é uma
+// chave privada RSA em PEM (alg RSA-OAEP e RSA-OAEP-256) ou uma chave
+// simétrica em base64 (A128KW, A192KW, A256KW e dir); enc pode ser
+// A128GCM, A192GCM, A256GCM, A128CBC-HS256, A192CBC-HS384 ou
+// A256CBC-HS512, com "zip": "DEF" opcional.
+type JWEDecryptor struct {
+	rsaKey *rsa.PrivateKey
+	secret []byte
+}
+
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Zip string `json:"zip"`
+	Cty string `json:"cty"`
+}
+
+func NewJWEDecryptor(key []byte) (*JWEDecryptor, error) {
+	key = bytes.TrimSpace(key)
+	if block, _ := pem.Decode(key); block != nil {
+		priv, err := parseRSAPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &JWEDecryptor{rsaKey: priv}, nil
+	}
+	secret, err := decodeBase64Key(string(key))
+	if err != nil {
+		return nil, fmt.Errorf("chave deve ser PEM (RSA) ou base64 (simétrica): %w", err)
+	}
+	return &JWEDecryptor{secret: secret}, nil
+}
+
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if priv, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return priv, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("chave privada inválida: %w", err)
+	}
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("chave privada não é RSA")
+	}
+	return priv, nil
+}
+
+func decodeBase64Key(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.StdEncoding, base64.RawStdEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, errors.New("base64 inválido")
+}
+
+func (d *JWEDecryptor) Decrypt(ctx context.Context, data []byte) ([]byte, string, error) {
+	parts := strings.Split(strings.TrimSpace(string(data)), ".")
+	if len(parts) != 5 {
+		return nil, "", fmt.Errorf("JWE compacto deve ter 5 partes, veio com %d", len(parts))
+	}
+	var raw [5][]byte
+	for i, p := range parts {
+		b, err := base64.RawURLEncoding.DecodeString(p)
+		if err != nil {
+			return nil, "", fmt.Errorf("JWE: parte %d inválida: %w", i+1, err)
+		}
+		raw[i] = b
+	}
+	var h jweHeader
+	if err := json.Unmarshal(raw[0], &h); err != nil {
+		return nil, "", fmt.Errorf("JWE: header inválido: %w", err)
+	}
+
+	cek, err := d.contentKey(h.Alg, raw[1])
+	if err != nil {
+		return nil, "", err
+	}
+	// o AAD é o header protegido como veio, em base64url
+	plain, err := decryptContent(h.Enc, cek, raw[2], raw[3], raw[4], []byte(parts[0]))
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch h.Zip {
+	case "":
+	case "DEF":
+		if plain, err = io.ReadAll(flate.NewReader(bytes.NewReader(plain))); err != nil {
+			return nil, "", fmt.Errorf("JWE: zip DEF inválido: %w", err)
+		}
+	default:
+		return nil, "", fmt.Errorf("JWE: zip %q não suportado", h.Zip)
+	}
+
+	contentType := h.Cty
+	if contentType != "" && !strings.Contains(contentType, "/") {
+		contentType = "application/" + contentType
+	}
+	return plain, contentType, nil
+}
+
+func (d *JWEDecryptor) contentKey(alg string, encrypted []byte) ([]byte, error) {
+	switch alg {
+	case "RSA-OAEP", "RSA-OAEP-256":
+		if d.rsaKey == nil {
+			return nil, fmt.Errorf("JWE: alg %s exige chave privada RSA", alg)
+		}
+		var h hash.Hash = sha1.New()
+		if alg == "RSA-OAEP-256" {
+			h = sha256.New()
+		}
+		cek, err := rsa.DecryptOAEP(h, nil, d.rsaKey, encrypted, nil)
+		if err != nil {
+			return nil, fmt.Errorf("JWE: chave de conteúdo não abre com a chave RSA: %w", err)
+		}
+		return cek, nil
+	case "A128KW", "A192KW", "A256KW":
+		if want := map[string]int{"A128KW": 16, "A192KW": 24, "A256KW": 32}[alg]; len(d.secret) != want {
+			return nil, fmt.Errorf("JWE: alg %s exige chave simétrica de %d bytes", alg, want)
+		}
+		return aesKeyUnwrap(d.secret, encrypted)
+	case "dir":
+		if d.secret == nil || len(encrypted) != 0 {
+			return nil, fmt.Errorf("JWE: alg dir exige chave simétrica e chave cifrada vazia")
+		}
+		return d.secret, nil
+	}
+	return nil, fmt.Errorf("JWE: alg %q não suportado", alg)
+}
+
+func decryptContent(enc string, cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	switch enc {
+	case "A128GCM", "A192GCM", "A256GCM":
+		if want := map[string]int{"A128GCM": 16, "A192GCM": 24, "A256GCM": 32}[enc]; len(cek) != want {
+			return nil, fmt.Errorf("JWE: enc %s exige chave de %d bytes", enc, want)
+		}
+		block, err := aes.NewCipher(cek)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+		if err != nil {
+			return nil, err
+		}
+		plain, err := gcm.Open(nil, iv, append(append([]byte{}, ciphertext...), tag...), aad)
+		if err != nil {
+			return nil, fmt.Errorf("JWE: falha na autenticação do conteúdo")
+		}
+		return plain, nil
+	case "A128CBC-HS256", "A192CBC-HS384", "A256CBC-HS512":
+		return decryptCBCHMAC(enc, cek, iv, ciphertext, tag, aad)
+	}
+	return nil, fmt.Errorf("JWE: enc %q não suportado", enc)
+}
+
+// decryptCBCHMAC segue a RFC 7518 §5.2: metade da chave autentica (HMAC de
+// AAD || IV || cifra || tamanho do AAD em bits), a outra metade decifra.
+func decryptCBCHMAC(enc string, cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	sizes := map[string]struct {
+		key int
+		h   func() hash.Hash
+	}{
+		"A128CBC-HS256": {32, sha256.New},
+		"A192CBC-HS384": {48, sha512.New384},
+		"A256CBC-HS512": {64, sha512.New},
+	}
+	s := sizes[enc]
+	if len(cek) != s.key {
+		return nil, fmt.Errorf("JWE: enc %s exige chave de %d bytes", enc, s.key)
+	}
+	macKey, encKey := cek[:s.key/2], cek[s.key/2:]
+
+	mac := hmac.New(s.h, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	binary.Write(mac, binary.BigEndian, uint64(len(aad))*8)
+	if subtle.ConstantTimeCompare(mac.Sum(nil)[:s.key/2], tag) != 1 {
+		return nil, fmt.Errorf("JWE: falha na autenticação do conteúdo")
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("JWE: cifra CBC com tamanho inválido")
+	}
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ciphertext)
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, fmt.Errorf("JWE: padding inválido")
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// aesKeyUnwrap é o AES Key Wrap da RFC 3394.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf("JWE: chave cifrada com tamanho inválido")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	a := append([]byte{}, wrapped[:8]...)
+	r := make([][]byte, n)
+	for i := range r {
+		r[i] = append([]byte{}, wrapped[8*(i+1):8*(i+2)]...)
+	}
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[i-1])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[i-1], buf[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a, []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}) != 1 {
+		return nil, fmt.Errorf("JWE: chave cifrada não abre com a chave simétrica")
+	}
+	return bytes.Join(r, nil), nil
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestAESKeyUnwrap(t *testing.T) {
+	// RFC 3394, seções 4.1 a 4.6
+	const (
+		kek128 = "000102030405060708090a0b0c0d0e0f"
+		kek192 = kek128 + "1011121314151617"
+		kek256 = kek128 + "101112131415161718191a1b1c1d1e1f"
+		key128 = "00112233445566778899aabbccddeeff"
+		key192 = key128 + "0001020304050607"
+		key256 = key128 + "000102030405060708090a0b0c0d0e0f"
+	)
+	tests := []struct {
+		name    string
+		kek     string
+		wrapped string
+		want    string
+	}{
+		{"4.1 KEK 128, chave 128", kek128, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5", key128},
+		{"4.2 KEK 192, chave 128", kek192, "96778b25ae6ca435f92b5b97c050aed2468ab8a17ad84e5d", key128},
+		{"4.3 KEK 256, chave 128", kek256, "64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7", key128},
+		{"4.4 KEK 192, chave 192", kek192, "031d33264e15d33268f24ec260743edce1c6c7ddee725a936ba814915c6762d2", key192},
+		{"4.5 KEK 256, chave 192", kek256, "a8f9bc1612c68b3ff6e6f4fbe30e71e4769c8b80a32cb8958cd5d17d6b254da1", key192},
+		{"4.6 KEK 256, chave 256", kek256, "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21", key256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := aesKeyUnwrap(mustHex(tt.kek), mustHex(tt.wrapped))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, mustHex(tt.want)) {
+				t.Errorf("aesKeyUnwrap = %x; quer %s", got, tt.want)
+			}
+		})
+	}
+
+	wrapped := mustHex(tests[0].wrapped)
+	tampered := append([]byte{}, wrapped...)
+	tampered[len(tampered)-1] ^= 1
+	for name, w := range map[string][]byte{"alterada": tampered, "curta": wrapped[:16], "fora de blocos": wrapped[:23]} {
+		if _, err := aesKeyUnwrap(mustHex(kek128), w); err == nil {
+			t.Errorf("aesKeyUnwrap com chave cifrada %s: quer erro", name)
+		}
+	}
+	if _, err := aesKeyUnwrap(mustHex(kek192), wrapped); err == nil {
+		t.Error("aesKeyUnwrap com KEK errada: quer erro")
+	}
+}
+
+func TestDecryptCBCHMAC(t *testing.T) {
+	// RFC 7518, apêndice B.1 a B.3
+	plain := "A cipher system must not be required to be secret, and it must be able to fall into the hands of the enemy without inconvenience"
+	aad := []byte("The second principle of Auguste Kerckhoffs")
+	iv := mustHex("1af38c2dc2b96ffdd86694092341bc04")
+	keyOf := func(n int) []byte {
+		k := make([]byte, n)
+		for i := range k {
+			k[i] = byte(i)
+		}
+		return k
+	}
+
+	tests := []struct {
+		enc        string
+		key        []byte
+		ciphertext string
+		tag        string
+	}{
+		{
+			"A128CBC-HS256", keyOf(32),
+			"c80edfa32ddf39d5ef00c0b468834279a2e46a1b8049f792f76bfe54b903a9c9a94ac9b47ad2655c5f10f9aef71427e2fc6f9b3f399a221489f16362c703233609d45ac69864e3321cf82935ac4096c86e133314c54019e8ca7980dfa4b9cf1b384c486f3a54c51078158ee5d79de59fbd34d848b3d69550a67646344427ade54b8851ffb598f7f80074b9473c82e2db",
+			"652c3fa36b0a7c5b3219fab3a30bc1c4",
+		},
+		{
+			"A192CBC-HS384", keyOf(48),
+			"ea65da6b59e61edb419be62d19712ae5d303eeb50052d0dfd6697f77224c8edb000d279bdc14c1072654bd30944230c657bed4ca0c9f4a8466f22b226d1746214bf8cfc2400add9f5126e479663fc90b3bed787a2f0ffcbf3904be2a641d5c2105bfe591bae23b1d7449e532eef60a9ac8bb6c6b01d35d49787bcd57ef484927f280adc91ac0c4e79c7b11efc60054e3",
+			"8490ac0e58949bfe51875d733f93ac2075168039ccc733d7",
+		},
+		{
+			"A256CBC-HS512", keyOf(64),
+			"4affaaadb78c31c5da4b1b590d10ffbd3dd8d5d302423526912da037ecbcc7bd822c301dd67c373bccb584ad3e9279c2e6d12a1374b77f077553df829410446b36ebd97066296ae6427ea75c2e0846a11a09ccf5370dc80bfecbad28c73f09b3a3b75e662a2594410ae496b2e2e6609e31e6e02cc837f053d21f37ff4f51950bbe2638d09dd7a4930930806d0703b1f6",
+			"4dd3b4c088a7f45c216839645b2012bf2e6269a8c56a816dbc1b267761955bc5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.enc, func(t *testing.T) {
+			ciphertext, tag := mustHex(tt.ciphertext), mustHex(tt.tag)
+			got, err := decryptContent(tt.enc, tt.key, iv, ciphertext, tag, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != plain {
+				t.Errorf("decryptContent = %q; quer %q", got, plain)
+			}
+
+			if _, err := decryptContent(tt.enc, tt.key, iv, ciphertext, tag, aad[1:]); err == nil {
+				t.Error("AAD alterado: quer erro")
+			}
+			tampered := append([]byte{}, tag...)
+			tampered[0] ^= 1
+			if _, err := decryptContent(tt.enc, tt.key, iv, ciphertext, tampered, aad); err == nil {
+				t.Error("tag alterada: quer erro")
+			}
+			if _, err := decryptContent(tt.enc, tt.key[1:], iv, ciphertext, tag, aad); err == nil {
+				t.Error("chave com tamanho errado: quer erro")
+			}
+		})
+	}
+}
+
+func TestJWEDecryptKnownAnswer(t *testing.T) {
+	// RFC 7516, apêndice A.3: A128KW com A128CBC-HS256
+	d, err := NewJWEDecryptor([]byte("GawgguFyGrWKav7AX4VKUg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := "eyJhbGciOiJBMTI4S1ciLCJlbmMiOiJBMTI4Q0JDLUhTMjU2In0." +
+		"6KB707dM9YTIgHtLvtgWQ8mKwboJW3of9locizkDTHzBC2IlrT1oOQ." +
+		"AxY8DCtDaGlsbGljb3RoZQ." +
+		"KDlTtXchhZTGufMYmOYGS4HffxPSUrfmqCHXaI9wOGY." +
+		"U0m_YmjN04DJvceFICbCVQ"
+
+	plain, contentType, err := d.Decrypt(context.Background(), []byte(token+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "Live long and prosper." || contentType != "" {
+		t.Errorf("Decrypt = %q, %q", plain, contentType)
+	}
+
+	// o header protegido é o AAD: trocar por um equivalente não abre
+	parts := strings.Split(token, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"enc":"A128CBC-HS256","alg":"A128KW"}`))
+	if _, _, err := d.Decrypt(context.Background(), []byte(strings.Join(parts, "."))); err == nil {
+		t.Error("Decrypt com header reescrito: quer erro")
+	}
+}
+
+// jweDirGCM monta um JWE compacto com alg dir e AES-GCM.
+func jweDirGCM(t *testing.T, header string, key, plain []byte) string {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := base64.RawURLEncoding.EncodeToString([]byte(header))
+	iv := make([]byte, gcm.NonceSize())
+	sealed := gcm.Seal(nil, iv, plain, []byte(protected))
+	ciphertext, tag := sealed[:len(plain)], sealed[len(plain):]
+	enc := base64.RawURLEncoding.EncodeToString
+	return strings.Join([]string{protected, "", enc(iv), enc(ciphertext), enc(tag)}, ".")
+}
+
+func TestJWEDecryptDirGCM(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	d, err := NewJWEDecryptor([]byte(base64.StdEncoding.EncodeToString(key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deflated bytes.Buffer
+	w, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	w.Write([]byte(`{"ok":true}`))
+	w.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		want     string
+		wantType string
+		wantErr  bool
+	}{
+		{name: "simples", token: jweDirGCM(t, `{"alg":"dir","enc":"A256GCM"}`, key, []byte("ola")), want: "ola"},
+		{name: "zip DEF e cty curto", token: jweDirGCM(t, `{"alg":"dir","enc":"A256GCM","zip":"DEF","cty":"json"}`, key, deflated.Bytes()), want: `{"ok":true}`, wantType: "application/json"},
+		{name: "cty completo", token: jweDirGCM(t, `{"alg":"dir","enc":"A256GCM","cty":"text/csv"}`, key, []byte("a,b")), want: "a,b", wantType: "text/csv"},
+		{name: "enc com chave de outro tamanho", token: jweDirGCM(t, `{"alg":"dir","enc":"A128GCM"}`, key, []byte("x")), wantErr: true},
+		{name: "zip desconhecido", token: jweDirGCM(t, `{"alg":"dir","enc":"A256GCM","zip":"GZ"}`, key, []byte("x")), wantErr: true},
+		{name: "chave errada", token: jweDirGCM(t, `{"alg":"dir","enc":"A256GCM"}`, bytes.Repeat([]byte{8}, 32), []byte("x")), wantErr: true},
+		{name: "alg não suportado", token: jweDirGCM(t, `{"alg":"RSA1_5","enc":"A256GCM"}`, key, []byte("x")), wantErr: true},
+		{name: "partes de menos", token: "a.b.c", wantErr: true},
+		{name: "base64 inválido", token: "a.b.c.d.e!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, contentType, err := d.Decrypt(context.Background(), []byte(tt.token))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Decrypt = %q; quer erro", plain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(plain) != tt.want || contentType != tt.wantType {
+				t.Errorf("Decrypt = %q, %q; quer %q, %q", plain, contentType, tt.want, tt.wantType)
+			}
+		})
+	}
+}
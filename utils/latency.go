@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyRecorder guarda o tempo até os headers de cada tentativa que
+// recebeu resposta, e o que esperou a que estourou o prazo antes deles;
+// seguro com nil.
+type LatencyRecorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+func (r *LatencyRecorder) observe(d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.samples = append(r.samples, d)
+	r.mu.Unlock()
+}
+
+// Samples devolve uma cópia das amostras, na ordem em que chegaram.
+func (r *LatencyRecorder) Samples() []time.Duration {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Duration(nil), r.samples...)
+}
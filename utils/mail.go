@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type MailAttachment struct {
+	Name string
+	Data []byte
+}
+
+type Mail struct {
+	From    string
+	To      []string
+	Subject string
+	// Headers vão no cabeçalho da mensagem, ex: X-Run-Id.
+	Headers     map[string]string
+	Body        string
+	Attachments []MailAttachment
+}
+
+// SMTPServer envia via STARTTLS (quando oferecido) ou, na porta 465, com
+// TLS implícito. User vazio desativa a autenticação.
+type SMTPServer struct {
+	Addr     string
+	User     string
+	Password string
+}
+
+func (s *SMTPServer) Send(m Mail) error {
+	host, port, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("endereço SMTP inválido %q: %w", s.Addr, err)
+	}
+
+	var client *smtp.Client
+	if port == "465" {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", s.Addr, &tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+		client, err = smtp.NewClient(conn, host)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	} else {
+		conn, err := net.DialTimeout("tcp", s.Addr, 30*time.Second)
+		if err != nil {
+			return err
+		}
+		client, err = smtp.NewClient(conn, host)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				client.Close()
+				return err
+			}
+		}
+	}
+	defer client.Close()
+
+	if s.User != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.User, s.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("destinatário %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (m Mail) bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	keys := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, mime.QEncoding.Encode("utf-8", m.Headers[k]))
+	}
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	boundaryBytes := make([]byte, 12)
+	rand.Read(boundaryBytes)
+	boundary := fmt.Sprintf("api-requester-%x", boundaryBytes)
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(&buf, []byte(m.Body))
+
+	for _, a := range m.Attachments {
+		name := mime.QEncoding.Encode("utf-8", filepath.Base(a.Name))
+		ctype := mime.TypeByExtension(filepath.Ext(a.Name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; name=%q\r\n", ctype, name)
+		fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=%q\r\n", name)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64Lines(&buf, a.Data)
+	}
+
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes()
+}
+
+func writeBase64Lines(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
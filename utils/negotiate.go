@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// NegotiateAuth envia "Authorization: Negotiate <token>" (SPNEGO). O token
+// é gerado por um comando externo com acesso ao cache de credenciais
+// Kerberos do host (ex: um script usando GSSAPI), que recebe o SPN
+// HTTP@<host> como último argumento e em SPNEGO_SPN e imprime o token em
+// base64. Um token novo é gerado por tentativa, pois servidores rejeitam
+// autenticadores repetidos.
+type NegotiateAuth struct {
+	Command []string
+}
+
+func (n *NegotiateAuth) Apply(req *http.Request) error {
+	spn := "HTTP@" + req.URL.Hostname()
+
+	args := append(append([]string{}, n.Command[1:]...), spn)
+	cmd := exec.CommandContext(req.Context(), n.Command[0], args...)
+	cmd.Env = append(os.Environ(), "SPNEGO_SPN="+spn)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("erro ao gerar token SPNEGO para %s: %w: %s", spn, err, strings.TrimSpace(stderr.String()))
+	}
+
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return fmt.Errorf("comando SPNEGO não retornou token para %s", spn)
+	}
+
+	req.Header.Set("Authorization", "Negotiate "+token)
+	return nil
+}
+
+func (n *NegotiateAuth) Refresh(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// NTLMCredentials identifica o usuário no proxy. Domain pode ficar vazio
+// quando o proxy aceita o domínio padrão.
+type NTLMCredentials struct {
+	Domain   string
+	User     string
+	Password string
+}
+
+// ParseNTLMUser aceita "DOMINIO\usuario", "usuario@dominio" ou só "usuario".
+func ParseNTLMUser(s string) (domain, user string) {
+	if d, u, ok := strings.Cut(s, `\`); ok {
+		return d, u
+	}
+	if u, d, ok := strings.Cut(s, "@"); ok {
+		return d, u
+	}
+	return "", s
+}
+
+const (
+	ntlmNegotiateUnicode       = 0x00000001
+	ntlmNegotiateOEM           = 0x00000002
+	ntlmRequestTarget          = 0x00000004
+	ntlmNegotiateNTLM          = 0x00000200
+	ntlmNegotiateAlwaysSign    = 0x00008000
+	ntlmNegotiateExtendedSec   = 0x00080000
+	ntlmNegotiate128           = 0x20000000
+	ntlmNegotiate56            = 0x80000000
+	ntlmDefaultNegotiateFlags  = ntlmNegotiateUnicode | ntlmNegotiateOEM | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSec | ntlmNegotiate128 | ntlmNegotiate56
+	ntlmAuthenticateHeaderSize = 64
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmDefaultNegotiateFlags)
+	return msg
+}
+
+type ntlmChallenge struct {
+	flags      uint32
+	challenge  []byte
+	targetInfo []byte
+}
+
+func parseNTLMChallenge(msg []byte) (*ntlmChallenge, error) {
+	if len(msg) < 32 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, fmt.Errorf("mensagem NTLM de desafio inválida")
+	}
+
+	c := &ntlmChallenge{
+		flags:     binary.LittleEndian.Uint32(msg[20:]),
+		challenge: msg[24:32],
+	}
+
+	if len(msg) >= 48 {
+		length := int(binary.LittleEndian.Uint16(msg[40:]))
+		offset := int(binary.LittleEndian.Uint32(msg[44:]))
+		if offset+length > len(msg) {
+			return nil, fmt.Errorf("target info NTLM fora dos limites")
+		}
+		c.targetInfo = msg[offset : offset+length]
+	}
+	return c, nil
+}
+
+// ntlmAuthenticateMessage monta a resposta NTLMv2 ao desafio do servidor.
+func ntlmAuthenticateMessage(c *ntlmChallenge, creds NTLMCredentials, now time.Time) []byte {
+	clientChallenge := make([]byte, 8)
+	rand.Read(clientChallenge)
+
+	// 100ns desde 1601-01-01
+	timestamp := uint64(now.UnixNano()/100) + 116444736000000000
+
+	return ntlmAuthenticate(c, creds, clientChallenge, timestamp)
+}
+
+func ntlmAuthenticate(c *ntlmChallenge, creds NTLMCredentials, clientChallenge []byte, timestamp uint64) []byte {
+	ntHash := md4Sum(utf16le(creds.Password))
+	ntlmv2Hash := hmacMD5(ntHash, utf16le(strings.ToUpper(creds.User)+creds.Domain))
+
+	var blob bytes.Buffer
+	blob.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	binary.Write(&blob, binary.LittleEndian, timestamp)
+	blob.Write(clientChallenge)
+	blob.Write([]byte{0, 0, 0, 0})
+	blob.Write(c.targetInfo)
+	blob.Write([]byte{0, 0, 0, 0})
+
+	proof := hmacMD5(ntlmv2Hash, append(append([]byte{}, c.challenge...), blob.Bytes()...))
+	ntResponse := append(proof, blob.Bytes()...)
+	lmResponse := append(hmacMD5(ntlmv2Hash, append(append([]byte{}, c.challenge...), clientChallenge...)), clientChallenge...)
+
+	fields := [][]byte{
+		lmResponse,
+		ntResponse,
+		utf16le(creds.Domain),
+		utf16le(creds.User),
+		nil, // workstation
+		nil, // chave de sessão
+	}
+
+	msg := make([]byte, ntlmAuthenticateHeaderSize)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+
+	offset := ntlmAuthenticateHeaderSize
+	for i, field := range fields {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(offset))
+		offset += len(field)
+	}
+
+	flags := c.flags &^ ntlmNegotiateOEM
+	binary.LittleEndian.PutUint32(msg[60:], flags|ntlmNegotiateUnicode)
+
+	for _, field := range fields {
+		msg = append(msg, field...)
+	}
+	return msg
+}
+
+// DialNTLMProxy abre um túnel CONNECT até addr autenticando no proxy HTTP
+// com NTLM. O handshake precisa acontecer na mesma conexão, por isso não
+// dá para usar o Proxy-Authorization estático do http.Transport.
+func DialNTLMProxy(ctx context.Context, proxyAddr, addr string, creds NTLMCredentials) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	br := bufio.NewReader(conn)
+
+	resp, err := ntlmConnect(conn, br, addr, ntlmNegotiateMessage())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		challenge, err := ntlmChallengeFromHeader(resp.Header)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if resp, err = ntlmConnect(conn, br, addr, ntlmAuthenticateMessage(challenge, creds, time.Now())); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy recusou CONNECT para %s: %s", addr, resp.Status)
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+func ntlmConnect(conn net.Conn, br *bufio.Reader, addr string, msg []byte) (*http.Response, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{
+			"Proxy-Authorization": {"NTLM " + base64.StdEncoding.EncodeToString(msg)},
+			"Proxy-Connection":    {"Keep-Alive"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, nil
+}
+
+func ntlmChallengeFromHeader(h http.Header) (*ntlmChallenge, error) {
+	for _, v := range h.Values("Proxy-Authenticate") {
+		scheme, payload, _ := strings.Cut(v, " ")
+		if !strings.EqualFold(scheme, "NTLM") || payload == "" {
+			continue
+		}
+		msg, err := base64.StdEncoding.DecodeString(strings.TrimSpace(payload))
+		if err != nil {
+			return nil, fmt.Errorf("desafio NTLM inválido: %w", err)
+		}
+		return parseNTLMChallenge(msg)
+	}
+	return nil, fmt.Errorf("proxy não ofereceu autenticação NTLM")
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(out[i*2:], u)
+	}
+	return out
+}
+
+func hmacMD5(key, data []byte) []byte {
+	h := hmac.New(md5.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// md4Sum implementa MD4 (RFC 1320), necessário para o hash NT e ausente
+// da biblioteca padrão.
+func md4Sum(data []byte) []byte {
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+
+	msg := append([]byte{}, data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(data))*8)
+
+	var x [16]uint32
+	for chunk := 0; chunk < len(msg); chunk += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[chunk+i*4:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+
+		f := func(x, y, z uint32) uint32 { return x&y | ^x&z }
+		g := func(x, y, z uint32) uint32 { return x&y | x&z | y&z }
+		h := func(x, y, z uint32) uint32 { return x ^ y ^ z }
+
+		for _, i := range []int{0, 4, 8, 12} {
+			a = bits.RotateLeft32(a+f(b, c, d)+x[i], 3)
+			d = bits.RotateLeft32(d+f(a, b, c)+x[i+1], 7)
+			c = bits.RotateLeft32(c+f(d, a, b)+x[i+2], 11)
+			b = bits.RotateLeft32(b+f(c, d, a)+x[i+3], 19)
+		}
+		for _, i := range []int{0, 1, 2, 3} {
+			a = bits.RotateLeft32(a+g(b, c, d)+x[i]+0x5a827999, 3)
+			d = bits.RotateLeft32(d+g(a, b, c)+x[i+4]+0x5a827999, 5)
+			c = bits.RotateLeft32(c+g(d, a, b)+x[i+8]+0x5a827999, 9)
+			b = bits.RotateLeft32(b+g(c, d, a)+x[i+12]+0x5a827999, 13)
+		}
+		for _, i := range []int{0, 2, 1, 3} {
+			a = bits.RotateLeft32(a+h(b, c, d)+x[i]+0x6ed9eba1, 3)
+			d = bits.RotateLeft32(d+h(a, b, c)+x[i+8]+0x6ed9eba1, 9)
+			c = bits.RotateLeft32(c+h(d, a, b)+x[i+4]+0x6ed9eba1, 11)
+			b = bits.RotateLeft32(b+h(c, d, a)+x[i+12]+0x6ed9eba1, 15)
+		}
+
+		a, b, c, d = a+aa, b+bb, c+cc, d+dd
+	}
+
+	out := make([]byte, 16)
+	binary.LittleEndian.PutUint32(out[0:], a)
+	binary.LittleEndian.PutUint32(out[4:], b)
+	binary.LittleEndian.PutUint32(out[8:], c)
+	binary.LittleEndian.PutUint32(out[12:], d)
+	return out
+}
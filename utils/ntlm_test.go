@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMD4(t *testing.T) {
+	// RFC 1320, apêndice A.5
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", "043f8582f241db351ce627e153e7f0e4"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890", "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := hex.EncodeToString(md4Sum([]byte(tt.in))); got != tt.want {
+				t.Errorf("md4Sum(%q) = %s; quer %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNTLMUser(t *testing.T) {
+	tests := []struct {
+		in, domain, user string
+	}{
+		{`CORP\ana`, "CORP", "ana"},
+		{"ana@corp.local", "corp.local", "ana"},
+		{"ana", "", "ana"},
+	}
+	for _, tt := range tests {
+		if d, u := ParseNTLMUser(tt.in); d != tt.domain || u != tt.user {
+			t.Errorf("ParseNTLMUser(%q) = %q, %q; quer %q, %q", tt.in, d, u, tt.domain, tt.user)
+		}
+	}
+}
+
+// Valores de MS-NLMP 4.2.1 e 4.2.4 (NTLMv2).
+var (
+	ntlmTestCreds           = NTLMCredentials{Domain: "Domain", User: "User", Password: "Password"}
+	ntlmTestServerChallenge = mustHex("0123456789abcdef")
+	ntlmTestClientChallenge = mustHex("aaaaaaaaaaaaaaaa")
+	ntlmTestTargetInfo      = mustHex("02000c0044006f006d00610069006e0001000c0053006500720076006500720000000000")
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// ntlmTestChallengeMessage monta uma mensagem de desafio (tipo 2) com o
+// target info no fim, como os proxies mandam.
+func ntlmTestChallengeMessage(flags uint32) []byte {
+	msg := make([]byte, 48)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], flags)
+	copy(msg[24:], ntlmTestServerChallenge)
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(ntlmTestTargetInfo)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(ntlmTestTargetInfo)))
+	binary.LittleEndian.PutUint32(msg[44:], 48)
+	return append(msg, ntlmTestTargetInfo...)
+}
+
+// ntlmField devolve o campo i (LM, NT, domínio, usuário, ...) da mensagem
+// de autenticação conferindo que o descritor aponta para dentro dela.
+func ntlmField(t *testing.T, msg []byte, i int) []byte {
+	t.Helper()
+	pos := 12 + i*8
+	length := int(binary.LittleEndian.Uint16(msg[pos:]))
+	if max := int(binary.LittleEndian.Uint16(msg[pos+2:])); max != length {
+		t.Fatalf("campo %d: MaxLen %d diferente de Len %d", i, max, length)
+	}
+	offset := int(binary.LittleEndian.Uint32(msg[pos+4:]))
+	if offset < ntlmAuthenticateHeaderSize || offset+length > len(msg) {
+		t.Fatalf("campo %d fora da mensagem: offset %d, tamanho %d, mensagem %d", i, offset, length, len(msg))
+	}
+	return msg[offset : offset+length]
+}
+
+func TestNTLMAuthenticateKnownAnswer(t *testing.T) {
+	if got, want := md4Sum(utf16le(ntlmTestCreds.Password)), mustHex("a4f49c406510bdcab6824ee7c30fd852"); !bytes.Equal(got, want) {
+		t.Fatalf("hash NT = %x; quer %x", got, want)
+	}
+
+	c, err := parseNTLMChallenge(ntlmTestChallengeMessage(ntlmDefaultNegotiateFlags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.challenge, ntlmTestServerChallenge) || !bytes.Equal(c.targetInfo, ntlmTestTargetInfo) {
+		t.Fatalf("desafio lido errado: %x / %x", c.challenge, c.targetInfo)
+	}
+
+	msg := ntlmAuthenticate(c, ntlmTestCreds, ntlmTestClientChallenge, 0)
+
+	if !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 3 {
+		t.Fatalf("cabeçalho inválido: %x", msg[:12])
+	}
+	flags := binary.LittleEndian.Uint32(msg[60:])
+	if flags&ntlmNegotiateUnicode == 0 || flags&ntlmNegotiateOEM != 0 {
+		t.Errorf("flags = %#x; quer Unicode sem OEM", flags)
+	}
+
+	lm := ntlmField(t, msg, 0)
+	if want := mustHex("86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"); !bytes.Equal(lm, want) {
+		t.Errorf("resposta LMv2 = %x; quer %x", lm, want)
+	}
+
+	nt := ntlmField(t, msg, 1)
+	if want := mustHex("68cd0ab851e51c96aabc927bebef6a1c"); len(nt) < 16 || !bytes.Equal(nt[:16], want) {
+		t.Errorf("NTProofStr = %x; quer %x", nt[:min(len(nt), 16)], want)
+	}
+	blob := append(append(mustHex("0101000000000000"+"0000000000000000"), ntlmTestClientChallenge...), 0, 0, 0, 0)
+	blob = append(append(blob, ntlmTestTargetInfo...), 0, 0, 0, 0)
+	if !bytes.Equal(nt[16:], blob) {
+		t.Errorf("blob NTLMv2 = %x; quer %x", nt[16:], blob)
+	}
+
+	if got := ntlmField(t, msg, 2); !bytes.Equal(got, utf16le("Domain")) {
+		t.Errorf("domínio = %x", got)
+	}
+	if got := ntlmField(t, msg, 3); !bytes.Equal(got, utf16le("User")) {
+		t.Errorf("usuário = %x", got)
+	}
+	for i := 4; i < 6; i++ {
+		if got := ntlmField(t, msg, i); len(got) != 0 {
+			t.Errorf("campo %d = %x; quer vazio", i, got)
+		}
+	}
+	if want := ntlmAuthenticateHeaderSize + len(lm) + len(nt) + len("Domain")*2 + len("User")*2; len(msg) != want {
+		t.Errorf("mensagem com %d bytes; quer %d", len(msg), want)
+	}
+}
+
+func TestParseNTLMChallengeInvalid(t *testing.T) {
+	valid := ntlmTestChallengeMessage(ntlmDefaultNegotiateFlags)
+	outOfBounds := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint32(outOfBounds[44:], uint32(len(valid)))
+
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{"curta", valid[:31]},
+		{"assinatura errada", append([]byte("NTLMSSX\x00"), valid[8:]...)},
+		{"tipo errado", ntlmNegotiateMessage()},
+		{"target info fora dos limites", outOfBounds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseNTLMChallenge(tt.msg); err == nil {
+				t.Error("quer erro")
+			}
+		})
+	}
+}
+
+func TestDialNTLMProxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// proxy de mentira: 407 com o desafio, depois 200 se a mensagem de
+	// autenticação vier na mesma conexão
+	errc := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+
+		for step := 1; step <= 2; step++ {
+			req, err := http.ReadRequest(br)
+			if err != nil {
+				errc <- err
+				return
+			}
+			if req.Method != http.MethodConnect || req.Host != "api.exemplo:443" {
+				errc <- &net.AddrError{Err: "CONNECT inesperado", Addr: req.Method + " " + req.Host}
+				return
+			}
+			payload, _ := strings.CutPrefix(req.Header.Get("Proxy-Authorization"), "NTLM ")
+			msg, err := base64.StdEncoding.DecodeString(payload)
+			if err != nil || len(msg) < 12 || binary.LittleEndian.Uint32(msg[8:]) != uint32(step*2-1) {
+				errc <- &net.AddrError{Err: "mensagem NTLM inesperada", Addr: payload}
+				return
+			}
+			if step == 1 {
+				challenge := base64.StdEncoding.EncodeToString(ntlmTestChallengeMessage(ntlmDefaultNegotiateFlags))
+				conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: NTLM " + challenge + "\r\nContent-Length: 0\r\n\r\n"))
+				continue
+			}
+			// bytes do destino chegando junto com a resposta do CONNECT
+			conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nola"))
+		}
+		errc <- nil
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := DialNTLMProxy(ctx, ln.Addr().String(), "api.exemplo:443", ntlmTestCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 3)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "ola" {
+		t.Errorf("Read = %q, %v; quer os bytes já lidos do proxy", buf, err)
+	}
+}
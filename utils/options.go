@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestOptions muda uma chamada de Do sem mexer na configuração do
+// cliente, que pode estar compartilhado por outras partes do serviço:
+// Header soma (ou substitui) cabeçalhos, Timeout troca o AttemptTimeout
+// de cada tentativa e NoRetry faz uma tentativa só, como MaxRetries 0.
+type RequestOptions struct {
+	Header  http.Header
+	Timeout time.Duration
+	NoRetry bool
+}
+
+type requestOptionsKey struct{}
+
+// WithRequestOptions vale para as chamadas feitas com o contexto
+// devolvido (inclusive por Fetch e Pages).
+func WithRequestOptions(ctx context.Context, o RequestOptions) context.Context {
+	return context.WithValue(ctx, requestOptionsKey{}, o)
+}
+
+func RequestOptionsFromContext(ctx context.Context) RequestOptions {
+	o, _ := ctx.Value(requestOptionsKey{}).(RequestOptions)
+	return o
+}
+
+// maxRetries é o MaxRetries desta chamada.
+func (rl *RateLimitClient) maxRetries(o RequestOptions) int {
+	if o.NoRetry {
+		return 0
+	}
+	return rl.MaxRetries
+}
+
+// attemptTimeout é o AttemptTimeout desta chamada.
+func (rl *RateLimitClient) attemptTimeout(o RequestOptions) time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return rl.AttemptTimeout
+}
+
+// withHeader devolve a requisição com os cabeçalhos das opções, numa cópia
+// para não alterar a de quem chamou.
+func (o RequestOptions) withHeader(req *http.Request) *http.Request {
+	if len(o.Header) == 0 {
+		return req
+	}
+	req = req.Clone(req.Context())
+	for k, v := range o.Header {
+		req.Header[k] = v
+	}
+	return req
+}
@@ -0,0 +1,10 @@
+package utils
+
+import (
+	"io"
+	"os"
+)
+
+// Output recebe as mensagens de progresso do pacote; em modo pipe o
+// chamador redireciona para stderr para não misturar com o body.
+var Output io.Writer = os.Stdout
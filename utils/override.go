@@ -0,0 +1,29 @@
+package utils
+
+import "sync/atomic"
+
+// RateOverride é o controle manual do operador sobre todos os limiters do
+// processo, para incidentes ou quando os headers do provedor estão errados.
+// ForceRate fixa a taxa (req/s) acima da configurada, da descoberta e do
+// calendário; IgnoreHeaders descarta limite, restante e reset informados
+// pela API (o Retry-After de um 429 continua valendo). Pode ser trocado com
+// as requisições em andamento.
+type RateOverride struct {
+	forceRate     atomic.Int64
+	ignoreHeaders atomic.Bool
+}
+
+var Override = &RateOverride{}
+
+func (o *RateOverride) Set(forceRate int, ignoreHeaders bool) {
+	o.forceRate.Store(int64(max(forceRate, 0)))
+	o.ignoreHeaders.Store(ignoreHeaders)
+}
+
+func (o *RateOverride) ForceRate() int {
+	return int(o.forceRate.Load())
+}
+
+func (o *RateOverride) IgnoreHeaders() bool {
+	return o.ignoreHeaders.Load()
+}
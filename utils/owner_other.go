@@ -0,0 +1,27 @@
+//go:build !unix
+
+package utils
+
+import (
+	"net"
+	"os"
+)
+
+// OwnedByCurrentUser não é verificável nesta plataforma; o diretório
+// temporário já é do usuário.
+func OwnedByCurrentUser(info os.FileInfo) bool {
+	return true
+}
+
+// ListenUnixPrivate abre o socket e o restringe ao usuário.
+func ListenUnixPrivate(path string) (net.Listener, error) {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
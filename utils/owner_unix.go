@@ -0,0 +1,23 @@
+//go:build unix
+
+package utils
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// OwnedByCurrentUser diz se o arquivo é do usuário que roda o processo.
+func OwnedByCurrentUser(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
+
+// ListenUnixPrivate abre o socket já só para o usuário: com o umask, não
+// há o intervalo entre a criação e o chmod em que outro usuário conecta.
+func ListenUnixPrivate(path string) (net.Listener, error) {
+	old := syscall.Umask(0o177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Paginate diz a Pages como pedir a próxima página: pelo parâmetro Param
+// (Mode "page", o padrão, ou "offset"), por cursor ("cursor": do jsonpath
+// Cursor na página ou do campo CursorField do último registro) ou pelo
+// Link rel="next" ("link").
+type Paginate struct {
+	Mode  string
+	Param string
+	// Start é a primeira página ou offset (padrão: 1 em page, 0 em offset).
+	Start     *int
+	SizeParam string
+	Size      int
+	// Records é o jsonpath dos registros; vazio quando a página é o array.
+	Records     string
+	Cursor      string
+	CursorField string
+	// HasMore, quando informado, encerra a coleta ao vir false.
+	HasMore string
+	// MaxPages para a coleta depois de tantas páginas (0 = sem limite).
+	MaxPages int
+}
+
+// Page é uma página entregue por Pages, com os registros já separados.
+type Page struct {
+	Number  int
+	URL     string
+	Records []any
+	*Result
+}
+
+// Pages percorre as páginas do job conforme job.Paginate (sem ele, só a
+// URL do job) e entrega cada uma assim que chega, sem juntar a coleta em
+// memória; parar o range encerra a coleta. Um erro é entregue uma vez, com
+// a página que falhou, e encerra a sequência. job.Client é ignorado: as
+// requisições usam rl.
+func (rl *RateLimitClient) Pages(ctx context.Context, job Job) iter.Seq2[Page, error] {
+	return func(yield func(Page, error) bool) {
+		c := job.Paginate
+		if c == nil {
+			c = &Paginate{Mode: "link"}
+		}
+		if err := c.validate(); err != nil {
+			yield(Page{}, err)
+			return
+		}
+
+		value := c.start()
+		cursor, next := "", ""
+		for number := 1; ; number++ {
+			pageURL, err := c.pageURL(job.URL, value, cursor, next)
+			if err != nil {
+				yield(Page{Number: number}, err)
+				return
+			}
+			if job.Paginate == nil {
+				pageURL = job.URL
+			}
+
+			res, err := rl.fetch(ctx, job, pageURL)
+			page := Page{Number: number, URL: pageURL, Result: res}
+			if err != nil {
+				yield(page, fmt.Errorf("página %d: %w", number, err))
+				return
+			}
+			var doc any
+			if err := json.Unmarshal(res.Body, &doc); err != nil {
+				yield(page, fmt.Errorf("página %d não é JSON: %w", number, err))
+				return
+			}
+			page.Records = SelectRecords(doc, c.Records)
+			if !yield(page, nil) {
+				return
+			}
+
+			if job.Paginate == nil || (c.MaxPages > 0 && number >= c.MaxPages) || (c.Mode != "link" && len(page.Records) == 0) {
+				return
+			}
+			switch c.Mode {
+			case "page":
+				value++
+			case "offset":
+				value += len(page.Records)
+			case "cursor":
+				if cursor = c.nextCursor(doc, page.Records); cursor == "" {
+					return
+				}
+			case "link":
+				if next, err = nextLink(pageURL, res.Header.Get("Link")); next == "" {
+					if err != nil {
+						yield(Page{Number: number + 1}, err)
+					}
+					return
+				}
+			}
+			if c.Size > 0 && c.Mode != "link" && c.Mode != "cursor" && len(page.Records) < c.Size {
+				return
+			}
+		}
+	}
+}
+
+func (c *Paginate) validate() error {
+	switch c.Mode {
+	case "":
+		c.Mode = "page"
+	case "page", "offset", "cursor", "link":
+	default:
+		return fmt.Errorf("paginate: mode deve ser page, offset, cursor ou link")
+	}
+	if c.Param == "" && c.Mode != "link" {
+		return fmt.Errorf("paginate: param é obrigatório")
+	}
+	if c.Mode == "cursor" && (c.Cursor == "") == (c.CursorField == "") {
+		return fmt.Errorf("paginate: mode cursor exige cursor ou cursor_field")
+	}
+	for _, p := range []string{c.Records, c.Cursor, c.HasMore} {
+		if p == "" {
+			continue
+		}
+		if _, err := ParseJSONPath(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Paginate) start() int {
+	if c.Start != nil {
+		return *c.Start
+	}
+	if c.Mode == "offset" {
+		return 0
+	}
+	return 1
+}
+
+func (c *Paginate) pageURL(raw string, value int, cursor, next string) (string, error) {
+	if next != "" {
+		return next, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	switch c.Mode {
+	case "page", "offset":
+		q.Set(c.Param, strconv.Itoa(value))
+	case "cursor":
+		if cursor != "" {
+			q.Set(c.Param, cursor)
+		}
+	}
+	if c.SizeParam != "" && c.Size > 0 {
+		q.Set(c.SizeParam, strconv.Itoa(c.Size))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (c *Paginate) nextCursor(doc any, records []any) string {
+	if c.HasMore != "" {
+		p, _ := ParseJSONPath(c.HasMore)
+		if more, ok := p.First(doc); ok && more == false {
+			return ""
+		}
+	}
+	var v any
+	if c.CursorField != "" {
+		if rec, ok := records[len(records)-1].(map[string]any); ok {
+			v = rec[c.CursorField]
+		}
+	} else {
+		p, _ := ParseJSONPath(c.Cursor)
+		v, _ = p.First(doc)
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// nextLink resolve o Link rel="next" sobre a URL da página; um link para
+// outro host é recusado.
+func nextLink(pageURL, link string) (string, error) {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return "", fmt.Errorf("Link rel=\"next\" inválido: %w", err)
+		}
+		next := base.ResolveReference(ref)
+		if next.Host != base.Host {
+			return "", fmt.Errorf("Link rel=\"next\" aponta para outro host (%s)", next.Host)
+		}
+		return next.String(), nil
+	}
+	return "", nil
+}
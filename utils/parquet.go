@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// tipos físicos, repetição, encodings e codecs de parquet.thrift
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1
+	parquetUTF8     = 0
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUncompressed = 0
+	parquetGzip         = 2
+)
+
+// WriteParquet grava os registros em um único row group, com uma página
+// por coluna. Todas as colunas são opcionais. compression: "gzip" ou "none".
+func WriteParquet(w io.Writer, cols []Column, records []any, compression string) error {
+	codec := int32(parquetGzip)
+	switch compression {
+	case "", "gzip":
+	case "none":
+		codec = parquetUncompressed
+	default:
+		return fmt.Errorf("compressão %q não suportada (gzip, none)", compression)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("PAR1")
+
+	type chunkInfo struct {
+		col          Column
+		offset       int64
+		uncompressed int64
+		compressed   int64
+	}
+	chunks := make([]chunkInfo, 0, len(cols))
+
+	for _, col := range cols {
+		values, err := encodeColumn(col, records)
+		if err != nil {
+			return err
+		}
+
+		page := values
+		if codec == parquetGzip {
+			var gz bytes.Buffer
+			zw := gzip.NewWriter(&gz)
+			zw.Write(values)
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			page = gz.Bytes()
+		}
+
+		var header thriftWriter
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(values)))
+		header.i32(3, int32(len(page)))
+		header.structBegin(5)
+		header.i32(1, int32(len(records)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.structEnd()
+		header.buf.WriteByte(0)
+
+		info := chunkInfo{
+			col:          col,
+			offset:       int64(out.Len()),
+			uncompressed: int64(header.buf.Len() + len(values)),
+			compressed:   int64(header.buf.Len() + len(page)),
+		}
+		out.Write(header.buf.Bytes())
+		out.Write(page)
+		chunks = append(chunks, info)
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(cols)+1)
+	meta.structBegin(0)
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(cols)))
+	meta.structEnd()
+	for _, col := range cols {
+		meta.structBegin(0)
+		meta.i32(1, parquetPhysicalType(col.Type))
+		meta.i32(3, parquetOptional)
+		meta.binary(4, []byte(col.Name))
+		if col.Type == "string" || col.Type == "json" {
+			meta.i32(6, parquetUTF8)
+		}
+		meta.structEnd()
+	}
+	meta.i64(3, int64(len(records)))
+
+	var totalSize int64
+	for _, c := range chunks {
+		totalSize += c.uncompressed
+	}
+
+	meta.list(4, thriftStruct, 1)
+	meta.structBegin(0)
+	meta.list(1, thriftStruct, len(chunks))
+	for _, c := range chunks {
+		meta.structBegin(0)
+		meta.i64(2, c.offset)
+		meta.structBegin(3)
+		meta.i32(1, parquetPhysicalType(c.col.Type))
+		meta.list(2, thriftI32, 2)
+		meta.zigzag(parquetPlain)
+		meta.zigzag(parquetRLE)
+		meta.list(3, thriftBinary, 1)
+		meta.varint(uint64(len(c.col.Name)))
+		meta.buf.WriteString(c.col.Name)
+		meta.i32(4, codec)
+		meta.i64(5, int64(len(records)))
+		meta.i64(6, c.uncompressed)
+		meta.i64(7, c.compressed)
+		meta.i64(9, c.offset)
+		meta.structEnd()
+		meta.structEnd()
+	}
+	meta.i64(2, totalSize)
+	meta.i64(3, int64(len(records)))
+	meta.structEnd()
+	meta.binary(6, []byte("api-requester"))
+	meta.buf.WriteByte(0)
+
+	out.Write(meta.buf.Bytes())
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len())))
+	out.WriteString("PAR1")
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func parquetPhysicalType(t string) int32 {
+	switch t {
+	case "int64":
+		return parquetInt64
+	case "double":
+		return parquetDouble
+	case "boolean":
+		return parquetBoolean
+	}
+	return parquetByteArray
+}
+
+// encodeColumn gera os níveis de definição (RLE/bit-packed, largura
+// 1) seguidos dos valores não nulos em PLAIN.
+func encodeColumn(col Column, records []any) ([]byte, error) {
+	defined := make([]bool, len(records))
+	var values bytes.Buffer
+	var bools []bool
+
+	for i, rec := range records {
+		obj, ok := rec.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("registro %d não é um objeto", i)
+		}
+		v, ok := obj[col.Name]
+		if !ok || v == nil {
+			continue
+		}
+		defined[i] = true
+
+		switch col.Type {
+		case "int64":
+			n, ok := v.(float64)
+			if !ok || n != math.Trunc(n) {
+				return nil, fmt.Errorf("coluna %s, registro %d: %v não é inteiro", col.Name, i, v)
+			}
+			values.Write(binary.LittleEndian.AppendUint64(nil, uint64(int64(n))))
+		case "double":
+			n, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("coluna %s, registro %d: %v não é número", col.Name, i, v)
+			}
+			values.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(n)))
+		case "boolean":
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("coluna %s, registro %d: %v não é booleano", col.Name, i, v)
+			}
+			bools = append(bools, b)
+		case "string":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("coluna %s, registro %d: %v não é string", col.Name, i, v)
+			}
+			writeParquetBytes(&values, []byte(s))
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			writeParquetBytes(&values, data)
+		}
+	}
+
+	if col.Type == "boolean" {
+		values.Write(packBits(bools))
+	}
+
+	levels := bitPackedRun(defined)
+
+	var out bytes.Buffer
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))))
+	out.Write(levels)
+	out.Write(values.Bytes())
+	return out.Bytes(), nil
+}
+
+func writeParquetBytes(buf *bytes.Buffer, b []byte) {
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(b))))
+	buf.Write(b)
+}
+
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// bitPackedRun codifica os níveis como uma única sequência bit-packed do
+// encoding híbrido RLE (grupos de 8 valores).
+func bitPackedRun(bits []bool) []byte {
+	groups := (len(bits) + 7) / 8
+	out := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	return append(out, packBits(bits)...)
+}
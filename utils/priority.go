@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+type Priority int
+
+const (
+	PriorityLow    Priority = -10
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 10
+)
+
+type priorityKey struct{}
+
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+// priorityGate libera a etapa de espera do limiter para um chamador por vez,
+// sempre escolhendo o de maior prioridade (FIFO em caso de empate).
+type priorityGate struct {
+	mu      sync.Mutex
+	busy    bool
+	seq     uint64
+	waiters waiterHeap
+}
+
+type gateWaiter struct {
+	prio  Priority
+	seq   uint64
+	ready chan struct{}
+	index int
+}
+
+func (g *priorityGate) acquire(ctx context.Context, prio Priority) error {
+	g.mu.Lock()
+	if !g.busy && len(g.waiters) == 0 {
+		g.busy = true
+		g.mu.Unlock()
+		return nil
+	}
+
+	g.seq++
+	w := &gateWaiter{prio: prio, seq: g.seq, ready: make(chan struct{})}
+	heap.Push(&g.waiters, w)
+	g.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&g.waiters, w.index)
+			g.mu.Unlock()
+			return ctx.Err()
+		}
+		g.mu.Unlock()
+		// a vez já tinha sido concedida: repassa para o próximo
+		g.release()
+		return ctx.Err()
+	}
+}
+
+func (g *priorityGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.waiters) == 0 {
+		g.busy = false
+		return
+	}
+
+	next := heap.Pop(&g.waiters).(*gateWaiter)
+	close(next.ready)
+}
+
+type waiterHeap []*gateWaiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].prio != h[j].prio {
+		return h[i].prio > h[j].prio
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*gateWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
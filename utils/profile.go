@@ -0,0 +1,56 @@
+package utils
+
+import "time"
+
+// LimiterProfile é o que o rate limiter aprendeu sobre um provedor e vale
+// levar para a próxima execução (ou para outra máquina): a taxa segura
+// travada pela exploração, o limite informado pela API e de quanto em
+// quanto tempo a cota volta.
+type LimiterProfile struct {
+	SafeRate   int
+	Limit      int
+	ResetEvery time.Duration
+}
+
+func (p LimiterProfile) Empty() bool {
+	return p == LimiterProfile{}
+}
+
+// observeReset mede o intervalo entre resets quando a janela anterior já
+// venceu e a API informa a seguinte; chame com rl.mu travado.
+func (rl *RateLimitClient) observeReset(reset time.Time) {
+	if rl.ResetTime.IsZero() || time.Now().Before(rl.ResetTime) {
+		return
+	}
+	if d := reset.Sub(rl.ResetTime); d >= time.Second {
+		rl.resetEvery = d
+	}
+}
+
+// Profile devolve o que o cliente (o Pacer, se houver) aprendeu nesta
+// execução.
+func (rl *RateLimitClient) Profile() LimiterProfile {
+	p := rl.pacer()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profile := LimiterProfile{Limit: p.Limit, ResetEvery: p.resetEvery}
+	if p.learned {
+		profile.SafeRate = p.SafeRate
+	}
+	return profile
+}
+
+// SeedProfile começa a exploração de taxa na taxa segura de um perfil
+// salvo, em vez de 1 req/s. Não trava a taxa: um 429 ainda a ajusta, e
+// uma taxa configurada continua valendo.
+func (rl *RateLimitClient) SeedProfile(profile LimiterProfile) {
+	p := rl.pacer()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.SafeRate > 0 || profile.SafeRate <= p.DynamicRate {
+		return
+	}
+	p.DynamicRate = profile.SafeRate
+}
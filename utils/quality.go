@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	SeverityFail = "fail"
+	SeverityWarn = "warn"
+)
+
+type QualityRule struct {
+	Type       string   `json:"type"`
+	Field      string   `json:"field,omitempty"`
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	MaxDropPct *float64 `json:"max_drop_pct,omitempty"`
+	MaxGrowPct *float64 `json:"max_grow_pct,omitempty"`
+	Severity   string   `json:"severity,omitempty"`
+}
+
+type QualityConfig struct {
+	Records string        `json:"records,omitempty"`
+	Rules   []QualityRule `json:"rules"`
+}
+
+type RuleResult struct {
+	Rule       string `json:"rule"`
+	Field      string `json:"field,omitempty"`
+	Severity   string `json:"severity"`
+	Passed     bool   `json:"passed"`
+	Violations int    `json:"violations"`
+	Message    string `json:"message,omitempty"`
+}
+
+type QualityReport struct {
+	Records       int          `json:"records"`
+	PreviousCount *int         `json:"previous_count,omitempty"`
+	Failed        bool         `json:"failed"`
+	Warnings      int          `json:"warnings"`
+	Results       []RuleResult `json:"results"`
+}
+
+func (c *QualityConfig) Validate() error {
+	for i, r := range c.Rules {
+		switch r.Type {
+		case "not_null", "unique", "range":
+			if r.Field == "" {
+				return fmt.Errorf("regra #%d (%s) sem field", i+1, r.Type)
+			}
+			if _, err := ParseJSONPath(r.Field); err != nil {
+				return fmt.Errorf("regra #%d: %w", i+1, err)
+			}
+		case "count_change", "min_count":
+		default:
+			return fmt.Errorf("regra #%d: tipo %q desconhecido", i+1, r.Type)
+		}
+		switch r.Severity {
+		case "", SeverityFail, SeverityWarn:
+		default:
+			return fmt.Errorf("regra #%d: severity deve ser fail ou warn", i+1)
+		}
+	}
+	if c.Records != "" {
+		if _, err := ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectRecords devolve os registros a avaliar: os itens do jsonpath
+// configurado, ou os itens do array raiz (ou o próprio documento).
+func SelectRecords(doc any, recordsPath string) []any {
+	if recordsPath != "" {
+		p, err := ParseJSONPath(recordsPath)
+		if err != nil {
+			return nil
+		}
+		found := p.Find(doc)
+		if len(found) == 1 {
+			if list, ok := found[0].([]any); ok {
+				return list
+			}
+		}
+		return found
+	}
+	if list, ok := doc.([]any); ok {
+		return list
+	}
+	return []any{doc}
+}
+
+func EvaluateQuality(cfg *QualityConfig, records []any, previousCount *int) QualityReport {
+	report := QualityReport{Records: len(records), PreviousCount: previousCount}
+
+	for _, rule := range cfg.Rules {
+		severity := rule.Severity
+		if severity == "" {
+			severity = SeverityFail
+		}
+		res := RuleResult{Rule: rule.Type, Field: rule.Field, Severity: severity}
+
+		switch rule.Type {
+		case "not_null":
+			p, _ := ParseJSONPath(rule.Field)
+			for _, rec := range records {
+				if v, ok := p.First(rec); !ok || v == nil {
+					res.Violations++
+				}
+			}
+			if res.Violations > 0 {
+				res.Message = fmt.Sprintf("%d registros com %s nulo ou ausente", res.Violations, rule.Field)
+			}
+		case "unique":
+			p, _ := ParseJSONPath(rule.Field)
+			seen := map[string]bool{}
+			for _, rec := range records {
+				v, ok := p.First(rec)
+				if !ok {
+					continue
+				}
+				key := fmt.Sprint(v)
+				if seen[key] {
+					res.Violations++
+				}
+				seen[key] = true
+			}
+			if res.Violations > 0 {
+				res.Message = fmt.Sprintf("%d valores duplicados em %s", res.Violations, rule.Field)
+			}
+		case "range":
+			p, _ := ParseJSONPath(rule.Field)
+			for _, rec := range records {
+				v, ok := p.First(rec)
+				if !ok || v == nil {
+					continue
+				}
+				n, isNum := schemaNumber(v)
+				if !isNum || (rule.Min != nil && n < *rule.Min) || (rule.Max != nil && n > *rule.Max) {
+					res.Violations++
+				}
+			}
+			if res.Violations > 0 {
+				res.Message = fmt.Sprintf("%d valores de %s fora do intervalo", res.Violations, rule.Field)
+			}
+		case "min_count":
+			if rule.Min != nil && float64(len(records)) < *rule.Min {
+				res.Violations = 1
+				res.Message = fmt.Sprintf("%d registros, mínimo esperado %v", len(records), *rule.Min)
+			}
+		case "count_change":
+			if previousCount == nil || *previousCount == 0 {
+				break
+			}
+			change := (float64(len(records)) - float64(*previousCount)) / float64(*previousCount) * 100
+			if rule.MaxDropPct != nil && change < -*rule.MaxDropPct {
+				res.Violations = 1
+				res.Message = fmt.Sprintf("queda de %.1f%% em relação à execução anterior (%d -> %d)", math.Abs(change), *previousCount, len(records))
+			}
+			if rule.MaxGrowPct != nil && change > *rule.MaxGrowPct {
+				res.Violations = 1
+				res.Message = fmt.Sprintf("crescimento de %.1f%% em relação à execução anterior (%d -> %d)", change, *previousCount, len(records))
+			}
+		}
+
+		res.Passed = res.Violations == 0
+		if !res.Passed {
+			if severity == SeverityFail {
+				report.Failed = true
+			} else {
+				report.Warnings++
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	return report
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RateRule struct {
+	Days   map[time.Weekday]bool
+	Window *TimeWindow
+	Rate   int
+}
+
+type RateCalendar struct {
+	Rules    []RateRule
+	Location *time.Location
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseRateCalendar lê regras separadas por ";" no formato
+// "[dias] [HH:MM-HH:MM]=req/s", ex: "mon-fri 09:00-18:00=2; *=10".
+// A primeira regra que casar com o horário atual vence.
+func ParseRateCalendar(spec, tz string) (*RateCalendar, error) {
+	loc := time.Local
+	if tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("fuso horário inválido %q: %w", tz, err)
+		}
+	}
+
+	cal := &RateCalendar{Location: loc}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		eq := strings.LastIndex(part, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("regra de taxa inválida %q: falta =req/s", part)
+		}
+		rate, err := strconv.Atoi(strings.TrimSpace(part[eq+1:]))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("regra de taxa inválida %q: taxa deve ser inteiro positivo", part)
+		}
+
+		rule := RateRule{Rate: rate}
+		for _, field := range strings.Fields(part[:eq]) {
+			switch {
+			case field == "*":
+			case strings.Contains(field, ":"):
+				bounds := strings.SplitN(field, "-", 2)
+				if len(bounds) != 2 {
+					return nil, fmt.Errorf("janela inválida %q", field)
+				}
+				start, err := parseClock(bounds[0])
+				if err != nil {
+					return nil, err
+				}
+				end, err := parseClock(bounds[1])
+				if err != nil {
+					return nil, err
+				}
+				rule.Window = &TimeWindow{Start: start, End: end}
+			default:
+				days, err := parseWeekdays(field)
+				if err != nil {
+					return nil, err
+				}
+				rule.Days = days
+			}
+		}
+		cal.Rules = append(cal.Rules, rule)
+	}
+
+	return cal, nil
+}
+
+func parseWeekdays(field string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, item := range strings.Split(strings.ToLower(field), ",") {
+		if from, to, ok := strings.Cut(item, "-"); ok {
+			start, ok1 := weekdayNames[from]
+			end, ok2 := weekdayNames[to]
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("dias inválidos %q", item)
+			}
+			for d := start; ; d = (d + 1) % 7 {
+				days[d] = true
+				if d == end {
+					break
+				}
+			}
+			continue
+		}
+		d, ok := weekdayNames[item]
+		if !ok {
+			return nil, fmt.Errorf("dia inválido %q", item)
+		}
+		days[d] = true
+	}
+	return days, nil
+}
+
+func (c *RateCalendar) RateAt(t time.Time) int {
+	if c == nil {
+		return 0
+	}
+
+	local := t.In(c.Location)
+	for _, rule := range c.Rules {
+		if rule.Days != nil && !rule.Days[local.Weekday()] {
+			continue
+		}
+		if rule.Window != nil && !rule.Window.contains(sinceMidnight(local)) {
+			continue
+		}
+		return rule.Rate
+	}
+	return 0
+}
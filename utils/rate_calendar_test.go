@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateCalendarRateAt(t *testing.T) {
+	cal, err := ParseRateCalendar("mon-fri 09:00-18:00=2; sat,sun=5; 22:00-02:00=1; *=10", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrap, err := ParseRateCalendar("fri-mon=3", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2025-03-10 é uma segunda-feira
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2025, 3, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		cal  *RateCalendar
+		t    time.Time
+		want int
+	}{
+		{"dia útil no horário", cal, at(10, 10, 0), 2},
+		{"início da janela", cal, at(10, 9, 0), 2},
+		{"fim da janela é exclusivo", cal, at(10, 18, 0), 10},
+		{"fim de semana", cal, at(15, 10, 0), 5},
+		{"janela que cruza a meia-noite, antes", cal, at(11, 23, 0), 1},
+		{"janela que cruza a meia-noite, depois", cal, at(12, 1, 30), 1},
+		{"regra curinga", cal, at(12, 20, 0), 10},
+		{"dias que cruzam o domingo", wrap, at(16, 12, 0), 3},
+		{"fora dos dias", wrap, at(12, 12, 0), 0},
+		{"sem calendário", nil, at(10, 10, 0), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cal.RateAt(tt.t); got != tt.want {
+				t.Errorf("RateAt(%v) = %d; quer %d", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRateCalendarErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		tz   string
+	}{
+		{"10", ""},
+		{"*=0", ""},
+		{"*=dois", ""},
+		{"mon 09:00=2", ""},
+		{"mon 25:00-26:00=2", ""},
+		{"xyz=2", ""},
+		{"mon-xyz=2", ""},
+		{"*=2", "Lugar/Nenhum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if _, err := ParseRateCalendar(tt.spec, tt.tz); err == nil {
+				t.Errorf("ParseRateCalendar(%q, %q): quer erro", tt.spec, tt.tz)
+			}
+		})
+	}
+}
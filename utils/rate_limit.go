@@ -1,13 +1,17 @@
 package utils
 
 import (
-	"errors"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
-	"sync"
-	"time"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type RateLimitClient struct {
@@ -23,21 +27,453 @@ type RateLimitClient struct {
 	AutoRateMode bool
 	DynamicRate  int
 	SafeRate     int
+	// learned indica que SafeRate foi travada pela exploração (429), não
+	// configurada.
+	learned bool
+	// resetEvery é o intervalo entre os resets de cota observados.
+	resetEvery time.Duration
 
 	LastRequest time.Time
+
+	MaxRetryAfter time.Duration
+
+	AttemptTimeout time.Duration
+	// HeaderTimeout limita só a espera pelos headers de cada tentativa; a
+	// leitura do body continua sob AttemptTimeout.
+	HeaderTimeout time.Duration
+
+	RateCalendar *RateCalendar
+
+	URLSigner *URLSigner
+
+	Auth AuthProvider
+
+	Bandwidth *BandwidthLimiter
+
+	Capture *HeaderCapture
+
+	Deprecations *DeprecationMonitor
+	Version      *VersionCheck
+
+	// Decryptor abre as respostas 2xx cifradas antes das BodyRules e de
+	// quem chamou.
+	Decryptor Decryptor
+	Verifier  *SignatureVerifier
+
+	Dialect *RateDialect
+
+	// Pacer, quando definido, é o cliente cujo ritmo (taxa, cota e reset)
+	// este compartilha: clientes de jobs do mesmo grupo dividem um só rate
+	// limit, cada um com sua autenticação e retries.
+	Pacer *RateLimitClient
+
+	// BodyRules marca respostas 2xx como retentáveis ou falhas pelo
+	// conteúdo (ver CompileBodyRules).
+	BodyRules []BodyRule
+
+	// HTMLPages decide o que fazer com a página HTML (login, WAF) que chega
+	// com status 2xx no lugar do JSON: retentar (padrão), falhar ou aceitar
+	// (HTMLPagesAllow, para endpoints que devolvem HTML de fato).
+	HTMLPages string
+
+	// WAFCooldown é a pausa depois de um desafio de WAF (ver
+	// WAFChallengeError); zero falha sem pausar.
+	WAFCooldown time.Duration
+
+	// ConnDebug loga a conexão de cada tentativa (nova ou reaproveitada, e
+	// há quanto tempo ociosa). FreshRetry descarta as conexões ociosas
+	// antes de cada nova tentativa e repete uma vez, numa conexão nova, a
+	// requisição idempotente que falha numa conexão reaproveitada (keep-alive
+	// já fechado pelo servidor).
+	ConnDebug  bool
+	FreshRetry bool
+
+	// Usage, quando definido, conta respostas, bytes e throttling para o
+	// consumo por provider.
+	Usage *UsageMeter
+
+	// Warnings recebe avisos não fatais: respostas acima de SlowResponse e
+	// cota restante abaixo da fração NearQuota do limite.
+	Warnings     *WarningLog
+	SlowResponse time.Duration
+	NearQuota    float64
+
+	// Latency, quando definido, recebe o tempo de resposta de cada
+	// tentativa.
+	Latency *LatencyRecorder
+
+	// Requests, quando definido, guarda cada requisição feita, para
+	// repeti-la depois (ver RequestLog).
+	Requests *RequestLog
+
+	// Audit responde às requisições que alteram dados (POST, PUT, PATCH,
+	// DELETE) sem enviá-las.
+	Audit bool
+
+	// SlowStart é a rampa de taxa depois de um início a frio (ver
+	// warmRate); zero desativa. SlowStartIdle é a ociosidade que conta
+	// como início a frio (padrão: SlowStart).
+	SlowStart     time.Duration
+	SlowStartIdle time.Duration
+	warmed        bool
+	warmSince     time.Time
+
+	// RetryStatus são os status 4xx repetidos com backoff, sem reduzir a
+	// taxa (padrão: DefaultRetryStatus); nil não repete nenhum.
+	RetryStatus []int
+	noEarlyData atomic.Bool
+
+	// ThrottleStagger é o passo entre as retomadas dos workers que pararam
+	// pelo mesmo throttling ou reset (ver stagger); zero desativa.
+	ThrottleStagger time.Duration
+	wakeAt          time.Time
+	wakeSlots       int
+
+	attempts atomic.Int64
+	retries  retryMeter
+
+	waf *WAFChallengeError
+
+	gate priorityGate
+}
+
+type RetryAfterExceededError struct {
+	Wait time.Duration
+	Max  time.Duration
+}
+
+func (e *RetryAfterExceededError) Error() string {
+	return fmt.Sprintf("Retry-After de %v excede o limite configurado de %v", e.Wait, e.Max)
 }
 
 func NewRateLimitClient() *RateLimitClient {
 	return &RateLimitClient{
-		Client:      &http.Client{},
+		Client:      &http.Client{CheckRedirect: CheckRedirect},
 		MaxRetries:  5,
 		BaseBackoff: 1 * time.Second,
 		DynamicRate: 1,
 		LastRequest: time.Now().Add(-1 * time.Hour),
+
+		MaxRetryAfter:   10 * time.Minute,
+		RetryStatus:     DefaultRetryStatus,
+		ThrottleStagger: DefaultThrottleStagger,
+
+		Deprecations: NewDeprecationMonitor(),
 	}
 }
 
 func (rl *RateLimitClient) Do(req *http.Request) (*http.Response, error) {
+	if rl.Audit && !readOnlyMethod(req.Method) {
+		return auditResponse(req), nil
+	}
+
+	ctx := req.Context()
+	opts := RequestOptionsFromContext(ctx)
+	req = opts.withHeader(req)
+	rl.Requests.record(req)
+	maxRetries := rl.maxRetries(opts)
+	p := rl.pacer()
+	trace := attemptTraceFrom(ctx)
+	trace.reset()
+	decisions := retryDecisionsFrom(ctx)
+	decisions.reset()
+
+	if blocked := rl.WAFBlocked(); blocked != nil {
+		return nil, blocked
+	}
+
+	if err := p.waitTurn(ctx); err != nil {
+		return nil, err
+	}
+
+	reauthenticated := false
+	resigned := false
+	exhausted := "rate limit"
+	var lastPage *HTMLPageError
+	freshRetried := false
+	retried := 0
+	defer func() { rl.retries.done(retried, exhausted == "") }()
+	// a última "tentativa" só espera e esgota: não conta como nova tentativa
+	countRetry := func(reason string, attempt int) {
+		if attempt < maxRetries {
+			rl.retries.retry(reason)
+			retried++
+		}
+	}
+	// decide registra a decisão depois da tentativa; a nova tentativa que
+	// passa de maxRetries só espera e esgota, fora as que não gastam
+	// tentativa (ver freeRetryRules)
+	decide := func(attempt, status int, reason, action, rule string, wait time.Duration, err error) {
+		if action == DecisionRetry && attempt >= maxRetries && !freeRetryRules[rule] {
+			action = DecisionExhausted
+		}
+		d := RetryDecision{Attempt: attempt + 1, Status: status, Reason: reason, Action: action, Wait: wait, Rule: rule}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		decisions.record(d)
+	}
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+
+		if rl.FreshRetry && attempt > 0 && !rl.noEarlyData.Load() {
+			rl.Client.CloseIdleConnections()
+		}
+		span := trace.begin()
+		sent := time.Now()
+		var conn connInfo
+		resp, err := rl.sendAttempt(req, attempt > 0 || reauthenticated || resigned || freshRetried, &conn)
+		rl.attempts.Add(1)
+		span.connection(&conn)
+		if rl.ConnDebug {
+			fmt.Fprintf(Output, "Tentativa %d em %s %s: %s\n", attempt+1, req.Method, req.URL.Host, &conn)
+		}
+
+		if err != nil {
+			span.fail(err)
+			// a tentativa que estoura o prazo entra no histórico com o que
+			// esperou, para o percentil não ficar só com as que responderam
+			if timedOut(ctx, err) {
+				rl.Latency.observe(time.Since(sent))
+			}
+			if rl.FreshRetry && conn.reused && !freshRetried && ctx.Err() == nil && freshRetryable(req) {
+				decide(attempt, 0, RetryNetwork, DecisionRetry, RuleFreshConn, 0, err)
+				fmt.Fprintf(Output, "Falha em %s: %v. Repetindo numa conexão nova...\n", &conn, err)
+				rl.Client.CloseIdleConnections()
+				rl.retries.retry(RetryNetwork)
+				retried++
+				freshRetried = true
+				attempt--
+				continue
+			}
+			decide(attempt, 0, RetryNetwork, DecisionFail, RuleNetworkError, 0, err)
+			return nil, err
+		}
+		span.response(resp.StatusCode)
+		throttled := rl.dialect().throttled(resp)
+		rl.Usage.response(throttled)
+
+		if rl.Capture != nil {
+			rl.Capture.Record(resp)
+		}
+		if rl.Deprecations != nil {
+			rl.Deprecations.Observe(resp)
+		}
+		rl.observeVersion(resp)
+		p.updateRateLimitTracking(resp)
+		elapsed := time.Since(sent)
+		rl.Latency.observe(elapsed)
+		rl.observeWarnings(req, elapsed)
+
+		if vendor, ok := DetectWAFChallenge(resp); ok {
+			resp.Body.Close()
+			err := rl.challenged(resp.StatusCode, vendor)
+			span.fail(err)
+			decide(attempt, resp.StatusCode, "waf", DecisionFail, RuleWAFChallenge, 0, err)
+			return nil, err
+		}
+
+		if !resigned && rl.correctSkew(ctx, req, resp, sent) {
+			decide(attempt, resp.StatusCode, "clock", DecisionRetry, RuleClockSkew, 0, nil)
+			resp.Body.Close()
+			resigned = true
+			attempt--
+			continue
+		}
+
+		if rl.Auth != nil && !reauthenticated && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			resp.Body.Close()
+			fmt.Fprintf(Output, "%d recebido. Renovando credenciais...\n", resp.StatusCode)
+			if err := rl.Auth.Refresh(ctx); err != nil {
+				decide(attempt, resp.StatusCode, "auth", DecisionFail, RuleAuthRefresh, 0, err)
+				return nil, err
+			}
+			decide(attempt, resp.StatusCode, "auth", DecisionRetry, RuleAuthRefresh, 0, nil)
+			reauthenticated = true
+			attempt--
+			continue
+		}
+
+		if !throttled && rl.retriesStatus(resp.StatusCode) {
+			resp.Body.Close()
+			reason, rule, wait, err := rl.statusRetry(resp, attempt)
+			if err != nil {
+				span.fail(err)
+				decide(attempt, resp.StatusCode, reason, DecisionFail, rule, 0, err)
+				return nil, err
+			}
+			span.retryAfter(wait)
+			decide(attempt, resp.StatusCode, reason, DecisionRetry, rule, wait, nil)
+			exhausted = fmt.Sprintf("status %d", resp.StatusCode)
+			countRetry(reason, attempt)
+
+			fmt.Fprintf(Output, "%d recebido. Tentativa %d/%d. Esperando %v...\n", resp.StatusCode, attempt+1, maxRetries, wait)
+			if err := SleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !throttled {
+			p.adjustDynamicRate(false)
+
+			p.mu.Lock()
+			p.LastRequest = time.Now()
+			p.mu.Unlock()
+
+			resp.Body = rl.Usage.wrap(span.wrap(resp.Body))
+			if err := rl.decryptBody(ctx, resp); err != nil {
+				span.fail(err)
+				decide(attempt, resp.StatusCode, RetryValidation, DecisionFail, RuleResponseReject, 0, err)
+				return nil, err
+			}
+			if err := rl.verifyBody(resp); err != nil {
+				span.fail(err)
+				decide(attempt, resp.StatusCode, RetryValidation, DecisionFail, RuleResponseReject, 0, err)
+				return nil, err
+			}
+			page, err := rl.detectHTMLPage(resp)
+			if err != nil {
+				span.fail(err)
+				decide(attempt, resp.StatusCode, RetryValidation, DecisionFail, RuleResponseReject, 0, err)
+				return nil, err
+			}
+			if page != nil {
+				resp.Body.Close()
+				if page.Kind == HTMLPageChallenge {
+					vendor, ok := wafVendor(resp)
+					if !ok {
+						vendor = "desconhecido"
+					}
+					err := rl.challenged(resp.StatusCode, vendor)
+					span.fail(err)
+					decide(attempt, resp.StatusCode, "waf", DecisionFail, RuleWAFChallenge, 0, err)
+					return nil, err
+				}
+				// sessão expirada: uma renovação de credenciais, como no 401
+				if page.Kind == HTMLPageLogin && rl.Auth != nil && !reauthenticated {
+					fmt.Fprintf(Output, "Tela de login recebida com status %d. Renovando credenciais...\n", resp.StatusCode)
+					if err := rl.Auth.Refresh(ctx); err != nil {
+						decide(attempt, resp.StatusCode, "auth", DecisionFail, RuleAuthRefresh, 0, err)
+						return nil, err
+					}
+					decide(attempt, resp.StatusCode, "auth", DecisionRetry, RuleAuthRefresh, 0, page)
+					reauthenticated = true
+					attempt--
+					continue
+				}
+				if rl.HTMLPages == HTMLPagesFail || page.Kind == HTMLPageLogin {
+					span.fail(page)
+					decide(attempt, resp.StatusCode, RetryValidation, DecisionFail, RuleHTMLPage, 0, page)
+					return nil, page
+				}
+				wait, rule, err := p.waitRule(resp, attempt)
+				if err != nil {
+					span.fail(err)
+					decide(attempt, resp.StatusCode, RetryValidation, DecisionFail, rule, 0, err)
+					return nil, err
+				}
+				span.retryAfter(wait)
+				decide(attempt, resp.StatusCode, RetryValidation, DecisionRetry, RuleHTMLPage+"/"+rule, wait, page)
+				lastPage = page
+				countRetry(RetryValidation, attempt)
+
+				fmt.Fprintf(Output, "Resposta %d é %s. Tentativa %d/%d. Esperando %v...\n", resp.StatusCode, page.describe(), attempt+1, maxRetries, wait)
+				if err := SleepContext(ctx, wait); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			lastPage = nil
+			verdict, err := rl.checkBody(resp)
+			if err != nil {
+				decide(attempt, resp.StatusCode, RetryNetwork, DecisionFail, RuleNetworkError, 0, err)
+				return nil, err
+			}
+			if verdict == nil {
+				if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+					decide(attempt, resp.StatusCode, "success", DecisionReturn, RuleSuccess, 0, nil)
+				} else {
+					decide(attempt, resp.StatusCode, "status", DecisionReturn, RuleNotRetried, 0, nil)
+				}
+				return resp, nil
+			}
+			if verdict.Action == BodyActionFail {
+				err := &BodyRuleError{Status: resp.StatusCode, Verdict: verdict}
+				span.fail(err)
+				decide(attempt, resp.StatusCode, RetryValidation, DecisionFail, RuleBodyRule, 0, err)
+				return nil, err
+			}
+
+			wait, rule, err := p.waitRule(resp, attempt)
+			if err != nil {
+				span.fail(err)
+				decide(attempt, resp.StatusCode, RetryValidation, DecisionFail, rule, 0, err)
+				return nil, err
+			}
+			span.retryAfter(wait)
+			decide(attempt, resp.StatusCode, RetryValidation, DecisionRetry, RuleBodyRule+"/"+rule, wait, &BodyRuleError{Status: resp.StatusCode, Verdict: verdict})
+			exhausted = "resposta com " + verdict.String()
+			countRetry(RetryValidation, attempt)
+
+			fmt.Fprintf(Output, "Resposta %d com %s. Tentativa %d/%d. Esperando %v...\n", resp.StatusCode, verdict, attempt+1, maxRetries, wait)
+			if err := SleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		resp.Body.Close()
+		p.adjustDynamicRate(true)
+		wait, rule, err := p.waitRule(resp, attempt)
+		if err != nil {
+			span.fail(err)
+			decide(attempt, resp.StatusCode, throttleReason(resp.StatusCode), DecisionFail, rule, 0, err)
+			return nil, err
+		}
+		wait = p.stagger(wait)
+		span.retryAfter(wait)
+		decide(attempt, resp.StatusCode, throttleReason(resp.StatusCode), DecisionRetry, rule, wait, nil)
+		countRetry(throttleReason(resp.StatusCode), attempt)
+
+		fmt.Fprintf(Output, "%d detectado. Tentativa %d/%d. Esperando %v...\n", resp.StatusCode, attempt+1, maxRetries, wait)
+		if err := SleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+
+	reason := exhausted
+	// o defer conta a requisição como esgotada
+	exhausted = ""
+	if lastPage != nil {
+		return nil, fmt.Errorf("excedido número máximo de tentativas: %w", lastPage)
+	}
+	return nil, fmt.Errorf("excedido número máximo de tentativas após %s", reason)
+}
+
+// checkBody aplica BodyRules às respostas 2xx em JSON; o body é lido
+// inteiro e devolvido à resposta para quem chamou.
+func (rl *RateLimitClient) checkBody(resp *http.Response) (*BodyVerdict, error) {
+	if len(rl.BodyRules) == 0 || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "json") {
+		return nil, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return evaluateBody(rl.BodyRules, data), nil
+}
+
+func (rl *RateLimitClient) waitTurn(ctx context.Context) error {
+	if err := rl.gate.acquire(ctx, PriorityFromContext(ctx)); err != nil {
+		return err
+	}
+	defer rl.gate.release()
 
 	rl.applyDynamicWait()
 
@@ -46,49 +482,117 @@ func (rl *RateLimitClient) Do(req *http.Request) (*http.Response, error) {
 		if wait < time.Second {
 			wait = time.Second
 		}
-		fmt.Printf("Esperando reset por header oficial: %v\n", wait)
-		time.Sleep(wait)
+		wait = rl.stagger(wait)
+		fmt.Fprintf(Output, "Esperando reset por header oficial: %v\n", wait)
+		if err := SleepContext(ctx, wait); err != nil {
+			return err
+		}
 	}
 
-	var resp *http.Response
-	var err error
+	return nil
+}
 
-	for attempt := 0; attempt <= rl.MaxRetries; attempt++ {
+func (rl *RateLimitClient) sendAttempt(req *http.Request, resend bool, conn *connInfo) (*http.Response, error) {
+	attemptReq := req
+	cancel := context.CancelFunc(func() {})
 
-		resp, err = rl.Client.Do(req)
+	if timeout := rl.attemptTimeout(RequestOptionsFromContext(req.Context())); timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), timeout)
+		attemptReq = req.Clone(ctx)
+	}
 
+	if resend && req.GetBody != nil {
+		body, err := req.GetBody()
 		if err != nil {
+			cancel()
 			return nil, err
 		}
+		if attemptReq == req {
+			attemptReq = req.Clone(req.Context())
+		}
+		attemptReq.Body = body
+	}
 
-		rl.updateRateLimitTracking(resp)
+	if rl.Auth != nil {
+		if attemptReq == req {
+			attemptReq = req.Clone(req.Context())
+		}
+		if err := rl.Auth.Apply(attemptReq); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
 
-		if resp.StatusCode != http.StatusTooManyRequests {
-			rl.adjustDynamicRate(false)
-			
-			rl.mu.Lock()
-			rl.LastRequest = time.Now()
-			rl.mu.Unlock()
-			
-			return resp, nil
+	if rl.URLSigner != nil {
+		if attemptReq == req {
+			attemptReq = req.Clone(req.Context())
+		}
+		if err := rl.URLSigner.Sign(attemptReq, SigningClock.Now()); err != nil {
+			cancel()
+			return nil, err
 		}
+	}
+
+	headers := func() bool { return true }
+	if rl.HeaderTimeout > 0 {
+		ctx, cancelCause := context.WithCancelCause(attemptReq.Context())
+		timer := time.AfterFunc(rl.HeaderTimeout, func() { cancelCause(&HeaderTimeoutError{Timeout: rl.HeaderTimeout}) })
+		attemptCancel := cancel
+		cancel = func() { timer.Stop(); cancelCause(nil); attemptCancel() }
+		headers = timer.Stop
+		attemptReq = attemptReq.WithContext(ctx)
+	}
 
+	resp, err := rl.Client.Do(traceConn(attemptReq, conn))
+	if !headers() && err == nil {
+		// o prazo venceu junto com a chegada dos headers
 		resp.Body.Close()
-		rl.adjustDynamicRate(true)               
-		wait := rl.getWaitTime(resp, attempt)
+		err = context.Cause(attemptReq.Context())
+	}
+	if err != nil {
+		if headerTimeout := headerTimeoutCause(attemptReq.Context()); headerTimeout != nil {
+			err = headerTimeout
+		}
+		cancel()
+		return nil, err
+	}
 
-		fmt.Printf("429 detectado. Tentativa %d/%d. Esperando %v...\n", attempt+1, rl.MaxRetries, wait)
-		time.Sleep(wait)
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	if rl.Bandwidth != nil {
+		resp.Body = rl.Bandwidth.Wrap(attemptReq.Context(), resp.Body)
 	}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
 
-	return nil, errors.New("excedido número máximo de tentativas após rate limit")
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+func SleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 func (rl *RateLimitClient) mustWaitBeforeNext() bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	if rl.Limit == 0 {
+	if rl.Limit == 0 || Override.IgnoreHeaders() {
 		return false
 	}
 	if rl.Remaining > 0 {
@@ -104,28 +608,25 @@ func (rl *RateLimitClient) updateRateLimitTracking(resp *http.Response) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	h := resp.Header
-	foundHeader := false
-
-	if v := h.Get("X-RateLimit-Limit"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			rl.Limit = n
-			foundHeader = true
+	if Override.IgnoreHeaders() {
+		if rl.SafeRate == 0 {
+			rl.AutoRateMode = true
 		}
+		return
 	}
 
-	if v := h.Get("X-RateLimit-Remaining"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			rl.Remaining = n
-			foundHeader = true
-		}
+	now := time.Now()
+	limit, remaining, reset, foundHeader := rl.dialect().quota(resp.Header, now)
+	if limit >= 0 {
+		rl.Limit = limit
 	}
-
-	if reset := h.Get("X-RateLimit-Reset"); reset != "" {
-		if ts, err := strconv.ParseInt(reset, 10, 64); err == nil {
-			rl.ResetTime = time.Unix(ts, 0)
-			foundHeader = true
-		}
+	if remaining >= 0 {
+		rl.Remaining = remaining
+	}
+	if !reset.IsZero() {
+		reset = rl.saneReset(resp, reset, now)
+		rl.observeReset(reset)
+		rl.ResetTime = reset
 	}
 
 	if foundHeader {
@@ -138,55 +639,134 @@ func (rl *RateLimitClient) updateRateLimitTracking(resp *http.Response) {
 	}
 }
 
-func (rl *RateLimitClient) getWaitTime(resp *http.Response, attempt int) time.Duration {
+func (rl *RateLimitClient) pacer() *RateLimitClient {
+	if rl.Pacer != nil {
+		return rl.Pacer
+	}
+	return rl
+}
+
+func (rl *RateLimitClient) dialect() *RateDialect {
+	if rl.Dialect != nil {
+		return rl.Dialect
+	}
+	return &DefaultRateDialect
+}
+
+func (rl *RateLimitClient) getWaitTime(resp *http.Response, attempt int) (time.Duration, error) {
+	wait, _, err := rl.waitRule(resp, attempt)
+	return wait, err
+}
+
+// waitRule é o getWaitTime com a regra que escolheu a espera (ver
+// RetryDecision).
+func (rl *RateLimitClient) waitRule(resp *http.Response, attempt int) (time.Duration, string, error) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	h := resp.Header
 
 	if retry := h.Get("Retry-After"); retry != "" {
-		if sec, err := strconv.Atoi(strings.TrimSpace(retry)); err == nil {
-			d := time.Duration(sec) * time.Second
+		if d, ok := ParseRetryAfter(retry); ok {
 			if d <= 0 {
 				d = rl.BaseBackoff
 			}
-			return d
-		}
-		if t, err := http.ParseTime(retry); err == nil {
-			d := time.Until(t)
-			if d <= 0 {
-				d = rl.BaseBackoff
+			if rl.MaxRetryAfter > 0 && d > rl.MaxRetryAfter {
+				return 0, RuleMaxRetryAfter, &RetryAfterExceededError{Wait: d, Max: rl.MaxRetryAfter}
 			}
-			return d
+			return d, RuleRetryAfter, nil
+		}
+	}
+
+	// cota zerada com reset conhecido: esperar o reset, não o backoff
+	if rl.Remaining == 0 && rl.Limit > 0 && time.Now().Before(rl.ResetTime) && !Override.IgnoreHeaders() {
+		d := time.Until(rl.ResetTime)
+		if rl.MaxRetryAfter > 0 && d > rl.MaxRetryAfter {
+			return 0, RuleMaxRetryAfter, &RetryAfterExceededError{Wait: d, Max: rl.MaxRetryAfter}
 		}
+		return d, RuleQuotaReset, nil
 	}
 
 	if rl.SafeRate > 0 {
-		return 1 * time.Second
+		return 1 * time.Second, RuleSafeRate, nil
 	}
 
 	wait := rl.BaseBackoff * time.Duration(1<<attempt)
 	if wait > 2*time.Minute {
 		wait = 2 * time.Minute
 	}
-	return wait
+	return wait, RuleBackoff, nil
 }
 
-func (rl *RateLimitClient) applyDynamicWait() {
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+
+	if sec, err := strconv.Atoi(value); err == nil {
+		if sec > int(math.MaxInt64/int64(time.Second)) {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+
+	if sec, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(sec) || math.IsInf(sec, 0) {
+			return 0, false
+		}
+		if sec > float64(math.MaxInt64/int64(time.Second)) {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(sec * float64(time.Second)), true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+
+	return 0, false
+}
+
+// Attempts conta as tentativas HTTP feitas pelo cliente, inclusive as que
+// voltaram 429 ou falharam na conexão.
+func (rl *RateLimitClient) Attempts() int64 {
+	return rl.attempts.Load()
+}
+
+// CurrentRate devolve a taxa (req/s) que está sendo aplicada agora.
+func (rl *RateLimitClient) CurrentRate() int {
+	rl = rl.pacer()
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	return rl.effectiveRate()
+}
 
+func (rl *RateLimitClient) effectiveRate() int {
 	currentRate := rl.DynamicRate
 	if rl.SafeRate > 0 {
 		currentRate = rl.SafeRate
 	}
 
+	if ceiling := rl.RateCalendar.RateAt(time.Now()); ceiling > 0 && currentRate > ceiling {
+		currentRate = ceiling
+	}
+	if forced := Override.ForceRate(); forced > 0 {
+		currentRate = forced
+	}
+
 	if currentRate <= 0 {
 		currentRate = 1
 	}
+	return currentRate
+}
+
+func (rl *RateLimitClient) applyDynamicWait() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	currentRate := rl.effectiveRate()
+	now := time.Now()
 
-	minInterval := time.Second / time.Duration(currentRate)
-	elapsed := time.Since(rl.LastRequest)
+	minInterval := time.Duration(float64(time.Second) / rl.warmRate(currentRate, now))
+	elapsed := now.Sub(rl.LastRequest)
 
 	if elapsed < minInterval {
 		sleepTime := minInterval - elapsed
@@ -218,11 +798,81 @@ func (rl *RateLimitClient) adjustDynamicRate(hit429 bool) {
 
 		rl.SafeRate = newSafe
 		rl.DynamicRate = newSafe
-		fmt.Printf("Limite seguro encontrado e travado em: %d req/s\n", rl.SafeRate)
+		rl.learned = true
+		fmt.Fprintf(Output, "Limite seguro encontrado e travado em: %d req/s\n", rl.SafeRate)
 		return
 	}
 
 	nextRate := rl.DynamicRate + 1
-	fmt.Printf("Aumentando taxa de exploração para %d req/s\n", nextRate)
+	if ceiling := rl.RateCalendar.RateAt(time.Now()); ceiling > 0 && nextRate > ceiling {
+		return
+	}
+	fmt.Fprintf(Output, "Aumentando taxa de exploração para %d req/s\n", nextRate)
 	rl.DynamicRate = nextRate
-}
\ No newline at end of file
+}
+
+// LimiterSnapshot é o que o rate limiter acredita num instante: a cota
+// informada pela API (quando há), a taxa e como ela está sendo decidida.
+type LimiterSnapshot struct {
+	// Mode é "forced" (-force-rate), "fixed" (rate configurado), "headers"
+	// (cota informada pela API), "auto" (taxa descoberta por exploração,
+	// também depois de travada no 429) ou "initial" (nenhuma resposta ainda).
+	Mode          string    `json:"mode"`
+	Limit         int       `json:"limit,omitempty"`
+	Remaining     *int      `json:"remaining,omitempty"`
+	Reset         time.Time `json:"reset,omitzero"`
+	DynamicRate   int       `json:"dynamic_rate"`
+	EffectiveRate int       `json:"effective_rate"`
+	LastRequest   time.Time `json:"last_request"`
+	// Shared indica que o ritmo é o do Pacer, dividido com outros jobs.
+	Shared bool `json:"shared,omitempty"`
+	// IgnoringHeaders indica que a cota informada pela API está sendo
+	// descartada (-ignore-rate-headers).
+	IgnoringHeaders bool `json:"ignoring_headers,omitempty"`
+}
+
+// LearnedRate devolve a taxa segura travada pela exploração de taxa (a do
+// Pacer, se houver), ou 0 se ela não travou nesta execução.
+func (rl *RateLimitClient) LearnedRate() int {
+	p := rl.pacer()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.learned {
+		return 0
+	}
+	return p.SafeRate
+}
+
+// Snapshot copia o estado do limiter (o do Pacer, se houver) sem alterá-lo.
+func (rl *RateLimitClient) Snapshot() LimiterSnapshot {
+	p := rl.pacer()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := LimiterSnapshot{
+		Reset:         p.ResetTime,
+		DynamicRate:   p.DynamicRate,
+		EffectiveRate: p.effectiveRate(),
+		LastRequest:   p.LastRequest,
+		Shared:        p != rl,
+
+		IgnoringHeaders: Override.IgnoreHeaders(),
+	}
+	switch {
+	case Override.ForceRate() > 0:
+		s.Mode = "forced"
+	case p.SafeRate > 0 && !p.learned:
+		s.Mode = "fixed"
+	case p.AutoRateMode || p.learned:
+		s.Mode = "auto"
+	case p.Limit > 0 || !p.ResetTime.IsZero():
+		s.Mode = "headers"
+	default:
+		s.Mode = "initial"
+	}
+	if p.Limit > 0 {
+		remaining := p.Remaining
+		s.Limit, s.Remaining = p.Limit, &remaining
+	}
+	return s
+}
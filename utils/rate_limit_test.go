@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"segundos", "120", 120 * time.Second, true},
+		{"com espaços", " 5 ", 5 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"fracionário", "1.5", 1500 * time.Millisecond, true},
+		{"inteiro que estoura Duration", "10000000000", time.Duration(math.MaxInt64), true},
+		{"inteiro acima de int64", "99999999999999999999", time.Duration(math.MaxInt64), true},
+		{"fracionário que estoura Duration", "1e12", time.Duration(math.MaxInt64), true},
+		{"NaN", "NaN", 0, false},
+		{"infinito", "+Inf", 0, false},
+		{"vazio", "", 0, false},
+		{"lixo", "amanhã", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ParseRetryAfter(%q) = %v, %v; quer %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfterHTTPDate(t *testing.T) {
+	value := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	got, ok := ParseRetryAfter(value)
+	if !ok || got <= 80*time.Second || got > 90*time.Second {
+		t.Errorf("ParseRetryAfter(%q) = %v, %v; quer perto de 90s", value, got, ok)
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const maxRedirects = 10
+
+// redirectHeaders são os únicos headers levados para outro host num
+// redirect: o destino costuma ser uma URL pré-assinada de storage, que
+// rejeita credenciais do provedor (e não deve recebê-las).
+var redirectHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "User-Agent"}
+
+// CheckRedirect segue redirects (303 See Other para o arquivo do resultado,
+// por exemplo) como o http.Client, mas, ao trocar de host, descarta todos
+// os headers da requisição além dos de redirectHeaders: Authorization,
+// API keys em headers próprios, cookies e assinaturas ficam no provedor.
+// A resposta do destino é a resposta da requisição.
+func CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("redirects demais")
+	}
+	origin := via[0].URL
+	if strings.EqualFold(req.URL.Host, origin.Host) {
+		return nil
+	}
+	kept := http.Header{}
+	for _, name := range redirectHeaders {
+		if v := req.Header.Values(name); len(v) > 0 {
+			kept[name] = v
+		}
+	}
+	req.Header = kept
+	fmt.Fprintf(Output, "Redirecionado (%d) para %s, sem as credenciais da origem\n", req.Response.StatusCode, req.URL.Host)
+	return nil
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted substitui, num RequestRecord, o valor de headers e parâmetros
+// de query que parecem segredos.
+const Redacted = "[removido]"
+
+// RequestRecord é uma requisição como o cliente a recebeu, antes de
+// autenticação e assinatura, para repeti-la depois: a URL completa, os
+// headers e o sha256 do body (o body em si não é guardado).
+type RequestRecord struct {
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	BodySHA256 string            `json:"body_sha256,omitempty"`
+	BodySize   int               `json:"body_size,omitempty"`
+}
+
+// RequestLog guarda as primeiras Max requisições (0 = todas) feitas pelo
+// cliente; cada chamada a Do conta uma vez, com ou sem retries.
+type RequestLog struct {
+	Max int
+
+	mu      sync.Mutex
+	records []RequestRecord
+	dropped int
+}
+
+func (l *RequestLog) record(req *http.Request) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	full := l.Max > 0 && len(l.records) >= l.Max
+	if full {
+		l.dropped++
+	}
+	l.mu.Unlock()
+	if full {
+		return
+	}
+
+	rec := RequestRecord{Time: time.Now(), Method: req.Method, URL: redactURL(req), Headers: map[string]string{}}
+	for name := range req.Header {
+		value := req.Header.Get(name)
+		if SensitiveName(name) {
+			value = Redacted
+		}
+		rec.Headers[name] = value
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			h := sha256.New()
+			n, _ := io.Copy(h, body)
+			body.Close()
+			if n > 0 {
+				rec.BodySHA256, rec.BodySize = hex.EncodeToString(h.Sum(nil)), int(n)
+			}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, rec)
+}
+
+// Records devolve as requisições guardadas e quantas passaram de Max.
+func (l *RequestLog) Records() ([]RequestRecord, int) {
+	if l == nil {
+		return nil, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.records, l.dropped
+}
+
+func redactURL(req *http.Request) string {
+	return RedactURL(*req.URL)
+}
+
+// RedactURL tira o usuário e a senha da URL e troca por Redacted os
+// parâmetros com nome de segredo (ver SensitiveName).
+func RedactURL(u url.URL) string {
+	u.User = nil
+	q := u.Query()
+	changed := false
+	for name := range q {
+		if SensitiveName(name) {
+			q.Set(name, Redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// SensitiveName diz se o nome de um header ou parâmetro costuma levar um
+// segredo (token, chave, senha, cookie, assinatura).
+func SensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "cookie", "token", "secret", "password", "key", "signature"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Motivos de nova tentativa em RetryStats.ByReason.
+const (
+	Retry429        = "429"
+	Retry5xx        = "5xx"
+	RetryThrottled  = "throttled"
+	RetryNetwork    = "network"
+	RetryValidation = "validation"
+)
+
+// RetryStats resume as novas tentativas de um cliente: por motivo, quantas
+// requisições esgotaram MaxRetries e quanto do orçamento de tentativas
+// (MaxRetries por requisição) foi gasto, no total (BudgetUsed) e na
+// requisição que chegou mais perto do limite (PeakUsed).
+type RetryStats struct {
+	Requests   int64            `json:"requests"`
+	Retries    int64            `json:"retries"`
+	ByReason   map[string]int64 `json:"by_reason,omitempty"`
+	Exhausted  int64            `json:"exhausted,omitempty"`
+	MaxRetries int              `json:"max_retries"`
+	BudgetUsed float64          `json:"budget_used"`
+	PeakUsed   float64          `json:"peak_used"`
+
+	peak   int
+	budget float64
+}
+
+// Merge soma o, recalculando as frações sobre o orçamento somado.
+func (s *RetryStats) Merge(o RetryStats) {
+	s.budget += o.budget
+	s.Requests += o.Requests
+	s.Retries += o.Retries
+	s.Exhausted += o.Exhausted
+	for reason, n := range o.ByReason {
+		if s.ByReason == nil {
+			s.ByReason = map[string]int64{}
+		}
+		s.ByReason[reason] += n
+	}
+	s.MaxRetries = max(s.MaxRetries, o.MaxRetries)
+	s.PeakUsed = max(s.PeakUsed, o.PeakUsed)
+	s.BudgetUsed = 0
+	if s.budget > 0 {
+		s.BudgetUsed = float64(s.Retries) / s.budget
+	}
+}
+
+type retryMeter struct {
+	mu    sync.Mutex
+	stats RetryStats
+}
+
+func (m *retryMeter) retry(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stats.ByReason == nil {
+		m.stats.ByReason = map[string]int64{}
+	}
+	m.stats.ByReason[reason]++
+	m.stats.Retries++
+}
+
+// done encerra uma requisição que fez retries novas tentativas.
+func (m *retryMeter) done(retries int, exhausted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Requests++
+	m.stats.peak = max(m.stats.peak, retries)
+	if exhausted {
+		m.stats.Exhausted++
+	}
+}
+
+// throttleReason é o motivo da nova tentativa depois de uma resposta de
+// throttling.
+func throttleReason(status int) string {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return Retry429
+	case status >= 500:
+		return Retry5xx
+	}
+	return RetryThrottled
+}
+
+// RetryStats devolve o resumo das novas tentativas feitas até agora.
+func (rl *RateLimitClient) RetryStats() RetryStats {
+	m := &rl.retries
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.stats
+	s.ByReason = make(map[string]int64, len(m.stats.ByReason))
+	for reason, n := range m.stats.ByReason {
+		s.ByReason[reason] = n
+	}
+	s.MaxRetries = rl.MaxRetries
+	s.budget = float64(s.Requests) * float64(rl.MaxRetries)
+	if s.budget > 0 {
+		s.BudgetUsed = float64(s.Retries) / s.budget
+		s.PeakUsed = float64(s.peak) / float64(rl.MaxRetries)
+	}
+	return s
+}
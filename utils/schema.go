@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JSONSchema valida documentos contra o subconjunto de JSON Schema usado em
+// specs OpenAPI: type, nullable, properties, required, items, enum,
+// additionalProperties, minimum/maximum, minLength/maxLength, pattern,
+// allOf/anyOf/oneOf e $ref locais ("#/components/schemas/X").
+type JSONSchema struct {
+	Root map[string]any
+	Doc  map[string]any
+}
+
+const maxSchemaErrors = 20
+
+func NewJSONSchema(schema, doc map[string]any) *JSONSchema {
+	if doc == nil {
+		doc = schema
+	}
+	return &JSONSchema{Root: schema, Doc: doc}
+}
+
+func (s *JSONSchema) Validate(value any) []string {
+	var errs []string
+	s.validate(s.Root, value, "$", &errs, 0)
+	return errs
+}
+
+func (s *JSONSchema) resolve(node map[string]any) (map[string]any, error) {
+	for i := 0; i < 32; i++ {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node, nil
+		}
+		target, err := s.LookupRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		node = target
+	}
+	return nil, fmt.Errorf("$ref circular")
+}
+
+func (s *JSONSchema) LookupRef(ref string) (map[string]any, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("$ref externo não suportado: %s", ref)
+	}
+
+	var cur any = s.Doc
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("$ref inválido: %s", ref)
+		}
+		cur, ok = m[part]
+		if !ok {
+			return nil, fmt.Errorf("$ref não encontrado: %s", ref)
+		}
+	}
+
+	m, ok := cur.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("$ref não aponta para um schema: %s", ref)
+	}
+	return m, nil
+}
+
+func (s *JSONSchema) validate(node map[string]any, value any, path string, errs *[]string, depth int) {
+	if len(*errs) >= maxSchemaErrors || depth > 64 {
+		return
+	}
+
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	node, err := s.resolve(node)
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+
+	if value == nil {
+		if nullable, _ := node["nullable"].(bool); nullable {
+			return
+		}
+		if typeAllows(node["type"], "null") {
+			return
+		}
+		if _, hasType := node["type"]; hasType {
+			fail("valor nulo não permitido")
+		}
+		return
+	}
+
+	if t, ok := node["type"]; ok && !typeMatches(t, value) {
+		fail("esperado %v, recebido %s", t, jsonTypeName(value))
+		return
+	}
+
+	if enum, ok := node["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("valor %v fora do enum", value)
+		}
+	}
+
+	for _, sub := range schemaList(node["allOf"]) {
+		s.validate(sub, value, path, errs, depth+1)
+	}
+	if alts := schemaList(node["anyOf"]); len(alts) > 0 && s.countMatches(alts, value, path, depth) == 0 {
+		fail("não corresponde a nenhum schema de anyOf")
+	}
+	if alts := schemaList(node["oneOf"]); len(alts) > 0 {
+		if n := s.countMatches(alts, value, path, depth); n != 1 {
+			fail("corresponde a %d schemas de oneOf, esperado 1", n)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := node["properties"].(map[string]any)
+		if required, ok := node["required"].([]any); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, present := v[name]; !present {
+					fail("campo obrigatório %q ausente", name)
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := path + "." + k
+			if sub, ok := props[k].(map[string]any); ok {
+				s.validate(sub, v[k], childPath, errs, depth+1)
+				continue
+			}
+			switch extra := node["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					*errs = append(*errs, childPath+": campo não permitido")
+				}
+			case map[string]any:
+				s.validate(extra, v[k], childPath, errs, depth+1)
+			}
+		}
+	case []any:
+		if items, ok := node["items"].(map[string]any); ok {
+			for i, item := range v {
+				s.validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs, depth+1)
+			}
+		}
+		if n, ok := schemaNumber(node["minItems"]); ok && float64(len(v)) < n {
+			fail("mínimo de %v itens, recebido %d", n, len(v))
+		}
+		if n, ok := schemaNumber(node["maxItems"]); ok && float64(len(v)) > n {
+			fail("máximo de %v itens, recebido %d", n, len(v))
+		}
+	case string:
+		if n, ok := schemaNumber(node["minLength"]); ok && float64(len([]rune(v))) < n {
+			fail("tamanho mínimo %v", n)
+		}
+		if n, ok := schemaNumber(node["maxLength"]); ok && float64(len([]rune(v))) > n {
+			fail("tamanho máximo %v", n)
+		}
+		if pattern, ok := node["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("não corresponde ao padrão %s", pattern)
+			}
+		}
+	default:
+		if num, ok := schemaNumber(value); ok {
+			if n, ok := schemaNumber(node["minimum"]); ok && num < n {
+				fail("valor %v menor que o mínimo %v", num, n)
+			}
+			if n, ok := schemaNumber(node["maximum"]); ok && num > n {
+				fail("valor %v maior que o máximo %v", num, n)
+			}
+		}
+	}
+}
+
+func (s *JSONSchema) countMatches(alts []map[string]any, value any, path string, depth int) int {
+	n := 0
+	for _, alt := range alts {
+		var sub []string
+		s.validate(alt, value, path, &sub, depth+1)
+		if len(sub) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func schemaList(v any) []map[string]any {
+	list, _ := v.([]any)
+	var out []map[string]any
+	for _, item := range list {
+		if m, ok := item.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func schemaNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func typeAllows(t any, name string) bool {
+	switch tv := t.(type) {
+	case string:
+		return tv == name
+	case []any:
+		for _, item := range tv {
+			if item == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeMatches(t any, value any) bool {
+	actual := jsonTypeName(value)
+	if typeAllows(t, actual) {
+		return true
+	}
+	if actual == "integer" && typeAllows(t, "number") {
+		return true
+	}
+	return false
+}
+
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		if n, ok := schemaNumber(v); ok {
+			if n == math.Trunc(n) && !math.IsInf(n, 0) {
+				return "integer"
+			}
+			return "number"
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func jsonEqual(a, b any) bool {
+	if na, ok := schemaNumber(a); ok {
+		nb, ok := schemaNumber(b)
+		return ok && na == nb
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
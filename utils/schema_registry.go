@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SchemaRegistry fala a API REST do Confluent Schema Registry.
+type SchemaRegistry struct {
+	URL      string
+	User     string
+	Password string
+	Client   *http.Client
+}
+
+func (r *SchemaRegistry) do(ctx context.Context, method, path string, body any, out any) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(r.URL, "/")+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	if r.User != "" {
+		req.SetBasicAuth(r.User, r.Password)
+	}
+
+	data, err := fetchBody(r.Client, req)
+	if err != nil {
+		return fmt.Errorf("schema registry: %w", err)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Register registra (ou reencontra) o schema no subject e devolve o id.
+func (r *SchemaRegistry) Register(ctx context.Context, subject string, schema []byte) (int, error) {
+	var out struct {
+		ID int `json:"id"`
+	}
+	err := r.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", map[string]string{"schema": string(schema)}, &out)
+	return out.ID, err
+}
+
+// Latest busca a versão mais recente do subject.
+func (r *SchemaRegistry) Latest(ctx context.Context, subject string) (int, []byte, error) {
+	var out struct {
+		ID     int    `json:"id"`
+		Schema string `json:"schema"`
+	}
+	err := r.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &out)
+	return out.ID, []byte(out.Schema), err
+}
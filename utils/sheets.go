@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const SheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// SheetsClient fala com a API de valores do Google Sheets (v4).
+type SheetsClient struct {
+	Endpoint string
+	Auth     AuthProvider
+	Client   *http.Client
+}
+
+// SheetRange monta a notação A1 com o nome da aba entre aspas.
+func SheetRange(sheet, cells string) string {
+	r := "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+	if cells != "" {
+		r += "!" + cells
+	}
+	return r
+}
+
+func (c *SheetsClient) Get(ctx context.Context, spreadsheet, rng string) ([][]any, error) {
+	var out struct {
+		Values [][]any `json:"values"`
+	}
+	err := c.do(ctx, http.MethodGet, spreadsheet, rng, "", nil, &out)
+	return out.Values, err
+}
+
+func (c *SheetsClient) Clear(ctx context.Context, spreadsheet, rng string) error {
+	return c.do(ctx, http.MethodPost, spreadsheet, rng, ":clear", map[string]any{}, nil)
+}
+
+// Update sobrescreve as células a partir do início de rng.
+func (c *SheetsClient) Update(ctx context.Context, spreadsheet, rng string, rows [][]any) error {
+	return c.do(ctx, http.MethodPut, spreadsheet, rng, "?valueInputOption=USER_ENTERED", map[string]any{"values": rows}, nil)
+}
+
+// Append insere as linhas depois da última linha preenchida da tabela.
+func (c *SheetsClient) Append(ctx context.Context, spreadsheet, rng string, rows [][]any) error {
+	return c.do(ctx, http.MethodPost, spreadsheet, rng, ":append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS", map[string]any{"values": rows}, nil)
+}
+
+func (c *SheetsClient) do(ctx context.Context, method, spreadsheet, rng, suffix string, body any, out any) error {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://sheets.googleapis.com"
+	}
+	target := strings.TrimRight(endpoint, "/") + "/v4/spreadsheets/" + url.PathEscape(spreadsheet) + "/values/" + url.PathEscape(rng) + suffix
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for refreshed := false; ; refreshed = true {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if err := c.Auth.Apply(req); err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && !refreshed {
+			if err := c.Auth.Refresh(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("sheets: status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+		if out != nil {
+			return json.Unmarshal(data, out)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    url.Values
+		want string
+	}{
+		{"vazia", nil, ""},
+		{"chaves em ordem", url.Values{"b": {"2"}, "a": {"1"}}, "a=1&b=2"},
+		{"valores repetidos em ordem", url.Values{"k": {"z", "a"}}, "k=a&k=z"},
+		{"valor vazio", url.Values{"Action": {"ReceiveMessage"}, "x": {""}}, "Action=ReceiveMessage&x="},
+		{"espaço vira %20", url.Values{"q": {"a b"}}, "q=a%20b"},
+		{"não reservados ficam", url.Values{"v": {"a-b_c.d~e"}}, "v=a-b_c.d~e"},
+		{"reservados escapados", url.Values{"v": {"a+b/c*d=e&f"}}, "v=a%2Bb%2Fc%2Ad%3De%26f"},
+		{"chave escapada", url.Values{"a b": {"1"}}, "a%20b=1"},
+		{"utf-8", url.Values{"v": {"ç"}}, "v=%C3%A7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canonicalQuery(tt.q); got != tt.want {
+				t.Errorf("canonicalQuery(%v) = %q; quer %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Acquire espera a vez de uma requisição que outro processo vai fazer (ver
+// o comando limiter): a mesma fila, taxa e espera de reset de Do, sem
+// enviar nada. Quem recebe a permissão conta a resposta com Report.
+func (rl *RateLimitClient) Acquire(ctx context.Context) error {
+	if blocked := rl.WAFBlocked(); blocked != nil {
+		return blocked
+	}
+	return rl.pacer().waitTurn(ctx)
+}
+
+// Report aplica ao limiter a resposta de uma requisição feita com uma
+// permissão de Acquire, como Do faria com as próprias: os headers de cota e,
+// num throttling, a redução da taxa. Num throttling, devolve também a espera
+// que a resposta pede (Retry-After, reset da cota ou o backoff).
+func (rl *RateLimitClient) Report(status int, header http.Header) (wait time.Duration, throttled bool) {
+	p := rl.pacer()
+	resp := &http.Response{StatusCode: status, Header: header}
+	throttled = rl.dialect().throttled(resp)
+	rl.Usage.response(throttled)
+	p.updateRateLimitTracking(resp)
+	p.adjustDynamicRate(throttled)
+	if !throttled {
+		return 0, false
+	}
+
+	wait, err := p.getWaitTime(resp, 0)
+	var exceeded *RetryAfterExceededError
+	if errors.As(err, &exceeded) {
+		wait = exceeded.Wait
+	}
+	return wait, true
+}
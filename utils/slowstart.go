@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// slowStartFloor é a fração da taxa com que a rampa do slow start começa.
+const slowStartFloor = 0.25
+
+// warmRate é a taxa (req/s) a aplicar agora. Com SlowStart, depois de um
+// início a frio — a primeira requisição do cliente ou SlowStartIdle sem
+// nenhuma — a taxa recomeça em um quarto de rate e sobe linearmente até
+// ela em SlowStart: há provedores que barram a rajada de um cliente que
+// estava parado mesmo abaixo do limite nominal. Chame com rl.mu travado.
+func (rl *RateLimitClient) warmRate(rate int, now time.Time) float64 {
+	if rl.SlowStart <= 0 {
+		return float64(rate)
+	}
+	idle := rl.SlowStartIdle
+	if idle <= 0 {
+		idle = rl.SlowStart
+	}
+	if !rl.warmed || now.Sub(rl.LastRequest) >= idle {
+		rl.warmed, rl.warmSince = true, now
+		fmt.Fprintf(Output, "Início a frio: taxa começa em %.2g req/s e sobe até %d req/s em %v\n", float64(rate)*slowStartFloor, rate, rl.SlowStart)
+	}
+	elapsed := now.Sub(rl.warmSince)
+	if elapsed >= rl.SlowStart {
+		return float64(rate)
+	}
+	return float64(rate) * (slowStartFloor + (1-slowStartFloor)*float64(elapsed)/float64(rl.SlowStart))
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"sync"
+)
+
+// ContentSniffer reconhece um formato pelos primeiros bytes do body, para
+// quando o provedor manda um arquivo comprimido ou binário com um
+// Content-Type genérico (application/octet-stream, ou até application/json).
+type ContentSniffer struct {
+	Name      string
+	MediaType string
+	Ext       string
+	Match     func(head []byte) bool
+	// Decode abre o conteúdo de formatos que só comprimem um payload
+	// (gzip, bzip2), que segue para a validação; nil nos formatos que não
+	// são o JSON da resposta (ZIP, PDF) e só podem ser gravados com download.
+	Decode func(r io.Reader) (io.Reader, error)
+}
+
+// SniffLen é quanto do começo do body os sniffers recebem.
+const SniffLen = 512
+
+var (
+	sniffMu  sync.RWMutex
+	sniffers []ContentSniffer
+)
+
+func init() {
+	RegisterSniffer(ContentSniffer{
+		Name: "gzip", MediaType: "application/gzip", Ext: ".gz",
+		Match: prefix("\x1f\x8b"),
+		Decode: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	})
+	RegisterSniffer(ContentSniffer{
+		Name: "bzip2", MediaType: "application/x-bzip2", Ext: ".bz2",
+		Match: prefix("BZh"),
+		Decode: func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+	})
+	RegisterSniffer(ContentSniffer{
+		Name: "zstd", MediaType: "application/zstd", Ext: ".zst",
+		Match: prefix("\x28\xb5\x2f\xfd"),
+	})
+	RegisterSniffer(ContentSniffer{
+		Name: "zip", MediaType: "application/zip", Ext: ".zip",
+		Match: func(head []byte) bool {
+			return bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06"))
+		},
+	})
+	RegisterSniffer(ContentSniffer{
+		Name: "pdf", MediaType: "application/pdf", Ext: ".pdf",
+		Match: prefix("%PDF-"),
+	})
+}
+
+func prefix(magic string) func([]byte) bool {
+	return func(head []byte) bool {
+		return bytes.HasPrefix(head, []byte(magic))
+	}
+}
+
+// RegisterSniffer acrescenta um formato; os registrados são testados na
+// ordem de registro. Registrar o mesmo nome duas vezes é erro de
+// programação.
+func RegisterSniffer(s ContentSniffer) {
+	sniffMu.Lock()
+	defer sniffMu.Unlock()
+
+	for _, other := range sniffers {
+		if other.Name == s.Name {
+			panic(fmt.Sprintf("sniffer %q registrado duas vezes", s.Name))
+		}
+	}
+	sniffers = append(sniffers, s)
+}
+
+// SniffContent devolve o formato reconhecido em head, o começo do body.
+func SniffContent(head []byte) (ContentSniffer, bool) {
+	sniffMu.RLock()
+	defer sniffMu.RUnlock()
+
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+	for _, s := range sniffers {
+		if s.Match(head) {
+			return s, true
+		}
+	}
+	return ContentSniffer{}, false
+}
+
+// genericTypes são os Content-Type que não dizem o formato de verdade: com
+// eles, o que o sniffer reconhece vale mais que o header.
+var genericTypes = map[string]bool{
+	"":                           true,
+	"application/octet-stream":   true,
+	"binary/octet-stream":        true,
+	"application/binary":         true,
+	"application/download":       true,
+	"application/force-download": true,
+	"application/x-download":     true,
+	"application/json":           true,
+	"text/plain":                 true,
+}
+
+// GenericContentType diz se o Content-Type é genérico (ver genericTypes).
+func GenericContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType == ""
+	}
+	return genericTypes[mediaType]
+}
+
+// DecodeSniffed abre o body comprimido com s.Decode, até max bytes abertos
+// (0 = sem limite), para um payload pequeno não virar gigabytes em memória.
+func DecodeSniffed(s ContentSniffer, body []byte, max int64) ([]byte, error) {
+	r, err := s.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir %s: %w", s.Name, err)
+	}
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir %s: %w", s.Name, err)
+	}
+	if max > 0 && int64(len(out)) > max {
+		return nil, fmt.Errorf("%s aberto passa de %d bytes", s.Name, max)
+	}
+	return out, nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import "time"
+
+// sameWake é a distância até a qual as retomadas de workers diferentes
+// contam como o mesmo instante: o mesmo Retry-After visto por respostas
+// que chegaram quase juntas, ou o mesmo reset de cota.
+const sameWake = time.Second
+
+// DefaultThrottleStagger é o ThrottleStagger de NewRateLimitClient.
+const DefaultThrottleStagger = 100 * time.Millisecond
+
+// stagger espalha a retomada dos workers que pararam pelo mesmo throttling:
+// o primeiro a pedir acorda entre wait e wait+ThrottleStagger, o segundo um
+// ThrottleStagger depois, e assim por diante, em vez de todos voltarem no
+// mesmo instante do reset e estourarem o limite de novo. Vale entre os
+// clientes de um Pacer, já que é chamado nele.
+func (rl *RateLimitClient) stagger(wait time.Duration) time.Duration {
+	if rl.ThrottleStagger <= 0 {
+		return wait
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	target := now.Add(wait)
+	last := rl.wakeAt.Add(time.Duration(rl.wakeSlots) * rl.ThrottleStagger)
+	if rl.wakeSlots == 0 || now.After(last) || target.Sub(rl.wakeAt).Abs() > sameWake {
+		rl.wakeAt, rl.wakeSlots = target, 0
+	}
+	slot := rl.wakeSlots
+	rl.wakeSlots++
+
+	// nunca antes do que a própria resposta pediu
+	delay := time.Duration(slot)*rl.ThrottleStagger + RandomJitter(rl.ThrottleStagger)
+	return max(wait, rl.wakeAt.Sub(now)+delay)
+}
@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateStore guarda pequenos arquivos JSON de estado entre execuções
+// (contagens anteriores, chaves já vistas, watermarks...). Cada arquivo é um
+// envelope com o tipo do estado, a versão do schema e o sha256 dos dados:
+// estados de versões anteriores são migrados na leitura (com cópia do
+// original), e arquivos corrompidos ou de uma versão mais nova viram erro,
+// com cópia, em vez de serem zerados em silêncio.
+type StateStore struct {
+	Dir string
+
+	// aead, com SetKey, cifra os dados de cada arquivo
+	aead cipher.AEAD
+}
+
+// stateFormat é a versão do envelope; arquivos sem envelope são da versão
+// 1 do schema do seu tipo. sealedFormat é o envelope com os dados cifrados
+// (Sealed no lugar de Data), que versões sem suporte recusam.
+const (
+	stateFormat  = 1
+	sealedFormat = 2
+)
+
+type stateEnvelope struct {
+	Format   int             `json:"format"`
+	Kind     string          `json:"kind"`
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"`
+	SavedAt  time.Time       `json:"saved_at"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Sealed   []byte          `json:"sealed,omitempty"`
+}
+
+// StateMigration converte os dados de um estado da versão from para
+// from+1.
+type StateMigration func(data json.RawMessage) (json.RawMessage, error)
+
+var (
+	stateMu         sync.RWMutex
+	stateMigrations = map[string]map[int]StateMigration{}
+)
+
+// RegisterStateMigration registra a migração de um tipo de estado (o nome
+// até o primeiro "-": watermark, dedup, usage...) da versão from para
+// from+1; a versão atual do tipo passa a ser a seguinte à maior migração.
+func RegisterStateMigration(kind string, from int, m StateMigration) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if stateMigrations[kind] == nil {
+		stateMigrations[kind] = map[int]StateMigration{}
+	}
+	if _, dup := stateMigrations[kind][from]; dup {
+		panic(fmt.Sprintf("migração de estado %s v%d registrada duas vezes", kind, from))
+	}
+	stateMigrations[kind][from] = m
+}
+
+// StateVersion é a versão atual do schema de um tipo de estado.
+func StateVersion(kind string) int {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+
+	version := 1
+	for from := range stateMigrations[kind] {
+		version = max(version, from+1)
+	}
+	return version
+}
+
+func stateKind(name string) string {
+	kind, _, _ := strings.Cut(name, "-")
+	return kind
+}
+
+func NewStateStore(dir string) (*StateStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório de estado: %w", err)
+	}
+	return &StateStore{Dir: dir}, nil
+}
+
+func (s *StateStore) path(name string) string {
+	if kind, job, ok := jobState(name); ok {
+		return filepath.Join(s.Dir, jobStateDir, job, kind+".json")
+	}
+	return s.legacyPath(name)
+}
+
+func (s *StateStore) Load(name string, v any) (bool, error) {
+	raw, err := s.read(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	data, version, err := s.openEnvelope(name, raw)
+	if errors.Is(err, errStateKey) {
+		return false, fmt.Errorf("estado %s: %w", name, err)
+	}
+	if err != nil {
+		return false, s.corrupted(name, raw, err)
+	}
+
+	kind := stateKind(name)
+	current := StateVersion(kind)
+	if version > current {
+		return false, fmt.Errorf("estado %s na versão %d, mais nova que a suportada (%d): atualize o api-requester", name, version, current)
+	}
+	if version < current {
+		if data, err = s.migrate(name, kind, raw, data, version, current); err != nil {
+			return false, err
+		}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, s.corrupted(name, raw, err)
+	}
+	return true, nil
+}
+
+// openEnvelope devolve os dados e a versão do schema, conferindo o sha256;
+// arquivos anteriores ao envelope são devolvidos inteiros, na versão 1.
+func (s *StateStore) openEnvelope(name string, raw []byte) (json.RawMessage, int, error) {
+	if !json.Valid(raw) {
+		return nil, 0, fmt.Errorf("JSON inválido")
+	}
+	var env stateEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil || env.Format == 0 || (env.Data == nil && env.Sealed == nil) {
+		return raw, 1, nil
+	}
+	if env.Format > sealedFormat {
+		return nil, 0, fmt.Errorf("formato %d desconhecido", env.Format)
+	}
+	if env.Format == sealedFormat {
+		// o GCM já confere a integridade
+		data, err := s.open(name, env)
+		return data, env.Version, err
+	}
+	if sum := stateChecksum(env.Data); sum != env.Checksum {
+		return nil, 0, fmt.Errorf("checksum não confere")
+	}
+	return env.Data, env.Version, nil
+}
+
+func stateChecksum(data []byte) string {
+	var compact bytes.Buffer
+	if json.Compact(&compact, data) == nil {
+		data = compact.Bytes()
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// migrate aplica as migrações em sequência e guarda uma cópia do arquivo
+// original, que o próximo Save sobrescreve. As cópias, como o estado, que
+// pode ter tokens, são só do usuário.
+func (s *StateStore) migrate(name, kind string, raw, data json.RawMessage, from, to int) (json.RawMessage, error) {
+	stateMu.RLock()
+	migrations := stateMigrations[kind]
+	stateMu.RUnlock()
+
+	for version := from; version < to; version++ {
+		m, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("estado %s: sem migração da versão %d para %d", name, version, version+1)
+		}
+		var err error
+		if data, err = m(data); err != nil {
+			return nil, fmt.Errorf("estado %s: migração da versão %d: %w", name, version, err)
+		}
+	}
+
+	backup := s.path(name) + fmt.Sprintf(".v%d.bak", from)
+	if _, err := os.Stat(backup); os.IsNotExist(err) {
+		os.MkdirAll(filepath.Dir(backup), 0o755)
+		if err := os.WriteFile(backup, raw, 0o600); err != nil {
+			return nil, fmt.Errorf("estado %s: erro ao copiar antes da migração: %w", name, err)
+		}
+	}
+	return data, nil
+}
+
+// corrupted guarda uma cópia do arquivo ilegível antes que um Save o
+// substitua.
+func (s *StateStore) corrupted(name string, raw []byte, cause error) error {
+	backup := s.path(name) + ".corrupt-" + time.Now().Format("20060102T150405") + ".bak"
+	os.MkdirAll(filepath.Dir(backup), 0o755)
+	if err := os.WriteFile(backup, raw, 0o600); err != nil {
+		return fmt.Errorf("estado %s corrompido (%v); cópia falhou: %w", name, cause, err)
+	}
+	return fmt.Errorf("estado %s corrompido (%v); cópia em %s", name, cause, backup)
+}
+
+// savedVersion é a versão do schema do arquivo atual (0 sem arquivo ou
+// envelope legível).
+func (s *StateStore) savedVersion(name string) int {
+	raw, err := s.read(name)
+	if err != nil {
+		return 0
+	}
+	var env stateEnvelope
+	if json.Unmarshal(raw, &env) != nil || env.Format == 0 {
+		return 0
+	}
+	return env.Version
+}
+
+func (s *StateStore) Save(name string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	kind := stateKind(name)
+	if newer := s.savedVersion(name); newer > StateVersion(kind) {
+		return fmt.Errorf("estado %s na versão %d, mais nova que a suportada (%d): não sobrescrito", name, newer, StateVersion(kind))
+	}
+	if err := s.checkSealed(name); err != nil {
+		return fmt.Errorf("estado %s: %w: não sobrescrito", name, err)
+	}
+	env := stateEnvelope{
+		Format:   stateFormat,
+		Kind:     kind,
+		Version:  StateVersion(kind),
+		Checksum: stateChecksum(payload),
+		SavedAt:  time.Now(),
+		Data:     payload,
+	}
+	if s.aead != nil {
+		if err := s.seal(name, &env); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	target := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "tmp-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := SyncFile(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		return err
+	}
+	if err := SyncDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if legacy := s.legacyPath(name); legacy != target {
+		if err := os.Remove(legacy); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// List devolve os nomes dos estados que começam com prefix, em ordem, tanto
+// da raiz de Dir quanto dos diretórios dos jobs.
+func (s *StateStore) List(prefix string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.Dir, prefix+"*.json"))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, m := range matches {
+		seen[strings.TrimSuffix(filepath.Base(m), ".json")] = true
+	}
+	perJob, err := filepath.Glob(filepath.Join(s.Dir, jobStateDir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range perJob {
+		name := strings.TrimSuffix(filepath.Base(m), ".json") + "-" + filepath.Base(filepath.Dir(m))
+		if _, _, ok := jobState(name); ok && strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Remove apaga um estado; não existir não é erro.
+func (s *StateStore) Remove(name string) error {
+	for _, path := range []string{s.path(name), s.legacyPath(name)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
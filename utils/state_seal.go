@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errStateKey = errors.New("estado cifrado: chave ausente ou diferente da que o gravou")
+
+// SetKey passa a cifrar os arquivos gravados (AES-256-GCM, com a chave
+// derivada do sha256 de secret). Arquivos em texto aberto continuam
+// legíveis e são cifrados no próximo Save.
+func (s *StateStore) SetKey(secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("chave do estado vazia")
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	s.aead, err = cipher.NewGCM(block)
+	return err
+}
+
+// sealAAD amarra o texto cifrado ao arquivo e à versão do schema, que
+// ficam abertos no envelope: trocar um arquivo por outro não passa.
+func sealAAD(name string, env stateEnvelope) []byte {
+	return []byte(name + "|" + env.Kind + "|" + strconv.Itoa(env.Version))
+}
+
+func (s *StateStore) seal(name string, env *stateEnvelope) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	// o sha256 do texto aberto entregaria valores fáceis de adivinhar
+	env.Format, env.Checksum = sealedFormat, ""
+	env.Sealed = s.aead.Seal(nonce, nonce, env.Data, sealAAD(name, *env))
+	env.Data = nil
+	return nil
+}
+
+// checkSealed recusa sobrescrever um arquivo cifrado com outra chave (ou
+// sem chave), o que perderia o estado.
+func (s *StateStore) checkSealed(name string) error {
+	raw, err := s.read(name)
+	if err != nil {
+		return nil
+	}
+	var env stateEnvelope
+	if json.Unmarshal(raw, &env) != nil || env.Format != sealedFormat {
+		return nil
+	}
+	_, err = s.open(name, env)
+	return err
+}
+
+func (s *StateStore) open(name string, env stateEnvelope) ([]byte, error) {
+	n := 0
+	if s.aead != nil {
+		n = s.aead.NonceSize()
+	}
+	if s.aead == nil || len(env.Sealed) < n {
+		return nil, errStateKey
+	}
+	data, err := s.aead.Open(nil, env.Sealed[:n], env.Sealed[n:], sealAAD(name, env))
+	if err != nil {
+		return nil, errStateKey
+	}
+	return data, nil
+}
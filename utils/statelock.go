@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jobStateDir é o subdiretório de Dir com um diretório por job, onde ficam
+// os estados dos tipos registrados com RegisterJobState.
+const jobStateDir = "jobs"
+
+// ErrStateLocked é o LockJob de um job que outra invocação está rodando.
+var ErrStateLocked = errors.New("estado em uso por outra execução")
+
+var jobStateKinds = map[string]bool{}
+
+// RegisterJobState marca tipos de estado (watermark, dedup...) como do job:
+// o estado "<tipo>-<job>" fica em Dir/jobs/<job>/<tipo>.json, junto com os
+// outros do mesmo job e sob o lock de LockJob. Os demais tipos (usage,
+// profiles...) são compartilhados e ficam na raiz de Dir.
+func RegisterJobState(kinds ...string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	for _, kind := range kinds {
+		jobStateKinds[kind] = true
+	}
+}
+
+// jobState separa o nome de um estado por job em tipo e job.
+func jobState(name string) (kind, job string, ok bool) {
+	kind, job, found := strings.Cut(name, "-")
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return kind, job, found && job != "" && jobStateKinds[kind]
+}
+
+// legacyPath é onde o estado ficava antes do diretório por job; Load ainda
+// lê dali, e o próximo Save move o arquivo.
+func (s *StateStore) legacyPath(name string) string {
+	return filepath.Join(s.Dir, name+".json")
+}
+
+func (s *StateStore) read(name string) ([]byte, error) {
+	raw, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) && s.path(name) != s.legacyPath(name) {
+		return os.ReadFile(s.legacyPath(name))
+	}
+	return raw, err
+}
+
+// LockJob impede que outra invocação (outro processo com o mesmo Dir) rode
+// o job ao mesmo tempo e intercale leituras e gravações dos seus estados.
+// Com o job já em uso, devolve ErrStateLocked sem esperar. unlock nunca é
+// nil, mesmo com erro.
+func (s *StateStore) LockJob(job string) (unlock func(), err error) {
+	unlock, err = s.lock(filepath.Join(s.Dir, jobStateDir, job, ".lock"), false)
+	if errors.Is(err, errLockBusy) {
+		err = ErrStateLocked
+	}
+	return unlock, err
+}
+
+// Lock serializa entre processos a leitura e a gravação de um estado
+// compartilhado entre jobs, esperando quem já o tem.
+func (s *StateStore) Lock(name string) (unlock func(), err error) {
+	return s.lock(strings.TrimSuffix(s.path(name), ".json")+".lock", true)
+}
+
+func (s *StateStore) lock(path string, wait bool) (func(), error) {
+	noop := func() {}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return noop, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return noop, err
+	}
+	if err := lockFile(f, wait); err != nil {
+		f.Close()
+		if errors.Is(err, errLockBusy) {
+			return noop, err
+		}
+		return noop, fmt.Errorf("lock de %s: %w", path, err)
+	}
+	return func() { f.Close() }, nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AttemptRecord é uma tentativa HTTP com os headers capturados. A URL vai
+// sem query string, que pode conter tokens ou assinaturas.
+type AttemptRecord struct {
+	Time    time.Time         `json:"time"`
+	URL     string            `json:"url"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// HeaderCapture guarda os headers escolhidos de cada tentativa (inclusive
+// as que voltaram 429).
+type HeaderCapture struct {
+	Names []string
+
+	mu       sync.Mutex
+	attempts []AttemptRecord
+}
+
+func NewHeaderCapture(names []string) *HeaderCapture {
+	return &HeaderCapture{Names: names}
+}
+
+func (c *HeaderCapture) Record(resp *http.Response) {
+	rec := AttemptRecord{Time: time.Now(), Status: resp.StatusCode}
+	if resp.Request != nil {
+		u := *resp.Request.URL
+		u.RawQuery, u.User = "", nil
+		rec.URL = u.String()
+	}
+	for _, name := range c.Names {
+		if v := resp.Header.Get(name); v != "" {
+			if rec.Headers == nil {
+				rec.Headers = map[string]string{}
+			}
+			rec.Headers[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts = append(c.attempts, rec)
+}
+
+// Drain devolve as tentativas registradas e limpa a lista.
+func (c *HeaderCapture) Drain() []AttemptRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := c.attempts
+	c.attempts = nil
+	return out
+}
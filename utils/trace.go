@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// AttemptTiming descreve uma tentativa HTTP: quanto durou (até o fim do
+// body, quando ele é lido), quantos bytes chegaram e quanto o cliente
+// esperou antes da próxima.
+type AttemptTiming struct {
+	Status  int
+	Elapsed time.Duration
+	Bytes   int64
+	Wait    time.Duration
+	Err     string
+
+	// Conn é "new" ou "reused" (com Idle, o tempo ociosa no pool); vazio
+	// quando nenhuma conexão chegou a ser obtida.
+	Conn string
+	Idle time.Duration
+}
+
+// AttemptTrace guarda as tentativas da última requisição feita com o
+// contexto; cada Do recomeça o registro.
+type AttemptTrace struct {
+	mu       sync.Mutex
+	attempts []*AttemptTiming
+}
+
+type attemptTraceKey struct{}
+
+func WithAttemptTrace(ctx context.Context) (context.Context, *AttemptTrace) {
+	t := &AttemptTrace{}
+	return context.WithValue(ctx, attemptTraceKey{}, t), t
+}
+
+func attemptTraceFrom(ctx context.Context) *AttemptTrace {
+	t, _ := ctx.Value(attemptTraceKey{}).(*AttemptTrace)
+	return t
+}
+
+// Attempts devolve uma cópia do registro; seguro com nil.
+func (t *AttemptTrace) Attempts() []AttemptTiming {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]AttemptTiming, len(t.attempts))
+	for i, a := range t.attempts {
+		out[i] = *a
+	}
+	return out
+}
+
+func (t *AttemptTrace) reset() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.attempts = nil
+	t.mu.Unlock()
+}
+
+// attemptSpan acompanha uma tentativa em andamento.
+type attemptSpan struct {
+	trace   *AttemptTrace
+	rec     *AttemptTiming
+	started time.Time
+}
+
+func (t *AttemptTrace) begin() *attemptSpan {
+	if t == nil {
+		return nil
+	}
+	rec := &AttemptTiming{}
+	t.mu.Lock()
+	t.attempts = append(t.attempts, rec)
+	t.mu.Unlock()
+	return &attemptSpan{trace: t, rec: rec, started: time.Now()}
+}
+
+func (s *attemptSpan) update(fn func(*AttemptTiming)) {
+	if s == nil {
+		return
+	}
+	s.trace.mu.Lock()
+	fn(s.rec)
+	s.rec.Elapsed = time.Since(s.started)
+	s.trace.mu.Unlock()
+}
+
+func (s *attemptSpan) fail(err error) {
+	s.update(func(r *AttemptTiming) { r.Err = err.Error() })
+}
+
+func (s *attemptSpan) response(status int) {
+	s.update(func(r *AttemptTiming) { r.Status = status })
+}
+
+func (s *attemptSpan) retryAfter(wait time.Duration) {
+	if s == nil {
+		return
+	}
+	s.trace.mu.Lock()
+	s.rec.Wait = wait
+	s.trace.mu.Unlock()
+}
+
+// wrap conta os bytes do body e estende a duração até a leitura terminar,
+// para que um timeout no meio do body apareça com o que já tinha chegado.
+func (s *attemptSpan) wrap(body io.ReadCloser) io.ReadCloser {
+	if s == nil {
+		return body
+	}
+	return &tracedBody{ReadCloser: body, span: s}
+}
+
+type tracedBody struct {
+	io.ReadCloser
+	span *attemptSpan
+	done bool
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done {
+		return n, err
+	}
+	b.span.update(func(r *AttemptTiming) {
+		r.Bytes += int64(n)
+		if err != nil && err != io.EOF {
+			r.Err = err.Error()
+		}
+	})
+	b.done = err != nil
+	return n, err
+}
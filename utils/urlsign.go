@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URLSigner adiciona à query string a chave, a expiração e o HMAC da URL
+// canônica (path + query ordenada, sem a assinatura). É aplicado pelo
+// RateLimitClient antes de cada tentativa, então retries saem com
+// assinatura nova.
+type URLSigner struct {
+	Key            string
+	Secret         []byte
+	KeyParam       string
+	ExpiresParam   string
+	SignatureParam string
+	TTL            time.Duration
+	Algorithm      string
+	Encoding       string
+}
+
+func (s *URLSigner) Validate() error {
+	if len(s.Secret) == 0 {
+		return fmt.Errorf("segredo de assinatura vazio")
+	}
+	if s.SignatureParam == "" {
+		return fmt.Errorf("parâmetro da assinatura não definido")
+	}
+	if _, err := s.newHash(); err != nil {
+		return err
+	}
+	switch s.Encoding {
+	case "", "hex", "base64", "base64url":
+	default:
+		return fmt.Errorf("encoding %q não suportado (hex, base64, base64url)", s.Encoding)
+	}
+	return nil
+}
+
+func (s *URLSigner) newHash() (func() hash.Hash, error) {
+	switch strings.ToLower(s.Algorithm) {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	}
+	return nil, fmt.Errorf("algoritmo %q não suportado (sha256, sha1)", s.Algorithm)
+}
+
+func (s *URLSigner) Sign(req *http.Request, now time.Time) error {
+	newHash, err := s.newHash()
+	if err != nil {
+		return err
+	}
+
+	q := req.URL.Query()
+	q.Del(s.SignatureParam)
+	if s.KeyParam != "" {
+		q.Set(s.KeyParam, s.Key)
+	}
+	if s.ExpiresParam != "" {
+		q.Set(s.ExpiresParam, strconv.FormatInt(now.Add(s.TTL).Unix(), 10))
+	}
+
+	canonical := req.URL.EscapedPath() + "?" + canonicalQuery(q)
+
+	mac := hmac.New(newHash, s.Secret)
+	mac.Write([]byte(canonical))
+	sum := mac.Sum(nil)
+
+	var sig string
+	switch s.Encoding {
+	case "base64":
+		sig = base64.StdEncoding.EncodeToString(sum)
+	case "base64url":
+		sig = base64.RawURLEncoding.EncodeToString(sum)
+	default:
+		sig = hex.EncodeToString(sum)
+	}
+
+	q.Set(s.SignatureParam, sig)
+	req.URL.RawQuery = canonicalQuery(q)
+	return nil
+}
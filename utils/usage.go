@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Usage é o consumo acumulado de um cliente: respostas recebidas, bytes de
+// body lidos e respostas de throttling (429 ou o que o dialeto considerar).
+type Usage struct {
+	Requests  int64 `json:"requests"`
+	Bytes     int64 `json:"bytes"`
+	Throttled int64 `json:"throttled"`
+}
+
+func (u *Usage) Add(o Usage) {
+	u.Requests += o.Requests
+	u.Bytes += o.Bytes
+	u.Throttled += o.Throttled
+}
+
+// UsageMeter conta o Usage de um RateLimitClient; seguro com nil.
+type UsageMeter struct {
+	requests  atomic.Int64
+	bytes     atomic.Int64
+	throttled atomic.Int64
+}
+
+func (m *UsageMeter) Snapshot() Usage {
+	if m == nil {
+		return Usage{}
+	}
+	return Usage{Requests: m.requests.Load(), Bytes: m.bytes.Load(), Throttled: m.throttled.Load()}
+}
+
+func (m *UsageMeter) response(throttled bool) {
+	if m == nil {
+		return
+	}
+	m.requests.Add(1)
+	if throttled {
+		m.throttled.Add(1)
+	}
+}
+
+func (m *UsageMeter) wrap(body io.ReadCloser) io.ReadCloser {
+	if m == nil {
+		return body
+	}
+	return &meteredBody{ReadCloser: body, meter: m}
+}
+
+type meteredBody struct {
+	io.ReadCloser
+	meter *UsageMeter
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.meter.bytes.Add(int64(n))
+	return n, err
+}
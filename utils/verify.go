@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	VerifyJWS         = "jws"
+	VerifyJWSDetached = "jws_detached"
+	VerifySignature   = "signature"
+)
+
+// SignatureVerifier confere a assinatura do provedor em cada resposta 2xx:
+// jws (o body é um JWS compacto, e o payload passa a ser o body),
+// jws_detached (JWS com payload vazio no Header, RFC 7515 apêndice F,
+// assinando o body) ou signature (assinatura crua do body no Header, em
+// base64 ou hex). Vale qualquer uma das chaves, para permitir rotação.
+type SignatureVerifier struct {
+	Mode     string
+	Header   string
+	Alg      string
+	Encoding string
+
+	Keys   []crypto.PublicKey
+	Secret []byte
+}
+
+// SignatureError é a resposta cuja assinatura não confere; não é
+// retentada.
+type SignatureError struct {
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return "assinatura da resposta inválida: " + e.Reason
+}
+
+// ParsePublicKeys lê as chaves públicas (PUBLIC KEY, RSA PUBLIC KEY ou
+// CERTIFICATE) de um PEM.
+func ParsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, cert.PublicKey)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("nenhuma chave pública no PEM")
+	}
+	return keys, nil
+}
+
+// Verify confere a resposta e devolve o body a ser usado: o payload, no
+// modo jws; o próprio body nos demais.
+func (v *SignatureVerifier) Verify(header http.Header, body []byte) ([]byte, error) {
+	switch v.Mode {
+	case VerifyJWS:
+		return v.verifyJWS(strings.TrimSpace(string(body)), nil)
+	case VerifyJWSDetached:
+		value := strings.TrimSpace(header.Get(v.Header))
+		if value == "" {
+			return nil, &SignatureError{Reason: "header " + v.Header + " ausente"}
+		}
+		if _, err := v.verifyJWS(value, body); err != nil {
+			return nil, err
+		}
+		return body, nil
+	}
+
+	value := strings.TrimSpace(header.Get(v.Header))
+	if value == "" {
+		return nil, &SignatureError{Reason: "header " + v.Header + " ausente"}
+	}
+	// formatos como "sha256=<hex>" trazem o algoritmo antes do valor
+	if _, after, ok := strings.Cut(value, "="); ok && v.Encoding == "hex" {
+		value = after
+	}
+	var sig []byte
+	var err error
+	if v.Encoding == "hex" {
+		sig, err = hex.DecodeString(value)
+	} else {
+		sig, err = decodeBase64Key(value)
+	}
+	if err != nil {
+		return nil, &SignatureError{Reason: "assinatura em " + v.Header + " mal codificada"}
+	}
+	if !v.verifyAny(v.Alg, body, sig, false) {
+		return nil, &SignatureError{Reason: "não confere com nenhuma chave (" + v.Alg + ")"}
+	}
+	return body, nil
+}
+
+// verifyJWS confere um JWS compacto; com detached, o payload (vazio no
+// token) é o body.
+func (v *SignatureVerifier) verifyJWS(token string, detached []byte) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &SignatureError{Reason: fmt.Sprintf("JWS compacto deve ter 3 partes, veio com %d", len(parts))}
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, &SignatureError{Reason: "header do JWS inválido"}
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(rawHeader, &h); err != nil {
+		return nil, &SignatureError{Reason: "header do JWS inválido"}
+	}
+	if h.Alg == "" || strings.EqualFold(h.Alg, "none") {
+		return nil, &SignatureError{Reason: "JWS sem algoritmo"}
+	}
+	if v.Alg != "" && h.Alg != v.Alg {
+		return nil, &SignatureError{Reason: fmt.Sprintf("JWS com alg %s, esperado %s", h.Alg, v.Alg)}
+	}
+
+	payload := parts[1]
+	if detached != nil {
+		if payload != "" {
+			return nil, &SignatureError{Reason: "JWS destacado com payload no token"}
+		}
+		payload = base64.RawURLEncoding.EncodeToString(detached)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &SignatureError{Reason: "assinatura do JWS mal codificada"}
+	}
+	if !v.verifyAny(h.Alg, []byte(parts[0]+"."+payload), sig, true) {
+		return nil, &SignatureError{Reason: "não confere com nenhuma chave (" + h.Alg + ")"}
+	}
+	if detached != nil {
+		return detached, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, &SignatureError{Reason: "payload do JWS mal codificado"}
+	}
+	return data, nil
+}
+
+// verifyAny tenta as chaves compatíveis com o algoritmo; em JWS, ECDSA vem
+// como r||s, e fora dele também é aceita em ASN.1.
+func (v *SignatureVerifier) verifyAny(alg string, signed, sig []byte, jws bool) bool {
+	if !ValidSignatureAlg(alg) {
+		return false
+	}
+	newHash, cryptoHash := algHash(alg)
+	var digest []byte
+	if newHash != nil {
+		h := newHash()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		if v.Secret == nil {
+			return false
+		}
+		mac := hmac.New(newHash, v.Secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), sig)
+	}
+
+	for _, key := range v.Keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			switch alg[:2] {
+			case "RS":
+				if rsa.VerifyPKCS1v15(k, cryptoHash, digest, sig) == nil {
+					return true
+				}
+			case "PS":
+				if rsa.VerifyPSS(k, cryptoHash, digest, sig, nil) == nil {
+					return true
+				}
+			}
+		case *ecdsa.PublicKey:
+			if alg[:2] != "ES" {
+				continue
+			}
+			size := (k.Curve.Params().BitSize + 7) / 8
+			if len(sig) == 2*size {
+				r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+				if ecdsa.Verify(k, digest, r, s) {
+					return true
+				}
+			}
+			if !jws && ecdsa.VerifyASN1(k, digest, sig) {
+				return true
+			}
+		case ed25519.PublicKey:
+			if alg == "EdDSA" && ed25519.Verify(k, signed, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func algHash(alg string) (func() hash.Hash, crypto.Hash) {
+	switch strings.TrimLeft(alg, "HRPES") {
+	case "256":
+		return sha256.New, crypto.SHA256
+	case "384":
+		return sha512.New384, crypto.SHA384
+	case "512":
+		return sha512.New, crypto.SHA512
+	}
+	return nil, 0
+}
+
+// verifyBody troca o body de uma resposta 2xx pelo conteúdo assinado,
+// depois de conferido.
+func (rl *RateLimitClient) verifyBody(resp *http.Response) error {
+	if rl.Verifier == nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	payload, err := rl.Verifier.Verify(resp.Header, data)
+	if err != nil {
+		return err
+	}
+	if rl.Verifier.Mode == VerifyJWS && json.Valid(payload) {
+		resp.Header.Set("Content-Type", "application/json")
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(payload)))
+	resp.ContentLength = int64(len(payload))
+	resp.Body = io.NopCloser(bytes.NewReader(payload))
+	return nil
+}
+
+// ValidSignatureAlg diz se o algoritmo é suportado.
+func ValidSignatureAlg(alg string) bool {
+	if alg == "EdDSA" {
+		return true
+	}
+	newHash, _ := algHash(alg)
+	return newHash != nil && len(alg) == 5 && strings.Contains("HS RS PS ES", alg[:2])
+}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func b64url(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// RFC 7520, seção 4.4: HS256 sobre o texto do Frodo.
+const (
+	rfc7520Key       = "hJtXIZ2uSN5kbQfbtTNWbpdmhkV8FJG-Onbc6mxCcYg"
+	rfc7520Protected = "eyJhbGciOiJIUzI1NiIsImtpZCI6IjAxOGMwYWU1LTRkOWItNDcxYi1iZmQ2LWVlZjMxNGJjNzAzNyJ9"
+	rfc7520Payload   = "SXTigJlzIGEgZGFuZ2Vyb3VzIGJ1c2luZXNzLCBGcm9kbywgZ29pbmcgb3V0IHlvdXIgZG9vci4gWW91IHN0ZXAgb250byB0aGUgcm9hZCwgYW5kIGlmIHlvdSBkb24ndCBrZWVwIHlvdXIgZmVldCwgdGhlcmXigJlzIG5vIGtub3dpbmcgd2hlcmUgeW91IG1pZ2h0IGJlIHN3ZXB0IG9mZiB0by4"
+	rfc7520Signature = "s0h6KThzkfBBBkLspW1h84VsJZFTsPPqMDA7g1Md7p0"
+	rfc7520Text      = "It’s a dangerous business, Frodo, going out your door. You step onto the road, and if you don't keep your feet, there’s no knowing where you might be swept off to."
+)
+
+func TestVerifyJWSKnownAnswer(t *testing.T) {
+	// RFC 7515, apêndice A.3: ES256 com a assinatura em r||s
+	es256 := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(b64url(t, "f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU")),
+		Y:     new(big.Int).SetBytes(b64url(t, "x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0")),
+	}
+	es256Token := "eyJhbGciOiJFUzI1NiJ9." +
+		"eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ." +
+		"DtEhU3ljbEg8L38VWAfUAqOyKAM6-Xx-F4GawxaepmXFCgfTjDxw5djxLa8ISlSApmWQxfKTUJqPP3-Kg6NU1Q"
+	claims := "{\"iss\":\"joe\",\r\n \"exp\":1300819380,\r\n \"http://example.com/is_root\":true}"
+
+	// RFC 7515, apêndice A.1: HS256
+	hs256Key := "AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"
+	hs256Token := "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9." +
+		"eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ." +
+		"dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	tests := []struct {
+		name  string
+		v     SignatureVerifier
+		token string
+		want  string
+	}{
+		{"RFC 7515 A.1 HS256", SignatureVerifier{Mode: VerifyJWS, Secret: b64url(t, hs256Key)}, hs256Token, claims},
+		{"RFC 7515 A.3 ES256", SignatureVerifier{Mode: VerifyJWS, Keys: []crypto.PublicKey{es256}}, es256Token, claims},
+		{"RFC 7520 4.4 HS256", SignatureVerifier{Mode: VerifyJWS, Alg: "HS256", Secret: b64url(t, rfc7520Key)}, rfc7520Protected + "." + rfc7520Payload + "." + rfc7520Signature, rfc7520Text},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.v.Verify(http.Header{}, []byte(tt.token+"\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Verify = %q; quer %q", got, tt.want)
+			}
+
+			parts := strings.Split(tt.token, ".")
+			sig := b64url(t, parts[2])
+			sig[len(sig)-1] ^= 1
+			parts[2] = base64.RawURLEncoding.EncodeToString(sig)
+			var se *SignatureError
+			if _, err := tt.v.Verify(http.Header{}, []byte(strings.Join(parts, "."))); !errors.As(err, &se) {
+				t.Errorf("Verify com assinatura alterada = %v; quer SignatureError", err)
+			}
+		})
+	}
+}
+
+func TestVerifyJWSDetachedKnownAnswer(t *testing.T) {
+	// RFC 7520, seção 4.5: o mesmo JWS da 4.4 com o payload destacado
+	v := SignatureVerifier{Mode: VerifyJWSDetached, Header: "X-JWS-Signature", Secret: b64url(t, rfc7520Key)}
+	header := http.Header{"X-Jws-Signature": {rfc7520Protected + ".." + rfc7520Signature}}
+
+	got, err := v.Verify(header, []byte(rfc7520Text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != rfc7520Text {
+		t.Errorf("Verify = %q; quer o body", got)
+	}
+
+	if _, err := v.Verify(header, []byte(rfc7520Text+" ")); err == nil {
+		t.Error("body alterado: quer erro")
+	}
+	attached := http.Header{"X-Jws-Signature": {rfc7520Protected + "." + rfc7520Payload + "." + rfc7520Signature}}
+	if _, err := v.Verify(attached, []byte(rfc7520Text)); err == nil {
+		t.Error("payload no token: quer erro")
+	}
+	if _, err := v.Verify(http.Header{}, []byte(rfc7520Text)); err == nil {
+		t.Error("header ausente: quer erro")
+	}
+}
+
+func TestVerifyJWSRejects(t *testing.T) {
+	secret := []byte("segredo")
+	sign := func(header, payload string) string {
+		signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name  string
+		alg   string
+		token string
+	}{
+		{"alg none", "", sign(`{"alg":"none"}`, "{}")},
+		{"sem alg", "", sign(`{}`, "{}")},
+		{"alg diferente do esperado", "HS512", sign(`{"alg":"HS256"}`, "{}")},
+		{"alg desconhecido", "", sign(`{"alg":"HS1"}`, "{}")},
+		{"partes de menos", "", "a.b"},
+		{"header não é JSON", "", sign(`alg`, "{}")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := SignatureVerifier{Mode: VerifyJWS, Alg: tt.alg, Secret: secret}
+			var se *SignatureError
+			if _, err := v.Verify(http.Header{}, []byte(tt.token)); !errors.As(err, &se) {
+				t.Errorf("Verify = %v; quer SignatureError", err)
+			}
+		})
+	}
+
+	v := SignatureVerifier{Mode: VerifyJWS, Secret: secret}
+	if got, err := v.Verify(http.Header{}, []byte(sign(`{"alg":"HS256"}`, `{"a":1}`))); err != nil || string(got) != `{"a":1}` {
+		t.Errorf("Verify = %q, %v; quer o payload", got, err)
+	}
+}
+
+func TestVerifyECDSAEncoding(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"ok":true}`)
+	digest := sha256.Sum256(body)
+	asn1Sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, 64)
+	r.FillBytes(raw[:32])
+	s.FillBytes(raw[32:])
+
+	v := SignatureVerifier{Mode: VerifySignature, Header: "X-Signature", Alg: "ES256", Keys: []crypto.PublicKey{&priv.PublicKey}}
+	for name, sig := range map[string][]byte{"r||s": raw, "ASN.1": asn1Sig} {
+		header := http.Header{"X-Signature": {base64.StdEncoding.EncodeToString(sig)}}
+		if _, err := v.Verify(header, body); err != nil {
+			t.Errorf("header com %s: %v", name, err)
+		}
+	}
+
+	// em JWS a RFC 7518 §3.4 exige r||s
+	protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`))
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	digest = sha256.Sum256([]byte(protected + "." + payload))
+	asn1Sig, _ = ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	jws := SignatureVerifier{Mode: VerifyJWS, Keys: v.Keys}
+	if _, err := jws.Verify(http.Header{}, []byte(protected+"."+payload+"."+base64.RawURLEncoding.EncodeToString(asn1Sig))); err == nil {
+		t.Error("JWS com assinatura ASN.1: quer erro")
+	}
+}
+
+func TestVerifySignatureHeader(t *testing.T) {
+	secret := []byte("segredo")
+	body := []byte(`{"id":1}`)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	tests := []struct {
+		name     string
+		encoding string
+		value    string
+		wantErr  bool
+	}{
+		{"hex com prefixo", "hex", "sha256=" + hex.EncodeToString(sum), false},
+		{"hex puro", "hex", hex.EncodeToString(sum), false},
+		{"base64", "", base64.StdEncoding.EncodeToString(sum), false},
+		{"base64url sem padding", "", base64.RawURLEncoding.EncodeToString(sum), false},
+		{"outro body", "hex", hex.EncodeToString(sum[:31]) + "00", true},
+		{"mal codificada", "hex", "zz", true},
+		{"ausente", "hex", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := SignatureVerifier{Mode: VerifySignature, Header: "X-Hub-Signature-256", Alg: "HS256", Encoding: tt.encoding, Secret: secret}
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("X-Hub-Signature-256", tt.value)
+			}
+			_, err := v.Verify(header, body)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("Verify = %v; quer erro %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyBody(t *testing.T) {
+	secret := []byte("segredo")
+	protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"a":1}`))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(protected + "." + payload))
+	token := protected + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	rl := &RateLimitClient{Verifier: &SignatureVerifier{Mode: VerifyJWS, Secret: secret}}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/jose"}},
+		Body:       io.NopCloser(strings.NewReader(token)),
+	}
+	if err := rl.verifyBody(resp); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if string(data) != `{"a":1}` || resp.ContentLength != 7 || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("body = %q, ContentLength = %d, Content-Type = %q", data, resp.ContentLength, resp.Header.Get("Content-Type"))
+	}
+
+	// respostas de erro passam sem conferência
+	resp = &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("falhou"))}
+	if err := rl.verifyBody(resp); err != nil {
+		t.Errorf("verifyBody em 500: %v", err)
+	}
+}
+
+func TestValidSignatureAlg(t *testing.T) {
+	for alg, want := range map[string]bool{
+		"HS256": true, "RS384": true, "PS512": true, "ES256": true, "EdDSA": true,
+		"none": false, "HS1": false, "ES257": false, "XS256": false, "RSA256": false, "": false,
+	} {
+		if got := ValidSignatureAlg(alg); got != want {
+			t.Errorf("ValidSignatureAlg(%q) = %v; quer %v", alg, got, want)
+		}
+	}
+}
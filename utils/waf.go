@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const WarningWAFChallenge = "waf_challenge"
+
+// WAFChallengeError é o desafio de WAF/anti-bot (captcha, JS challenge)
+// recebido no lugar da resposta. Não é retentado: tentativas seguidas só
+// estendem o bloqueio do IP. Until é o fim da pausa em que nenhuma
+// requisição sai para o mesmo provedor.
+type WAFChallengeError struct {
+	Status int
+	Vendor string
+	Until  time.Time
+}
+
+func (e *WAFChallengeError) Error() string {
+	return fmt.Sprintf("desafio de WAF (%s) com status %d: sem novas requisições até %s", e.Vendor, e.Status, e.Until.Format(time.RFC3339))
+}
+
+// wafCookies são os cookies de sessão anti-bot que cada fornecedor grava
+// junto com o desafio.
+var wafCookies = map[string]string{
+	"cf_clearance":  "cloudflare",
+	"__cf_bm":       "cloudflare",
+	"incap_ses":     "imperva",
+	"visid_incap":   "imperva",
+	"_abck":         "akamai",
+	"ak_bmsc":       "akamai",
+	"bm_sz":         "akamai",
+	"datadome":      "datadome",
+	"_px":           "perimeterx",
+	"aws-waf-token": "aws-waf",
+}
+
+// DetectWAFChallenge reconhece o 403 (ou qualquer status com
+// Cf-Mitigated: challenge) vindo de um WAF pelos headers e cookies do
+// fornecedor.
+func DetectWAFChallenge(resp *http.Response) (string, bool) {
+	h := resp.Header
+	if strings.EqualFold(h.Get("Cf-Mitigated"), "challenge") {
+		return "cloudflare", true
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		return "", false
+	}
+	return wafVendor(resp)
+}
+
+func wafVendor(resp *http.Response) (string, bool) {
+	h := resp.Header
+	switch {
+	case h.Get("X-Amzn-Waf-Action") != "":
+		return "aws-waf", true
+	case h.Get("X-Datadome") != "" || h.Get("X-Dd-B") != "":
+		return "datadome", true
+	case h.Get("X-Iinfo") != "" || h.Get("X-Cdn") == "Imperva":
+		return "imperva", true
+	case h.Get("X-Px-Block") != "":
+		return "perimeterx", true
+	}
+	for _, c := range resp.Cookies() {
+		for prefix, vendor := range wafCookies {
+			if strings.HasPrefix(c.Name, prefix) {
+				return vendor, true
+			}
+		}
+	}
+	switch server := strings.ToLower(h.Get("Server")); {
+	case server == "cloudflare" && h.Get("Cf-Ray") != "":
+		return "cloudflare", true
+	case strings.Contains(server, "akamaighost"):
+		return "akamai", true
+	}
+	return "", false
+}
+
+// challenged pausa o provedor (o pacer, compartilhado pelo grupo) por
+// WAFCooldown e avisa.
+func (rl *RateLimitClient) challenged(status int, vendor string) *WAFChallengeError {
+	err := &WAFChallengeError{Status: status, Vendor: vendor, Until: time.Now().Add(rl.WAFCooldown)}
+	p := rl.pacer()
+	p.mu.Lock()
+	if rl.WAFCooldown > 0 {
+		p.waf = err
+	}
+	p.mu.Unlock()
+	rl.Warnings.Add(WarningWAFChallenge, vendor, err.Error())
+	return err
+}
+
+// WAFBlocked devolve o desafio cuja pausa ainda vale.
+func (rl *RateLimitClient) WAFBlocked() *WAFChallengeError {
+	p := rl.pacer()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.waf == nil || !time.Now().Before(p.waf.Until) {
+		return nil
+	}
+	return p.waf
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	WarningSlowResponse = "slow_response"
+	WarningNearQuota    = "near_quota"
+	WarningDeprecation  = "deprecation"
+)
+
+// Warning é um problema não fatal visto na execução. Ocorrências repetidas
+// do mesmo tipo e chave viram uma só, com Count e a mensagem mais recente.
+type Warning struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// WarningLog junta os avisos de um job; seguro com nil.
+type WarningLog struct {
+	mu      sync.Mutex
+	order   []string
+	entries map[string]*Warning
+}
+
+func (l *WarningLog) Add(kind, key, message string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := kind + "|" + key
+	if w, ok := l.entries[id]; ok {
+		w.Count++
+		w.Message = message
+		return
+	}
+	if l.entries == nil {
+		l.entries = map[string]*Warning{}
+	}
+	l.entries[id] = &Warning{Kind: kind, Message: message, Count: 1}
+	l.order = append(l.order, id)
+}
+
+// Warnings devolve os avisos na ordem em que apareceram pela primeira vez.
+func (l *WarningLog) Warnings() []Warning {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]Warning, 0, len(l.order))
+	for _, id := range l.order {
+		out = append(out, *l.entries[id])
+	}
+	return out
+}
+
+// observeWarnings avisa sobre respostas lentas e sobre a cota informada
+// pelo servidor abaixo de NearQuota.
+func (rl *RateLimitClient) observeWarnings(req *http.Request, elapsed time.Duration) {
+	if rl.Warnings == nil {
+		return
+	}
+	if rl.SlowResponse > 0 && elapsed > rl.SlowResponse {
+		rl.Warnings.Add(WarningSlowResponse, req.URL.Host,
+			fmt.Sprintf("resposta de %s levou %v (limite %v)", req.URL.Host, elapsed.Round(time.Millisecond), rl.SlowResponse))
+	}
+
+	p := rl.pacer()
+	p.mu.Lock()
+	limit, remaining := p.Limit, p.Remaining
+	p.mu.Unlock()
+	if rl.NearQuota > 0 && limit > 0 && float64(remaining) <= rl.NearQuota*float64(limit) {
+		rl.Warnings.Add(WarningNearQuota, req.URL.Host,
+			fmt.Sprintf("cota de %s quase esgotada: %d de %d restantes", req.URL.Host, remaining, limit))
+	}
+}
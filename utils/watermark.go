@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// MaxWatermark devolve o maior valor do campo entre os registros, comparando
+// numericamente, como data RFC3339 ou lexicograficamente, nessa ordem.
+func MaxWatermark(records []any, field *JSONPath, current string) string {
+	best := current
+	for _, rec := range records {
+		v, ok := field.First(rec)
+		if !ok || v == nil {
+			continue
+		}
+		candidate := watermarkString(v)
+		if best == "" || compareWatermarks(candidate, best) > 0 {
+			best = candidate
+		}
+	}
+	return best
+}
+
+func watermarkString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func compareWatermarks(a, b string) int {
+	if fa, err := strconv.ParseFloat(a, 64); err == nil {
+		if fb, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case fa > fb:
+				return 1
+			case fa < fb:
+				return -1
+			}
+			return 0
+		}
+	}
+	if ta, err := time.Parse(time.RFC3339Nano, a); err == nil {
+		if tb, err := time.Parse(time.RFC3339Nano, b); err == nil {
+			return ta.Compare(tb)
+		}
+	}
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+type ExecutionWindow struct {
+	Windows  []TimeWindow
+	Location *time.Location
+
+	// Jitter atrasa a retomada por um tempo aleatório entre zero e Jitter,
+	// para que várias instâncias não voltem todas no mesmo segundo.
+	Jitter time.Duration
+}
+
+// ParseExecutionWindow aceita intervalos no formato "06:00-09:00,22:00-02:00";
+// intervalos que cruzam a meia-noite são permitidos.
+func ParseExecutionWindow(spec, tz string) (*ExecutionWindow, error) {
+	loc := time.Local
+	if tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("fuso horário inválido %q: %w", tz, err)
+		}
+	}
+
+	w := &ExecutionWindow{Location: loc}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("janela inválida %q: use HH:MM-HH:MM", part)
+		}
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		if start == end {
+			return nil, fmt.Errorf("janela inválida %q: início igual ao fim", part)
+		}
+		w.Windows = append(w.Windows, TimeWindow{Start: start, End: end})
+	}
+
+	if len(w.Windows) == 0 {
+		return nil, fmt.Errorf("nenhuma janela de execução informada")
+	}
+	return w, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("horário inválido %q: use HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	h, m, s := t.Clock()
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+}
+
+func (tw TimeWindow) contains(tod time.Duration) bool {
+	if tw.Start < tw.End {
+		return tod >= tw.Start && tod < tw.End
+	}
+	return tod >= tw.Start || tod < tw.End
+}
+
+func (w *ExecutionWindow) Allowed(t time.Time) bool {
+	tod := sinceMidnight(t.In(w.Location))
+	for _, tw := range w.Windows {
+		if tw.contains(tod) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *ExecutionWindow) NextOpen(t time.Time) time.Time {
+	if w.Allowed(t) {
+		return t
+	}
+
+	local := t.In(w.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.Location)
+
+	var next time.Time
+	for day := 0; day <= 1; day++ {
+		for _, tw := range w.Windows {
+			d := midnight.AddDate(0, 0, day)
+			start := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, w.Location).Add(tw.Start)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+func (w *ExecutionWindow) Wait(ctx context.Context) error {
+	now := time.Now()
+	if w.Allowed(now) {
+		return nil
+	}
+
+	next := w.NextOpen(now).Add(RandomJitter(w.Jitter))
+	fmt.Fprintf(Output, "Fora da janela de execução, aguardando até %s\n", next.Format(time.RFC3339))
+	return SleepContext(ctx, time.Until(next))
+}
+
+// RandomJitter sorteia uma espera entre zero e max.
+func RandomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max + 1)
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// WriteHook roda em volta da publicação de um arquivo de saída. Pre recebe
+// o arquivo já gravado no temporário (pode inspecioná-lo, ou reescrevê-lo
+// no lugar) antes do rename atômico para final; um erro impede a
+// publicação. Post recebe o arquivo já publicado. Qualquer um dos dois
+// pode ser nil.
+type WriteHook struct {
+	Pre  func(staged, final string) error
+	Post func(final string) error
+}
+
+var (
+	writeHooksMu sync.RWMutex
+	writeHooks   []namedWriteHook
+)
+
+type namedWriteHook struct {
+	name string
+	WriteHook
+}
+
+// RegisterWriteHook registra um hook de escrita (antivírus, conversão de
+// formato, notificação...); os hooks rodam na ordem de registro, e
+// registrar o mesmo nome duas vezes é erro de programação.
+func RegisterWriteHook(name string, hook WriteHook) {
+	writeHooksMu.Lock()
+	defer writeHooksMu.Unlock()
+
+	for _, h := range writeHooks {
+		if h.name == name {
+			panic(fmt.Sprintf("write hook %q registrado duas vezes", name))
+		}
+	}
+	writeHooks = append(writeHooks, namedWriteHook{name, hook})
+}
+
+// PreWrite roda os Pre registrados e para no primeiro erro.
+func PreWrite(staged, final string) error {
+	writeHooksMu.RLock()
+	defer writeHooksMu.RUnlock()
+
+	for _, h := range writeHooks {
+		if h.Pre == nil {
+			continue
+		}
+		if err := h.Pre(staged, final); err != nil {
+			return fmt.Errorf("%s: %w", h.name, err)
+		}
+	}
+	return nil
+}
+
+// PostWrite roda todos os Post registrados e junta os erros.
+func PostWrite(final string) error {
+	writeHooksMu.RLock()
+	defer writeHooksMu.RUnlock()
+
+	var errs []error
+	for _, h := range writeHooks {
+		if h.Post == nil {
+			continue
+		}
+		if err := h.Post(final); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// estilos de xlsxStyles (cellXfs)
+const (
+	xlsxStyleHeader   = 1
+	xlsxStyleInteger  = 2
+	xlsxStyleDecimal  = 3
+	xlsxStyleDate     = 4
+	xlsxStyleDateTime = 5
+)
+
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// WriteXLSX grava uma planilha com cabeçalho em negrito fixado, autofiltro,
+// larguras estimadas, números formatados e datas ISO convertidas em datas
+// do Excel.
+func WriteXLSX(w io.Writer, sheet string, cols []Column, records []any) error {
+	sheet = xlsxSheetName(sheet)
+	dateCols := xlsxDateColumns(cols, records)
+
+	var rows bytes.Buffer
+	widths := make([]int, len(cols))
+
+	rows.WriteString(`<row r="1">`)
+	for i, col := range cols {
+		xlsxInlineString(&rows, xlsxCellRef(i, 1), col.Name, xlsxStyleHeader)
+		widths[i] = utf8.RuneCountInString(col.Name)
+	}
+	rows.WriteString(`</row>`)
+
+	for r, rec := range records {
+		obj, ok := rec.(map[string]any)
+		if !ok {
+			return fmt.Errorf("registro %d não é um objeto", r)
+		}
+
+		row := r + 2
+		fmt.Fprintf(&rows, `<row r="%d">`, row)
+		for i, col := range cols {
+			v, ok := obj[col.Name]
+			if !ok || v == nil {
+				continue
+			}
+			ref := xlsxCellRef(i, row)
+			text := ""
+
+			switch val := v.(type) {
+			case float64:
+				style := xlsxStyleDecimal
+				if col.Type == "int64" {
+					style = xlsxStyleInteger
+				}
+				text = strconv.FormatFloat(val, 'f', -1, 64)
+				fmt.Fprintf(&rows, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, text)
+			case bool:
+				b := "0"
+				if val {
+					b = "1"
+				}
+				text = strconv.FormatBool(val)
+				fmt.Fprintf(&rows, `<c r="%s" t="b"><v>%s</v></c>`, ref, b)
+			case string:
+				text = val
+				if t, dateOnly, ok := parseSheetDate(val); dateCols[i] && ok {
+					style := xlsxStyleDateTime
+					if dateOnly {
+						style = xlsxStyleDate
+					}
+					fmt.Fprintf(&rows, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(excelSerial(t), 'f', -1, 64))
+				} else {
+					xlsxInlineString(&rows, ref, val, 0)
+				}
+			default:
+				data, _ := json.Marshal(val)
+				text = string(data)
+				xlsxInlineString(&rows, ref, text, 0)
+			}
+
+			widths[i] = max(widths[i], utf8.RuneCountInString(text))
+		}
+		rows.WriteString(`</row>`)
+	}
+
+	var sheetXML bytes.Buffer
+	sheetXML.WriteString(xml.Header)
+	sheetXML.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	sheetXML.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	if len(cols) > 0 {
+		sheetXML.WriteString(`<cols>`)
+		for i, width := range widths {
+			fmt.Fprintf(&sheetXML, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, min(width+3, 60))
+		}
+		sheetXML.WriteString(`</cols>`)
+	}
+	sheetXML.WriteString(`<sheetData>`)
+	sheetXML.Write(rows.Bytes())
+	sheetXML.WriteString(`</sheetData>`)
+	if len(cols) > 0 {
+		fmt.Fprintf(&sheetXML, `<autoFilter ref="A1:%s"/>`, xlsxCellRef(len(cols)-1, len(records)+1))
+	}
+	sheetXML.WriteString(`</worksheet>`)
+
+	var sheetName bytes.Buffer
+	xml.EscapeText(&sheetName, []byte(sheet))
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + sheetName.String() + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`<definedNames><definedName name="_xlnm._FilterDatabase" localSheetId="0" hidden="1">'` + strings.ReplaceAll(sheetName.String(), "'", "''") + `'!$A$1:$` + xlsxColumnName(max(len(cols)-1, 0)) + `$` + strconv.Itoa(len(records)+1) + `</definedName></definedNames>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/worksheets/sheet1.xml", sheetXML.String()},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/><bgColor indexed="64"/></patternFill></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="6">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>` +
+	`<xf numFmtId="1" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+func xlsxInlineString(buf *bytes.Buffer, ref, text string, style int) {
+	if style > 0 {
+		fmt.Fprintf(buf, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+	} else {
+		fmt.Fprintf(buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+	}
+	xml.EscapeText(buf, []byte(text))
+	buf.WriteString(`</t></is></c>`)
+}
+
+// xlsxDateColumns marca as colunas de texto em que todo valor presente é
+// uma data ISO; só essas viram datas do Excel.
+func xlsxDateColumns(cols []Column, records []any) []bool {
+	out := make([]bool, len(cols))
+	for i, col := range cols {
+		if col.Type != "string" {
+			continue
+		}
+
+		seen := false
+		out[i] = true
+		for _, rec := range records {
+			obj, _ := rec.(map[string]any)
+			s, ok := obj[col.Name].(string)
+			if !ok {
+				continue
+			}
+			if _, _, ok := parseSheetDate(s); !ok {
+				out[i] = false
+				break
+			}
+			seen = true
+		}
+		out[i] = out[i] && seen
+	}
+	return out
+}
+
+func parseSheetDate(s string) (time.Time, bool, bool) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true, true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, false, true
+	}
+	return time.Time{}, false, false
+}
+
+// excelSerial converte usando o relógio do próprio valor (o Excel não tem
+// fuso horário).
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Sub(excelEpoch).Hours() / 24
+}
+
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xlsxCellRef(col, row int) string {
+	return xlsxColumnName(col) + strconv.Itoa(row)
+}
+
+// xlsxSheetName aplica as restrições do Excel: até 31 caracteres e sem
+// []:*?/\.
+func xlsxSheetName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		s = "Dados"
+	}
+	if utf8.RuneCountInString(s) > 31 {
+		s = string([]rune(s)[:31])
+	}
+	return s
+}
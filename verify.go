@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"apiconsume/utils"
+)
+
+// VerifyConfig confere a assinatura do provedor em cada resposta 2xx e
+// falha o job quando ela não confere, antes de gravar qualquer coisa. keys
+// são PEMs de chaves públicas ou certificados (vale qualquer uma, para
+// rotação); secret é a chave dos algoritmos HS* e aceita templates.
+type VerifyConfig struct {
+	Type     string   `json:"type"`
+	Header   string   `json:"header,omitempty"`
+	Alg      string   `json:"alg,omitempty"`
+	Encoding string   `json:"encoding,omitempty"`
+	Keys     []string `json:"keys,omitempty"`
+	Secret   string   `json:"secret,omitempty"`
+}
+
+func (c *VerifyConfig) verifier(baseDir string) (*utils.SignatureVerifier, error) {
+	switch c.Type {
+	case utils.VerifyJWS:
+	case utils.VerifyJWSDetached, utils.VerifySignature:
+		if c.Header == "" {
+			return nil, fmt.Errorf("%s exige header", c.Type)
+		}
+	default:
+		return nil, fmt.Errorf("type deve ser jws, jws_detached ou signature")
+	}
+	switch {
+	case c.Type == utils.VerifySignature && c.Alg == "":
+		return nil, fmt.Errorf("signature exige alg")
+	case c.Alg != "" && !utils.ValidSignatureAlg(c.Alg):
+		return nil, fmt.Errorf("alg %q não suportado (HS*, RS*, PS*, ES* ou EdDSA)", c.Alg)
+	case c.Encoding != "" && c.Encoding != "base64" && c.Encoding != "hex":
+		return nil, fmt.Errorf("encoding deve ser base64 ou hex")
+	case len(c.Keys) == 0 && c.Secret == "":
+		return nil, fmt.Errorf("informe keys e/ou secret")
+	}
+
+	v := &utils.SignatureVerifier{Mode: c.Type, Header: c.Header, Alg: c.Alg, Encoding: c.Encoding}
+	for _, path := range c.Keys {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := utils.ParsePublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		v.Keys = append(v.Keys, keys...)
+	}
+	if c.Secret != "" {
+		secret, err := renderTemplate(c.Secret, templateVars{})
+		if err != nil {
+			return nil, err
+		}
+		v.Secret = []byte(secret)
+	}
+	return v, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sync"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var wafCooldown = flag.Duration("waf-cooldown", 2*time.Hour, "pausa do provedor depois de um desafio de WAF/captcha, também entre execuções (0 = só falha, sem pausa)")
+
+// wafState guarda, por provider (ver usageKey), a pausa do último desafio
+// de WAF, para que as próximas execuções não insistam no IP bloqueado.
+type wafState struct {
+	Providers map[string]wafCooldownEntry `json:"providers"`
+}
+
+type wafCooldownEntry struct {
+	Until  time.Time `json:"until"`
+	Vendor string    `json:"vendor"`
+	Status int       `json:"status"`
+	Job    string    `json:"job"`
+}
+
+var wafMu sync.Mutex
+
+func loadWAFState(job JobConfig) wafState {
+	var st wafState
+	if _, err := stateStore.Load("waf", &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+	if st.Providers == nil {
+		st.Providers = map[string]wafCooldownEntry{}
+	}
+	return st
+}
+
+// wafCooldownFor devolve a pausa em vigor para o provider do job.
+func wafCooldownFor(job JobConfig, now time.Time) *utils.WAFChallengeError {
+	wafMu.Lock()
+	defer wafMu.Unlock()
+
+	e, ok := loadWAFState(job).Providers[job.usageKey()]
+	if !ok || !now.Before(e.Until) {
+		return nil
+	}
+	return &utils.WAFChallengeError{Status: e.Status, Vendor: e.Vendor, Until: e.Until}
+}
+
+// recordWAFCooldown grava a pausa do desafio recebido pelo job em
+// -state-dir/waf.json.
+func recordWAFCooldown(job JobConfig, rl *utils.RateLimitClient) {
+	blocked := rl.WAFBlocked()
+	if blocked == nil {
+		return
+	}
+
+	wafMu.Lock()
+	defer wafMu.Unlock()
+	defer lockState("waf")()
+
+	st := loadWAFState(job)
+	if e, ok := st.Providers[job.usageKey()]; ok && !e.Until.Before(blocked.Until) {
+		return
+	}
+	st.Providers[job.usageKey()] = wafCooldownEntry{Until: blocked.Until, Vendor: blocked.Vendor, Status: blocked.Status, Job: job.Name}
+	if err := stateStore.Save("waf", st); err != nil {
+		log.Printf("[%s] Erro ao salvar pausa de WAF: %v", job.Name, err)
+	}
+}
+
+func skipWAFCooldown(job JobConfig, res *jobResult, blocked *utils.WAFChallengeError) {
+	log.Printf("[%s] Job ignorado: desafio de WAF (%s) em %s, pausa até %s", job.Name, blocked.Vendor, job.usageKey(), blocked.Until.Format(time.RFC3339))
+	res.Skipped = true
+	res.Error = "ignorado: pausa de WAF até " + blocked.Until.Format(time.RFC3339)
+}
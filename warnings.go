@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	warnSlow        = flag.Duration("warn-slow", 30*time.Second, "aviso quando uma tentativa demora mais que isso para responder (0 = desativado)")
+	warnQuota       = flag.Float64("warn-quota", 0.1, "aviso quando a cota restante informada pela API cai abaixo desta fração do limite (0 = desativado)")
+	warnGrowth      = flag.Float64("warn-growth", 3, "aviso quando a saída do job cresce este número de vezes sobre a execução anterior (0 = desativado)")
+	warningsWebhook = flag.String("warnings-webhook", "", "URL que recebe um POST JSON com os avisos da execução de -config, quando houver")
+)
+
+const (
+	warningPayloadGrowth = "payload_growth"
+
+	warningsAlertTimeout = 10 * time.Second
+)
+
+// runWarning é um aviso do resumo da execução, separado das falhas.
+type runWarning struct {
+	Job string `json:"job"`
+	utils.Warning
+}
+
+type payloadState struct {
+	Bytes int `json:"bytes"`
+}
+
+// jobWarnings junta os avisos do cliente e os de descontinuação do job.
+func jobWarnings(rl *utils.RateLimitClient) []utils.Warning {
+	if rl.Deprecations != nil {
+		for _, notice := range rl.Deprecations.Notices() {
+			rl.Warnings.Add(utils.WarningDeprecation, notice.Key(), notice.String())
+		}
+	}
+	return rl.Warnings.Warnings()
+}
+
+// checkGrowth avisa quando a saída cresceu -warn-growth vezes sobre a
+// anterior; o tamanho novo só é gravado pelo commit, depois da publicação.
+func checkGrowth(job JobConfig, out []byte, warnings *utils.WarningLog) func() {
+	stateName := "payload-" + job.Name
+	var st payloadState
+	if _, err := stateStore.Load(stateName, &st); err != nil {
+		log.Printf("[%s] %v", job.Name, err)
+	}
+	if *warnGrowth > 0 && st.Bytes > 0 && float64(len(out)) >= *warnGrowth*float64(st.Bytes) {
+		msg := fmt.Sprintf("saída cresceu de %d para %d bytes (%.1fx)", st.Bytes, len(out), float64(len(out))/float64(st.Bytes))
+		log.Printf("[%s] AVISO: %s", job.Name, msg)
+		warnings.Add(warningPayloadGrowth, "", msg)
+	}
+	return func() {
+		if err := stateStore.Save(stateName, payloadState{Bytes: len(out)}); err != nil {
+			log.Printf("[%s] Erro ao salvar tamanho da saída: %v", job.Name, err)
+		}
+	}
+}
+
+func runWarnings(results []jobResult) []runWarning {
+	var out []runWarning
+	for _, r := range results {
+		for _, w := range r.warnings {
+			out = append(out, runWarning{Job: r.Job, Warning: w})
+		}
+	}
+	return out
+}
+
+func notifyWarnings(ctx context.Context, runID string, warnings []runWarning) {
+	if *warningsWebhook == "" || len(warnings) == 0 {
+		return
+	}
+	payload := struct {
+		RunID    string       `json:"run_id"`
+		Warnings []runWarning `json:"warnings"`
+	}{runID, warnings}
+	if err := postNotice(ctx, *warningsWebhook, payload, warningsAlertTimeout); err != nil {
+		log.Printf("Erro ao notificar avisos via webhook: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"apiconsume/utils"
+)
+
+type WatermarkConfig struct {
+	Field   string `json:"field"`
+	Records string `json:"records,omitempty"`
+	Initial string `json:"initial,omitempty"`
+}
+
+type watermarkState struct {
+	Value string `json:"value"`
+}
+
+func (c *WatermarkConfig) validate() error {
+	if c.Field == "" {
+		return fmt.Errorf("watermark.field é obrigatório")
+	}
+	if _, err := utils.ParseJSONPath(c.Field); err != nil {
+		return err
+	}
+	if c.Records != "" {
+		if _, err := utils.ParseJSONPath(c.Records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadWatermark(job JobConfig) (string, error) {
+	var st watermarkState
+	ok, err := stateStore.Load("watermark-"+job.Name, &st)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return job.Watermark.Initial, nil
+	}
+	return st.Value, nil
+}
+
+// advanceWatermark calcula o novo watermark a partir dos registros e devolve
+// a função que o persiste, chamada só depois da gravação da saída.
+func advanceWatermark(job JobConfig, body []byte, current string) (func(), error) {
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("resposta não é JSON válido: %w", err)
+	}
+
+	field, _ := utils.ParseJSONPath(job.Watermark.Field)
+	records := utils.SelectRecords(doc, job.Watermark.Records)
+	next := utils.MaxWatermark(records, field, current)
+
+	return func() {
+		if next == current {
+			return
+		}
+		if err := stateStore.Save("watermark-"+job.Name, watermarkState{Value: next}); err != nil {
+			log.Printf("[%s] Erro ao salvar watermark: %v", job.Name, err)
+			return
+		}
+		log.Printf("[%s] Watermark avançado de %q para %q", job.Name, current, next)
+	}, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"apiconsume/utils"
+)
+
+var (
+	preWriteCommand  = flag.String("pre-write-command", "", "comando rodado com o arquivo de saída ainda no temporário, antes de publicá-lo (antivírus, conversão...); sair com erro impede a publicação")
+	postWriteCommand = flag.String("post-write-command", "", "comando rodado com o arquivo de saída já publicado (notificação...)")
+)
+
+// registerWriteCommands registra -pre-write-command e -post-write-command
+// como hooks de escrita, depois dos registrados pela biblioteca.
+func registerWriteCommands() {
+	if *preWriteCommand == "" && *postWriteCommand == "" {
+		return
+	}
+	var hook utils.WriteHook
+	if *preWriteCommand != "" {
+		hook.Pre = func(staged, final string) error {
+			return runWriteCommand(*preWriteCommand, staged, "STAGED_FILE="+staged, "FINAL_FILE="+final)
+		}
+	}
+	if *postWriteCommand != "" {
+		hook.Post = func(final string) error {
+			return runWriteCommand(*postWriteCommand, final, "FINAL_FILE="+final)
+		}
+	}
+	utils.RegisterWriteHook("write-command", hook)
+}
+
+// runWriteCommand roda o comando com o arquivo como último argumento.
+func runWriteCommand(command, path string, env ...string) error {
+	args := append(strings.Fields(command), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
+
+// publishFile troca o arquivo temporário pela saída final passando pelos
+// hooks de escrita: os Pre podem recusar a publicação (o temporário é
+// descartado e o arquivo anterior fica como estava); a falha de um Post só
+// vai para o log.
+func publishFile(staged, final string) error {
+	if err := utils.PreWrite(staged, final); err != nil {
+		return fmt.Errorf("publicação de %s recusada pelo hook: %w", final, err)
+	}
+	backupOutput(final, time.Now())
+	if err := utils.MoveFile(staged, final); err != nil {
+		return err
+	}
+	if err := utils.PostWrite(final); err != nil {
+		log.Printf("Erro no hook pós-escrita de %s: %v", final, err)
+	}
+	return nil
+}
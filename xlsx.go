@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"apiconsume/utils"
+)
+
+func init() {
+	sinkTypes["xlsx"] = newXLSXSink
+}
+
+// xlsxSink exporta os registros para uma planilha Excel, para quem consome
+// a coleta diretamente no Excel.
+type xlsxSink struct {
+	Records string `json:"records"`
+	Schema  string `json:"schema"`
+	Path    string `json:"path"`
+	Sheet   string `json:"sheet"`
+
+	columns []utils.Column
+}
+
+func newXLSXSink(raw json.RawMessage, baseDir string) (sink, error) {
+	s := &xlsxSink{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if s.columns, err = loadSchemaColumns(s.Schema, baseDir); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *xlsxSink) Deliver(ctx context.Context, out sinkOutput) error {
+	records, columns, err := tabularRecords(out.Data, s.Records, s.columns)
+	if err != nil {
+		return err
+	}
+
+	sheet := s.Sheet
+	if sheet == "" {
+		sheet = out.Job.Name
+	}
+
+	var buf bytes.Buffer
+	if err := utils.WriteXLSX(&buf, sheet, columns, records); err != nil {
+		return err
+	}
+
+	writeFile(sinkPath(s.Path, out.Path, ".xlsx"), buf.Bytes())
+	return nil
+}